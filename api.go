@@ -7,4 +7,44 @@ const (
 	CrFieldPathAnnotation  = Domain + "/CrReadyWhenFieldPath"
 	CrFieldValueAnnotation = Domain + "/CrReadyWhenFieldValue"
 	CrdSupportEnabled      = Domain + "/SupportEnabled"
+
+	// ReadinessPathAnnotation is set on an object to an expression over JsonPaths of its fields that must be
+	// true for the object to be considered ready. It takes precedence over all other readiness checks.
+	ReadinessPathAnnotation = Domain + "/readinessPath"
+
+	// DependenciesHashAnnotation is set on pod templates to a combined hash of content of dependencies
+	// referenced with the "hash" modifier.
+	DependenciesHashAnnotation = Domain + "/dependenciesHash"
+
+	// ForceReadyAnnotation is set on a Bundle to a comma-separated list of resource names that should be
+	// considered ready regardless of the result of the readiness check. It is an emergency manual override.
+	ForceReadyAnnotation = Domain + "/forceReady"
+
+	// DeletionProtectionAnnotation is set on a Bundle to "true" to prevent deletion of its resources
+	// when the Bundle is deleted. Resources are deleted once the annotation is removed.
+	DeletionProtectionAnnotation = Domain + "/deletionProtection"
+
+	// DeletePolicyAnnotation is set on objects of resources with a delete policy so that the policy
+	// is known when the object is deleted after its resource has been removed from the Bundle.
+	DeletePolicyAnnotation = Domain + "/deletePolicy"
+
+	// IgnorePathsAnnotation is set on objects of resources with ignored paths to a JSON list of the paths.
+	// Ignored fields are not compared with the spec and are never updated.
+	IgnorePathsAnnotation = Domain + "/ignorePaths"
+
+	// DeleteGracePeriodAnnotation is set on objects of resources with a delete grace period so that
+	// the grace period is known when the object is deleted after its resource has been removed from the Bundle.
+	DeleteGracePeriodAnnotation = Domain + "/deleteGracePeriodSeconds"
+
+	// PluginResourceAnnotation is set on additional objects produced by a plugin resource to the name of
+	// the resource. Such objects are not deleted while the resource is in the Bundle.
+	PluginResourceAnnotation = Domain + "/pluginResource"
+
+	// ForceResyncAnnotation is set on a Bundle to an arbitrary value. When the value changes, all resources of
+	// the Bundle are processed once even if they are known to be ready and unchanged.
+	ForceResyncAnnotation = Domain + "/forceResync"
+
+	// TraceParentAnnotation is set on a Bundle to a W3C trace context traceparent by the system that manages it.
+	// Traces of processing of the Bundle are then part of that system's trace.
+	TraceParentAnnotation = Domain + "/traceparent"
 )
@@ -2,6 +2,8 @@ package app
 
 import (
 	"flag"
+	go_runtime "runtime"
+	"strings"
 	"time"
 
 	"github.com/atlassian/ctrl"
@@ -28,6 +30,7 @@ import (
 	apiExtClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apiext_v1b1inf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
@@ -36,25 +39,195 @@ import (
 	core_v1inf "k8s.io/client-go/informers/core/v1"
 	ext_v1b1inf "k8s.io/client-go/informers/extensions/v1beta1"
 	"k8s.io/client-go/kubernetes"
+	core_v1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	core_v1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 type BundleControllerConstructor struct {
-	Plugins               []plugin.NewFunc
-	ServiceCatalogSupport bool
+	Plugins                   []plugin.NewFunc
+	ServiceCatalogSupport     bool
+	BlockInUseDeletion        bool
+	ValidateObjectNamespace   bool
+	AllowClusterScopedObjects bool
+	DefaultReadyFieldPath     string
+	DefaultReadyFieldValue    string
+	ErrorHoldTime             time.Duration
+	RetryBaseDelay            time.Duration
+	RetryMaxDelay             time.Duration
+	BlockedRequeueDelay       time.Duration
+	RequeueJitter             float64
+	MaxResources              int
+	ValidateCrdSchema         bool
+	UnresolvableGvkPolicy     string
+	ConflictRetries           int
+	ContinueOnConflict        bool
+	MaxConcurrentResources    int
+	MaxConcurrentDeletions    int
+	ConditionHistorySize      int
+	OldObjectDeletionTimeout  time.Duration
+	DeletionBatchSize         int
+	ForceDeletion             bool
+	PanicBudget               int
+	PanicBudgetWindow         time.Duration
+	FullReconcilePeriod       time.Duration
+	SkipUnchangedResources    bool
+	QuotaCheck                bool
+	// FieldManager identifies Smith with every write of objects of resources. The client libraries cannot pass
+	// a field manager explicitly, so it is sent as the user agent of the client that makes the write and
+	// the API server derives the field manager from it.
+	FieldManager string
+	PatchUpdates bool
+	// DebugListenOn is the address to serve debug endpoints on. Empty to disable.
+	DebugListenOn string
+	// AdmissionListenOn is the address to serve the validating admission webhook on. Empty to disable.
+	AdmissionListenOn string
+	AdmissionTLSCert  string
+	AdmissionTLSKey   string
+	// CompletionWebhookURL is the URL to POST Bundle completion notifications to. Empty to disable.
+	CompletionWebhookURL        string
+	CompletionWebhookRetries    int
+	CompletionWebhookRetryDelay time.Duration
+	// TracingOTLPEndpoint is the URL of an OTLP/HTTP endpoint to export spans to. Empty to disable.
+	TracingOTLPEndpoint string
+	// TracingSamplingRatio is the fraction of processing iterations that are traced.
+	TracingSamplingRatio float64
+	// WatchThrottle is a comma-separated list of Kind.group=duration pairs.
+	WatchThrottle string
+	PluginTimeout time.Duration
+	// PluginTimeouts is a comma-separated list of plugin=duration pairs.
+	PluginTimeouts string
+	// See bundlec.Controller for description of these fields.
+	LargeBundleResources      int
+	MaxConcurrentLargeBundles int
 
 	// To override things constructed by default. And for tests.
 	SmithClient  smithClientset.Interface
 	ScClient     scClientset.Interface
 	ApiExtClient apiExtClientset.Interface
 	SmartClient  bundlec.SmartClient
+	Recorder     record.EventRecorder
+
+	// ErrorMessageTransformer is used to transform errors into user-friendly messages for conditions. Optional.
+	ErrorMessageTransformer bundlec.ErrorMessageTransformer
+	// Tracer is used to trace processing of Bundles. Optional. Overrides TracingOTLPEndpoint.
+	Tracer bundlec.Tracer
 }
 
 func (c *BundleControllerConstructor) AddFlags(flagset *flag.FlagSet) {
 	flagset.BoolVar(&c.ServiceCatalogSupport, "bundle-service-catalog", true, "Service Catalog support in Bundle controller. Enabled by default.")
+	flagset.StringVar(&c.DefaultReadyFieldPath, "bundle-default-ready-field-path", "", "Default JsonPath of a field that indicates readiness of a Custom Resource. Used if CRD and Bundle do not specify one.")
+	flagset.StringVar(&c.DefaultReadyFieldValue, "bundle-default-ready-field-value", "", "Default value of the field that indicates readiness of a Custom Resource. Used if CRD and Bundle do not specify one.")
+	flagset.BoolVar(&c.BlockInUseDeletion, "bundle-block-in-use-deletion", false, "Do not delete objects removed from a Bundle while they are referenced by objects in the Bundle or their resources are depended on by resources in the Bundle.")
+	flagset.BoolVar(&c.ValidateObjectNamespace, "bundle-validate-object-namespace", false, "Fail resources which objects specify a namespace other than the namespace of the Bundle or are cluster-scoped. Such objects cannot be garbage collected. Disabled by default.")
+	flagset.BoolVar(&c.AllowClusterScopedObjects, "bundle-allow-cluster-scoped-objects", false, "Allow cluster-scoped objects when -bundle-validate-object-namespace is enabled.")
+	flagset.BoolVar(&c.ValidateCrdSchema, "bundle-validate-crd-schema", false, "Validate Custom Resources against OpenAPI schemas of their CRDs before creating/updating them. Disabled by default.")
+	flagset.StringVar(&c.UnresolvableGvkPolicy, "bundle-unresolvable-gvk-policy", string(bundlec.UnresolvableGvkPolicyBlock), `What to do with objects that cannot be deleted because a client for them cannot be obtained (e.g. CRD was removed). "block" blocks deletion, "skip" leaves them orphaned.`)
+	flagset.IntVar(&c.MaxResources, "bundle-max-resources", 1000, "Maximum number of resources in a Bundle. Bundles with more resources fail without being processed. Zero means no limit.")
+	flagset.IntVar(&c.LargeBundleResources, "bundle-large-bundle-resources", 0, "Number of resources starting from which a Bundle is considered large. Zero disables the limit on concurrently processed large Bundles.")
+	flagset.IntVar(&c.MaxConcurrentLargeBundles, "bundle-max-concurrent-large-bundles", 1, "Maximum number of large Bundles processed concurrently, so that they do not starve small Bundles.")
+	flagset.StringVar(&c.WatchThrottle, "bundle-watch-throttle", "", `Comma-separated list of Kind.group=duration pairs. Events for objects of a listed kind are coalesced within the window before the owning Bundle is enqueued, e.g. "ServiceInstance.servicecatalog.k8s.io=10s". Use "Kind" for the core group.`)
+	flagset.IntVar(&c.ConflictRetries, "bundle-conflict-retries", 3, "Number of times processing of a resource is retried on conflict before the Bundle is requeued. Zero requeues the Bundle on the first conflict.")
+	flagset.BoolVar(&c.ContinueOnConflict, "bundle-continue-on-conflict", false, "Fail only the conflicting resource, with a retriable error, when conflict retries run out instead of short-circuiting processing of the Bundle. Other resources are still processed and the Bundle is requeued afterwards.")
+	flagset.IntVar(&c.MaxConcurrentResources, "bundle-max-concurrent-resources", go_runtime.GOMAXPROCS(0), "Maximum number of resources of a Bundle that do not depend on each other processed concurrently. Defaults to GOMAXPROCS.")
+	flagset.IntVar(&c.ConditionHistorySize, "bundle-condition-history-size", 0, "Number of most recent transitions recorded in each condition of a Bundle. Zero disables the history.")
+	flagset.IntVar(&c.MaxConcurrentDeletions, "bundle-max-concurrent-deletions", 10, "Maximum number of objects of a Bundle deleted concurrently.")
+	flagset.DurationVar(&c.OldObjectDeletionTimeout, "bundle-old-object-deletion-timeout", 5*time.Minute, "How long to wait for an object that is being deleted to be gone before it is created again. Zero means no timeout.")
+	flagset.StringVar(&c.FieldManager, "bundle-field-manager", "smith", "Field manager name Smith identifies itself with when it writes objects of resources. Instances managing the same objects should use distinct names.")
+	flagset.BoolVar(&c.PatchUpdates, "bundle-patch-updates", false, "Patch existing objects of resources with the Update strategy with fields set in the Bundle instead of replacing them, so that fields written by other controllers are preserved. Objects of each Bundle are written with their own field manager derived from the field manager name and the Bundle.")
+	flagset.StringVar(&c.DebugListenOn, "bundle-debug-listen-on", "", `Address to serve read-only debug endpoints on, e.g. a table of resource conditions at "`+bundlec.ConditionsPath+`?namespace=ns&name=bundle". Empty to disable.`)
+	flagset.StringVar(&c.AdmissionListenOn, "bundle-admission-listen-on", "", `Address to serve the validating admission webhook for Bundles on, at "`+bundlec.AdmissionPath+`". Bundles that would fail because of duplicate resources, unknown dependencies, dependency cycles or unknown plugins are rejected. Empty to disable.`)
+	flagset.StringVar(&c.AdmissionTLSCert, "bundle-admission-tls-cert", "", "File with the TLS certificate of the validating admission webhook. Required if the webhook is enabled.")
+	flagset.StringVar(&c.AdmissionTLSKey, "bundle-admission-tls-key", "", "File with the TLS private key of the validating admission webhook. Required if the webhook is enabled.")
+	flagset.BoolVar(&c.ForceDeletion, "bundle-force-deletion", false, "When a Bundle is deleted, also delete objects that were deleted and re-created out-of-band if the Bundle still controls them, instead of skipping them.")
+	flagset.IntVar(&c.DeletionBatchSize, "bundle-deletion-batch-size", 0, "Maximum number of objects deleted per iteration when a Bundle is deleted. If set, the Bundle's finalizer is only removed once all objects are gone. Zero deletes all objects at once.")
+	flagset.IntVar(&c.PanicBudget, "bundle-panic-budget", 10, "Number of panics during processing of Bundles tolerated within the panic budget window before the process is terminated. Zero disables termination.")
+	flagset.DurationVar(&c.PanicBudgetWindow, "bundle-panic-budget-window", 10*time.Minute, "Window within which panics are counted against the panic budget.")
+	flagset.DurationVar(&c.FullReconcilePeriod, "bundle-full-reconcile-period", 0, "Enables selective reconcile: when an object changes, only its resource and resources that depend on it are processed. All resources of a Bundle are processed at least once per this period. Zero disables selective reconcile.")
+	flagset.BoolVar(&c.QuotaCheck, "bundle-quota-check", false, "Watch ResourceQuotas so that Bundles with quotaCheck set fail before creating objects if pods of their workloads do not fit into the quotas. Requires list/watch access to ResourceQuotas.")
+	flagset.BoolVar(&c.SkipUnchangedResources, "bundle-skip-unchanged-resources", false, "Skip processing of resources that are ready and which definition, object and objects of dependencies have not changed since they were last processed.")
+	flagset.StringVar(&c.CompletionWebhookURL, "bundle-completion-webhook-url", "", "URL to POST a JSON notification to when a Bundle becomes Ready or fails with a non-retriable error for its current generation. Empty to disable.")
+	flagset.IntVar(&c.CompletionWebhookRetries, "bundle-completion-webhook-retries", 5, "Number of times delivery of a completion notification is retried.")
+	flagset.DurationVar(&c.CompletionWebhookRetryDelay, "bundle-completion-webhook-retry-delay", 5*time.Second, "Delay before the first retry of delivery of a completion notification. The delay doubles with each retry, up to a minute.")
+	flagset.StringVar(&c.TracingOTLPEndpoint, "bundle-tracing-otlp-endpoint", "", `URL of an OTLP/HTTP endpoint to export spans of Bundle processing to, e.g. "http://otel-collector:4318/v1/traces". Empty to disable.`)
+	flagset.Float64Var(&c.TracingSamplingRatio, "bundle-tracing-sampling-ratio", 1, "Fraction of Bundle processing iterations that are traced, between 0 and 1.")
+	flagset.DurationVar(&c.ErrorHoldTime, "bundle-error-hold-time", 0, "How long Error conditions are held after the error has cleared, to avoid flapping on transient errors. Disabled by default.")
+	flagset.DurationVar(&c.RetryBaseDelay, "bundle-retry-base-delay", time.Second, "Delay before a resource is retried after its first retriable error. The delay doubles with each consecutive retriable error. Zero disables backoff.")
+	flagset.DurationVar(&c.RetryMaxDelay, "bundle-retry-max-delay", 5*time.Minute, "Maximum delay before a resource is retried after a retriable error.")
+	flagset.DurationVar(&c.BlockedRequeueDelay, "bundle-blocked-requeue-delay", 5*time.Minute, "Delay after which a Bundle with resources blocked by dependencies is processed again in case a watch event was missed. Zero disables the requeue.")
+	flagset.DurationVar(&c.PluginTimeout, "bundle-plugin-timeout", time.Minute, "How long a plugin may take to process a resource before it fails with a retriable error. Zero disables the timeout.")
+	flagset.StringVar(&c.PluginTimeouts, "bundle-plugin-timeouts", "", `Comma-separated list of plugin=duration pairs overriding the plugin timeout for particular plugins, e.g. "slowPlugin=5m".`)
+	flagset.Float64Var(&c.RequeueJitter, "bundle-requeue-jitter", 0.1, "Maximum fraction of the blocked requeue delay and of watch throttle windows that is randomly added to them, so that Bundles woken up by the same change are not all processed at once. Zero disables jitter.")
 }
 
 func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Context) (*ctrl.Constructed, error) {
+	unresolvableGvkPolicy := bundlec.UnresolvableGvkPolicy(c.UnresolvableGvkPolicy)
+	switch unresolvableGvkPolicy {
+	case "":
+		unresolvableGvkPolicy = bundlec.UnresolvableGvkPolicyBlock
+	case bundlec.UnresolvableGvkPolicyBlock, bundlec.UnresolvableGvkPolicySkip:
+	default:
+		return nil, errors.Errorf("unknown unresolvable GVK policy %q", c.UnresolvableGvkPolicy)
+	}
+	if c.ConflictRetries < 0 {
+		return nil, errors.New("number of conflict retries must not be negative")
+	}
+	if c.MaxConcurrentResources < 0 {
+		return nil, errors.New("maximum number of concurrently processed resources must not be negative")
+	}
+	if c.ConditionHistorySize < 0 {
+		return nil, errors.New("condition history size must not be negative")
+	}
+	if c.MaxConcurrentDeletions < 0 {
+		return nil, errors.New("maximum number of concurrently deleted objects must not be negative")
+	}
+	if c.RetryBaseDelay < 0 || c.RetryMaxDelay < 0 {
+		return nil, errors.New("retry delays must not be negative")
+	}
+	if c.BlockedRequeueDelay < 0 {
+		return nil, errors.New("blocked requeue delay must not be negative")
+	}
+	if c.RequeueJitter < 0 {
+		return nil, errors.New("requeue jitter must not be negative")
+	}
+	if c.MaxResources < 0 {
+		return nil, errors.New("maximum number of resources must not be negative")
+	}
+	if c.FieldManager == "" {
+		return nil, errors.New("field manager must not be empty")
+	}
+	if c.DeletionBatchSize < 0 {
+		return nil, errors.New("deletion batch size must not be negative")
+	}
+	if c.PanicBudget < 0 {
+		return nil, errors.New("panic budget must not be negative")
+	}
+	if c.PanicBudget > 0 && c.PanicBudgetWindow <= 0 {
+		return nil, errors.New("panic budget window must be positive")
+	}
+	if c.TracingSamplingRatio < 0 || c.TracingSamplingRatio > 1 {
+		return nil, errors.New("tracing sampling ratio must be between 0 and 1")
+	}
+	if c.CompletionWebhookRetries < 0 {
+		return nil, errors.New("number of completion webhook retries must not be negative")
+	}
+	if c.LargeBundleResources > 0 && c.MaxConcurrentLargeBundles < 1 {
+		return nil, errors.New("maximum number of concurrently processed large Bundles must be positive")
+	}
+	watchThrottle, err := parseWatchThrottle(c.WatchThrottle)
+	if err != nil {
+		return nil, err
+	}
+	if c.PluginTimeout < 0 {
+		return nil, errors.New("plugin timeout must not be negative")
+	}
+	pluginTimeouts, err := parsePluginTimeouts(c.PluginTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Plugins
 	pluginContainers, err := c.loadPlugins()
 	if err != nil {
@@ -98,12 +271,39 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 			},
 			meta.InterfacesForUnstructured,
 		)
+		writeConfig := *config.RestConfig
+		if writeConfig.RateLimiter == nil && writeConfig.QPS > 0 {
+			// Writes with all field managers share the configured QPS/burst
+			writeConfig.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(writeConfig.QPS, writeConfig.Burst)
+		}
 		smartClient = &smart.DynamicClient{
 			ClientPool: dynamic.NewClientPool(config.RestConfig, rm, dynamic.LegacyAPIPathResolverFunc),
 			Mapper:     rm,
+			// Objects of resources are written with the field manager as the user agent
+			ClientPoolForUserAgent: func(userAgent string) smart.ClientPool {
+				userAgentConfig := writeConfig
+				userAgentConfig.UserAgent = userAgent
+				return dynamic.NewClientPool(&userAgentConfig, rm, dynamic.LegacyAPIPathResolverFunc)
+			},
 		}
 	}
 
+	// Events
+	recorder := c.Recorder
+	if recorder == nil {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(&core_v1client.EventSinkImpl{
+			Interface: config.MainClient.CoreV1().Events(meta_v1.NamespaceNone),
+		})
+		recorder = eventBroadcaster.NewRecorder(scheme, core_v1.EventSource{Component: config.AppName})
+	}
+
+	// Metrics
+	metrics := bundlec.NewMetrics(config.AppName)
+	if err = metrics.Register(config.Registry); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	// Informers
 	bundleInf, err := smithInformer(config, cctx, smithClient, smith_v1.BundleGVK, client.BundleInformer)
 	if err != nil {
@@ -120,6 +320,11 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		return nil, err
 	}
 
+	var schemaValidator bundlec.SchemaValidator
+	if c.ValidateCrdSchema {
+		schemaValidator = store.NewCrdSchema(crdStore)
+	}
+
 	var catalog *store.Catalog
 	if c.ServiceCatalogSupport {
 		catalog, err = svcCatalog(config, cctx, scClient)
@@ -134,6 +339,10 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		readyTypes = append(readyTypes, ready_types.ServiceCatalogKnownTypes)
 	}
 	rc := readychecker.New(crdStore, readyTypes...)
+	rc.Default = readychecker.FieldPathValue{
+		Path:  c.DefaultReadyFieldPath,
+		Value: c.DefaultReadyFieldValue,
+	}
 
 	// Object cleanup
 	cleanupTypes := []map[schema.GroupKind]cleanup.SpecCleanup{clean_types.MainKnownTypes}
@@ -169,27 +378,116 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		}
 	}
 
+	var quotaLister core_v1listers.ResourceQuotaLister
+	if c.QuotaCheck {
+		quotaInf, err := cctx.MainInformer(config, core_v1.SchemeGroupVersion.WithKind("ResourceQuota"), core_v1inf.NewResourceQuotaInformer)
+		if err != nil {
+			return nil, err
+		}
+		quotaLister = core_v1listers.NewResourceQuotaLister(quotaInf.GetIndexer())
+	}
+
+	var completionNotifier bundlec.CompletionNotifier
+	if c.CompletionWebhookURL != "" {
+		completionNotifier = bundlec.NewWebhookCompletionNotifier(config.Logger, c.CompletionWebhookURL,
+			c.CompletionWebhookRetries, c.CompletionWebhookRetryDelay)
+	}
+
+	tracer := c.Tracer
+	if tracer == nil && c.TracingOTLPEndpoint != "" {
+		tracer = bundlec.NewOTLPTracer(config.Logger, c.TracingOTLPEndpoint, c.TracingSamplingRatio)
+	}
+
 	// Controller
 	cntrlr := &bundlec.Controller{
-		Logger:           config.Logger,
-		ReadyForWork:     cctx.ReadyForWork,
-		BundleClient:     smithClient.SmithV1(),
-		BundleStore:      bs,
-		SmartClient:      smartClient,
-		Rc:               rc,
-		Store:            multiStore,
-		SpecCheck:        specCheck,
-		WorkQueue:        cctx.WorkQueue,
-		CrdResyncPeriod:  config.ResyncPeriod,
-		Namespace:        config.Namespace,
-		PluginContainers: pluginContainers,
-		Scheme:           scheme,
-		Catalog:          catalog,
+		Logger:                    config.Logger,
+		ReadyForWork:              cctx.ReadyForWork,
+		BundleClient:              smithClient.SmithV1(),
+		BundleStore:               bs,
+		SmartClient:               smartClient,
+		Rc:                        rc,
+		Store:                     multiStore,
+		SpecCheck:                 specCheck,
+		WorkQueue:                 cctx.WorkQueue,
+		CrdResyncPeriod:           config.ResyncPeriod,
+		Namespace:                 config.Namespace,
+		PluginContainers:          pluginContainers,
+		Scheme:                    scheme,
+		Catalog:                   catalog,
+		Recorder:                  recorder,
+		Metrics:                   metrics,
+		BlockInUseDeletion:        c.BlockInUseDeletion,
+		ValidateObjectNamespace:   c.ValidateObjectNamespace,
+		AllowClusterScopedObjects: c.AllowClusterScopedObjects,
+		ConflictRetries:           c.ConflictRetries,
+		FieldManager:              c.FieldManager,
+		PatchUpdates:              c.PatchUpdates,
+		ContinueOnConflict:        c.ContinueOnConflict,
+		MaxConcurrentResources:    c.MaxConcurrentResources,
+		MaxConcurrentDeletions:    c.MaxConcurrentDeletions,
+		ConditionHistorySize:      c.ConditionHistorySize,
+		OldObjectDeletionTimeout:  c.OldObjectDeletionTimeout,
+		DeletionBatchSize:         c.DeletionBatchSize,
+		ForceDeletion:             c.ForceDeletion,
+		PanicBudget:               c.PanicBudget,
+		PanicBudgetWindow:         c.PanicBudgetWindow,
+		FullReconcilePeriod:       c.FullReconcilePeriod,
+		SkipUnchangedResources:    c.SkipUnchangedResources,
+		ErrorHoldTime:             c.ErrorHoldTime,
+		RetryBaseDelay:            c.RetryBaseDelay,
+		RetryMaxDelay:             c.RetryMaxDelay,
+		BlockedRequeueDelay:       c.BlockedRequeueDelay,
+		RequeueJitter:             c.RequeueJitter,
+		MaxResources:              c.MaxResources,
+		SchemaValidator:           schemaValidator,
+		UnresolvableGvkPolicy:     unresolvableGvkPolicy,
+		LargeBundleResources:      c.LargeBundleResources,
+		MaxConcurrentLargeBundles: c.MaxConcurrentLargeBundles,
+		ErrorMessageTransformer:   c.ErrorMessageTransformer,
+		WatchThrottle:             watchThrottle,
+		PluginTimeout:             c.PluginTimeout,
+		PluginTimeouts:            pluginTimeouts,
+		Tracer:                    tracer,
+		CompletionNotifier:        completionNotifier,
+		ResourceQuotaLister:       quotaLister,
 	}
 	cntrlr.Prepare(crdInf, resourceInfs)
 
+	var srvs servers
+	if c.DebugListenOn != "" {
+		srvs = append(srvs, &bundlec.DebugServer{
+			Logger:             config.Logger,
+			Addr:               c.DebugListenOn,
+			BundleStore:        bs,
+			DryRunner:          cntrlr,
+			QueueDepthReporter: cntrlr,
+		})
+	}
+	if c.AdmissionListenOn != "" {
+		if c.AdmissionTLSCert == "" || c.AdmissionTLSKey == "" {
+			return nil, errors.New("TLS certificate and key are required to serve the validating admission webhook")
+		}
+		srvs = append(srvs, &bundlec.AdmissionServer{
+			Logger:           config.Logger,
+			Addr:             c.AdmissionListenOn,
+			CertFile:         c.AdmissionTLSCert,
+			KeyFile:          c.AdmissionTLSKey,
+			PluginContainers: pluginContainers,
+		})
+	}
+
+	var server ctrl.Server
+	switch len(srvs) {
+	case 0:
+	case 1:
+		server = srvs[0]
+	default:
+		server = srvs
+	}
+
 	return &ctrl.Constructed{
 		Interface: cntrlr,
+		Server:    server,
 	}, nil
 }
 
@@ -333,3 +631,60 @@ func svcCatalog(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.In
 	}
 	return store.NewCatalog(serviceClassInf, servicePlanInf)
 }
+
+// parseWatchThrottle parses a comma-separated list of Kind.group=duration pairs.
+func parseWatchThrottle(value string) (map[schema.GroupKind]time.Duration, error) {
+	result := make(map[schema.GroupKind]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid watch throttle %q, expected Kind.group=duration", pair)
+		}
+		window, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid watch throttle duration for %q", parts[0])
+		}
+		if window < 0 {
+			return nil, errors.Errorf("negative watch throttle duration for %q", parts[0])
+		}
+		var gk schema.GroupKind
+		kindGroup := strings.SplitN(parts[0], ".", 2)
+		gk.Kind = kindGroup[0]
+		if len(kindGroup) == 2 {
+			gk.Group = kindGroup[1]
+		}
+		if gk.Kind == "" {
+			return nil, errors.Errorf("invalid watch throttle %q, kind is empty", pair)
+		}
+		result[gk] = window
+	}
+	return result, nil
+}
+
+// parsePluginTimeouts parses a comma-separated list of plugin=duration pairs.
+func parsePluginTimeouts(value string) (map[smith_v1.PluginName]time.Duration, error) {
+	result := make(map[smith_v1.PluginName]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid plugin timeout %q, expected plugin=duration", pair)
+		}
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid timeout for plugin %q", parts[0])
+		}
+		if timeout < 0 {
+			return nil, errors.Errorf("negative timeout for plugin %q", parts[0])
+		}
+		result[smith_v1.PluginName(parts[0])] = timeout
+	}
+	return result, nil
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"context"
+
+	"github.com/atlassian/ctrl"
+)
+
+// servers runs several servers as one. All servers are stopped when any of them fails.
+type servers []ctrl.Server
+
+func (s servers) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(s))
+	for _, server := range s {
+		go func(server ctrl.Server) {
+			errs <- server.Run(ctx)
+		}(server)
+	}
+	var firstErr error
+	for range s {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
@@ -2,12 +2,16 @@ package v1
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 
 	"github.com/atlassian/smith/pkg/apis/smith"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8s_json "k8s.io/apimachinery/pkg/util/json"
 )
 
@@ -18,11 +22,23 @@ const (
 	BundleInProgress BundleConditionType = "InProgress"
 	BundleReady      BundleConditionType = "Ready"
 	BundleError      BundleConditionType = "Error"
+	// BundleDeleting is only set while the Bundle is being deleted. Its message says what deletion waits for
+	// and how many objects controlled by the Bundle still exist.
+	BundleDeleting BundleConditionType = "Deleting"
 )
 
 const (
 	BundleReasonTerminalError  = "TerminalError"
 	BundleReasonRetriableError = "RetriableError"
+	BundleReasonPaused         = "Paused"
+	// BundleReasonErrorHeld means that the Bundle would be ready but its Error condition is still held
+	// after the error has cleared.
+	BundleReasonErrorHeld = "ErrorHeld"
+
+	// Reasons of the Deleting condition.
+	BundleReasonWaitingForFinalizers = "WaitingForFinalizers"
+	BundleReasonDeletionProtected    = "DeletionProtected"
+	BundleReasonDeletingObjects      = "DeletingObjects"
 )
 
 type ResourceConditionType string
@@ -33,17 +49,75 @@ const (
 	ResourceInProgress ResourceConditionType = "InProgress"
 	ResourceReady      ResourceConditionType = "Ready"
 	ResourceError      ResourceConditionType = "Error"
+	// ResourceForceReady is only present if the resource is forced to be ready by an operator.
+	ResourceForceReady ResourceConditionType = "ForceReady"
 )
 
 const (
 	// Blocked condition reasons
 
 	ResourceReasonDependenciesNotReady = "DependenciesNotReady"
+	// ResourceReasonDependenciesNotCreated means that objects of none of the dependencies that are not ready
+	// exist yet, as opposed to DependenciesNotReady when some of them exist but are not ready.
+	ResourceReasonDependenciesNotCreated = "DependenciesNotCreated"
+
+	// InProgress condition reasons
+
+	ResourceReasonWaitingForOldObjectDeletion = "WaitingForOldObjectDeletion"
+	// ResourceReasonWaitingForMinReadySeconds means that the object is ready but has not been ready for
+	// MinReadySeconds of the resource yet. Ready condition is Unknown with the same reason meanwhile.
+	ResourceReasonWaitingForMinReadySeconds = "WaitingForMinReadySeconds"
+	// ResourceReasonWaitingForRollout means that the object needs to be created or updated but the number of
+	// resources that are being rolled out has reached MaxResourcesPerSync of the Bundle.
+	ResourceReasonWaitingForRollout = "WaitingForRollout"
+
+	// Ready condition reasons
+
+	ResourceReasonCreateOnly = "CreateOnly"
+	// ResourceReasonErrorHeld means that the object is ready but the Error condition of the resource is still
+	// held after the error has cleared.
+	ResourceReasonErrorHeld = "ErrorHeld"
 
 	// Error condition reasons
 
-	ResourceReasonTerminalError  = "TerminalError"
-	ResourceReasonRetriableError = "RetriableError"
+	ResourceReasonTerminalError    = "TerminalError"
+	ResourceReasonRetriableError   = "RetriableError"
+	ResourceReasonDeadlineExceeded = "DeadlineExceeded"
+)
+
+// ErrorCode is a machine-readable classification of the error reported by an Error condition.
+type ErrorCode string
+
+// These are codes of errors reported by Error conditions of Bundles and resources.
+// Code is empty if the error is not classified.
+const (
+	// ErrorCodeTooManyResources means that the Bundle has more resources than allowed.
+	ErrorCodeTooManyResources ErrorCode = "TooManyResources"
+	// ErrorCodeDuplicateResource means that resources have the same name or produce the same object.
+	ErrorCodeDuplicateResource ErrorCode = "DuplicateResource"
+	// ErrorCodeUnknownDependency means that a resource references or runs after a resource that is not in the Bundle.
+	ErrorCodeUnknownDependency ErrorCode = "UnknownDependency"
+	// ErrorCodeDependencyCycle means that dependencies of resources form a cycle.
+	ErrorCodeDependencyCycle ErrorCode = "DependencyCycle"
+	// ErrorCodePluginNotFound means that a resource refers to a plugin that is not registered.
+	ErrorCodePluginNotFound ErrorCode = "PluginNotFound"
+	// ErrorCodePluginTimeout means that a plugin did not finish processing a resource in time.
+	ErrorCodePluginTimeout ErrorCode = "PluginTimeout"
+	// ErrorCodeSpecInvalid means that the definition of a resource or its object is invalid.
+	ErrorCodeSpecInvalid ErrorCode = "SpecInvalid"
+	// ErrorCodeConflict means that an object was modified concurrently.
+	ErrorCodeConflict ErrorCode = "Conflict"
+	// ErrorCodeReadinessCheckFailed means that readiness of an object could not be determined or the object failed.
+	ErrorCodeReadinessCheckFailed ErrorCode = "ReadinessCheckFailed"
+	// ErrorCodeDeadlineExceeded means that a resource has not become ready within its progress deadline.
+	ErrorCodeDeadlineExceeded ErrorCode = "DeadlineExceeded"
+	// ErrorCodeDeletionBlocked means that removed objects are not deleted because they are still in use.
+	ErrorCodeDeletionBlocked ErrorCode = "DeletionBlocked"
+	// ErrorCodeQuotaExceeded means that objects of the Bundle would not fit into ResourceQuotas of the namespace.
+	ErrorCodeQuotaExceeded ErrorCode = "QuotaExceeded"
+	// ErrorCodeResourcesFailed is reported by a Bundle when processing of some of its resources failed.
+	// Codes of the resources' errors are reported by their conditions.
+	ErrorCodeResourcesFailed ErrorCode = "ResourcesFailed"
 )
 
 type ConditionStatus string
@@ -63,6 +137,8 @@ type PluginStatusStr string
 const (
 	PluginStatusOk           PluginStatusStr = "Ok"
 	PluginStatusNoSuchPlugin PluginStatusStr = "NoSuchPlugin"
+	// PluginStatusReadinessCheckError means the readiness check of the plugin failed for at least one resource.
+	PluginStatusReadinessCheckError PluginStatusStr = "ReadinessCheckError"
 )
 
 const (
@@ -76,6 +152,16 @@ const (
 	BundleResourceName = BundleResourcePlural + "." + smith.GroupName
 
 	ReferenceModifierBindSecret = "bindsecret"
+	// ReferenceModifierHash resolves a reference into a hash of the referenced content.
+	// Hashes of all references with this modifier are also injected into the pod template annotations of the
+	// referring object to trigger a rolling update when referenced content changes.
+	ReferenceModifierHash = "hash"
+	// ReferenceModifierLabel resolves a reference into the value of a label of the referenced object.
+	// Path of the reference is the key of the label. Referring resource is blocked until the label is present.
+	ReferenceModifierLabel = "label"
+	// ReferenceModifierAnnotation resolves a reference into the value of an annotation of the referenced object.
+	// Path of the reference is the key of the annotation. Referring resource is blocked until the annotation is present.
+	ReferenceModifierAnnotation = "annotation"
 )
 
 var BundleGVK = SchemeGroupVersion.WithKind(BundleResourceKind)
@@ -123,6 +209,49 @@ func (b *Bundle) GetCondition(conditionType BundleConditionType) (int, *BundleCo
 // +k8s:deepcopy-gen=true
 type BundleSpec struct {
 	Resources []Resource `json:"resources,omitempty"`
+	// Schedule restricts creation/update of objects to scheduled times. Between scheduled times
+	// only readiness of existing objects is monitored. Optional.
+	Schedule *BundleSchedule `json:"schedule,omitempty"`
+	// ProgressDeadlineSeconds is the maximum time in seconds a resource may be continuously not ready
+	// before it is considered failed with a terminal error. The deadline starts again when the Bundle changes.
+	// Optional.
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Paused stops processing of the Bundle. Objects are neither created, updated nor deleted and statuses of
+	// resources are kept as they were when the Bundle was paused. Objects are still deleted when the Bundle
+	// is deleted.
+	Paused bool `json:"paused,omitempty"`
+	// ReclaimPolicy defines what happens to objects of resources that are removed from the Bundle.
+	// Delete is used if not set. Objects are always deleted when the Bundle is deleted.
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// Finalizers are added to the Bundle by Smith so that external systems can do their cleanup before
+	// objects of the Bundle are deleted. An external system removes its finalizer once the cleanup is done.
+	// Objects are only deleted once all these finalizers have been removed.
+	Finalizers []string `json:"finalizers,omitempty"`
+	// MaxResourcesPerSync limits how many resources are rolled out at a time. A resource is being rolled out if
+	// its object was created or updated in the current iteration or if it is in progress. Resources beyond
+	// the limit are not created or updated until the resources that are being rolled out become ready.
+	// Zero means no limit.
+	MaxResourcesPerSync int32 `json:"maxResourcesPerSync,omitempty"`
+	// QuotaCheck enables a check that compute resources requested by pods of workload objects that do not exist yet
+	// fit into ResourceQuotas of the namespace. The Bundle fails with a terminal error before any objects are
+	// created or updated if they do not fit.
+	QuotaCheck bool `json:"quotaCheck,omitempty"`
+}
+
+type ReclaimPolicy string
+
+const (
+	// ReclaimPolicyDelete means objects of removed resources are deleted.
+	ReclaimPolicyDelete ReclaimPolicy = "Delete"
+	// ReclaimPolicyRetain means objects of removed resources are kept. The controller owner reference
+	// to the Bundle is removed from them so that they are no longer managed by it.
+	ReclaimPolicyRetain ReclaimPolicy = "Retain"
+)
+
+// BundleSchedule defines when objects of a Bundle are reconciled.
+type BundleSchedule struct {
+	// DailyAt is a list of times of day in HH:MM format (UTC) when objects are reconciled.
+	DailyAt []string `json:"dailyAt"`
 }
 
 // +k8s:deepcopy-gen=true
@@ -140,6 +269,24 @@ type BundleCondition struct {
 	Reason string `json:"reason,omitempty"`
 	// A human readable message indicating details about the transition.
 	Message string `json:"message,omitempty"`
+	// Code is a machine-readable classification of the error. Only set for Error condition.
+	Code ErrorCode `json:"code,omitempty"`
+	// ObservedGeneration is the generation of the Bundle at which the condition was last updated.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Transitions are the most recent transitions of the condition, oldest first.
+	// Only recorded if enabled in the controller.
+	Transitions []ConditionTransition `json:"transitions,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+// ConditionTransition is a past transition of a condition from one status to another.
+type ConditionTransition struct {
+	// Status the condition transitioned to.
+	Status ConditionStatus `json:"status"`
+	// Reason of the condition at the time of the transition.
+	Reason string `json:"reason,omitempty"`
+	// Time of the transition.
+	Time meta_v1.Time `json:"time"`
 }
 
 func (bc *BundleCondition) String() string {
@@ -162,6 +309,19 @@ type PluginStatus struct {
 	Version string          `json:"version"`
 	Kind    string          `json:"kind"`
 	Status  PluginStatusStr `json:"status,omitempty"`
+	// Resources is the number of resources in the Bundle that use the plugin.
+	Resources int32 `json:"resources,omitempty"`
+	// FailedResources is the number of resources that use the plugin and are in Error state.
+	FailedResources int32 `json:"failedResources,omitempty"`
+	// Objects is the number of existing objects produced by resources that use the plugin, including
+	// additional objects.
+	Objects int32 `json:"objects,omitempty"`
+}
+
+// String returns the status along with the number of resources that are not failing out of all resources
+// that use the plugin e.g. "Ok 8/10".
+func (ps *PluginStatus) String() string {
+	return fmt.Sprintf("%s %d/%d", ps.Status, ps.Resources-ps.FailedResources, ps.Resources)
 }
 
 // +k8s:deepcopy-gen=true
@@ -171,6 +331,15 @@ type BundleStatus struct {
 	ResourceStatuses []ResourceStatus  `json:"resourceStatuses,omitempty"`
 	ObjectsToDelete  []ObjectToDelete  `json:"objectsToDelete,omitempty"`
 	PluginStatuses   []PluginStatus    `json:"pluginStatuses,omitempty"`
+	// LastScheduledReconcileTime is the time when the last scheduled reconcile has completed.
+	// Only set for Bundles with a schedule.
+	LastScheduledReconcileTime meta_v1.Time `json:"lastScheduledReconcileTime,omitempty"`
+	// ReadyResources is the number of ready resources out of TotalResources resources of the Bundle.
+	ReadyResources int32 `json:"readyResources,omitempty"`
+	TotalResources int32 `json:"totalResources,omitempty"`
+	// ResourceOrder is the order in which resources were processed, dependencies first.
+	// Not set if the order cannot be determined, e.g. because there is a dependency cycle.
+	ResourceOrder []ResourceName `json:"resourceOrder,omitempty"`
 }
 
 func (bs *BundleStatus) String() string {
@@ -208,32 +377,149 @@ type ReferenceName string
 // PluginName is a name of a plugin to be invoked.
 type PluginName string
 
+// UpdateStrategy defines what is done when the object of a resource exists already.
+type UpdateStrategy string
+
+const (
+	// UpdateStrategyUpdate updates the object if it does not match the spec.
+	UpdateStrategyUpdate UpdateStrategy = "Update"
+	// UpdateStrategyCreateOnly never updates the object, regardless of how it differs from the spec.
+	// Readiness of the object is checked as usual. Useful for one-shot objects like bootstrap Jobs.
+	UpdateStrategyCreateOnly UpdateStrategy = "CreateOnly"
+	// UpdateStrategyRecreate updates the object like UpdateStrategyUpdate, but if the update is rejected as invalid,
+	// e.g. because an immutable field was changed, the object is deleted and then created from the spec.
+	// Destructive, so it must be opted in explicitly.
+	UpdateStrategyRecreate UpdateStrategy = "Recreate"
+	// UpdateStrategyPatch patches the object with fields that are set in the spec instead of replacing it.
+	// Fields that are not set in the spec are left as they are, so objects which are populated by someone else
+	// do not need to be fully specified. Built-in kinds are patched with a strategic merge patch, other kinds
+	// with a JSON merge patch.
+	UpdateStrategyPatch UpdateStrategy = "Patch"
+)
+
 // +k8s:deepcopy-gen=true
 // Resource describes an object that should be provisioned.
 type Resource struct {
 	// Name of the resource for references.
 	Name ResourceName `json:"name"`
 
+	// Labels of the resource. Used to select resources in references. Not applied to the object.
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// Explicit dependencies.
 	References []Reference `json:"references,omitempty"`
 
+	// RunAfter lists resources that must be ready before this resource is processed.
+	// Unlike References they only affect ordering, no values are taken from them.
+	RunAfter []ResourceName `json:"runAfter,omitempty"`
+
+	// Priority influences the order in which resources that do not depend on each other are processed.
+	// Resources with higher priority are processed first. Resources with the same priority are processed
+	// in the order they are listed in the Bundle.
+	Priority int32 `json:"priority,omitempty"`
+
+	// MinReadySeconds is the number of seconds the object must be continuously ready for before
+	// the resource is reported as ready. Zero means the resource is ready as soon as the object is ready.
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// MaxRetries is the maximum number of consecutive failed processing attempts after which
+	// an error is considered terminal even if it is retriable. Zero means unlimited.
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+
+	// CreateOnly means that the object is created if it does not exist but is never updated.
+	// An existing object is considered ready as is. Useful for objects that are only seeded
+	// and then managed by someone else. Unlike with CreateOnly update strategy, readiness of
+	// an existing object is not checked. Must not be combined with UpdateStrategy.
+	CreateOnly bool `json:"createOnly,omitempty"`
+
+	// UpdateStrategy defines what is done when the object exists already. Update is used if not set.
+	UpdateStrategy UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// Adopt means that an existing object that does not have a controller is taken over by the Bundle
+	// instead of failing the resource. Objects controlled by someone else are never adopted.
+	Adopt bool `json:"adopt,omitempty"`
+
+	// Disabled means that the object is not created and an existing object is deleted.
+	// References to a disabled resource are resolved as if it was not ready, i.e. dependents
+	// are blocked unless the references are optional or have defaults.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// DeletePolicy is the propagation policy used when the object is deleted, i.e. when the resource is
+	// removed from the Bundle or when the Bundle is deleted. One of Foreground, Background or Orphan.
+	// Foreground is used if not set.
+	DeletePolicy meta_v1.DeletionPropagation `json:"deletePolicy,omitempty"`
+
+	// DeleteGracePeriodSeconds is the grace period used when the object is deleted. Must not be negative.
+	// Default grace period of the object's kind is used if not set.
+	DeleteGracePeriodSeconds *int64 `json:"deleteGracePeriodSeconds,omitempty"`
+
+	// IgnorePaths are paths to fields of the object that are not compared with the spec and are never updated,
+	// e.g. fields set by defaulting or by sidecar injection. Keys that contain dots are quoted in brackets,
+	// e.g. metadata.annotations['sidecar.istio.io/status'].
+	IgnorePaths []string `json:"ignorePaths,omitempty"`
+
 	Spec ResourceSpec `json:"spec"`
 }
 
+// SpecHash returns a hash of the resource definition. It is recorded in the status of the resource
+// when the resource is processed.
+func (r *Resource) SpecHash() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 // +k8s:deepcopy-gen=true
 // Refer to a part of another object
 type Reference struct {
 	Name     ReferenceName `json:"name,omitempty"`
-	Resource ResourceName  `json:"resource"`
+	Resource ResourceName  `json:"resource,omitempty"`
 	Path     string        `json:"path,omitempty"`
 	Example  interface{}   `json:"example,omitempty"`
 	Modifier string        `json:"modifier,omitempty"`
+	// Default is used if the referenced resource is not ready or the referenced field does not exist.
+	// Dependent resource is not blocked by the referenced resource if the default is set.
+	Default interface{} `json:"default,omitempty"`
+	// Optional reference does not block the dependent resource if the referenced resource is not ready or
+	// the referenced field does not exist. Default is used as the value in that case, null if it is not set.
+	// The referenced resource is still processed before the dependent resource.
+	Optional bool `json:"optional,omitempty"`
+	// Selector selects resources of the Bundle by their labels instead of naming a single resource in Resource.
+	// The reference is expanded into a nameless reference to each of the selected resources, so it only
+	// makes the dependent resource depend on them. A selector that matches no resources is satisfied.
+	Selector *meta_v1.LabelSelector `json:"selector,omitempty"`
+	// Object names an object in the namespace of the Bundle that is not an object of a resource of the Bundle,
+	// e.g. an object of another Bundle, instead of naming a resource in Resource. The dependent resource is blocked
+	// until the object exists and is ready. Values are taken from the object the same way as from objects of resources.
+	Object *ExternalObjectReference `json:"object,omitempty"`
 }
 
 // DeepCopyInto is an deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Reference) DeepCopyInto(out *Reference) {
 	*out = *in
 	out.Example = runtime.DeepCopyJSONValue(in.Example)
+	out.Default = runtime.DeepCopyJSONValue(in.Default)
+	if in.Selector != nil {
+		out.Selector = in.Selector.DeepCopy()
+	}
+	if in.Object != nil {
+		object := *in.Object
+		out.Object = &object
+	}
+}
+
+// ExternalObjectReference names an object that is not an object of a resource of the Bundle.
+type ExternalObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+func (r *ExternalObjectReference) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind)
 }
 
 // Ref returns string representation of the reference that can be used to pull in the referred entity.
@@ -285,8 +571,48 @@ func (in *PluginSpec) DeepCopyInto(out *PluginSpec) {
 type ResourceStatus struct {
 	Name       ResourceName        `json:"name"`
 	Conditions []ResourceCondition `json:"conditions,omitempty"`
+	// ConsecutiveFailures is the number of consecutive failed processing attempts.
+	// Only tracked for resources with MaxRetries set.
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+	// LastAction is the last action Smith took on the object of the resource.
+	LastAction ResourceAction `json:"lastAction,omitempty"`
+	// LastActionTime is the time of the last action. For the Unchanged action it is the time
+	// when the object was first found unchanged.
+	LastActionTime meta_v1.Time `json:"lastActionTime,omitempty"`
+	// SpecHash is the hash of the resource definition (see Resource.SpecHash) at the time the resource
+	// was last processed. The status is stale if it does not match the hash of the current definition.
+	SpecHash string `json:"specHash,omitempty"`
+	// AppliedSpecHash is a short hash of the object Smith last sent to the API server when it created or updated
+	// the object, after references were resolved and plugins were invoked. Together with AppliedGeneration it
+	// identifies what the object was last set to by Smith.
+	AppliedSpecHash string `json:"appliedSpecHash,omitempty"`
+	// AppliedGeneration is the generation of the Bundle the object was last created or updated from.
+	AppliedGeneration int64 `json:"appliedGeneration,omitempty"`
+	// RetryCount is the number of consecutive retriable errors of the resource.
+	// It is reset when the resource becomes ready.
+	RetryCount int32 `json:"retryCount,omitempty"`
+	// NextRetryTime is the time after which the resource is retried after a retriable error. The resource is not
+	// processed before that time unless the Bundle changes. The delay grows exponentially with RetryCount.
+	NextRetryTime *meta_v1.Time `json:"nextRetryTime,omitempty"`
+	// ProgressStartTime is the time the progress deadline was restarted at because the generation of the Bundle
+	// changed while the resource was not ready. The deadline is tracked since the later of this time and the last
+	// transition of the Ready condition.
+	ProgressStartTime *meta_v1.Time `json:"progressStartTime,omitempty"`
 }
 
+type ResourceAction string
+
+// These are actions Smith takes on objects of resources.
+const (
+	ResourceActionCreated   ResourceAction = "Created"
+	ResourceActionUpdated   ResourceAction = "Updated"
+	ResourceActionUnchanged ResourceAction = "Unchanged"
+	// ResourceActionDeleted means the object was deleted to be re-created.
+	ResourceActionDeleted ResourceAction = "Deleted"
+	// ResourceActionAdopted means an existing object without a controller was taken over by the Bundle.
+	ResourceActionAdopted ResourceAction = "Adopted"
+)
+
 func (rs *ResourceStatus) GetCondition(conditionType ResourceConditionType) (int, *ResourceCondition) {
 	for i := range rs.Conditions {
 		resCond := &rs.Conditions[i]
@@ -322,6 +648,10 @@ type ResourceCondition struct {
 	Reason string `json:"reason,omitempty"`
 	// A human readable message indicating details about the transition.
 	Message string `json:"message,omitempty"`
+	// Code is a machine-readable classification of the error. Only set for Error condition.
+	Code ErrorCode `json:"code,omitempty"`
+	// ObservedGeneration is the generation of the Bundle at which the condition was last updated.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 func (rc *ResourceCondition) String() string {
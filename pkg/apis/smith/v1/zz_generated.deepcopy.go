@@ -43,6 +43,13 @@ func (in *BundleCondition) DeepCopyInto(out *BundleCondition) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.Transitions != nil {
+		in, out := &in.Transitions, &out.Transitions
+		*out = make([]ConditionTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -89,6 +96,27 @@ func (in *BundleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleSchedule) DeepCopyInto(out *BundleSchedule) {
+	*out = *in
+	if in.DailyAt != nil {
+		in, out := &in.DailyAt, &out.DailyAt
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleSchedule.
+func (in *BundleSchedule) DeepCopy() *BundleSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BundleSpec) DeepCopyInto(out *BundleSpec) {
 	*out = *in
@@ -99,6 +127,29 @@ func (in *BundleSpec) DeepCopyInto(out *BundleSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BundleSchedule)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int32)
+			**out = **in
+		}
+	}
+	if in.Finalizers != nil {
+		in, out := &in.Finalizers, &out.Finalizers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -139,6 +190,12 @@ func (in *BundleStatus) DeepCopyInto(out *BundleStatus) {
 		*out = make([]PluginStatus, len(*in))
 		copy(*out, *in)
 	}
+	in.LastScheduledReconcileTime.DeepCopyInto(&out.LastScheduledReconcileTime)
+	if in.ResourceOrder != nil {
+		in, out := &in.ResourceOrder, &out.ResourceOrder
+		*out = make([]ResourceName, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -152,6 +209,23 @@ func (in *BundleStatus) DeepCopy() *BundleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionTransition) DeepCopyInto(out *ConditionTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionTransition.
+func (in *ConditionTransition) DeepCopy() *ConditionTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginSpec.
 func (in *PluginSpec) DeepCopy() *PluginSpec {
 	if in == nil {
@@ -175,6 +249,13 @@ func (in *Reference) DeepCopy() *Reference {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.References != nil {
 		in, out := &in.References, &out.References
 		*out = make([]Reference, len(*in))
@@ -182,6 +263,25 @@ func (in *Resource) DeepCopyInto(out *Resource) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RunAfter != nil {
+		in, out := &in.RunAfter, &out.RunAfter
+		*out = make([]ResourceName, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeleteGracePeriodSeconds != nil {
+		in, out := &in.DeleteGracePeriodSeconds, &out.DeleteGracePeriodSeconds
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	if in.IgnorePaths != nil {
+		in, out := &in.IgnorePaths, &out.IgnorePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Spec.DeepCopyInto(&out.Spec)
 	return
 }
@@ -253,6 +353,23 @@ func (in *ResourceStatus) DeepCopyInto(out *ResourceStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.LastActionTime.DeepCopyInto(&out.LastActionTime)
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = (*in).DeepCopy()
+		}
+	}
+	if in.ProgressStartTime != nil {
+		in, out := &in.ProgressStartTime, &out.ProgressStartTime
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = (*in).DeepCopy()
+		}
+	}
 	return
 }
 
@@ -1,6 +1,8 @@
 package smart
 
 import (
+	"sync"
+
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,10 +25,56 @@ type ClientPool interface {
 type DynamicClient struct {
 	ClientPool ClientPool
 	Mapper     Mapper
+	// ClientPoolForUserAgent returns a pool of clients that identify themselves with the user agent. Optional.
+	// The API server derives the field manager of a write from the user agent of the client.
+	// It is called once per user agent, pools are reused.
+	ClientPoolForUserAgent func(userAgent string) ClientPool
+
+	userAgentPoolsMx sync.Mutex
+	userAgentPools   map[string]ClientPool
 }
 
 func (c *DynamicClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
-	client, err := c.ClientPool.ClientForGroupVersionKind(gvk)
+	return c.forGVK(c.ClientPool, gvk, namespace)
+}
+
+// ForGVKWithFieldManager returns a client which writes are identified with the field manager. Client libraries
+// cannot pass the field manager explicitly, so it is sent as the user agent. Client returned by ForGVK is used if
+// ClientPoolForUserAgent is not set.
+func (c *DynamicClient) ForGVKWithFieldManager(gvk schema.GroupVersionKind, namespace, fieldManager string) (dynamic.ResourceInterface, error) {
+	if c.ClientPoolForUserAgent == nil {
+		return c.ForGVK(gvk, namespace)
+	}
+	return c.forGVK(c.clientPoolForUserAgent(fieldManager), gvk, namespace)
+}
+
+// clientPoolForUserAgent returns the pool of clients for the user agent. Pools are cached so that clients and
+// their rate limiters are not created for each write.
+func (c *DynamicClient) clientPoolForUserAgent(userAgent string) ClientPool {
+	c.userAgentPoolsMx.Lock()
+	defer c.userAgentPoolsMx.Unlock()
+	clientPool, ok := c.userAgentPools[userAgent]
+	if !ok {
+		if c.userAgentPools == nil {
+			c.userAgentPools = make(map[string]ClientPool)
+		}
+		clientPool = c.ClientPoolForUserAgent(userAgent)
+		c.userAgentPools[userAgent] = clientPool
+	}
+	return clientPool
+}
+
+// IsNamespaced checks if objects of the kind are namespaced.
+func (c *DynamicClient) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	rm, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
+	}
+	return rm.Scope.Name() != meta.RESTScopeNameRoot, nil
+}
+
+func (c *DynamicClient) forGVK(clientPool ClientPool, gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	client, err := clientPool.ClientForGroupVersionKind(gvk)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to instantiate client for %s", gvk)
 	}
@@ -35,6 +83,11 @@ func (c *DynamicClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (d
 		return nil, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
 	}
 
+	if rm.Scope.Name() == meta.RESTScopeNameRoot {
+		// Cluster-scoped objects do not have a namespace, even if they are controlled by a namespaced object
+		namespace = meta_v1.NamespaceNone
+	}
+
 	return client.Resource(&meta_v1.APIResource{
 		Name:       rm.Resource,
 		Namespaced: namespace != meta_v1.NamespaceNone,
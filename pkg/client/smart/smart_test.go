@@ -0,0 +1,35 @@
+package smart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+type fakeClientPool struct {
+	userAgent string
+}
+
+func (p *fakeClientPool) ClientForGroupVersionKind(schema.GroupVersionKind) (dynamic.Interface, error) {
+	return nil, nil
+}
+
+func TestClientPoolIsReusedPerUserAgent(t *testing.T) {
+	t.Parallel()
+	var created []string
+	c := &DynamicClient{
+		ClientPoolForUserAgent: func(userAgent string) ClientPool {
+			created = append(created, userAgent)
+			return &fakeClientPool{userAgent: userAgent}
+		},
+	}
+
+	a1 := c.clientPoolForUserAgent("a")
+	a2 := c.clientPoolForUserAgent("a")
+	b := c.clientPoolForUserAgent("b")
+	assert.True(t, a1 == a2)
+	assert.Equal(t, "b", b.(*fakeClientPool).userAgent)
+	assert.Equal(t, []string{"a", "b"}, created)
+}
@@ -0,0 +1,160 @@
+package bundlec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	ctrlLogz "github.com/atlassian/ctrl/logz"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admission_v1b1 "k8s.io/api/admission/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	AdmissionPath = "/admission/bundles/validate"
+
+	admissionReadTimeout     = 10 * time.Second
+	admissionWriteTimeout    = 10 * time.Second
+	admissionShutdownTimeout = 5 * time.Second
+	// admissionMaxRequestSize limits the size of admission review requests. API server limits the size of
+	// objects to 1.5MiB and the object may be sent twice (old and new object).
+	admissionMaxRequestSize = 4 * 1024 * 1024
+)
+
+// ValidateBundle runs the structural checks of the Bundle spec that would fail the Bundle or its resources
+// when it is processed: duplicate resource names, resources producing the same object, references to unknown
+// resources, dependency cycles and references to unknown plugins.
+func ValidateBundle(bundle *smith_v1.Bundle, pluginContainers map[smith_v1.PluginName]plugin.PluginContainer) error {
+	resources, err := expandReferences(bundle.Spec.Resources, pluginContainers)
+	if err != nil {
+		return err
+	}
+	names := make(map[smith_v1.ResourceName]struct{}, len(resources))
+	for _, res := range resources {
+		if _, exist := names[res.Name]; exist {
+			return withErrorCode(smith_v1.ErrorCodeDuplicateResource, errors.Errorf("bundle contains two resources with the same name %q", res.Name))
+		}
+		names[res.Name] = struct{}{}
+	}
+	st := &bundleSyncTask{
+		bundle:           bundle,
+		pluginContainers: pluginContainers,
+	}
+	if err := st.checkDuplicateObjects(); err != nil {
+		return err
+	}
+	if missingPlugins := st.checkPluginsExist(); len(missingPlugins) > 0 {
+		// Report the first resource in the order of resources to make the error stable
+		for _, res := range bundle.Spec.Resources {
+			if err, ok := missingPlugins[res.Name]; ok {
+				return errors.Wrapf(err, "resource %q", res.Name)
+			}
+		}
+	}
+	if _, _, err := sortBundle(bundle, pluginContainers); err != nil {
+		return errors.Wrap(err, "topological sort of resources failed")
+	}
+	return nil
+}
+
+// AdmissionHandler is a validating admission webhook that rejects Bundles that fail ValidateBundle.
+// Bundles are validated on create and update.
+type AdmissionHandler struct {
+	Logger           *zap.Logger
+	PluginContainers map[smith_v1.PluginName]plugin.PluginContainer
+}
+
+func (h *AdmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var review admission_v1b1.AdmissionReview
+	if err := json.NewDecoder(io.LimitReader(r.Body, admissionMaxRequestSize)).Decode(&review); err != nil {
+		http.Error(w, "failed to decode admission review", http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review does not contain a request", http.StatusBadRequest)
+		return
+	}
+	review.Response = h.review(review.Request)
+	review.Request = nil // Response is enough
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		h.Logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
+
+func (h *AdmissionHandler) review(request *admission_v1b1.AdmissionRequest) *admission_v1b1.AdmissionResponse {
+	response := &admission_v1b1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: true,
+	}
+	if request.Operation != admission_v1b1.Create && request.Operation != admission_v1b1.Update {
+		return response
+	}
+	var bundle smith_v1.Bundle
+	if err := json.Unmarshal(request.Object.Raw, &bundle); err != nil {
+		response.Allowed = false
+		response.Result = &meta_v1.Status{
+			Status:  meta_v1.StatusFailure,
+			Message: "failed to decode Bundle: " + err.Error(),
+			Reason:  meta_v1.StatusReasonBadRequest,
+			Code:    http.StatusBadRequest,
+		}
+		return response
+	}
+	if err := ValidateBundle(&bundle, h.PluginContainers); err != nil {
+		h.Logger.Info("Rejecting invalid Bundle", ctrlLogz.Namespace(&bundle), ctrlLogz.Object(&bundle), zap.Error(err))
+		response.Allowed = false
+		response.Result = &meta_v1.Status{
+			Status:  meta_v1.StatusFailure,
+			Message: err.Error(),
+			Reason:  meta_v1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	return response
+}
+
+// AdmissionServer serves the validating admission webhook for Bundles over TLS.
+type AdmissionServer struct {
+	Logger           *zap.Logger
+	Addr             string
+	CertFile         string
+	KeyFile          string
+	PluginContainers map[smith_v1.PluginName]plugin.PluginContainer
+}
+
+func (s *AdmissionServer) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(AdmissionPath, &AdmissionHandler{
+		Logger:           s.Logger,
+		PluginContainers: s.PluginContainers,
+	})
+	srv := &http.Server{
+		Addr:         s.Addr,
+		Handler:      mux,
+		ReadTimeout:  admissionReadTimeout,
+		WriteTimeout: admissionWriteTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServeTLS(s.CertFile, s.KeyFile)
+	}()
+	select {
+	case err := <-serveErr:
+		return errors.Wrap(err, "admission server failed")
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), admissionShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
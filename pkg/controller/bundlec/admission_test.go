@@ -0,0 +1,176 @@
+package bundlec
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	admission_v1b1 "k8s.io/api/admission/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func admissionConfigMap(name string) *core_v1.ConfigMap {
+	return &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: name,
+		},
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		resources []smith_v1.Resource
+		code      smith_v1.ErrorCode
+	}{
+		{
+			name: "valid",
+			resources: []smith_v1.Resource{
+				{Name: "a", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+				{Name: "b", RunAfter: []smith_v1.ResourceName{"a"}, Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm2")}},
+			},
+		},
+		{
+			name: "duplicate name",
+			resources: []smith_v1.Resource{
+				{Name: "a", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+				{Name: "a", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm2")}},
+			},
+			code: smith_v1.ErrorCodeDuplicateResource,
+		},
+		{
+			name: "duplicate object",
+			resources: []smith_v1.Resource{
+				{Name: "a", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+				{Name: "b", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+			},
+			code: smith_v1.ErrorCodeDuplicateResource,
+		},
+		{
+			name: "unknown dependency",
+			resources: []smith_v1.Resource{
+				{Name: "a", References: []smith_v1.Reference{{Resource: "missing"}}, Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+			},
+			code: smith_v1.ErrorCodeUnknownDependency,
+		},
+		{
+			name: "cycle",
+			resources: []smith_v1.Resource{
+				{Name: "a", RunAfter: []smith_v1.ResourceName{"b"}, Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+				{Name: "b", RunAfter: []smith_v1.ResourceName{"a"}, Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm2")}},
+			},
+			code: smith_v1.ErrorCodeDependencyCycle,
+		},
+		{
+			name: "missing plugin",
+			resources: []smith_v1.Resource{
+				{Name: "a", Spec: smith_v1.ResourceSpec{Plugin: &smith_v1.PluginSpec{Name: "unknown", ObjectName: "obj1"}}},
+			},
+			code: smith_v1.ErrorCodePluginNotFound,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			bundle := &smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "bundle1",
+					Namespace: "ns",
+				},
+				Spec: smith_v1.BundleSpec{
+					Resources: tc.resources,
+				},
+			}
+			err := ValidateBundle(bundle, nil)
+			if tc.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.code, errorCode(err))
+		})
+	}
+}
+
+func TestAdmissionHandler(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	review := func(t *testing.T, bundle *smith_v1.Bundle) *admission_v1b1.AdmissionResponse {
+		raw, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		body, err := json.Marshal(admission_v1b1.AdmissionReview{
+			Request: &admission_v1b1.AdmissionRequest{
+				UID:       "request-uid",
+				Namespace: "ns",
+				Operation: admission_v1b1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+		require.NoError(t, err)
+		h := &AdmissionHandler{
+			Logger: logger,
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, AdmissionPath, bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response admission_v1b1.AdmissionReview
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.Response)
+		assert.EqualValues(t, "request-uid", response.Response.UID)
+		return response.Response
+	}
+	bundle := &smith_v1.Bundle{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       smith_v1.BundleResourceKind,
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{Name: "a", Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm1")}},
+				{Name: "b", References: []smith_v1.Reference{{Resource: "a"}}, Spec: smith_v1.ResourceSpec{Object: admissionConfigMap("cm2")}},
+			},
+		},
+	}
+
+	response := review(t, bundle)
+	assert.True(t, response.Allowed)
+
+	bundle.Spec.Resources[1].References[0].Resource = "missing"
+	response = review(t, bundle)
+	assert.False(t, response.Allowed)
+	require.NotNil(t, response.Result)
+	assert.Equal(t, meta_v1.StatusReasonInvalid, response.Result.Reason)
+	assert.Contains(t, response.Result.Message, `references unknown resource "missing"`)
+}
+
+func TestAdmissionHandlerRejectsGet(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	h := &AdmissionHandler{
+		Logger: logger,
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, AdmissionPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -5,27 +5,49 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ash2k/stager/wait"
 	ctrlLogz "github.com/atlassian/ctrl/logz"
+	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	smithClient_v1 "github.com/atlassian/smith/pkg/client/clientset_generated/clientset/typed/smith/v1"
 	"github.com/atlassian/smith/pkg/plugin"
 	"github.com/atlassian/smith/pkg/resources"
 	"github.com/atlassian/smith/pkg/store"
+	"github.com/atlassian/smith/pkg/util"
 	"github.com/atlassian/smith/pkg/util/graph"
 	"github.com/atlassian/smith/pkg/util/logz"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	core_v1 "k8s.io/api/core/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	core_v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// errorHeldMessagePrefix starts the suffix that is appended to the message of an Error condition that is held
+	// after the error has cleared. The suffix says when the hold expires.
+	errorHeldMessagePrefix = " (error has cleared, condition is held until "
+	// errorHeldTimeLayout is the layout of the time in the suffix.
+	errorHeldTimeLayout = time.RFC3339
 )
 
 type bundleSyncTask struct {
 
 	// Inputs
 
+	// ctx is cancelled when processing should be aborted, e.g. on shutdown. Optional.
+	ctx              context.Context
 	logger           *zap.Logger
 	bundleClient     smithClient_v1.BundlesGetter
 	smartClient      SmartClient
@@ -36,84 +58,281 @@ type bundleSyncTask struct {
 	pluginContainers map[smith_v1.PluginName]plugin.PluginContainer
 	scheme           *runtime.Scheme
 	catalog          *store.Catalog
+	recorder         record.EventRecorder
+	// schemaValidator is optional. Objects are not validated if it is nil.
+	schemaValidator SchemaValidator
+
+	// validateObjectNamespace enables validation that objects are in the Bundle's namespace.
+	validateObjectNamespace bool
+	// allowClusterScoped allows cluster-scoped objects if validateObjectNamespace is set.
+	allowClusterScoped bool
+	// oldObjectDeletionTimeout is how long to wait for an object that is being deleted to be gone before
+	// giving up. Zero means no timeout.
+	oldObjectDeletionTimeout time.Duration
+	// deletionBatchSize is the maximum number of objects deleted per iteration when the Bundle is deleted.
+	// Zero means all objects are deleted at once.
+	deletionBatchSize int
+	// forceDeletion makes deletion of objects when the Bundle is deleted retry against the current UID of
+	// an object that was re-created, if the Bundle controls it.
+	forceDeletion bool
+	// conflictRetries is the number of times processing of a resource is retried on conflict
+	// before processing of the Bundle is short-circuited.
+	conflictRetries int
+	// continueOnConflict makes a conflict that remains after retries fail only the conflicting resource
+	// instead of short-circuiting processing of the Bundle.
+	continueOnConflict bool
+	// fieldManager identifies writes of objects. Optional.
+	fieldManager string
+	// patchUpdates makes objects of resources with the Update strategy be patched instead of replaced.
+	patchUpdates bool
+	// blockInUseDeletion enables the interlock that prevents deletion of removed objects
+	// that are still referenced by objects of present resources.
+	blockInUseDeletion bool
+	// errorHoldTime is how long an Error condition is held after the error has cleared.
+	// Zero disables holding.
+	errorHoldTime time.Duration
+	// retryBaseDelay is the delay before a resource is retried after its first retriable error. It doubles with
+	// each consecutive retriable error, up to retryMaxDelay. Zero disables backoff.
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	// blockedRequeueDelay is the delay after which the Bundle is processed again if any of its resources
+	// are blocked by dependencies. Zero disables the requeue.
+	blockedRequeueDelay time.Duration
+	// requeueJitter is the maximum fraction of blockedRequeueDelay that is randomly added to it.
+	requeueJitter float64
+	// maxResources is the maximum number of resources in the Bundle. Zero means no limit.
+	maxResources int
+	// pluginTimeout is how long a plugin may take to process a resource. pluginTimeouts overrides it
+	// for particular plugins. Zero means no timeout.
+	pluginTimeout  time.Duration
+	pluginTimeouts map[smith_v1.PluginName]time.Duration
+	// unresolvableGvkPolicy defines what to do with objects that cannot be deleted
+	// because a client for their GVK cannot be obtained.
+	unresolvableGvkPolicy UnresolvableGvkPolicy
+	// errorMessageTransformer is optional. Errors are put into conditions as is if it is nil.
+	errorMessageTransformer ErrorMessageTransformer
+	// tracer is optional. span is the root span of the processing iteration.
+	tracer Tracer
+	span   Span
+	// changedObjects are objects which changes triggered processing of the Bundle. If set, only resources
+	// affected by the changes are processed. Nil means all resources are processed.
+	changedObjects map[changedObject]struct{}
+	// maxConcurrentResources is the maximum number of resources that do not depend on each other
+	// processed concurrently. Values less than 2 mean resources are processed one by one.
+	maxConcurrentResources int
+	// maxConcurrentDeletions is the maximum number of objects deleted concurrently. Values less than 2 mean
+	// objects are deleted one by one.
+	maxConcurrentDeletions int
+	// resourceCache is optional. If set, resources that were ready after they were processed with the same
+	// inputs are not processed again.
+	resourceCache *resourceCache
+	// deletedObjects is optional. If set, objects deleted by the task are recorded into it and
+	// resources wait for such objects to be gone before re-creating them.
+	deletedObjects *deletedObjects
+	// conditionHistorySize is the number of most recent transitions kept in each Bundle condition.
+	// Zero disables the history.
+	conditionHistorySize int
+	// metrics is optional.
+	metrics *Metrics
+	// completionNotifier is optional. It is notified when the Bundle transitions into a terminal state.
+	completionNotifier CompletionNotifier
+	// quotaLister is optional. If set, Bundles with quotaCheck are checked against ResourceQuotas of their namespace.
+	quotaLister core_v1listers.ResourceQuotaLister
 
 	// Outputs
 
 	processedResources map[smith_v1.ResourceName]*resourceInfo
 	objectsToDelete    map[objectRef]runtime.Object
 	newFinalizers      []string
+	// requeueAfter is the delay after which the Bundle should be processed again. Zero if not needed.
+	requeueAfter time.Duration
+	// scheduledReconcile is true if objects of a Bundle with a schedule were reconciled.
+	scheduledReconcile bool
+	// paused is true if processing was skipped because the Bundle is paused.
+	paused bool
+	// resourceOrder is the order in which resources were processed. Nil if it was not determined.
+	resourceOrder []smith_v1.ResourceName
+	// deletingCond is the Deleting condition to set while the Bundle is being deleted. Nil if not set.
+	deletingCond *smith_v1.BundleCondition
+	// rollingOut is the number of processed resources which objects were created or updated, or are in progress.
+	rollingOut int
 }
 
 // Parse bundle, build resource graph, traverse graph, assert each resource exists.
 // For each resource ensure its dependencies (if any) are in READY state before creating it.
 // If at least one dependency is not READY - skip the resource. Rebuild will/should be called once the dependency
-// updates it's state (noticed via watching).
+// updates it's state (noticed via watching). In case a watch event is missed, the Bundle is also requeued after
+// blockedRequeueDelay.
 
 // READY state might mean something different for each resource type. For a Custom Resource it may mean
 // that a field "State" in the Status of the resource is set to "Ready". It is customizable via
 // annotations with some defaults.
 func (st *bundleSyncTask) processNormal() (retriableError bool, e error) {
-	// If the "deleteResources" finalizer is missing, add it and finish the processing iteration
-	if !hasDeleteResourcesFinalizer(st.bundle) {
-		st.newFinalizers = addDeleteResourcesFinalizer(st.bundle.GetFinalizers())
+	// Refuse to process Bundles that are too large before doing any work
+	if st.maxResources > 0 && len(st.bundle.Spec.Resources) > st.maxResources {
+		return false, withErrorCode(smith_v1.ErrorCodeTooManyResources, errors.Errorf("bundle has %d resources, maximum allowed number of resources is %d",
+			len(st.bundle.Spec.Resources), st.maxResources))
+	}
+
+	// If the "deleteResources" finalizer or finalizers requested in the spec are missing, add them and
+	// finish the processing iteration
+	if len(missingFinalizers(st.bundle)) > 0 {
+		st.newFinalizers = addMissingFinalizers(st.bundle)
 		return false, nil
 	}
 
-	// Build resource map by name
-	resourceMap := make(map[smith_v1.ResourceName]smith_v1.Resource, len(st.bundle.Spec.Resources))
-	for _, res := range st.bundle.Spec.Resources {
+	if st.bundle.Spec.Paused {
+		st.logger.Info("Bundle is paused, skipping processing")
+		st.paused = true
+		return false, nil
+	}
+
+	// Build resource map by name. References with selectors are expanded into references to selected resources
+	// and inputs of plugins are added as references.
+	resources, err := expandReferences(st.bundle.Spec.Resources, st.pluginContainers)
+	if err != nil {
+		return false, err
+	}
+	resourceMap := make(map[smith_v1.ResourceName]smith_v1.Resource, len(resources))
+	for _, res := range resources {
 		if _, exist := resourceMap[res.Name]; exist {
-			return false, errors.Errorf("bundle contains two resources with the same name %q", res.Name)
+			return false, withErrorCode(smith_v1.ErrorCodeDuplicateResource, errors.Errorf("bundle contains two resources with the same name %q", res.Name))
 		}
 		resourceMap[res.Name] = res
 	}
+	if err := st.checkDuplicateObjects(); err != nil {
+		return false, err
+	}
+	missingPlugins := st.checkPluginsExist()
 
 	// Build the graph and topologically sort it
-	_, sorted, sortErr := sortBundle(st.bundle)
+	sortSpan := startSpan(st.tracer, st.span, spanSortBundle, nil)
+	g, sorted, sortErr := sortBundle(st.bundle, st.pluginContainers)
+	sortSpan.Finish(sortErr)
 	if sortErr != nil {
 		return false, errors.Wrap(sortErr, "topological sort of resources failed")
 	}
 
-	st.processedResources = make(map[smith_v1.ResourceName]*resourceInfo, len(st.bundle.Spec.Resources))
+	monitorOnly, err := st.checkSchedule()
+	if err != nil {
+		return false, err
+	}
+	if !monitorOnly {
+		// Fail before any objects are created or updated
+		if err = st.checkQuota(); err != nil {
+			return false, err
+		}
+	}
 
-	// Visit vertices in sorted order
-	for _, resName := range sorted {
-		// Process the resource
-		resourceName := resName.(smith_v1.ResourceName)
-		logger := st.logger.With(logz.Resource(resourceName))
-		res := resourceMap[resourceName]
-		rst := resourceSyncTask{
-			logger:             logger,
-			smartClient:        st.smartClient,
-			rc:                 st.rc,
-			store:              st.store,
-			specCheck:          st.specCheck,
-			bundle:             st.bundle,
-			processedResources: st.processedResources,
-			pluginContainers:   st.pluginContainers,
-			scheme:             st.scheme,
-			catalog:            st.catalog,
-		}
-		resInfo := rst.processResource(&res)
-		retriable, resErr := resInfo.fetchError()
-		if resErr != nil {
-			if api_errors.IsConflict(errors.Cause(resErr)) {
-				// Short circuit on conflict
-				return retriable, resErr
-			}
-			logger.Error("Done processing resource", zap.Bool("ready", resInfo.isReady()), zap.Error(resErr))
-		} else {
-			logger.Info("Done processing resource", zap.Bool("ready", resInfo.isReady()))
+	if st.resourceCache != nil && st.resourceCache.resync(st.bundle.UID, st.bundle.Annotations[smith.ForceResyncAnnotation]) {
+		st.logger.Info("Force resync requested, processing all resources")
+	}
+
+	st.processedResources = make(map[smith_v1.ResourceName]*resourceInfo, len(st.bundle.Spec.Resources))
+	affected := make(map[smith_v1.ResourceName]struct{})
+
+	// Visit vertices layer by layer. Resources of a layer do not depend on each other
+	// and are processed concurrently.
+	layers := g.Layers(sorted)
+	for _, layer := range layers {
+		for _, resName := range layer {
+			st.resourceOrder = append(st.resourceOrder, resName.(smith_v1.ResourceName))
+		}
+	}
+	// Disabled resources are not in the graph. They are recorded as processed so that references
+	// to them are resolved as references to resources that are not ready.
+	for _, res := range st.bundle.Spec.Resources {
+		if res.Disabled {
+			st.processedResources[res.Name] = &resourceInfo{
+				status: resourceStatusDisabled{},
+			}
+			affected[res.Name] = struct{}{}
 		}
-		st.processedResources[resourceName] = &resInfo
 	}
-	err := st.findObjectsToDelete()
+	for _, layer := range layers {
+		toProcess := make([]smith_v1.Resource, 0, len(layer))
+		for _, resName := range layer {
+			resourceName := resName.(smith_v1.ResourceName)
+			res := resourceMap[resourceName]
+			if err, ok := missingPlugins[resourceName]; ok {
+				st.logger.Error("Resource refers to a plugin that is not registered", logz.Resource(resourceName), zap.Error(err))
+				st.processedResources[resourceName] = &resourceInfo{
+					status: resourceStatusError{
+						err: err,
+					},
+				}
+				affected[resourceName] = struct{}{}
+				continue
+			}
+			if resInfo, ok := st.waitingForRetryInfo(&res); ok {
+				st.logger.Debug("Resource is waiting for its retry delay to expire, skipping", logz.Resource(resourceName))
+				st.processedResources[resourceName] = resInfo
+				affected[resourceName] = struct{}{}
+				continue
+			}
+			if st.changedObjects != nil && !monitorOnly && !st.isAffected(&res, affected) {
+				logger := st.logger.With(logz.Resource(resourceName))
+				if resInfo, ok := st.unaffectedResourceInfo(logger, &res); ok {
+					logger.Debug("Resource is not affected by changes, skipping")
+					st.processedResources[resourceName] = resInfo
+					continue
+				}
+			}
+			if st.resourceCache != nil && !monitorOnly {
+				logger := st.logger.With(logz.Resource(resourceName))
+				if resInfo, ok := st.cachedResourceInfo(logger, &res); ok {
+					logger.Debug("Resource and its dependencies have not changed since it was ready, skipping")
+					st.processedResources[resourceName] = resInfo
+					continue
+				}
+			}
+			// Dependents of a processed resource are processed too
+			affected[resourceName] = struct{}{}
+			toProcess = append(toProcess, res)
+		}
+		results := st.syncLayer(toProcess, monitorOnly)
+		if err = st.context().Err(); err != nil {
+			// Processing was aborted, e.g. because of shutdown. Results are incomplete so status is not updated.
+			return false, err
+		}
+		// processedResources is read while resources are processed so it is only updated once
+		// the whole layer is done
+		for i, res := range toProcess {
+			result := &results[i]
+			if result.requeueAfter > 0 {
+				st.requeue(result.requeueAfter)
+			}
+			if retriable, resErr := result.info.fetchError(); resErr != nil && api_errors.IsConflict(errors.Cause(resErr)) {
+				if !st.continueOnConflict {
+					// Short circuit on conflict
+					return retriable, resErr
+				}
+				// Conflict fails the resource with a retriable error so that the Bundle is requeued once
+				// other resources have been processed. Dependents of the resource are blocked.
+				result.info.status = resourceStatusError{
+					err:              resErr,
+					isRetriableError: true,
+				}
+			}
+			st.processedResources[res.Name] = &result.info
+			if st.metrics != nil {
+				st.metrics.observeResource(st.resourceGVK(&toProcess[i]), resourceOutcome(result.info.status), result.duration)
+			}
+			if st.resourceCache != nil && !monitorOnly {
+				st.cacheResourceInputs(&toProcess[i], &result.info)
+			}
+		}
+	}
+	st.requeueIfBlocked()
+	err = st.findObjectsToDelete()
 	if err != nil {
 		return false, err
 	}
-	if st.isBundleReady() {
+	if !monitorOnly && st.isBundleReady() {
 		// Delete objects which were removed from the bundle
+		deleteSpan := startSpan(st.tracer, st.span, spanDeleteRemovedResources, nil)
 		retriable, err := st.deleteRemovedResources()
+		deleteSpan.Finish(err)
 		if err != nil {
 			return retriable, err
 		}
@@ -122,39 +341,432 @@ func (st *bundleSyncTask) processNormal() (retriableError bool, e error) {
 	return false, nil
 }
 
+// resourceSyncResult is the outcome of processing of a resource.
+type resourceSyncResult struct {
+	info         resourceInfo
+	requeueAfter time.Duration
+	// duration is how long processing of the resource took.
+	duration time.Duration
+}
+
+// syncLayer processes resources of a layer. If the Bundle limits the number of resources that are rolled out
+// at a time, resources are processed in chunks that fit into the limit, and once the limit is reached
+// changes to objects of the remaining resources are deferred. Results are returned in the order of resources.
+func (st *bundleSyncTask) syncLayer(resources []smith_v1.Resource, monitorOnly bool) []resourceSyncResult {
+	limit := int(st.bundle.Spec.MaxResourcesPerSync)
+	if limit <= 0 || monitorOnly {
+		return st.syncResources(resources, monitorOnly, false)
+	}
+	results := make([]resourceSyncResult, 0, len(resources))
+	for len(results) < len(resources) {
+		pending := resources[len(results):]
+		available := limit - st.rollingOut
+		deferChanges := available <= 0
+		if !deferChanges && available < len(pending) {
+			pending = pending[:available]
+		}
+		chunk := st.syncResources(pending, false, deferChanges)
+		for i := range chunk {
+			if isRollingOut(&chunk[i].info) {
+				st.rollingOut++
+			}
+		}
+		results = append(results, chunk...)
+	}
+	return results
+}
+
+// isRollingOut checks if the object of a processed resource was created, updated or adopted, or is in progress.
+func isRollingOut(resInfo *resourceInfo) bool {
+	switch resInfo.action {
+	case smith_v1.ResourceActionCreated, smith_v1.ResourceActionUpdated, smith_v1.ResourceActionAdopted:
+		return true
+	}
+	inProgress, ok := resInfo.status.(resourceStatusInProgress)
+	return ok && !inProgress.waitingForRollout
+}
+
+// syncResources processes resources that do not depend on each other, at most maxConcurrentResources
+// at a time. Results are returned in the order of resources.
+func (st *bundleSyncTask) syncResources(resources []smith_v1.Resource, monitorOnly, deferChanges bool) []resourceSyncResult {
+	results := make([]resourceSyncResult, len(resources))
+	runConcurrently(len(resources), st.maxConcurrentResources, func(i int) {
+		results[i] = st.syncResource(&resources[i], monitorOnly, deferChanges)
+	})
+	return results
+}
+
+// runConcurrently calls f for each index from 0 to n-1, at most limit calls at a time. Values of limit less
+// than 2 mean calls are made one by one. A panic in f is re-raised in the calling goroutine once all calls
+// are done.
+func runConcurrently(n, limit int, f func(i int)) {
+	if limit <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+	panics := make([]interface{}, n)
+	var wg wait.Group
+	slots := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		i := i
+		slots <- struct{}{}
+		wg.Start(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panics[i] = r
+				}
+				<-slots
+			}()
+			f(i)
+		})
+	}
+	wg.Wait()
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+}
+
+func (st *bundleSyncTask) syncResource(res *smith_v1.Resource, monitorOnly, deferChanges bool) resourceSyncResult {
+	logger := st.logger.With(logz.Resource(res.Name))
+	resSpan := startSpan(st.tracer, st.span, spanProcessResource, map[string]string{
+		"resource": string(res.Name),
+		"gvk":      st.resourceGVK(res).String(),
+	})
+	var result resourceSyncResult
+	startTime := time.Now()
+	specHash, err := res.SpecHash()
+	if err != nil {
+		// Hash is informational, resource can be processed without it
+		logger.Error("Failed to hash resource definition", zap.Error(err))
+	}
+	for attempt := 0; ; attempt++ {
+		rst := resourceSyncTask{
+			ctx:                      st.context(),
+			logger:                   logger,
+			smartClient:              st.smartClient,
+			rc:                       st.rc,
+			store:                    st.store,
+			specCheck:                st.specCheck,
+			bundle:                   st.bundle,
+			processedResources:       st.processedResources,
+			pluginContainers:         st.pluginContainers,
+			scheme:                   st.scheme,
+			catalog:                  st.catalog,
+			recorder:                 st.recorder,
+			schemaValidator:          st.schemaValidator,
+			monitorOnly:              monitorOnly,
+			deferChanges:             deferChanges,
+			validateObjectNamespace:  st.validateObjectNamespace,
+			allowClusterScoped:       st.allowClusterScoped,
+			oldObjectDeletionTimeout: st.oldObjectDeletionTimeout,
+			deletedObjects:           st.deletedObjects,
+			fieldManager:             st.fieldManager,
+			patchUpdates:             st.patchUpdates,
+			pluginTimeout:            st.pluginTimeout,
+			pluginTimeouts:           st.pluginTimeouts,
+			tracer:                   st.tracer,
+			span:                     resSpan,
+			// Object in the Store may be stale after a conflict
+			fetchFromServer: attempt > 0,
+		}
+		result.info = rst.processResource(res)
+		if rst.requeueAfter > 0 && (result.requeueAfter == 0 || rst.requeueAfter < result.requeueAfter) {
+			result.requeueAfter = rst.requeueAfter
+		}
+		result.info.defaultedReferences = rst.defaultedReferences
+		result.info.action = rst.action
+		result.info.specHash = specHash
+		result.info.appliedSpecHash = rst.appliedSpecHash
+		_, resErr := result.info.fetchError()
+		if resErr == nil || !api_errors.IsConflict(errors.Cause(resErr)) || attempt >= st.conflictRetries {
+			break
+		}
+		logger.Info("Conflict while processing resource, retrying", zap.Int("attempt", attempt+1), zap.Error(resErr))
+	}
+	result.duration = time.Since(startTime)
+	_, resErr := result.info.fetchError()
+	resSpan.Finish(resErr)
+	if resErr != nil {
+		if !api_errors.IsConflict(errors.Cause(resErr)) {
+			logger.Error("Done processing resource", zap.Bool("ready", result.info.isReady()), zap.Error(resErr))
+		}
+	} else {
+		logger.Info("Done processing resource", zap.Bool("ready", result.info.isReady()))
+	}
+	return result
+}
+
+// resourceOutcome returns the outcome of processing of a resource for metrics.
+func resourceOutcome(status resourceStatus) string {
+	switch status.(type) {
+	case resourceStatusReady:
+		return resourceOutcomeReady
+	case resourceStatusDependenciesNotReady:
+		return resourceOutcomeBlocked
+	case resourceStatusInProgress:
+		return resourceOutcomeInProgress
+	default:
+		return resourceOutcomeError
+	}
+}
+
+// resourceGVK returns GVK of the object of the resource. Empty GVK is returned if it cannot be determined.
+func (st *bundleSyncTask) resourceGVK(res *smith_v1.Resource) schema.GroupVersionKind {
+	return resourceGVK(res, st.pluginContainers)
+}
+
+// resourceGVK returns GVK of the object of the resource. Empty GVK is returned if it cannot be determined.
+func resourceGVK(res *smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.PluginContainer) schema.GroupVersionKind {
+	if res.Spec.Object != nil {
+		return res.Spec.Object.GetObjectKind().GroupVersionKind()
+	}
+	if res.Spec.Plugin != nil {
+		if pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]; ok {
+			return pluginContainer.Plugin.Describe().GVK
+		}
+	}
+	return schema.GroupVersionKind{}
+}
+
+// checkDuplicateObjects checks that objects of resources do not collide. Objects of the same kind are the
+// same object regardless of the version. Additional objects of plugin resources are only known once they
+// are produced, they are checked when the resource is processed.
+func (st *bundleSyncTask) checkDuplicateObjects() error {
+	type objectKey struct {
+		gk   schema.GroupKind
+		name string
+	}
+	owners := make(map[objectKey]smith_v1.ResourceName, len(st.bundle.Spec.Resources))
+	for i := range st.bundle.Spec.Resources {
+		res := &st.bundle.Spec.Resources[i]
+		if res.Disabled {
+			continue
+		}
+		key := objectKey{gk: st.resourceGVK(res).GroupKind(), name: resourceObjectName(res)}
+		if key.gk.Kind == "" || key.name == "" {
+			// Invalid resource or unknown plugin, reported when the resource is processed
+			continue
+		}
+		if owner, ok := owners[key]; ok {
+			return withErrorCode(smith_v1.ErrorCodeDuplicateResource, errors.Errorf("resources %q and %q produce the same object %s %q", owner, res.Name, key.gk, key.name))
+		}
+		owners[key] = res.Name
+	}
+	return nil
+}
+
+// checkPluginsExist checks that plugins of all plugin resources are registered. Returns errors for resources
+// that refer to unknown plugins, such resources are failed without being processed.
+func (st *bundleSyncTask) checkPluginsExist() map[smith_v1.ResourceName]error {
+	var missing map[smith_v1.ResourceName]error
+	for _, res := range st.bundle.Spec.Resources {
+		if res.Spec.Plugin == nil || res.Disabled {
+			continue
+		}
+		if _, ok := st.pluginContainers[res.Spec.Plugin.Name]; ok {
+			continue
+		}
+		if missing == nil {
+			missing = make(map[smith_v1.ResourceName]error)
+		}
+		missing[res.Name] = withErrorCode(smith_v1.ErrorCodePluginNotFound, errors.Errorf("no such plugin %q", res.Spec.Plugin.Name))
+	}
+	return missing
+}
+
+// resourceObjectName returns name of the object of the resource.
+func resourceObjectName(res *smith_v1.Resource) string {
+	if res.Spec.Object != nil {
+		return res.Spec.Object.(meta_v1.Object).GetName()
+	}
+	if res.Spec.Plugin != nil {
+		return res.Spec.Plugin.ObjectName
+	}
+	return ""
+}
+
+// isAffected checks if the resource must be processed because its object or any of its dependencies have
+// changed, or because it was not ready at the current generation of the Bundle.
+func (st *bundleSyncTask) isAffected(res *smith_v1.Resource, affected map[smith_v1.ResourceName]struct{}) bool {
+	for _, reference := range res.References {
+		if _, ok := affected[reference.Resource]; ok {
+			return true
+		}
+		if !isBlockingReference(reference) {
+			// Whether default value is used needs to be determined again
+			return true
+		}
+		if reference.Object != nil {
+			// Changes to objects outside of the Bundle are not tracked
+			return true
+		}
+	}
+	for _, dependency := range res.RunAfter {
+		if _, ok := affected[dependency]; ok {
+			return true
+		}
+	}
+	if isForceReady(st.bundle, res.Name) {
+		return true
+	}
+	if _, ok := st.changedObjects[changedObject{GroupKind: st.resourceGVK(res).GroupKind(), Name: resourceObjectName(res)}]; ok {
+		return true
+	}
+	_, resStatus := st.bundle.Status.GetResourceStatus(res.Name)
+	if resStatus == nil {
+		return true
+	}
+	_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	return readyCond == nil || readyCond.Status != smith_v1.ConditionTrue || readyCond.ObservedGeneration != st.bundle.Generation
+}
+
+// unaffectedResourceInfo returns information about the existing object of a resource that is not affected by changes.
+// Returns false if the object cannot be used as is and the resource must be processed.
+func (st *bundleSyncTask) unaffectedResourceInfo(logger *zap.Logger, res *smith_v1.Resource) (*resourceInfo, bool) {
+	rst := resourceSyncTask{
+		logger:           logger,
+		smartClient:      st.smartClient,
+		store:            st.store,
+		bundle:           st.bundle,
+		pluginContainers: st.pluginContainers,
+		scheme:           st.scheme,
+	}
+	if res.Spec.Plugin != nil {
+		// Additional objects of plugin resources are only known once the plugin has produced them
+		return nil, false
+	}
+	actual, status := rst.getActualObject(res)
+	if status != nil || actual == nil {
+		return nil, false
+	}
+	resInfo := rst.unchangedResourceInfo(st.resourceGVK(res), actual, resourceStatusReady{})
+	if !resInfo.isReady() {
+		return nil, false
+	}
+	resInfo.action = rst.action
+	return &resInfo, true
+}
+
+// checkSchedule checks if objects of the Bundle should only be monitored because the Bundle has a schedule and
+// the scheduled reconcile has completed already. The Bundle is requeued to be processed at the next scheduled time.
+func (st *bundleSyncTask) checkSchedule() (bool /*monitorOnly*/, error) {
+	if st.bundle.Spec.Schedule == nil {
+		return false, nil
+	}
+	now := time.Now()
+	prev, next, err := scheduleInstants(st.bundle.Spec.Schedule, now)
+	if err != nil {
+		return false, err
+	}
+	st.requeue(next.Sub(now))
+	if !st.bundle.Status.LastScheduledReconcileTime.Time.Before(prev) {
+		st.logger.Sugar().Debugf("Scheduled reconcile has completed, next one is at %s", next)
+		return true, nil
+	}
+	st.scheduledReconcile = true
+	return false, nil
+}
+
 // Process the bundle marked with DeletionTimestamp
 // TODO: remove this method after https://github.com/kubernetes/kubernetes/issues/59850 is fixed
 func (st *bundleSyncTask) processDeleted() (retriableError bool, e error) {
 	if hasDeleteResourcesFinalizer(st.bundle) {
+		// External systems may need objects for their cleanup so objects are kept until finalizers
+		// requested in the spec are removed. Removal of a finalizer triggers processing of the Bundle.
+		if pending := pendingExternalFinalizers(st.bundle); len(pending) > 0 {
+			st.logger.Sugar().Infof("Waiting for finalizers %q to be removed", pending)
+			st.setDeletingCondition(smith_v1.BundleReasonWaitingForFinalizers, fmt.Sprintf("Waiting for finalizers %q to be removed", pending), -1)
+			return false, nil
+		}
 		if !resources.HasFinalizer(st.bundle, meta_v1.FinalizerDeleteDependents) {
+			// Keep the finalizer and the resources until protection is removed. Protection is an expected state,
+			// not an error. Removal of the annotation triggers processing of the Bundle.
+			if st.bundle.Annotations[smith.DeletionProtectionAnnotation] == "true" {
+				if _, cond := st.bundle.GetCondition(smith_v1.BundleDeleting); cond == nil || cond.Reason != smith_v1.BundleReasonDeletionProtected {
+					// Only emitted when the Bundle becomes protected, not on every iteration
+					st.recorder.Eventf(st.bundle, core_v1.EventTypeWarning, EventReasonDeletionProtected,
+						"Resources are not deleted because Bundle has %s annotation", smith.DeletionProtectionAnnotation)
+				}
+				st.logger.Info("Bundle is protected from deletion, keeping resources")
+				st.setDeletingCondition(smith_v1.BundleReasonDeletionProtected, fmt.Sprintf("Objects are not deleted because Bundle has %s annotation", smith.DeletionProtectionAnnotation), -1)
+				return false, nil
+			}
 			// If "foregroundDeletion" finalizer was not set, perform manual cascade deletion
-			retrieable, err := st.deleteAllResources()
+			deleteSpan := startSpan(st.tracer, st.span, spanDeleteAllResources, nil)
+			remaining, retrieable, err := st.deleteAllResources()
+			deleteSpan.Finish(err)
 			if err != nil {
 				return retrieable, err
 			}
+			if st.deletionBatchSize > 0 && remaining > 0 {
+				// Keep the finalizer until all objects are gone
+				st.logger.Sugar().Infof("Waiting for %d object(s) to be deleted", remaining)
+				st.setDeletingCondition(smith_v1.BundleReasonDeletingObjects, "Objects are being deleted", remaining)
+				st.requeue(deletionBatchRequeueDelay)
+				return false, nil
+			}
 		}
 
 		// If the "foregroundDeletion" finalizer is set, or the manual deletion
 		// of resources has succeeded, remove the "deleteResources" finalizer
 		st.newFinalizers = removeDeleteResourcesFinalizer(st.bundle.GetFinalizers())
 	}
+	if resources.HasFinalizer(st.bundle, meta_v1.FinalizerDeleteDependents) {
+		// Garbage collector deletes objects before it removes the Bundle
+		st.setDeletingCondition(smith_v1.BundleReasonDeletingObjects, "Objects are being deleted by garbage collector", -1)
+	}
 	return false, nil
 }
 
-func (st *bundleSyncTask) deleteAllResources() (retriableError bool, e error) {
+// setDeletingCondition records why the Bundle that is being deleted still exists. The number of objects controlled
+// by the Bundle that still exist is appended to the message. It is looked up in the Store if it is negative.
+func (st *bundleSyncTask) setDeletingCondition(reason, message string, remaining int) {
+	if remaining < 0 {
+		objs, err := st.store.ObjectsControlledBy(st.bundle.Namespace, st.bundle.UID)
+		if err != nil {
+			st.logger.Error("Failed to count remaining objects", zap.Error(err))
+			return
+		}
+		remaining = len(objs)
+	}
+	st.deletingCond = &smith_v1.BundleCondition{
+		Type:    smith_v1.BundleDeleting,
+		Status:  smith_v1.ConditionTrue,
+		Reason:  reason,
+		Message: fmt.Sprintf("%s, %d object(s) remaining", message, remaining),
+	}
+}
+
+// deleteAllResources deletes objects controlled by the Bundle. If deletionBatchSize is set, at most that many
+// objects are deleted. Returns the number of objects that still exist, excluding orphaned objects.
+func (st *bundleSyncTask) deleteAllResources() (remaining int, retriableError bool, e error) {
 	objs, err := st.store.ObjectsControlledBy(st.bundle.Namespace, st.bundle.UID)
 	if err != nil {
-		return false, err
+		return 0, false, err
 	}
 	st.objectsToDelete = make(map[objectRef]runtime.Object, len(objs))
 
 	var firstErr error
 	retriable := true
-	policy := meta_v1.DeletePropagationForeground
+	var deletions []objectDeletion
+	remaining = len(objs)
 	for _, obj := range objs {
 		m := obj.(meta_v1.Object)
-		gvk := obj.GetObjectKind().GroupVersionKind()
 		name := m.GetName()
+		gvk, err := util.ObjectGVK(st.scheme, obj)
+		if err != nil {
+			if firstErr == nil {
+				retriable = false
+				firstErr = err
+			} else {
+				st.logger.Error("Failed to determine GVK of object", ctrlLogz.ObjectName(name), zap.Error(err))
+			}
+			continue
+		}
 		ref := objectRef{
 			GroupVersionKind: gvk,
 			Name:             name,
@@ -166,38 +778,24 @@ func (st *bundleSyncTask) deleteAllResources() (retriableError bool, e error) {
 			logger.Debug("Object is marked for deletion already")
 			continue
 		}
-		uid := m.GetUID()
-
-		logger.Info("Deleting object")
-		resClient, err := st.smartClient.ForGVK(gvk, st.bundle.Namespace)
-		if err != nil {
-			if firstErr == nil {
-				retriable = false
-				firstErr = err
-			} else {
-				logger.Error("Failed to get client for object", zap.Error(err))
-			}
-			continue
+		if st.deletionBatchSize > 0 && len(deletions) >= st.deletionBatchSize {
+			continue // Deleted in a subsequent batch
 		}
-
-		err = resClient.Delete(name, &meta_v1.DeleteOptions{
-			Preconditions: &meta_v1.Preconditions{
-				UID: &uid,
-			},
-			PropagationPolicy: &policy,
+		deletions = append(deletions, objectDeletion{
+			ref:    ref,
+			obj:    m,
+			logger: logger,
+			force:  st.forceDeletion,
 		})
-		if err != nil && !api_errors.IsNotFound(err) && !api_errors.IsConflict(err) {
-			// not found means object has been deleted already
-			// conflict means it has been deleted and re-created (UID does not match)
-			if firstErr == nil {
-				firstErr = err
-			} else {
-				logger.Warn("Failed to delete object", zap.Error(err))
-			}
-			continue
+	}
+	st.deleteObjects(deletions)
+	for _, d := range deletions {
+		if d.orphaned {
+			remaining--
 		}
 	}
-	return retriable, firstErr
+	collectDeletionErrors(deletions, &firstErr, &retriable)
+	return remaining, retriable, firstErr
 }
 
 // findObjectsToDelete initializes objectsToDelete field with objects that have controller owner references to
@@ -207,80 +805,576 @@ func (st *bundleSyncTask) findObjectsToDelete() error {
 	if err != nil {
 		return err
 	}
+	// Additional objects that ready plugin resources have produced are kept. Additional objects of plugin resources
+	// that are not ready are not known for certain, all objects produced by such resources earlier are kept.
+	type objectKey struct {
+		gk   schema.GroupKind
+		name string
+	}
+	additionalObjects := make(map[objectKey]struct{})
+	unsettledPluginResources := make(map[string]struct{})
+	for _, res := range st.bundle.Spec.Resources {
+		if res.Spec.Plugin == nil || res.Disabled {
+			continue
+		}
+		resInfo := st.processedResources[res.Name]
+		if resInfo == nil || !resInfo.isReady() {
+			unsettledPluginResources[string(res.Name)] = struct{}{}
+			continue
+		}
+		for _, additional := range resInfo.additional {
+			additionalObjects[objectKey{gk: additional.GroupVersionKind().GroupKind(), name: additional.GetName()}] = struct{}{}
+		}
+	}
 	st.objectsToDelete = make(map[objectRef]runtime.Object, len(objs))
 	for _, obj := range objs {
 		m := obj.(meta_v1.Object)
+		if resName, ok := m.GetAnnotations()[smith.PluginResourceAnnotation]; ok {
+			if _, ok = unsettledPluginResources[resName]; ok {
+				continue
+			}
+		}
+		gvk, err := util.ObjectGVK(st.scheme, obj)
+		if err != nil {
+			return err
+		}
+		if _, ok := additionalObjects[objectKey{gk: gvk.GroupKind(), name: m.GetName()}]; ok {
+			continue
+		}
 		ref := objectRef{
-			GroupVersionKind: obj.GetObjectKind().GroupVersionKind(),
+			GroupVersionKind: gvk,
 			Name:             m.GetName(),
 		}
 		st.objectsToDelete[ref] = obj
 	}
-	for _, res := range st.bundle.Spec.Resources {
-		var gvk schema.GroupVersionKind
-		var name string
-		if res.Spec.Object != nil {
-			gvk = res.Spec.Object.GetObjectKind().GroupVersionKind()
-			name = res.Spec.Object.(meta_v1.Object).GetName()
-		} else if res.Spec.Plugin != nil {
-			gvk = st.pluginContainers[res.Spec.Plugin.Name].Plugin.Describe().GVK
-			name = res.Spec.Plugin.ObjectName
+	for i := range st.bundle.Spec.Resources {
+		res := &st.bundle.Spec.Resources[i]
+		if res.Disabled {
+			// Objects of disabled resources are deleted
+			continue
+		}
+		if ref, ok := st.resourceObjectRef(res); ok {
+			delete(st.objectsToDelete, ref)
+		}
+	}
+	return nil
+}
+
+// resourceObjectRef returns a reference to the object of the resource. Returns false if the object kind cannot
+// be determined.
+func (st *bundleSyncTask) resourceObjectRef(res *smith_v1.Resource) (objectRef, bool) {
+	var gvk schema.GroupVersionKind
+	var name string
+	if res.Spec.Object != nil {
+		gvk = res.Spec.Object.GetObjectKind().GroupVersionKind()
+		name = res.Spec.Object.(meta_v1.Object).GetName()
+	} else if res.Spec.Plugin != nil {
+		pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
+		if !ok {
+			// Unknown plugin, object kind cannot be determined. The resource is failed so the Bundle is
+			// not ready and removed objects are not deleted.
+			return objectRef{}, false
+		}
+		gvk = pluginContainer.Plugin.Describe().GVK
+		name = res.Spec.Plugin.ObjectName
+	} else {
+		// neither "object" nor "plugin" field is specified. This shouldn't really happen (schema), but we
+		// ignore the error and continue collecting objects. Even if not caught by the schema, this error
+		// must have been reported earlier while processing this resource.
+		return objectRef{}, false
+	}
+	return objectRef{
+		GroupVersionKind: gvk,
+		Name:             name,
+	}, true
+}
+
+// skipUndeletableObject reports an object that cannot be deleted because a client for its GVK cannot be obtained
+// (e.g. the CRD was removed before its instances). Returns true if the object should be left orphaned
+// according to the policy.
+func (st *bundleSyncTask) skipUndeletableObject(logger *zap.Logger, ref objectRef, err error) bool {
+	if st.unresolvableGvkPolicy != UnresolvableGvkPolicySkip {
+		return false
+	}
+	logger.Warn("Leaving object orphaned because a client for it cannot be obtained", zap.Error(err))
+	st.recorder.Eventf(st.bundle, core_v1.EventTypeWarning, EventReasonObjectOrphaned,
+		"Leaving %s %q orphaned because a client for it cannot be obtained: %v", ref.Kind, ref.Name, err)
+	return true
+}
+
+func (st *bundleSyncTask) deleteRemovedResources() (retriableError bool, e error) {
+	if st.bundle.Spec.ReclaimPolicy == smith_v1.ReclaimPolicyRetain {
+		return st.orphanRemovedResources()
+	}
+	var firstErr error
+	var inUse []string
+	retriable := true
+	var inSpec resourceObjects
+	var dependents map[smith_v1.ResourceName][]smith_v1.ResourceName
+	if st.blockInUseDeletion {
+		inSpec = st.objectsToDeleteOfResources()
+		dependents = st.resourceDependents()
+	}
+	for _, layer := range st.objectsToDeleteInLayers() {
+		deletions := make([]objectDeletion, 0, len(layer))
+		for _, ref := range layer {
+			m := st.objectsToDelete[ref].(meta_v1.Object)
+			logger := st.logger.With(ctrlLogz.ObjectGk(ref.GroupVersionKind.GroupKind()), ctrlLogz.ObjectName(ref.Name))
+			if m.GetDeletionTimestamp() != nil {
+				logger.Debug("Object is marked for deletion already")
+				continue
+			}
+			if st.blockInUseDeletion {
+				var dependentsInSpec []smith_v1.ResourceName
+				if resName, ok := inSpec.resources[ref]; ok {
+					dependentsInSpec = dependents[resName]
+				}
+				if referencedBy := st.resourcesReferencingObject(m.GetUID(), dependentsInSpec); len(referencedBy) > 0 {
+					logger.Sugar().Warnf("Not deleting object because it is still referenced by resource(s) %q", referencedBy)
+					inUse = append(inUse, fmt.Sprintf("%s %q is referenced by resource(s) %q", ref.Kind, ref.Name, referencedBy))
+					continue
+				}
+			}
+			deletions = append(deletions, objectDeletion{
+				ref:    ref,
+				obj:    m,
+				logger: logger,
+			})
+		}
+		st.deleteObjects(deletions)
+		collectDeletionErrors(deletions, &firstErr, &retriable)
+	}
+	if firstErr == nil && len(inUse) > 0 {
+		// Re-processing will be triggered once objects stop referencing the objects being deleted
+		sort.Strings(inUse)
+		return true, withErrorCode(smith_v1.ErrorCodeDeletionBlocked, errors.Errorf("deletion of removed objects is blocked because they are still in use: %s", strings.Join(inUse, "; ")))
+	}
+	return retriable, firstErr
+}
+
+// orphanRemovedResources removes the controller owner reference to the Bundle from objects which were removed
+// from the Bundle so that they are kept but are no longer managed by it.
+func (st *bundleSyncTask) orphanRemovedResources() (retriableError bool, e error) {
+	var firstErr error
+	for ref, obj := range st.objectsToDelete {
+		logger := st.logger.With(ctrlLogz.ObjectGk(ref.GroupVersionKind.GroupKind()), ctrlLogz.ObjectName(ref.Name))
+		if obj.(meta_v1.Object).GetDeletionTimestamp() != nil {
+			logger.Debug("Object is marked for deletion already")
+			continue
+		}
+		if err := st.orphanObject(logger, ref, obj); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			} else {
+				logger.Warn("Failed to orphan object", zap.Error(err))
+			}
+		}
+	}
+	return true, firstErr
+}
+
+func (st *bundleSyncTask) orphanObject(logger *zap.Logger, ref objectRef, obj runtime.Object) error {
+	logger.Info("Orphaning object because reclaim policy is Retain")
+	u, err := util.RuntimeToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	u.SetGroupVersionKind(ref.GroupVersionKind)
+	ownerRefs := u.GetOwnerReferences()
+	newOwnerRefs := make([]meta_v1.OwnerReference, 0, len(ownerRefs))
+	for _, ownerRef := range ownerRefs {
+		if ownerRef.UID != st.bundle.UID {
+			newOwnerRefs = append(newOwnerRefs, ownerRef)
+		}
+	}
+	u.SetOwnerReferences(newOwnerRefs)
+	resClient, err := writeClient(st.smartClient, ref.GroupVersionKind, u.GetNamespace(), st.fieldManager)
+	if err != nil {
+		return err
+	}
+	_, err = resClient.Update(u)
+	if err != nil {
+		if api_errors.IsNotFound(err) {
+			// Object has been deleted already
+			return nil
+		}
+		return errors.Wrapf(err, "failed to orphan %s %q", ref.Kind, ref.Name)
+	}
+	st.recorder.Eventf(st.bundle, core_v1.EventTypeNormal, EventReasonObjectOrphaned,
+		"Left %s %q orphaned because reclaim policy is %s", ref.Kind, ref.Name, smith_v1.ReclaimPolicyRetain)
+	return nil
+}
+
+// objectsToDeleteInLayers returns references to objects to delete split into layers so that dependents come
+// before their dependencies. Objects of the same layer do not depend on each other and can be deleted
+// concurrently. Objects of resources that are still in the Bundle (e.g. disabled resources) are ordered by
+// references and runAfter dependencies between the resources, in reverse of the order they are created in.
+// Objects of removed resources are not in the Bundle anymore so dependencies between them are taken from
+// owner references that objects have to objects they reference. Objects are otherwise ordered by kind and name.
+func (st *bundleSyncTask) objectsToDeleteInLayers() [][]objectRef {
+	refs := make([]objectRef, 0, len(st.objectsToDelete))
+	uid2ref := make(map[types.UID]objectRef, len(st.objectsToDelete))
+	for ref, obj := range st.objectsToDelete {
+		refs = append(refs, ref)
+		uid2ref[obj.(meta_v1.Object).GetUID()] = ref
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	g := graph.NewGraph(len(refs))
+	for _, ref := range refs {
+		g.AddVertex(ref, nil)
+	}
+	// Edges point at objects that must be deleted first
+	addEdge := func(dependency, dependent objectRef) {
+		if err := g.AddEdge(dependency, dependent); err != nil {
+			// Cannot happen, both vertices are in the graph
+			st.logger.Error("Failed to add edge", zap.Error(err))
+		}
+	}
+	inSpec := st.objectsToDeleteOfResources()
+	for _, ref := range refs {
+		if _, ok := inSpec.resources[ref]; ok {
+			continue
+		}
+		for _, ownerRef := range st.objectsToDelete[ref].(meta_v1.Object).GetOwnerReferences() {
+			dependency, ok := uid2ref[ownerRef.UID]
+			if !ok || dependency == ref {
+				continue
+			}
+			if _, ok = inSpec.resources[dependency]; ok {
+				continue
+			}
+			addEdge(dependency, ref)
+		}
+	}
+	resources, err := expandReferences(st.bundle.Spec.Resources, st.pluginContainers)
+	if err != nil {
+		// Invalid references fail processing of the Bundle before objects are deleted
+		st.logger.Warn("Failed to order objects of resources to delete by dependencies", zap.Error(err))
+		resources = nil
+	}
+	for _, res := range resources {
+		dependents := inSpec.objects[res.Name]
+		if len(dependents) == 0 {
+			continue
+		}
+		dependencies := make([]smith_v1.ResourceName, 0, len(res.References)+len(res.RunAfter))
+		for _, reference := range res.References {
+			if reference.Object == nil {
+				dependencies = append(dependencies, reference.Resource)
+			}
+		}
+		dependencies = append(dependencies, res.RunAfter...)
+		for _, dependency := range dependencies {
+			if dependency == res.Name {
+				continue
+			}
+			for _, dependencyRef := range inSpec.objects[dependency] {
+				for _, dependentRef := range dependents {
+					addEdge(dependencyRef, dependentRef)
+				}
+			}
+		}
+	}
+	sorted, err := g.TopologicalSort()
+	if err != nil {
+		// Owner references form a cycle, fall back to deleting objects one by one in the order by kind and name
+		st.logger.Warn("Failed to order objects to delete by dependencies", zap.Error(err))
+		layers := make([][]objectRef, 0, len(refs))
+		for _, ref := range refs {
+			layers = append(layers, []objectRef{ref})
+		}
+		return layers
+	}
+	vertexLayers := g.Layers(sorted)
+	layers := make([][]objectRef, 0, len(vertexLayers))
+	for _, vertexLayer := range vertexLayers {
+		layer := make([]objectRef, 0, len(vertexLayer))
+		for _, v := range vertexLayer {
+			layer = append(layer, v.(objectRef))
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// resourceObjects maps objects to delete to resources of the Bundle they belong to and back.
+type resourceObjects struct {
+	resources map[objectRef]smith_v1.ResourceName
+	objects   map[smith_v1.ResourceName][]objectRef
+}
+
+// objectsToDeleteOfResources finds objects to delete that belong to resources which are still in the Bundle.
+// Additional objects of plugin resources are found by the annotation that names the resource.
+func (st *bundleSyncTask) objectsToDeleteOfResources() resourceObjects {
+	ro := resourceObjects{
+		resources: make(map[objectRef]smith_v1.ResourceName),
+		objects:   make(map[smith_v1.ResourceName][]objectRef),
+	}
+	add := func(ref objectRef, resName smith_v1.ResourceName) {
+		if _, ok := ro.resources[ref]; ok {
+			return
+		}
+		ro.resources[ref] = resName
+		ro.objects[resName] = append(ro.objects[resName], ref)
+	}
+	inBundle := make(map[smith_v1.ResourceName]struct{}, len(st.bundle.Spec.Resources))
+	for i := range st.bundle.Spec.Resources {
+		res := &st.bundle.Spec.Resources[i]
+		inBundle[res.Name] = struct{}{}
+		ref, ok := st.resourceObjectRef(res)
+		if !ok {
+			continue
+		}
+		if _, ok = st.objectsToDelete[ref]; ok {
+			add(ref, res.Name)
+		}
+	}
+	for ref, obj := range st.objectsToDelete {
+		resName, ok := obj.(meta_v1.Object).GetAnnotations()[smith.PluginResourceAnnotation]
+		if !ok {
+			continue
+		}
+		if _, ok = inBundle[smith_v1.ResourceName(resName)]; ok {
+			add(ref, smith_v1.ResourceName(resName))
+		}
+	}
+	// Objects of a resource are recorded in a deterministic order
+	for _, refs := range ro.objects {
+		sort.Slice(refs, func(i, j int) bool {
+			return refs[i].Kind < refs[j].Kind || refs[i].Kind == refs[j].Kind && refs[i].Name < refs[j].Name
+		})
+	}
+	return ro
+}
+
+// objectDeletion is a deletion of an object and its outcome.
+type objectDeletion struct {
+	ref    objectRef
+	obj    meta_v1.Object
+	logger *zap.Logger
+	// force makes the deletion retry against the current UID if the object was re-created and
+	// is controlled by the Bundle.
+	force bool
+
+	// orphaned is true if the object was left orphaned because a client for it cannot be obtained.
+	orphaned bool
+	// clientErr is true if err is a failure to get a client for the object. Such errors are not retriable.
+	clientErr bool
+	err       error
+}
+
+// deleteObjects deletes objects, at most maxConcurrentDeletions at a time. Outcomes are recorded in deletions.
+func (st *bundleSyncTask) deleteObjects(deletions []objectDeletion) {
+	runConcurrently(len(deletions), st.maxConcurrentDeletions, func(i int) {
+		st.deleteObject(&deletions[i])
+	})
+}
+
+func (st *bundleSyncTask) deleteObject(d *objectDeletion) {
+	d.logger.Info("Deleting object")
+	// Cluster-scoped objects do not have a namespace
+	namespace := d.obj.GetNamespace()
+	resClient, err := writeClient(st.smartClient, d.ref.GroupVersionKind, namespace, st.fieldManager)
+	if err != nil {
+		if st.skipUndeletableObject(d.logger, d.ref, err) {
+			d.orphaned = true
+			return
+		}
+		d.clientErr = true
+		d.err = err
+		return
+	}
+
+	uid := d.obj.GetUID()
+	policy := st.deletePolicy(d.ref.GroupVersionKind.GroupKind(), d.obj)
+	err = resClient.Delete(d.ref.Name, &meta_v1.DeleteOptions{
+		Preconditions: &meta_v1.Preconditions{
+			UID: &uid,
+		},
+		PropagationPolicy:  &policy,
+		GracePeriodSeconds: st.deleteGracePeriod(d.ref.GroupVersionKind.GroupKind(), d.obj),
+	})
+	if err != nil && api_errors.IsConflict(err) && d.force {
+		uid, err = st.forceDeleteObject(d, resClient, policy)
+	}
+	if err != nil && !api_errors.IsNotFound(err) && !api_errors.IsConflict(err) {
+		// not found means object has been deleted already
+		// conflict means it has been deleted and re-created (UID does not match)
+		d.err = err
+		return
+	}
+	if err == nil && st.deletedObjects != nil {
+		st.deletedObjects.add(namespace, d.ref.GroupVersionKind.GroupKind(), d.ref.Name, uid)
+	}
+}
+
+// forceDeleteObject deletes the object that was deleted and re-created out-of-band, if it is controlled by the
+// Bundle. Returns the UID of the deleted object. Not found error is returned if the object is gone or is not
+// controlled by the Bundle.
+func (st *bundleSyncTask) forceDeleteObject(d *objectDeletion, resClient dynamic.ResourceInterface, policy meta_v1.DeletionPropagation) (types.UID, error) {
+	actual, err := resClient.Get(d.ref.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if !meta_v1.IsControlledBy(actual, st.bundle) {
+		d.logger.Info("Object was re-created and is not controlled by the Bundle, skipping deletion")
+		return "", api_errors.NewNotFound(schema.GroupResource{Group: d.ref.Group, Resource: d.ref.Kind}, d.ref.Name)
+	}
+	if actual.GetDeletionTimestamp() != nil {
+		d.logger.Debug("Re-created object is marked for deletion already")
+		return "", api_errors.NewNotFound(schema.GroupResource{Group: d.ref.Group, Resource: d.ref.Kind}, d.ref.Name)
+	}
+	uid := actual.GetUID()
+	d.logger.Info("Object was re-created, deleting it", zap.String("uid", string(uid)))
+	return uid, resClient.Delete(d.ref.Name, &meta_v1.DeleteOptions{
+		Preconditions: &meta_v1.Preconditions{
+			UID: &uid,
+		},
+		PropagationPolicy:  &policy,
+		GracePeriodSeconds: st.deleteGracePeriod(d.ref.GroupVersionKind.GroupKind(), actual),
+	})
+}
+
+// collectDeletionErrors stores the first error of deletions into firstErr unless it is set already.
+// Errors getting a client make the error non-retriable. Other errors are logged.
+func collectDeletionErrors(deletions []objectDeletion, firstErr *error, retriable *bool) {
+	for _, d := range deletions {
+		if d.err == nil {
+			continue
+		}
+		if *firstErr == nil {
+			if d.clientErr {
+				*retriable = false
+			}
+			*firstErr = d.err
+		} else if d.clientErr {
+			d.logger.Error("Failed to get client for object", zap.Error(d.err))
 		} else {
-			// neither "object" nor "plugin" field is specified. This shouldn't really happen (schema), but we
-			// ignore the error and continue collecting objects. Even if not caught by the schema, this error
-			// must have been reported earlier while processing this resource.
+			d.logger.Warn("Failed to delete object", zap.Error(d.err))
+		}
+	}
+}
+
+// deletePolicy returns the propagation policy to delete the object with. Policy of the resource that defines
+// the object takes precedence over the policy recorded on the object, which is used for objects of removed
+// resources. Foreground policy is used if neither is set.
+func (st *bundleSyncTask) deletePolicy(gk schema.GroupKind, obj meta_v1.Object) meta_v1.DeletionPropagation {
+	for _, res := range st.bundle.Spec.Resources {
+		if res.DeletePolicy != "" && isValidDeletePolicy(res.DeletePolicy) &&
+			st.resourceGVK(&res).GroupKind() == gk && resourceObjectName(&res) == obj.GetName() {
+			return res.DeletePolicy
+		}
+	}
+	if policy := meta_v1.DeletionPropagation(obj.GetAnnotations()[smith.DeletePolicyAnnotation]); isValidDeletePolicy(policy) {
+		return policy
+	}
+	return meta_v1.DeletePropagationForeground
+}
+
+// deleteGracePeriod returns the grace period to delete the object with. Grace period of the resource that defines
+// the object takes precedence over the grace period recorded on the object, which is used for objects of removed
+// resources. Nil is returned if neither is set, default grace period of the object's kind is used then.
+func (st *bundleSyncTask) deleteGracePeriod(gk schema.GroupKind, obj meta_v1.Object) *int64 {
+	for _, res := range st.bundle.Spec.Resources {
+		if res.DeleteGracePeriodSeconds != nil && *res.DeleteGracePeriodSeconds >= 0 &&
+			st.resourceGVK(&res).GroupKind() == gk && resourceObjectName(&res) == obj.GetName() {
+			gracePeriod := *res.DeleteGracePeriodSeconds
+			return &gracePeriod
+		}
+	}
+	if value, ok := obj.GetAnnotations()[smith.DeleteGracePeriodAnnotation]; ok {
+		if gracePeriod, err := strconv.ParseInt(value, 10, 64); err == nil && gracePeriod >= 0 {
+			return &gracePeriod
+		}
+	}
+	return nil
+}
+
+func isValidDeletePolicy(policy meta_v1.DeletionPropagation) bool {
+	switch policy {
+	case meta_v1.DeletePropagationForeground, meta_v1.DeletePropagationBackground, meta_v1.DeletePropagationOrphan:
+		return true
+	default:
+		return false
+	}
+}
+
+func isValidUpdateStrategy(strategy smith_v1.UpdateStrategy) bool {
+	switch strategy {
+	case "", smith_v1.UpdateStrategyUpdate, smith_v1.UpdateStrategyCreateOnly, smith_v1.UpdateStrategyRecreate, smith_v1.UpdateStrategyPatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// resourcesReferencingObject returns names of processed resources whose objects have an owner reference
+// to the object with the given UID, together with dependents which are resources that still depend on
+// the resource of the object in the Bundle (e.g. on a disabled resource).
+func (st *bundleSyncTask) resourcesReferencingObject(uid types.UID, dependents []smith_v1.ResourceName) []smith_v1.ResourceName {
+	isDependent := make(map[smith_v1.ResourceName]struct{}, len(dependents))
+	for _, dependent := range dependents {
+		isDependent[dependent] = struct{}{}
+	}
+	var names []smith_v1.ResourceName
+	for _, res := range st.bundle.Spec.Resources { // Deterministic iteration order
+		if _, ok := isDependent[res.Name]; ok {
+			names = append(names, res.Name)
 			continue
 		}
-		delete(st.objectsToDelete, objectRef{
-			GroupVersionKind: gvk,
-			Name:             name,
-		})
+		resInfo := st.processedResources[res.Name]
+		if resInfo == nil || resInfo.actual == nil {
+			continue
+		}
+		for _, ownerRef := range resInfo.actual.GetOwnerReferences() {
+			if ownerRef.UID == uid {
+				names = append(names, res.Name)
+				break
+			}
+		}
 	}
-	return nil
+	return names
 }
 
-func (st *bundleSyncTask) deleteRemovedResources() (retriableError bool, e error) {
-	var firstErr error
-	retriable := true
-	policy := meta_v1.DeletePropagationForeground
-	for ref, obj := range st.objectsToDelete {
-		logger := st.logger.With(ctrlLogz.ObjectGk(ref.GroupVersionKind.GroupKind()), ctrlLogz.ObjectName(ref.Name))
-		m := obj.(meta_v1.Object)
-		if m.GetDeletionTimestamp() != nil {
-			logger.Debug("Object is marked for deletion already")
+// resourceDependents returns names of enabled resources that reference resources or run after them,
+// by the names of the resources they depend on.
+func (st *bundleSyncTask) resourceDependents() map[smith_v1.ResourceName][]smith_v1.ResourceName {
+	resources, err := expandReferences(st.bundle.Spec.Resources, st.pluginContainers)
+	if err != nil {
+		// Invalid references fail processing of the Bundle before objects are deleted. References by name
+		// can still be used.
+		st.logger.Warn("Failed to expand references of resources", zap.Error(err))
+		resources = st.bundle.Spec.Resources
+	}
+	dependents := make(map[smith_v1.ResourceName][]smith_v1.ResourceName)
+	for _, res := range resources {
+		if res.Disabled {
 			continue
 		}
-		logger.Info("Deleting object")
-		resClient, err := st.smartClient.ForGVK(ref.GroupVersionKind, st.bundle.Namespace)
-		if err != nil {
-			if firstErr == nil {
-				retriable = false
-				firstErr = err
-			} else {
-				logger.Error("Failed to get client for object", zap.Error(err))
+		dependencies := make(map[smith_v1.ResourceName]struct{}, len(res.References)+len(res.RunAfter))
+		for _, reference := range res.References {
+			if reference.Object == nil && reference.Resource != "" {
+				dependencies[reference.Resource] = struct{}{}
 			}
-			continue
 		}
-
-		uid := m.GetUID()
-		err = resClient.Delete(ref.Name, &meta_v1.DeleteOptions{
-			Preconditions: &meta_v1.Preconditions{
-				UID: &uid,
-			},
-			PropagationPolicy: &policy,
-		})
-		if err != nil && !api_errors.IsNotFound(err) && !api_errors.IsConflict(err) {
-			// not found means object has been deleted already
-			// conflict means it has been deleted and re-created (UID does not match)
-			if firstErr == nil {
-				firstErr = err
-			} else {
-				logger.Warn("Failed to delete object", zap.Error(err))
-			}
-			continue
+		for _, dependency := range res.RunAfter {
+			dependencies[dependency] = struct{}{}
+		}
+		for dependency := range dependencies {
+			dependents[dependency] = append(dependents[dependency], res.Name)
 		}
 	}
-	return retriable, firstErr
+	return dependents
 }
 
 func (st *bundleSyncTask) updateBundle() error {
@@ -302,6 +1396,10 @@ func (st *bundleSyncTask) handleProcessResult(retriable bool, processErr error)
 	}
 
 	bundleUpdated := false
+	var completion *Completion
+	// retryDelay is the backoff delay after which failed resources should be retried. Zero if not set.
+	var retryDelay time.Duration
+	var events []resourceEvent
 
 	if st.newFinalizers != nil {
 		// Update finalizers
@@ -323,32 +1421,113 @@ func (st *bundleSyncTask) handleProcessResult(retriable bool, processErr error)
 			st.logger.Error("Error updating ObjectsToDelete status field", zap.Error(err))
 		}
 		bundleUpdated = true
+	} else if st.paused {
+		bundleUpdated = st.updatePausedCondition()
 	} else if st.bundle.DeletionTimestamp == nil {
 		// Construct resource conditions and check if there were any resource errors
 		resourceStatuses := make([]smith_v1.ResourceStatus, 0, len(st.processedResources))
 		var failedResources []smith_v1.ResourceName
 		retriableResourceErr := true
+		// nextRetryTime is the earliest time a resource that failed with a retriable error should be retried at
+		var nextRetryTime time.Time
+		// transitions describes resource conditions that have changed status during this iteration
+		var transitions []string
 		for _, res := range st.bundle.Spec.Resources { // Deterministic iteration order
+			if res.Disabled {
+				// Disabled resources have no status
+				continue
+			}
 			blockedCond, inProgressCond, readyCond, errorCond := st.resourceConditions(res)
+			progressStartTime := st.checkProgressDeadline(res, &inProgressCond, &readyCond, &errorCond)
+			retryCount, resNextRetryTime := st.retryBackoff(res, errorCond)
+			if errorCond.Status == smith_v1.ConditionTrue && errorCond.Reason == smith_v1.ResourceReasonRetriableError &&
+				resNextRetryTime != nil && (nextRetryTime.IsZero() || resNextRetryTime.Time.Before(nextRetryTime)) {
+				nextRetryTime = resNextRetryTime.Time
+			}
 
 			if errorCond.Status == smith_v1.ConditionTrue {
 				failedResources = append(failedResources, res.Name)
 				retriableResourceErr = retriableResourceErr && errorCond.Reason == smith_v1.ResourceReasonRetriableError // Must not continue if at least one error is not retriable
+			} else if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+				// Held error does not fail the resource, it is only reflected in the condition
+				if _, oldErrorCond := oldStatus.GetCondition(smith_v1.ResourceError); oldErrorCond != nil {
+					if message, hold := st.holdError(oldErrorCond.Status, oldErrorCond.Message); hold {
+						errorCond.Status = smith_v1.ConditionTrue
+						errorCond.Reason = oldErrorCond.Reason
+						errorCond.Message = message
+						errorCond.Code = oldErrorCond.Code
+						if readyCond.Status == smith_v1.ConditionTrue {
+							// Resource is not reported as ready while it is reported as failed
+							readyCond.Status = smith_v1.ConditionFalse
+							readyCond.Reason = smith_v1.ResourceReasonErrorHeld
+							readyCond.Message = "Object is ready, waiting for the held Error condition to expire"
+						}
+					}
+				}
 			}
 
-			bundleUpdated = updateResourceCondition(st.bundle, res.Name, &blockedCond) || bundleUpdated
-			bundleUpdated = updateResourceCondition(st.bundle, res.Name, &inProgressCond) || bundleUpdated
-			bundleUpdated = updateResourceCondition(st.bundle, res.Name, &readyCond) || bundleUpdated
-			bundleUpdated = updateResourceCondition(st.bundle, res.Name, &errorCond) || bundleUpdated
+			_, oldStatus := st.bundle.Status.GetResourceStatus(res.Name)
+			events = append(events, resourceTransitionEvents(res.Name, oldStatus, &blockedCond, &readyCond, &errorCond)...)
+
+			updateCondition := func(condition *smith_v1.ResourceCondition) {
+				update := updateResourceCondition(st.bundle, res.Name, condition)
+				bundleUpdated = update.updated || bundleUpdated
+				if update.transitioned() {
+					transitions = append(transitions, update.String())
+				}
+			}
+			updateCondition(&blockedCond)
+			updateCondition(&inProgressCond)
+			updateCondition(&readyCond)
+			updateCondition(&errorCond)
+			conditions := []smith_v1.ResourceCondition{blockedCond, inProgressCond, readyCond, errorCond}
+			forceReadyCond := st.forceReadyCondition(res)
+			if forceReadyCond != nil {
+				updateCondition(forceReadyCond)
+				conditions = append(conditions, *forceReadyCond)
+			}
+			consecutiveFailures := st.consecutiveFailures(res)
+			lastAction, lastActionTime := st.lastAction(res)
+			specHash := st.processedSpecHash(res)
+			appliedSpecHash, appliedGeneration := st.appliedSpec(res)
+			if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+				bundleUpdated = bundleUpdated || oldStatus.ConsecutiveFailures != consecutiveFailures
+				// Condition is dropped when the override is removed
+				bundleUpdated = bundleUpdated || len(oldStatus.Conditions) != len(conditions)
+				bundleUpdated = bundleUpdated || oldStatus.LastAction != lastAction || !oldStatus.LastActionTime.Equal(&lastActionTime)
+				bundleUpdated = bundleUpdated || oldStatus.SpecHash != specHash
+				bundleUpdated = bundleUpdated || oldStatus.AppliedSpecHash != appliedSpecHash || oldStatus.AppliedGeneration != appliedGeneration
+				// Retry time only changes together with the retry count
+				bundleUpdated = bundleUpdated || oldStatus.RetryCount != retryCount
+				bundleUpdated = bundleUpdated || !oldStatus.ProgressStartTime.Equal(progressStartTime)
+			}
 			resourceStatuses = append(resourceStatuses, smith_v1.ResourceStatus{
-				Name:       res.Name,
-				Conditions: []smith_v1.ResourceCondition{blockedCond, inProgressCond, readyCond, errorCond},
+				Name:                res.Name,
+				Conditions:          conditions,
+				ConsecutiveFailures: consecutiveFailures,
+				LastAction:          lastAction,
+				LastActionTime:      lastActionTime,
+				SpecHash:            specHash,
+				AppliedSpecHash:     appliedSpecHash,
+				AppliedGeneration:   appliedGeneration,
+				RetryCount:          retryCount,
+				NextRetryTime:       resNextRetryTime,
+				ProgressStartTime:   progressStartTime,
 			})
 		}
+		if len(transitions) > 0 {
+			st.logger.Info("Resource conditions transitioned", zap.Strings("transitions", transitions))
+		}
+		// Status is rebuilt from scratch on each iteration. Make sure statuses of resources that are
+		// not in the Bundle anymore (e.g. restored from a backup) are dropped.
+		bundleUpdated = bundleUpdated || len(st.bundle.Status.ResourceStatuses) != len(resourceStatuses)
 
 		if processErr == nil && len(failedResources) > 0 {
-			processErr = errors.Errorf("error processing resource(s): %q", failedResources)
+			processErr = withErrorCode(smith_v1.ErrorCodeResourcesFailed, errors.Errorf("error processing resource(s): %q", failedResources))
 			retriable = retriableResourceErr
+			if delay := nextRetryTime.Sub(time.Now()); retriable && !nextRetryTime.IsZero() && delay > 0 {
+				retryDelay = delay
+			}
 		}
 
 		// Bundle conditions
@@ -364,7 +1543,8 @@ func (st *bundleSyncTask) handleProcessResult(retriable bool, processErr error)
 			}
 		} else {
 			errorCond.Status = smith_v1.ConditionTrue
-			errorCond.Message = processErr.Error()
+			errorCond.Message = st.errorMessage(processErr)
+			errorCond.Code = errorCode(processErr)
 			if retriable {
 				errorCond.Reason = smith_v1.BundleReasonRetriableError
 				inProgressCond.Status = smith_v1.ConditionTrue
@@ -373,15 +1553,70 @@ func (st *bundleSyncTask) handleProcessResult(retriable bool, processErr error)
 			}
 		}
 
-		bundleUpdated = updateBundleCondition(st.bundle, &inProgressCond) || bundleUpdated
-		bundleUpdated = updateBundleCondition(st.bundle, &readyCond) || bundleUpdated
-		bundleUpdated = updateBundleCondition(st.bundle, &errorCond) || bundleUpdated
+		if errorCond.Status != smith_v1.ConditionTrue {
+			if _, oldErrorCond := st.bundle.GetCondition(smith_v1.BundleError); oldErrorCond != nil {
+				if message, hold := st.holdError(oldErrorCond.Status, oldErrorCond.Message); hold {
+					errorCond.Status = smith_v1.ConditionTrue
+					errorCond.Reason = oldErrorCond.Reason
+					errorCond.Message = message
+					errorCond.Code = oldErrorCond.Code
+					if readyCond.Status == smith_v1.ConditionTrue {
+						// Bundle is not reported as ready while it is reported as failed
+						readyCond.Status = smith_v1.ConditionFalse
+						readyCond.Reason = smith_v1.BundleReasonErrorHeld
+						readyCond.Message = "All resources are ready, waiting for the held Error condition to expire"
+					}
+				}
+			}
+		}
+
+		bundleUpdated = updateBundleCondition(st.bundle, &inProgressCond, st.conditionHistorySize) || bundleUpdated
+		bundleUpdated = updateBundleCondition(st.bundle, &readyCond, st.conditionHistorySize) || bundleUpdated
+		bundleUpdated = updateBundleCondition(st.bundle, &errorCond, st.conditionHistorySize) || bundleUpdated
+
+		// Scheduled reconcile continues until the Bundle becomes ready so that objects blocked on
+		// dependencies are created too
+		if st.scheduledReconcile && processErr == nil && st.isBundleReady() {
+			st.bundle.Status.LastScheduledReconcileTime = meta_v1.Now()
+			bundleUpdated = true
+		}
 
 		// Plugin statuses
 		pluginStatuses := st.pluginStatuses()
 		bundleUpdated = bundleUpdated || !reflect.DeepEqual(st.bundle.Status.PluginStatuses, pluginStatuses)
 		st.bundle.Status.PluginStatuses = pluginStatuses
 
+		// Progress
+		ready, total := st.readyResources()
+		bundleUpdated = bundleUpdated || st.bundle.Status.ReadyResources != int32(ready) || st.bundle.Status.TotalResources != int32(total)
+		st.bundle.Status.ReadyResources = int32(ready)
+		st.bundle.Status.TotalResources = int32(total)
+		bundleUpdated = bundleUpdated || !reflect.DeepEqual(st.bundle.Status.ResourceOrder, st.resourceOrder)
+		st.bundle.Status.ResourceOrder = st.resourceOrder
+
+		// Terminal state is notified once per transition into it for the current generation
+		_, oldReadyCond := st.bundle.GetCondition(smith_v1.BundleReady)
+		_, oldErrorCond := st.bundle.GetCondition(smith_v1.BundleError)
+		outcome := completionOutcome(st.bundle.Generation, &readyCond, &errorCond)
+		if outcome != "" && outcome != completionOutcome(st.bundle.Generation, oldReadyCond, oldErrorCond) {
+			completion = &Completion{
+				Namespace:  st.bundle.Namespace,
+				Name:       st.bundle.Name,
+				UID:        st.bundle.UID,
+				Generation: st.bundle.Generation,
+				Outcome:    outcome,
+			}
+			if st.span != nil {
+				completion.TraceParent = st.span.TraceParent()
+			}
+			if outcome == CompletionOutcomeError {
+				completion.Message = errorCond.Message
+				completion.TransitionTime = errorCond.LastTransitionTime
+			} else {
+				completion.TransitionTime = readyCond.LastTransitionTime
+			}
+		}
+
 		// Update the bundle status
 		if bundleUpdated {
 			st.bundle.Status.ResourceStatuses = resourceStatuses
@@ -397,17 +1632,187 @@ func (st *bundleSyncTask) handleProcessResult(retriable bool, processErr error)
 		}
 	}
 
+	if st.deletingCond != nil {
+		bundleUpdated = st.updateDeletingCondition() || bundleUpdated
+	}
+
 	if bundleUpdated {
 		ex := st.updateBundle()
+		if ex == nil {
+			// Events are only emitted once the transitions are persisted to avoid duplicates on conflicts
+			for _, event := range events {
+				st.recorder.Event(st.bundle, event.eventType, event.reason, event.message)
+			}
+			if completion != nil && st.completionNotifier != nil {
+				st.completionNotifier.NotifyCompletion(*completion)
+			}
+		}
 		if processErr == nil {
 			processErr = ex
 			retriable = true
 		}
 	}
 
+	if retriable && retryDelay > 0 {
+		// Failed resources are retried with backoff rather than with the work queue's rate limiting
+		st.requeue(retryDelay)
+		retriable = false
+	}
+
 	return retriable, processErr
 }
 
+// errorMessage returns a message for the error to be put into a condition.
+func (st *bundleSyncTask) errorMessage(err error) string {
+	if st.errorMessageTransformer == nil {
+		return err.Error()
+	}
+	return st.errorMessageTransformer.ErrorMessage(err)
+}
+
+// defaultedReferencesMessage returns a condition message noting that default values were used for references.
+func defaultedReferencesMessage(defaulted []smith_v1.ReferenceName) string {
+	if len(defaulted) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Default value is used for reference(s) %q", defaulted)
+}
+
+// forceReadyCondition returns the ForceReady condition if the resource is forced to be ready, nil otherwise.
+func (st *bundleSyncTask) forceReadyCondition(res smith_v1.Resource) *smith_v1.ResourceCondition {
+	resInfo, ok := st.processedResources[res.Name]
+	if !ok {
+		return nil
+	}
+	readyStatus, ok := resInfo.status.(resourceStatusReady)
+	if !ok || !readyStatus.forceReady {
+		return nil
+	}
+	return &smith_v1.ResourceCondition{
+		Type:    smith_v1.ResourceForceReady,
+		Status:  smith_v1.ConditionTrue,
+		Message: fmt.Sprintf("Readiness check is overridden by %s annotation", smith.ForceReadyAnnotation),
+	}
+}
+
+// holdError decides whether an Error condition that has just cleared should still be reported to avoid flapping.
+// The condition is held for errorHoldTime since the moment the error was first seen cleared. The time the hold
+// expires is recorded in the message of the held condition. The Bundle is requeued to be processed once the hold
+// expires. Returns the message for the held condition and true if the condition should be held.
+func (st *bundleSyncTask) holdError(oldStatus smith_v1.ConditionStatus, oldMessage string) (string, bool /*hold*/) {
+	if st.errorHoldTime <= 0 || oldStatus != smith_v1.ConditionTrue {
+		return "", false
+	}
+	now := time.Now()
+	heldUntil, held := errorHeldUntil(oldMessage)
+	if !held {
+		// Error has just cleared, start holding it. Time in the message has a precision of a second,
+		// the hold is rounded up to not expire before errorHoldTime.
+		heldUntil = now.Add(st.errorHoldTime + time.Second - 1).Truncate(time.Second)
+		oldMessage += errorHeldMessagePrefix + heldUntil.UTC().Format(errorHeldTimeLayout) + ")"
+	}
+	holdRemaining := heldUntil.Sub(now)
+	if holdRemaining <= 0 {
+		return "", false
+	}
+	st.requeue(holdRemaining)
+	return oldMessage, true
+}
+
+// errorHeldUntil returns the time the hold of a held Error condition expires. Returns false if the message
+// is not a message of a held condition.
+func errorHeldUntil(message string) (time.Time, bool) {
+	i := strings.LastIndex(message, errorHeldMessagePrefix)
+	if i < 0 || !strings.HasSuffix(message, ")") {
+		return time.Time{}, false
+	}
+	heldUntil, err := time.Parse(errorHeldTimeLayout, message[i+len(errorHeldMessagePrefix):len(message)-1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return heldUntil, true
+}
+
+// context returns the context of the processing iteration.
+func (st *bundleSyncTask) context() context.Context {
+	if st.ctx == nil {
+		return context.Background()
+	}
+	return st.ctx
+}
+
+// updatePausedCondition marks the Bundle as paused in its InProgress condition. Other conditions and statuses of
+// resources are kept as they were when the Bundle was paused. Returns true if the Bundle needs to be updated.
+func (st *bundleSyncTask) updatePausedCondition() bool {
+	inProgressCond := smith_v1.BundleCondition{
+		Type:    smith_v1.BundleInProgress,
+		Status:  smith_v1.ConditionFalse,
+		Reason:  smith_v1.BundleReasonPaused,
+		Message: "Processing of the Bundle is paused",
+	}
+	if !updateBundleCondition(st.bundle, &inProgressCond, st.conditionHistorySize) {
+		return false
+	}
+	if i, _ := st.bundle.GetCondition(smith_v1.BundleInProgress); i >= 0 {
+		st.bundle.Status.Conditions[i] = inProgressCond
+	} else {
+		st.bundle.Status.Conditions = append(st.bundle.Status.Conditions, inProgressCond)
+	}
+	return true
+}
+
+// updateDeletingCondition sets the Deleting condition of the Bundle. A protected Bundle also has the reason
+// in its InProgress condition. Other conditions are kept as they were when deletion started.
+// Returns true if the Bundle needs to be updated.
+func (st *bundleSyncTask) updateDeletingCondition() bool {
+	updated := st.setBundleCondition(*st.deletingCond)
+	if st.deletingCond.Reason == smith_v1.BundleReasonDeletionProtected {
+		updated = st.setBundleCondition(smith_v1.BundleCondition{
+			Type:    smith_v1.BundleInProgress,
+			Status:  smith_v1.ConditionTrue,
+			Reason:  smith_v1.BundleReasonDeletionProtected,
+			Message: st.deletingCond.Message,
+		}) || updated
+	}
+	return updated
+}
+
+// setBundleCondition replaces the condition of the same type, or adds it if the Bundle does not have one.
+// Returns true if the condition has changed.
+func (st *bundleSyncTask) setBundleCondition(cond smith_v1.BundleCondition) bool {
+	if !updateBundleCondition(st.bundle, &cond, st.conditionHistorySize) {
+		return false
+	}
+	if i, _ := st.bundle.GetCondition(cond.Type); i >= 0 {
+		st.bundle.Status.Conditions[i] = cond
+	} else {
+		st.bundle.Status.Conditions = append(st.bundle.Status.Conditions, cond)
+	}
+	return true
+}
+
+// requeue asks for the Bundle to be processed again after the delay. The shortest requested delay wins.
+func (st *bundleSyncTask) requeue(delay time.Duration) {
+	if st.requeueAfter == 0 || delay < st.requeueAfter {
+		st.requeueAfter = delay
+	}
+}
+
+// requeueIfBlocked requests the Bundle to be processed again after blockedRequeueDelay if any resource is
+// blocked by dependencies. This is a safety net for watch events of dependencies that were missed.
+// The delay is jittered so that Bundles blocked by the same dependency are not all processed at once.
+func (st *bundleSyncTask) requeueIfBlocked() {
+	if st.blockedRequeueDelay <= 0 {
+		return
+	}
+	for _, resInfo := range st.processedResources {
+		if _, ok := resInfo.status.(resourceStatusDependenciesNotReady); ok {
+			st.requeue(jitteredDelay(st.blockedRequeueDelay, st.requeueJitter))
+			return
+		}
+	}
+}
+
 func (st *bundleSyncTask) updateObjectsToDeleteStatus() (bool /* bundleUpdated */, error) {
 	if st.objectsToDelete == nil {
 		err := st.findObjectsToDelete()
@@ -463,13 +1868,23 @@ func (st *bundleSyncTask) updateObjectsToDeleteStatus() (bool /* bundleUpdated *
 }
 
 func (st *bundleSyncTask) isBundleReady() bool {
+	ready, total := st.readyResources()
+	return ready == total
+}
+
+// readyResources returns the number of ready resources and the total number of enabled resources of the Bundle.
+func (st *bundleSyncTask) readyResources() (int, int /*total*/) {
+	ready, total := 0, 0
 	for _, res := range st.bundle.Spec.Resources {
-		res := st.processedResources[res.Name]
-		if res == nil || !res.isReady() {
-			return false
+		if res.Disabled {
+			continue
+		}
+		total++
+		if resInfo := st.processedResources[res.Name]; resInfo != nil && resInfo.isReady() {
+			ready++
 		}
 	}
-	return true
+	return ready, total
 }
 
 type objectRef struct {
@@ -478,38 +1893,53 @@ type objectRef struct {
 }
 
 // pluginStatuses visits each valid Plugin just once, collecting its PluginStatus.
+// Outcomes of all resources that use a plugin are counted in its status.
 func (st *bundleSyncTask) pluginStatuses() []smith_v1.PluginStatus {
 	// Plugin statuses
-	name2status := make(map[smith_v1.PluginName]struct{})
+	name2index := make(map[smith_v1.PluginName]int)
 	// most likely will be of the same size as before
 	pluginStatuses := make([]smith_v1.PluginStatus, 0, len(st.bundle.Status.PluginStatuses))
 	for _, res := range st.bundle.Spec.Resources { // Deterministic iteration order
-		if res.Spec.Plugin == nil {
-			continue // Not a plugin
+		if res.Spec.Plugin == nil || res.Disabled {
+			continue // Not a plugin or not processed
 		}
 		pluginName := res.Spec.Plugin.Name
-		if _, ok := name2status[pluginName]; ok {
-			continue // Already reported
-		}
-		name2status[pluginName] = struct{}{}
-		var pluginStatus smith_v1.PluginStatus
-		pluginContainer, ok := st.pluginContainers[pluginName]
-		if ok {
-			describe := pluginContainer.Plugin.Describe()
-			pluginStatus = smith_v1.PluginStatus{
-				Name:    pluginName,
-				Group:   describe.GVK.Group,
-				Version: describe.GVK.Version,
-				Kind:    describe.GVK.Kind,
-				Status:  smith_v1.PluginStatusOk,
+		index, ok := name2index[pluginName]
+		if !ok {
+			var pluginStatus smith_v1.PluginStatus
+			pluginContainer, ok := st.pluginContainers[pluginName]
+			if ok {
+				describe := pluginContainer.Plugin.Describe()
+				pluginStatus = smith_v1.PluginStatus{
+					Name:    pluginName,
+					Group:   describe.GVK.Group,
+					Version: describe.GVK.Version,
+					Kind:    describe.GVK.Kind,
+					Status:  smith_v1.PluginStatusOk,
+				}
+			} else {
+				pluginStatus = smith_v1.PluginStatus{
+					Name:   pluginName,
+					Status: smith_v1.PluginStatusNoSuchPlugin,
+				}
 			}
-		} else {
-			pluginStatus = smith_v1.PluginStatus{
-				Name:   pluginName,
-				Status: smith_v1.PluginStatusNoSuchPlugin,
+			index = len(pluginStatuses)
+			name2index[pluginName] = index
+			pluginStatuses = append(pluginStatuses, pluginStatus)
+		}
+		pluginStatus := &pluginStatuses[index]
+		pluginStatus.Resources++
+		if resInfo, ok := st.processedResources[res.Name]; ok {
+			if resInfo.actual != nil {
+				pluginStatus.Objects += 1 + int32(len(resInfo.additional))
+			}
+			if resErr, failed := resInfo.status.(resourceStatusError); failed {
+				pluginStatus.FailedResources++
+				if _, ok := errors.Cause(resErr.err).(*pluginReadinessError); ok && pluginStatus.Status == smith_v1.PluginStatusOk {
+					pluginStatus.Status = smith_v1.PluginStatusReadinessCheckError
+				}
 			}
 		}
-		pluginStatuses = append(pluginStatuses, pluginStatus)
 	}
 	return pluginStatuses
 }
@@ -532,16 +1962,55 @@ func (st *bundleSyncTask) resourceConditions(res smith_v1.Resource) (
 		switch resStatus := resInfo.status.(type) {
 		case resourceStatusDependenciesNotReady:
 			blockedCond.Status = smith_v1.ConditionTrue
-			blockedCond.Reason = smith_v1.ResourceReasonDependenciesNotReady
-			blockedCond.Message = fmt.Sprintf("Not ready: %q", resStatus.dependencies)
+			if resStatus.notCreated {
+				blockedCond.Reason = smith_v1.ResourceReasonDependenciesNotCreated
+			} else {
+				blockedCond.Reason = smith_v1.ResourceReasonDependenciesNotReady
+			}
+			blockedCond.Message = resStatus.message()
 		case resourceStatusInProgress:
 			inProgressCond.Status = smith_v1.ConditionTrue
+			if resStatus.waitingForOldObjectDeletion {
+				inProgressCond.Reason = smith_v1.ResourceReasonWaitingForOldObjectDeletion
+				inProgressCond.Message = "Waiting for the old object to be deleted"
+			} else if resStatus.waitingForRollout {
+				inProgressCond.Reason = smith_v1.ResourceReasonWaitingForRollout
+				inProgressCond.Message = fmt.Sprintf("Waiting for other resources to be rolled out, at most %d resources are rolled out at a time", st.bundle.Spec.MaxResourcesPerSync)
+			} else if resStatus.waitingForMinReady {
+				inProgressCond.Reason = smith_v1.ResourceReasonWaitingForMinReadySeconds
+				inProgressCond.Message = fmt.Sprintf("Waiting for the object to stay ready for %ds", res.MinReadySeconds)
+				// Transition time of the Unknown status is the time the object became ready
+				readyCond.Status = smith_v1.ConditionUnknown
+				readyCond.Reason = smith_v1.ResourceReasonWaitingForMinReadySeconds
+				readyCond.Message = inProgressCond.Message
+			} else {
+				inProgressCond.Message = defaultedReferencesMessage(resInfo.defaultedReferences)
+			}
 		case resourceStatusReady:
 			readyCond.Status = smith_v1.ConditionTrue
+			if resStatus.createOnly {
+				readyCond.Reason = smith_v1.ResourceReasonCreateOnly
+				readyCond.Message = "Object exists and is not updated because resource is create-only"
+			} else {
+				readyCond.Message = defaultedReferencesMessage(resInfo.defaultedReferences)
+			}
 		case resourceStatusError:
 			errorCond.Status = smith_v1.ConditionTrue
-			errorCond.Message = resStatus.err.Error()
-			if resStatus.isRetriableError {
+			if resStatus.waitingForRetry {
+				// Message of the previous attempt has been transformed already
+				errorCond.Reason = smith_v1.ResourceReasonRetriableError
+				errorCond.Message = resStatus.err.Error()
+				errorCond.Code = errorCode(resStatus.err)
+				inProgressCond.Status = smith_v1.ConditionTrue
+				break
+			}
+			errorCond.Message = st.errorMessage(resStatus.err)
+			errorCond.Code = errorCode(resStatus.err)
+			if res.MaxRetries > 0 && st.consecutiveFailures(res) > res.MaxRetries {
+				// Retry limit exceeded, error is terminal even if it is retriable
+				errorCond.Reason = smith_v1.ResourceReasonTerminalError
+				errorCond.Message = fmt.Sprintf("giving up after %d consecutive failures: %s", res.MaxRetries+1, errorCond.Message)
+			} else if resStatus.isRetriableError {
 				errorCond.Reason = smith_v1.ResourceReasonRetriableError
 				inProgressCond.Status = smith_v1.ConditionTrue
 			} else {
@@ -566,30 +2035,227 @@ func (st *bundleSyncTask) resourceConditions(res smith_v1.Resource) (
 	return blockedCond, inProgressCond, readyCond, errorCond
 }
 
+// checkProgressDeadline fails a resource with a terminal error if it has been continuously not ready for longer
+// than the progress deadline of the Bundle. The time is tracked since the last transition of the Ready condition
+// or since the generation of the Bundle changed, whichever is later. Returns the time the deadline was restarted at
+// because of a generation change, to be recorded in the status of the resource.
+// The Bundle is requeued to check the deadline again if it has not been exceeded yet.
+func (st *bundleSyncTask) checkProgressDeadline(res smith_v1.Resource, inProgressCond, readyCond, errorCond *smith_v1.ResourceCondition) *meta_v1.Time {
+	deadline := st.bundle.Spec.ProgressDeadlineSeconds
+	if deadline == nil || *deadline <= 0 || readyCond.Status == smith_v1.ConditionTrue {
+		return nil
+	}
+	_, oldStatus := st.bundle.Status.GetResourceStatus(res.Name)
+	if oldStatus == nil {
+		return nil
+	}
+	_, oldReadyCond := oldStatus.GetCondition(smith_v1.ResourceReady)
+	if oldReadyCond == nil || oldReadyCond.Status == smith_v1.ConditionTrue || oldReadyCond.LastTransitionTime.IsZero() {
+		return nil
+	}
+	deadlineDuration := time.Duration(*deadline) * time.Second
+	if oldReadyCond.ObservedGeneration != st.bundle.Generation {
+		// Bundle has changed, the resource gets the full deadline to progress to the new spec
+		st.requeue(deadlineDuration)
+		now := meta_v1.Now()
+		return &now
+	}
+	progressStart := oldReadyCond.LastTransitionTime
+	if oldStatus.ProgressStartTime != nil && oldStatus.ProgressStartTime.After(progressStart.Time) {
+		progressStart = *oldStatus.ProgressStartTime
+	}
+	if remaining := progressStart.Add(deadlineDuration).Sub(time.Now()); remaining > 0 {
+		st.requeue(remaining)
+		return oldStatus.ProgressStartTime
+	}
+	message := fmt.Sprintf("resource has not become ready within the progress deadline of %ds", *deadline)
+	if errorCond.Status == smith_v1.ConditionTrue && errorCond.Message != "" {
+		message += ": " + errorCond.Message
+	}
+	inProgressCond.Status = smith_v1.ConditionFalse
+	errorCond.Status = smith_v1.ConditionTrue
+	errorCond.Reason = smith_v1.ResourceReasonDeadlineExceeded
+	errorCond.Message = message
+	errorCond.Code = smith_v1.ErrorCodeDeadlineExceeded
+	return oldStatus.ProgressStartTime
+}
+
+// consecutiveFailures returns the number of consecutive failed processing attempts of a resource,
+// including the current one. Failures are only tracked for resources with MaxRetries set and the
+// number is capped at MaxRetries+1 to avoid updating the Bundle on each iteration once the limit is reached.
+func (st *bundleSyncTask) consecutiveFailures(res smith_v1.Resource) int32 {
+	if res.MaxRetries <= 0 {
+		return 0
+	}
+	var failures int32
+	if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+		failures = oldStatus.ConsecutiveFailures
+	}
+	resInfo, ok := st.processedResources[res.Name]
+	if !ok || resInfo.isWaitingForRetry() {
+		// Resource was not processed, keep the previous value
+		return failures
+	}
+	if _, isError := resInfo.status.(resourceStatusError); !isError {
+		return 0
+	}
+	if failures <= res.MaxRetries {
+		failures++
+	}
+	return failures
+}
+
+// retryBackoff returns the number of consecutive retriable errors of a resource and the time after which
+// the resource should be retried. The delay doubles with each consecutive retriable error. Both are reset
+// once the resource becomes ready and kept as is while the resource is not retried.
+func (st *bundleSyncTask) retryBackoff(res smith_v1.Resource, errorCond smith_v1.ResourceCondition) (int32, *meta_v1.Time) {
+	if st.retryBaseDelay <= 0 {
+		return 0, nil
+	}
+	var retryCount int32
+	var nextRetryTime *meta_v1.Time
+	if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+		retryCount = oldStatus.RetryCount
+		nextRetryTime = oldStatus.NextRetryTime
+	}
+	resInfo, ok := st.processedResources[res.Name]
+	if !ok || resInfo.isWaitingForRetry() {
+		// Resource was not processed, keep the previous value
+		return retryCount, nextRetryTime
+	}
+	if resInfo.isReady() {
+		return 0, nil
+	}
+	if errorCond.Status != smith_v1.ConditionTrue || errorCond.Reason != smith_v1.ResourceReasonRetriableError {
+		return retryCount, nextRetryTime
+	}
+	retryCount++
+	delay := st.retryBaseDelay
+	for i := int32(1); i < retryCount && delay < st.retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if st.retryMaxDelay > 0 && delay > st.retryMaxDelay {
+		delay = st.retryMaxDelay
+	}
+	nextRetryTime = &meta_v1.Time{Time: time.Now().Add(delay)}
+	return retryCount, nextRetryTime
+}
+
+// waitingForRetryInfo returns information about a resource that failed with a retriable error at the current
+// generation of the Bundle and must not be processed again before its NextRetryTime. The error of the previous
+// attempt is kept. Returns false if the resource should be processed.
+func (st *bundleSyncTask) waitingForRetryInfo(res *smith_v1.Resource) (*resourceInfo, bool) {
+	if st.retryBaseDelay <= 0 {
+		return nil, false
+	}
+	_, oldStatus := st.bundle.Status.GetResourceStatus(res.Name)
+	if oldStatus == nil || oldStatus.NextRetryTime == nil {
+		return nil, false
+	}
+	remaining := oldStatus.NextRetryTime.Sub(time.Now())
+	if remaining <= 0 {
+		return nil, false
+	}
+	_, errorCond := oldStatus.GetCondition(smith_v1.ResourceError)
+	if errorCond == nil || errorCond.Status != smith_v1.ConditionTrue || errorCond.Reason != smith_v1.ResourceReasonRetriableError ||
+		errorCond.ObservedGeneration != st.bundle.Generation {
+		return nil, false
+	}
+	st.requeue(remaining)
+	return &resourceInfo{
+		status: resourceStatusError{
+			err:              withErrorCode(errorCond.Code, errors.New(errorCond.Message)),
+			isRetriableError: true,
+			waitingForRetry:  true,
+		},
+		specHash: oldStatus.SpecHash,
+	}, true
+}
+
+// lastAction returns the last action taken on the object of a resource and its time.
+// Time of the Unchanged action is kept while the object stays unchanged to avoid updating the Bundle
+// on each iteration.
+func (st *bundleSyncTask) lastAction(res smith_v1.Resource) (smith_v1.ResourceAction, meta_v1.Time) {
+	var action smith_v1.ResourceAction
+	var actionTime meta_v1.Time
+	if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+		action = oldStatus.LastAction
+		actionTime = oldStatus.LastActionTime
+	}
+	resInfo, ok := st.processedResources[res.Name]
+	if !ok || resInfo.action == "" {
+		// No action was taken, keep the previous value
+		return action, actionTime
+	}
+	if resInfo.action == smith_v1.ResourceActionUnchanged && action == smith_v1.ResourceActionUnchanged {
+		return action, actionTime
+	}
+	return resInfo.action, meta_v1.Now()
+}
+
+// processedSpecHash returns the hash of the resource definition that was last processed.
+func (st *bundleSyncTask) processedSpecHash(res smith_v1.Resource) string {
+	if resInfo, ok := st.processedResources[res.Name]; ok {
+		return resInfo.specHash
+	}
+	// Resource was not processed, keep the previous value
+	if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+		return oldStatus.SpecHash
+	}
+	return ""
+}
+
+// appliedSpec returns the hash of the object that was last sent to the API server and the generation of the Bundle
+// it was produced from.
+func (st *bundleSyncTask) appliedSpec(res smith_v1.Resource) (string, int64) {
+	if resInfo, ok := st.processedResources[res.Name]; ok && resInfo.appliedSpecHash != "" {
+		return resInfo.appliedSpecHash, st.bundle.Generation
+	}
+	// Object was not created or updated, keep the previous value
+	if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+		return oldStatus.AppliedSpecHash, oldStatus.AppliedGeneration
+	}
+	return "", 0
+}
+
 // updateBundleCondition updates passed condition by fetching information from an existing resource condition if present.
-// Sets LastTransitionTime to now if the status has changed.
+// Sets LastTransitionTime to now if the status has changed and ObservedGeneration to the generation of the Bundle.
+// Up to historySize most recent transitions are kept in the condition.
 // Returns true if resource condition in the bundle does not match and needs to be updated.
-func updateBundleCondition(b *smith_v1.Bundle, condition *smith_v1.BundleCondition) bool {
+func updateBundleCondition(b *smith_v1.Bundle, condition *smith_v1.BundleCondition, historySize int) bool {
 	now := meta_v1.Now()
 	condition.LastTransitionTime = now
+	condition.ObservedGeneration = b.Generation
+	transition := smith_v1.ConditionTransition{
+		Status: condition.Status,
+		Reason: condition.Reason,
+		Time:   now,
+	}
 
 	// Try to find resource condition
 	_, oldCondition := b.GetCondition(condition.Type)
 
 	if oldCondition == nil {
 		// New resource condition
+		condition.Transitions = appendTransition(nil, &transition, historySize)
 		return true
 	}
 
 	// We are updating an existing condition, so we need to check if it has changed.
 	if condition.Status == oldCondition.Status {
 		condition.LastTransitionTime = oldCondition.LastTransitionTime
+		condition.Transitions = appendTransition(oldCondition.Transitions, nil, historySize)
+	} else {
+		condition.Transitions = appendTransition(oldCondition.Transitions, &transition, historySize)
 	}
 
 	isEqual := condition.Status == oldCondition.Status &&
 		condition.Reason == oldCondition.Reason &&
 		condition.Message == oldCondition.Message &&
-		condition.LastTransitionTime.Equal(&oldCondition.LastTransitionTime)
+		condition.Code == oldCondition.Code &&
+		condition.ObservedGeneration == oldCondition.ObservedGeneration &&
+		condition.LastTransitionTime.Equal(&oldCondition.LastTransitionTime) &&
+		len(condition.Transitions) == len(oldCondition.Transitions)
 
 	if !isEqual {
 		condition.LastUpdateTime = now
@@ -599,18 +2265,71 @@ func updateBundleCondition(b *smith_v1.Bundle, condition *smith_v1.BundleConditi
 	return !isEqual
 }
 
+// appendTransition returns transitions with the transition appended, if it is not nil, keeping at most
+// historySize most recent ones. Transitions slice is not modified.
+func appendTransition(transitions []smith_v1.ConditionTransition, transition *smith_v1.ConditionTransition, historySize int) []smith_v1.ConditionTransition {
+	if historySize <= 0 {
+		return nil
+	}
+	result := make([]smith_v1.ConditionTransition, 0, len(transitions)+1)
+	result = append(result, transitions...)
+	if transition != nil {
+		result = append(result, *transition)
+	}
+	if len(result) > historySize {
+		result = result[len(result)-historySize:]
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// resourceConditionUpdate describes how a resource condition has changed compared to the condition in the status
+// of the Bundle.
+type resourceConditionUpdate struct {
+	resName  smith_v1.ResourceName
+	condType smith_v1.ResourceConditionType
+	// updated is true if any field of the condition has changed.
+	updated bool
+	// oldStatus is the status of the condition before the update. Empty if the condition is new.
+	oldStatus smith_v1.ConditionStatus
+	newStatus smith_v1.ConditionStatus
+}
+
+// transitioned returns true if the status of the condition has changed.
+func (u resourceConditionUpdate) transitioned() bool {
+	return u.updated && u.oldStatus != u.newStatus
+}
+
+func (u resourceConditionUpdate) String() string {
+	oldStatus := u.oldStatus
+	if oldStatus == "" {
+		oldStatus = "None"
+	}
+	return fmt.Sprintf("%s/%s: %s -> %s", u.resName, u.condType, oldStatus, u.newStatus)
+}
+
 // updateResourceCondition updates passed condition by fetching information from an existing resource condition if present.
-// Sets LastTransitionTime to now if the status has changed.
-// Returns true if resource condition in the bundle does not match and needs to be updated.
-func updateResourceCondition(b *smith_v1.Bundle, resName smith_v1.ResourceName, condition *smith_v1.ResourceCondition) bool {
+// Sets LastTransitionTime to now if the status has changed or waiting for MinReadySeconds has started and
+// ObservedGeneration to the generation of the Bundle.
+// Returns how the condition has changed, the resource condition in the bundle needs to be updated if it does not match.
+func updateResourceCondition(b *smith_v1.Bundle, resName smith_v1.ResourceName, condition *smith_v1.ResourceCondition) resourceConditionUpdate {
 	now := meta_v1.Now()
 	condition.LastTransitionTime = now
+	condition.ObservedGeneration = b.Generation
+	update := resourceConditionUpdate{
+		resName:   resName,
+		condType:  condition.Type,
+		updated:   true,
+		newStatus: condition.Status,
+	}
 	// Try to find this resource status
 	_, status := b.Status.GetResourceStatus(resName)
 
 	if status == nil {
 		// No status for this resource, hence it's a new resource condition
-		return true
+		return update
 	}
 
 	// Try to find resource condition
@@ -618,46 +2337,234 @@ func updateResourceCondition(b *smith_v1.Bundle, resName smith_v1.ResourceName,
 
 	if oldCondition == nil {
 		// New resource condition
-		return true
+		return update
 	}
+	update.oldStatus = oldCondition.Status
 
 	// We are updating an existing condition, so we need to check if it has changed.
-	if condition.Status == oldCondition.Status {
+	// Waiting for MinReadySeconds starts when the object becomes ready even if the status was Unknown
+	// for another reason before (e.g. the resource was not processed).
+	startsMinReadyWait := condition.Reason == smith_v1.ResourceReasonWaitingForMinReadySeconds &&
+		oldCondition.Reason != smith_v1.ResourceReasonWaitingForMinReadySeconds
+	if condition.Status == oldCondition.Status && !startsMinReadyWait {
 		condition.LastTransitionTime = oldCondition.LastTransitionTime
 	}
 
 	isEqual := condition.Status == oldCondition.Status &&
 		condition.Reason == oldCondition.Reason &&
 		condition.Message == oldCondition.Message &&
+		condition.Code == oldCondition.Code &&
+		condition.ObservedGeneration == oldCondition.ObservedGeneration &&
 		condition.LastTransitionTime.Equal(&oldCondition.LastTransitionTime)
 
 	if !isEqual {
 		condition.LastUpdateTime = now
 	}
 
-	// Return true if one of the fields have changed.
-	return !isEqual
+	update.updated = !isEqual
+	return update
 }
 
-func sortBundle(bundle *smith_v1.Bundle) (*graph.Graph, []graph.V, error) {
+func sortBundle(bundle *smith_v1.Bundle, pluginContainers map[smith_v1.PluginName]plugin.PluginContainer) (*graph.Graph, []graph.V, error) {
 	g := graph.NewGraph(len(bundle.Spec.Resources))
 
+	// Disabled resources are not processed and are excluded from the graph together with
+	// dependencies on them
+	disabled := make(map[smith_v1.ResourceName]struct{})
+	enabled := make([]smith_v1.Resource, 0, len(bundle.Spec.Resources))
 	for _, res := range bundle.Spec.Resources {
+		if res.Disabled {
+			disabled[res.Name] = struct{}{}
+			continue
+		}
+		enabled = append(enabled, res)
+	}
+	// Vertices are sorted in the order they are added unless dependencies dictate otherwise
+	sort.SliceStable(enabled, func(i, j int) bool {
+		return enabled[i].Priority > enabled[j].Priority
+	})
+	for _, res := range enabled {
 		g.AddVertex(graph.V(res.Name), nil)
 	}
 
-	for _, res := range bundle.Spec.Resources {
+	if err := checkDependenciesExist(bundle.Spec.Resources); err != nil {
+		return nil, nil, err
+	}
+
+	// A reference with a selector is an edge to each of the selected resources, an input of a plugin is
+	// an edge to the consumed resource
+	resources, err := expandReferences(bundle.Spec.Resources, pluginContainers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Both references and runAfter dependencies are edges of the same graph.
+	// Edge kinds are recorded to be able to explain a cycle.
+	edgeKinds := make(map[dependencyEdge]dependencyKinds)
+	for _, res := range resources {
+		if res.Disabled {
+			continue
+		}
 		for _, reference := range res.References {
+			if reference.Object != nil {
+				// Objects outside of the Bundle are not vertices of the graph
+				continue
+			}
+			if _, ok := disabled[reference.Resource]; ok {
+				continue
+			}
 			if err := g.AddEdge(res.Name, reference.Resource); err != nil {
 				return nil, nil, err
 			}
+			edge := dependencyEdge{from: res.Name, to: reference.Resource}
+			kinds := edgeKinds[edge]
+			kinds.reference = true
+			edgeKinds[edge] = kinds
+		}
+		for _, dependency := range res.RunAfter {
+			if _, ok := disabled[dependency]; ok {
+				continue
+			}
+			if err := g.AddEdge(res.Name, dependency); err != nil {
+				return nil, nil, err
+			}
+			edge := dependencyEdge{from: res.Name, to: dependency}
+			kinds := edgeKinds[edge]
+			kinds.runAfter = true
+			edgeKinds[edge] = kinds
 		}
 	}
 
 	sorted, err := g.TopologicalSort()
 	if err != nil {
+		if cycleErr, ok := errors.Cause(err).(*graph.CycleError); ok {
+			return nil, nil, withErrorCode(smith_v1.ErrorCodeDependencyCycle, errors.Errorf("%v: %s", cycleErr, describeCycle(cycleErr.Cycle, edgeKinds)))
+		}
 		return nil, nil, err
 	}
 
 	return g, sorted, nil
 }
+
+// checkDependenciesExist checks that references and runAfter dependencies of resources name resources
+// declared in the Bundle.
+func checkDependenciesExist(resources []smith_v1.Resource) error {
+	declared := make(map[smith_v1.ResourceName]struct{}, len(resources))
+	for _, res := range resources {
+		declared[res.Name] = struct{}{}
+	}
+	for _, res := range resources {
+		for _, reference := range res.References {
+			if reference.Object != nil {
+				if reference.Resource != "" || reference.Selector != nil {
+					return withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Errorf("resource %q has a reference %q with an object, such references cannot have a resource or a selector", res.Name, reference.Name))
+				}
+				// Object is not a resource of the Bundle
+				continue
+			}
+			if reference.Selector != nil {
+				// Selector only selects declared resources
+				continue
+			}
+			if _, ok := declared[reference.Resource]; !ok {
+				return withErrorCode(smith_v1.ErrorCodeUnknownDependency, errors.Errorf("resource %q references unknown resource %q", res.Name, reference.Resource))
+			}
+		}
+		for _, dependency := range res.RunAfter {
+			if _, ok := declared[dependency]; !ok {
+				return withErrorCode(smith_v1.ErrorCodeUnknownDependency, errors.Errorf("resource %q runs after unknown resource %q", res.Name, dependency))
+			}
+		}
+	}
+	return nil
+}
+
+// expandReferenceSelectors returns resources where each reference with a selector is replaced with nameless
+// references to the resources it selects, in the order of resources in the Bundle. A resource never selects itself
+// and disabled resources are never selected.
+// Resources without such references are returned as is, other resources are copied.
+func expandReferenceSelectors(resources []smith_v1.Resource) ([]smith_v1.Resource, error) {
+	var expanded []smith_v1.Resource
+	for i, res := range resources {
+		hasSelector := false
+		for _, reference := range res.References {
+			if reference.Selector != nil {
+				hasSelector = true
+				break
+			}
+		}
+		if !hasSelector {
+			if expanded != nil {
+				expanded = append(expanded, res)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = make([]smith_v1.Resource, 0, len(resources))
+			expanded = append(expanded, resources[:i]...)
+		}
+		res = *res.DeepCopy()
+		references := make([]smith_v1.Reference, 0, len(res.References))
+		for _, reference := range res.References {
+			if reference.Selector == nil {
+				references = append(references, reference)
+				continue
+			}
+			if reference.Resource != "" {
+				return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Errorf("resource %q has a reference with both resource and selector specified", res.Name))
+			}
+			if reference.Name != "" || reference.Path != "" {
+				return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Errorf("resource %q has a reference %q with a selector, such references cannot have a name or a path", res.Name, reference.Name))
+			}
+			selector, err := meta_v1.LabelSelectorAsSelector(reference.Selector)
+			if err != nil {
+				return nil, errors.Wrapf(err, "resource %q has a reference with invalid selector", res.Name)
+			}
+			for _, selected := range resources {
+				if selected.Name == res.Name || selected.Disabled || !selector.Matches(labels.Set(selected.Labels)) {
+					continue
+				}
+				selectedReference := reference
+				selectedReference.Resource = selected.Name
+				selectedReference.Selector = nil
+				references = append(references, selectedReference)
+			}
+		}
+		res.References = references
+		expanded = append(expanded, res)
+	}
+	if expanded == nil {
+		return resources, nil
+	}
+	return expanded, nil
+}
+
+type dependencyEdge struct {
+	from, to smith_v1.ResourceName
+}
+
+// dependencyKinds describes which declarations an edge of the dependency graph comes from.
+type dependencyKinds struct {
+	reference bool
+	runAfter  bool
+}
+
+// describeCycle explains which declarations contribute to a cycle, so that it is clear which one to fix.
+func describeCycle(cycle []graph.V, edgeKinds map[dependencyEdge]dependencyKinds) string {
+	constraints := make([]string, 0, len(cycle)-1)
+	for i := 0; i < len(cycle)-1; i++ {
+		from := cycle[i].(smith_v1.ResourceName)
+		to := cycle[i+1].(smith_v1.ResourceName)
+		var verb string
+		switch kinds := edgeKinds[dependencyEdge{from: from, to: to}]; {
+		case kinds.reference && kinds.runAfter:
+			verb = "references and runs after"
+		case kinds.runAfter:
+			verb = "runs after"
+		default:
+			verb = "references"
+		}
+		constraints = append(constraints, fmt.Sprintf("%q %s %q", from, verb, to))
+	}
+	return strings.Join(constraints, ", ")
+}
@@ -0,0 +1,3737 @@
+package bundlec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestDeleteRemovedResourcesBlockedWhenInUse(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	inUse := &core_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "old",
+			UID:  "old-uid",
+		},
+	}
+	actual := &unstructured.Unstructured{
+		Object: map[string]interface{}{},
+	}
+	actual.SetOwnerReferences([]meta_v1.OwnerReference{
+		{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       "old",
+			UID:        "old-uid",
+		},
+	})
+	st := bundleSyncTask{
+		logger: logger,
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+				},
+			},
+		},
+		blockInUseDeletion: true,
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				actual: actual,
+				status: resourceStatusReady{},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{
+			{
+				GroupVersionKind: core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+				Name:             "old",
+			}: inUse,
+		},
+	}
+
+	retriable, err := st.deleteRemovedResources()
+	require.Error(t, err)
+	assert.True(t, retriable)
+	assert.EqualError(t, err, `deletion of removed objects is blocked because they are still in use: ConfigMap "old" is referenced by resource(s) ["a"]`)
+}
+
+func TestDeleteDisabledResourceBlockedWhenDependedOn(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "cm1",
+			UID:  "cm1-uid",
+		},
+	}
+	st := bundleSyncTask{
+		logger: logger,
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name:     "config",
+						Disabled: true,
+						Spec: smith_v1.ResourceSpec{
+							Object: configMap,
+						},
+					},
+					{
+						Name:     "a",
+						RunAfter: []smith_v1.ResourceName{"config"},
+					},
+					{
+						Name: "b",
+						References: []smith_v1.Reference{
+							{
+								Name:     "config-ref",
+								Resource: "config",
+								Path:     "metadata.name",
+								Optional: true,
+							},
+						},
+					},
+					{
+						Name: "c",
+					},
+				},
+			},
+		},
+		blockInUseDeletion: true,
+		// Objects of dependents do not have owner references to the object
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"config": {
+				status: resourceStatusDisabled{},
+			},
+			"a": {
+				actual: &unstructured.Unstructured{Object: map[string]interface{}{}},
+				status: resourceStatusReady{},
+			},
+			"b": {
+				actual: &unstructured.Unstructured{Object: map[string]interface{}{}},
+				status: resourceStatusReady{},
+			},
+			"c": {
+				actual: &unstructured.Unstructured{Object: map[string]interface{}{}},
+				status: resourceStatusReady{},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{
+			{
+				GroupVersionKind: core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+				Name:             "cm1",
+			}: configMap,
+		},
+	}
+
+	retriable, err := st.deleteRemovedResources()
+	require.Error(t, err)
+	assert.True(t, retriable)
+	assert.EqualError(t, err, `deletion of removed objects is blocked because they are still in use: ConfigMap "cm1" is referenced by resource(s) ["a" "b"]`)
+}
+
+func TestDeleteRemovedResourcesDependentsFirst(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMapGVK := core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK := core_v1.SchemeGroupVersion.WithKind("Secret")
+	dependency := &core_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "a",
+			UID:  "a-uid",
+		},
+	}
+	independent := &core_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "c",
+			UID:  "c-uid",
+		},
+	}
+	dependent := &core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "b",
+			UID:  "b-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       "a",
+					UID:        "a-uid",
+				},
+			},
+		},
+	}
+	result := &ReconcileResult{}
+	st := bundleSyncTask{
+		logger:      logger,
+		smartClient: &simulationSmartClient{result: result},
+		bundle:      &smith_v1.Bundle{},
+		objectsToDelete: map[objectRef]runtime.Object{
+			{GroupVersionKind: configMapGVK, Name: "a"}: dependency,
+			{GroupVersionKind: configMapGVK, Name: "c"}: independent,
+			{GroupVersionKind: secretGVK, Name: "b"}:    dependent,
+		},
+	}
+
+	_, err := st.deleteRemovedResources()
+	require.NoError(t, err)
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{Version: "v1", Kind: "Secret", Name: "b"},
+		{Version: "v1", Kind: "ConfigMap", Name: "a"},
+		{Version: "v1", Kind: "ConfigMap", Name: "c"},
+	}, result.Deleted)
+}
+
+func TestDeleteDisabledResourcesInReverseDependencyOrder(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMapGVK := core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK := core_v1.SchemeGroupVersion.WithKind("Secret")
+	result := &ReconcileResult{}
+	st := bundleSyncTask{
+		logger:      logger,
+		smartClient: &simulationSmartClient{result: result},
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name:     "config",
+						Disabled: true,
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "a",
+								},
+							},
+						},
+					},
+					{
+						Name:     "secret",
+						Disabled: true,
+						References: []smith_v1.Reference{
+							{
+								Name:     "config-ref",
+								Resource: "config",
+								Path:     "metadata.name",
+							},
+						},
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.Secret{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "Secret",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "b",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		// Objects do not have owner references to each other, order is determined by the Bundle
+		objectsToDelete: map[objectRef]runtime.Object{
+			{GroupVersionKind: configMapGVK, Name: "a"}: &core_v1.ConfigMap{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "a",
+					UID:  "a-uid",
+				},
+			},
+			{GroupVersionKind: secretGVK, Name: "b"}: &core_v1.Secret{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "b",
+					UID:  "b-uid",
+				},
+			},
+		},
+	}
+
+	_, err := st.deleteRemovedResources()
+	require.NoError(t, err)
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{Version: "v1", Kind: "Secret", Name: "b"},
+		{Version: "v1", Kind: "ConfigMap", Name: "a"},
+	}, result.Deleted)
+}
+
+func TestMaxRetriesExceededErrorIsTerminal(t *testing.T) {
+	t.Parallel()
+	res := smith_v1.Resource{
+		Name:       "a",
+		MaxRetries: 2,
+	}
+	st := bundleSyncTask{
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{res},
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusError{
+					err:              errors.New("boom"),
+					isRetriableError: true,
+				},
+			},
+		},
+	}
+
+	for expectedFailures := int32(1); expectedFailures <= 2; expectedFailures++ {
+		assert.Equal(t, expectedFailures, st.consecutiveFailures(res))
+		_, inProgressCond, _, errorCond := st.resourceConditions(res)
+		assert.Equal(t, smith_v1.ResourceReasonRetriableError, errorCond.Reason)
+		assert.Equal(t, smith_v1.ConditionTrue, inProgressCond.Status)
+		st.bundle.Status.ResourceStatuses = []smith_v1.ResourceStatus{
+			{
+				Name:                "a",
+				ConsecutiveFailures: expectedFailures,
+			},
+		}
+	}
+
+	// Limit is exceeded
+	assert.EqualValues(t, 3, st.consecutiveFailures(res))
+	_, inProgressCond, _, errorCond := st.resourceConditions(res)
+	assert.Equal(t, smith_v1.ResourceReasonTerminalError, errorCond.Reason)
+	assert.Equal(t, "giving up after 3 consecutive failures: boom", errorCond.Message)
+	assert.Equal(t, smith_v1.ConditionFalse, inProgressCond.Status)
+
+	// Counter is capped
+	st.bundle.Status.ResourceStatuses[0].ConsecutiveFailures = 3
+	assert.EqualValues(t, 3, st.consecutiveFailures(res))
+
+	// Success resets the counter
+	st.processedResources["a"].status = resourceStatusReady{}
+	assert.Zero(t, st.consecutiveFailures(res))
+}
+
+func TestClearedErrorIsHeld(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	errorTransition := meta_v1.NewTime(time.Now().Add(-time.Hour))
+	st := bundleSyncTask{
+		logger:        logger,
+		bundleClient:  simulationBundlesGetter{},
+		recorder:      &record.FakeRecorder{},
+		errorHoldTime: time.Minute,
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+				},
+			},
+			Status: smith_v1.BundleStatus{
+				Conditions: []smith_v1.BundleCondition{
+					{
+						Type:               smith_v1.BundleError,
+						Status:             smith_v1.ConditionTrue,
+						Reason:             smith_v1.BundleReasonRetriableError,
+						Message:            "boom",
+						LastTransitionTime: errorTransition,
+						LastUpdateTime:     errorTransition,
+					},
+				},
+				ResourceStatuses: []smith_v1.ResourceStatus{
+					{
+						Name: "a",
+						Conditions: []smith_v1.ResourceCondition{
+							{
+								Type:               smith_v1.ResourceError,
+								Status:             smith_v1.ConditionTrue,
+								Reason:             smith_v1.ResourceReasonRetriableError,
+								Message:            "boom",
+								LastTransitionTime: errorTransition,
+								LastUpdateTime:     errorTransition,
+							},
+						},
+					},
+				},
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusReady{},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{},
+	}
+
+	// Error has just cleared
+	clearedAt := time.Now()
+	_, err := st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	// Bundle is requeued when the hold expires
+	assert.True(t, st.requeueAfter >= time.Minute-time.Since(clearedAt), st.requeueAfter)
+	assert.True(t, st.requeueAfter <= time.Minute+time.Second, st.requeueAfter)
+
+	_, bundleErrorCond := st.bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, bundleErrorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, bundleErrorCond.Status)
+	heldUntil, held := errorHeldUntil(bundleErrorCond.Message)
+	require.True(t, held, bundleErrorCond.Message)
+	assert.Equal(t, "boom (error has cleared, condition is held until "+heldUntil.UTC().Format(time.RFC3339)+")", bundleErrorCond.Message)
+	assert.True(t, errorTransition.Equal(&bundleErrorCond.LastTransitionTime))
+	// Bundle is not ready while the Error condition is held
+	_, bundleReadyCond := st.bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, bundleReadyCond)
+	assert.Equal(t, smith_v1.ConditionFalse, bundleReadyCond.Status)
+	assert.Equal(t, smith_v1.BundleReasonErrorHeld, bundleReadyCond.Reason)
+
+	_, resStatus := st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	_, resErrorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, resErrorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, resErrorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonRetriableError, resErrorCond.Reason)
+	_, resReadyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, resReadyCond)
+	assert.Equal(t, smith_v1.ConditionFalse, resReadyCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonErrorHeld, resReadyCond.Reason)
+
+	// Hold continues with the same message
+	heldMessage := bundleErrorCond.Message
+	st.requeueAfter = 0
+	_, err = st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.NotZero(t, st.requeueAfter)
+	_, bundleErrorCond = st.bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, bundleErrorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, bundleErrorCond.Status)
+	assert.Equal(t, heldMessage, bundleErrorCond.Message)
+
+	// Hold has expired
+	expired := "boom (error has cleared, condition is held until " + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339) + ")"
+	bundleErrorCond.Message = expired
+	_, resStatus = st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	_, resErrorCond = resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, resErrorCond)
+	resErrorCond.Message = expired
+	st.requeueAfter = 0
+	_, err = st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.Zero(t, st.requeueAfter)
+
+	_, bundleErrorCond = st.bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, bundleErrorCond)
+	assert.Equal(t, smith_v1.ConditionFalse, bundleErrorCond.Status)
+	_, bundleReadyCond = st.bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, bundleReadyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, bundleReadyCond.Status)
+	_, resStatus = st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	_, resErrorCond = resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, resErrorCond)
+	assert.Equal(t, smith_v1.ConditionFalse, resErrorCond.Status)
+}
+
+func TestForceReadyUnblocksDependents(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+			Annotations: map[string]string{
+				smith.ForceReadyAnnotation: "other, deployment",
+			},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "deployment",
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "d1",
+							},
+						},
+					},
+				},
+				{
+					Name: "config",
+					References: []smith_v1.Reference{
+						{
+							Resource: "deployment",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	assert.Len(t, result.Created, 2)
+	assert.Empty(t, result.Blocked)
+	_, readyCond := result.Bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("deployment")
+	require.NotNil(t, resStatus)
+	_, forceReadyCond := resStatus.GetCondition(smith_v1.ResourceForceReady)
+	require.NotNil(t, forceReadyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, forceReadyCond.Status)
+
+	_, resStatus = result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, forceReadyCond = resStatus.GetCondition(smith_v1.ResourceForceReady)
+	assert.Nil(t, forceReadyCond)
+}
+
+// controlledObjectsStore returns a fixed list of objects as controlled by any Bundle.
+type controlledObjectsStore struct {
+	Store
+	objs []runtime.Object
+}
+
+func (s controlledObjectsStore) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error) {
+	return s.objs, nil
+}
+
+func controlledObjects() map[string]runtime.Object {
+	typed := &core_v1.ConfigMap{
+		// TypeMeta is empty, as it is for typed objects from informers
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "map1",
+			UID:  "map1-uid",
+		},
+	}
+	unstr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": "map1",
+				"uid":  "map1-uid",
+			},
+		},
+	}
+	return map[string]runtime.Object{
+		"typed":        typed,
+		"unstructured": unstr,
+	}
+}
+
+func TestFindObjectsToDeleteResolvesGVK(t *testing.T) {
+	t.Parallel()
+	sc := runtime.NewScheme()
+	require.NoError(t, core_v1.AddToScheme(sc))
+
+	for name, obj := range controlledObjects() {
+		obj := obj
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			st := bundleSyncTask{
+				store:  controlledObjectsStore{objs: []runtime.Object{obj}},
+				scheme: sc,
+				bundle: &smith_v1.Bundle{},
+			}
+
+			require.NoError(t, st.findObjectsToDelete())
+			assert.Equal(t, map[objectRef]runtime.Object{
+				{
+					GroupVersionKind: core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+					Name:             "map1",
+				}: obj,
+			}, st.objectsToDelete)
+		})
+	}
+}
+
+func TestDeleteAllResourcesResolvesGVK(t *testing.T) {
+	t.Parallel()
+	sc := runtime.NewScheme()
+	require.NoError(t, core_v1.AddToScheme(sc))
+
+	for name, obj := range controlledObjects() {
+		obj := obj
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+			result := &ReconcileResult{}
+			st := bundleSyncTask{
+				logger:      logger,
+				store:       controlledObjectsStore{objs: []runtime.Object{obj}},
+				smartClient: &simulationSmartClient{result: result},
+				scheme:      sc,
+				bundle:      &smith_v1.Bundle{},
+			}
+
+			_, _, err := st.deleteAllResources()
+			require.NoError(t, err)
+			assert.Equal(t, []smith_v1.ObjectToDelete{
+				{Version: "v1", Kind: "ConfigMap", Name: "map1"},
+			}, result.Deleted)
+		})
+	}
+}
+
+// noClientsSmartClient fails to provide a client for any GVK, as if CRDs were removed.
+type noClientsSmartClient struct {
+}
+
+func (noClientsSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return nil, errors.Errorf("no client for %s", gvk)
+}
+
+func TestDeleteAllResourcesUnresolvableGvkPolicy(t *testing.T) {
+	t.Parallel()
+	inputs := map[UnresolvableGvkPolicy]string{
+		UnresolvableGvkPolicyBlock: "no client for /v1, Kind=ConfigMap",
+		UnresolvableGvkPolicySkip:  "",
+	}
+	for policy, expectedErr := range inputs {
+		policy := policy
+		expectedErr := expectedErr
+		t.Run(string(policy), func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+			recorder := record.NewFakeRecorder(1)
+			st := bundleSyncTask{
+				logger:                logger,
+				store:                 controlledObjectsStore{objs: []runtime.Object{controlledObjects()["unstructured"]}},
+				smartClient:           noClientsSmartClient{},
+				bundle:                &smith_v1.Bundle{},
+				recorder:              recorder,
+				unresolvableGvkPolicy: policy,
+			}
+
+			_, _, err := st.deleteAllResources()
+			if expectedErr == "" {
+				require.NoError(t, err)
+				require.Len(t, recorder.Events, 1)
+				assert.Equal(t, `Warning ObjectOrphaned Leaving ConfigMap "map1" orphaned because a client for it cannot be obtained: no client for /v1, Kind=ConfigMap`, <-recorder.Events)
+			} else {
+				assert.EqualError(t, err, expectedErr)
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func TestDeleteAllResourcesInBatches(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	now := meta_v1.Now()
+	var objs []runtime.Object
+	for _, name := range []string{"map1", "map2", "map3"} {
+		objs = append(objs, &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				UID:  types.UID(name + "-uid"),
+			},
+		})
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              "bundle1",
+			Namespace:         "ns",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{FinalizerDeleteResources},
+		},
+	}
+	newTask := func(objs []runtime.Object, result *ReconcileResult) *bundleSyncTask {
+		return &bundleSyncTask{
+			logger:            logger,
+			store:             controlledObjectsStore{objs: objs},
+			smartClient:       &simulationSmartClient{result: result},
+			bundle:            bundle,
+			deletionBatchSize: 2,
+		}
+	}
+
+	// First batch
+	result := &ReconcileResult{}
+	st := newTask(objs, result)
+	_, err := st.processDeleted()
+	require.NoError(t, err)
+	assert.Len(t, result.Deleted, 2)
+	assert.Nil(t, st.newFinalizers)
+	assert.Equal(t, deletionBatchRequeueDelay, st.requeueAfter)
+	require.NotNil(t, st.deletingCond)
+	assert.Equal(t, smith_v1.BundleReasonDeletingObjects, st.deletingCond.Reason)
+	assert.Equal(t, "Objects are being deleted, 3 object(s) remaining", st.deletingCond.Message)
+
+	// Second batch, first two objects are being deleted
+	for _, obj := range objs[:2] {
+		obj.(*core_v1.ConfigMap).DeletionTimestamp = &now
+	}
+	result = &ReconcileResult{}
+	st = newTask(objs, result)
+	_, err = st.processDeleted()
+	require.NoError(t, err)
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{Version: "v1", Kind: "ConfigMap", Name: "map3"},
+	}, result.Deleted)
+	assert.Nil(t, st.newFinalizers)
+
+	// All objects are gone
+	result = &ReconcileResult{}
+	st = newTask(nil, result)
+	_, err = st.processDeleted()
+	require.NoError(t, err)
+	assert.Empty(t, result.Deleted)
+	assert.Empty(t, st.newFinalizers)
+	assert.NotNil(t, st.newFinalizers)
+	assert.Zero(t, st.requeueAfter)
+}
+
+func TestExternalFinalizers(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       "bundle1",
+			Namespace:  "ns",
+			UID:        "bundle1-uid",
+			Finalizers: []string{"other/finalizer"},
+		},
+		Spec: smith_v1.BundleSpec{
+			Finalizers: []string{"example.com/cleanup", FinalizerDeleteResources},
+		},
+	}
+
+	// Finalizers are added
+	st := bundleSyncTask{
+		logger: logger,
+		bundle: bundle,
+	}
+	_, err := st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other/finalizer", FinalizerDeleteResources, "example.com/cleanup"}, st.newFinalizers)
+	assert.Equal(t, []string{"other/finalizer"}, bundle.Finalizers, "Bundle must not be mutated")
+
+	// Deleted Bundle waits for the external finalizer to be removed
+	now := meta_v1.Now()
+	bundle.DeletionTimestamp = &now
+	bundle.Finalizers = st.newFinalizers
+	result := &ReconcileResult{}
+	st = bundleSyncTask{
+		logger:      logger,
+		store:       controlledObjectsStore{},
+		smartClient: &simulationSmartClient{result: result},
+		bundle:      bundle,
+	}
+	_, err = st.processDeleted()
+	require.NoError(t, err)
+	assert.Nil(t, st.newFinalizers)
+
+	// External finalizer is removed
+	bundle.Finalizers = []string{"other/finalizer", FinalizerDeleteResources}
+	st = bundleSyncTask{
+		logger:      logger,
+		store:       controlledObjectsStore{},
+		smartClient: &simulationSmartClient{result: result},
+		bundle:      bundle,
+	}
+	_, err = st.processDeleted()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other/finalizer"}, st.newFinalizers)
+}
+
+func TestDeletingCondition(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	now := meta_v1.Now()
+	bundle := &smith_v1.Bundle{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       smith_v1.BundleResourceKind,
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              "bundle1",
+			Namespace:         "ns",
+			UID:               "bundle1-uid",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{meta_v1.FinalizerDeleteDependents},
+		},
+	}
+	trueVar := true
+	configMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "map1",
+			Namespace: "ns",
+			UID:       "map1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion:         smith_v1.BundleResourceGroupVersion,
+					Kind:               smith_v1.BundleResourceKind,
+					Name:               "bundle1",
+					UID:                "bundle1-uid",
+					Controller:         &trueVar,
+					BlockOwnerDeletion: &trueVar,
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{configMap})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	_, cond := result.Bundle.GetCondition(smith_v1.BundleDeleting)
+	require.NotNil(t, cond)
+	assert.Equal(t, smith_v1.ConditionTrue, cond.Status)
+	assert.Equal(t, smith_v1.BundleReasonDeletingObjects, cond.Reason)
+	assert.Equal(t, "Objects are being deleted by garbage collector, 1 object(s) remaining", cond.Message)
+
+	// Protected Bundle reports why objects are kept
+	bundle.Finalizers = []string{FinalizerDeleteResources}
+	bundle.Annotations = map[string]string{
+		smith.DeletionProtectionAnnotation: "true",
+	}
+	result, err = s.Simulate(bundle, []runtime.Object{configMap})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	_, cond = result.Bundle.GetCondition(smith_v1.BundleDeleting)
+	require.NotNil(t, cond)
+	assert.Equal(t, smith_v1.BundleReasonDeletionProtected, cond.Reason)
+	assert.Equal(t, "Objects are not deleted because Bundle has smith.atlassian.com/deletionProtection annotation, 1 object(s) remaining", cond.Message)
+	_, cond = result.Bundle.GetCondition(smith_v1.BundleInProgress)
+	require.NotNil(t, cond)
+	assert.Equal(t, smith_v1.ConditionTrue, cond.Status)
+	assert.Equal(t, smith_v1.BundleReasonDeletionProtected, cond.Reason)
+	_, cond = result.Bundle.GetCondition(smith_v1.BundleError)
+	assert.Nil(t, cond)
+}
+
+func TestDeletionProtectionEventIsEmittedOnce(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	now := meta_v1.Now()
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              "bundle1",
+			Namespace:         "ns",
+			UID:               "bundle1-uid",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{FinalizerDeleteResources},
+			Annotations: map[string]string{
+				smith.DeletionProtectionAnnotation: "true",
+			},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	for i := 0; i < 2; i++ {
+		result := &ReconcileResult{}
+		st := bundleSyncTask{
+			logger:       logger,
+			bundleClient: simulationBundlesGetter{},
+			smartClient:  &simulationSmartClient{result: result},
+			store:        controlledObjectsStore{},
+			bundle:       bundle,
+			recorder:     recorder,
+		}
+		st.runRecorded(result)
+		require.NoError(t, result.Error)
+		assert.False(t, result.Retriable)
+		assert.Empty(t, result.Deleted)
+		bundle = result.Bundle
+	}
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, EventReasonDeletionProtected)
+}
+
+func TestConditionsObserveBundleGeneration(t *testing.T) {
+	t.Parallel()
+	transitionTime := meta_v1.NewTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Generation: 2,
+		},
+		Status: smith_v1.BundleStatus{
+			Conditions: []smith_v1.BundleCondition{
+				{Type: smith_v1.BundleReady, Status: smith_v1.ConditionTrue, LastTransitionTime: transitionTime, ObservedGeneration: 1},
+			},
+			ResourceStatuses: []smith_v1.ResourceStatus{
+				{
+					Name: "a",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionTrue, LastTransitionTime: transitionTime, ObservedGeneration: 2},
+					},
+				},
+			},
+		},
+	}
+
+	// Condition observed at an older generation is updated
+	bundleCond := smith_v1.BundleCondition{Type: smith_v1.BundleReady, Status: smith_v1.ConditionTrue}
+	assert.True(t, updateBundleCondition(bundle, &bundleCond, 0))
+	assert.EqualValues(t, 2, bundleCond.ObservedGeneration)
+	assert.Equal(t, transitionTime, bundleCond.LastTransitionTime)
+
+	// Condition observed at the current generation is not updated
+	resCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionTrue}
+	assert.False(t, updateResourceCondition(bundle, "a", &resCond).updated)
+	assert.EqualValues(t, 2, resCond.ObservedGeneration)
+}
+
+func TestResourceConditionTransition(t *testing.T) {
+	t.Parallel()
+	bundle := &smith_v1.Bundle{
+		Status: smith_v1.BundleStatus{
+			ResourceStatuses: []smith_v1.ResourceStatus{
+				{
+					Name: "a",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse},
+					},
+				},
+			},
+		},
+	}
+
+	readyCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionTrue}
+	update := updateResourceCondition(bundle, "a", &readyCond)
+	assert.True(t, update.transitioned())
+	assert.Equal(t, "a/Ready: False -> True", update.String())
+
+	errorCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceError, Status: smith_v1.ConditionFalse}
+	update = updateResourceCondition(bundle, "a", &errorCond)
+	assert.True(t, update.transitioned())
+	assert.Equal(t, "a/Error: None -> False", update.String())
+
+	// Message change is an update but not a transition
+	readyCond = smith_v1.ResourceCondition{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse, Message: "changed"}
+	update = updateResourceCondition(bundle, "a", &readyCond)
+	assert.True(t, update.updated)
+	assert.False(t, update.transitioned())
+}
+
+func TestLastActionUnchangedKeepsTime(t *testing.T) {
+	t.Parallel()
+	actionTime := meta_v1.NewTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	st := bundleSyncTask{
+		bundle: &smith_v1.Bundle{
+			Status: smith_v1.BundleStatus{
+				ResourceStatuses: []smith_v1.ResourceStatus{
+					{Name: "a", LastAction: smith_v1.ResourceActionUnchanged, LastActionTime: actionTime},
+					{Name: "b", LastAction: smith_v1.ResourceActionCreated, LastActionTime: actionTime},
+					{Name: "c", LastAction: smith_v1.ResourceActionCreated, LastActionTime: actionTime},
+				},
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {action: smith_v1.ResourceActionUnchanged},
+			"b": {action: smith_v1.ResourceActionUpdated},
+			"c": {}, // blocked, no action taken
+		},
+	}
+
+	action, at := st.lastAction(smith_v1.Resource{Name: "a"})
+	assert.Equal(t, smith_v1.ResourceActionUnchanged, action)
+	assert.True(t, actionTime.Equal(&at))
+
+	action, at = st.lastAction(smith_v1.Resource{Name: "b"})
+	assert.Equal(t, smith_v1.ResourceActionUpdated, action)
+	assert.True(t, at.After(actionTime.Time))
+
+	action, at = st.lastAction(smith_v1.Resource{Name: "c"})
+	assert.Equal(t, smith_v1.ResourceActionCreated, action)
+	assert.True(t, actionTime.Equal(&at))
+
+	action, at = st.lastAction(smith_v1.Resource{Name: "d"})
+	assert.Empty(t, action)
+	assert.True(t, at.IsZero())
+}
+
+func TestScheduledBundleIsOnlyMonitoredBetweenScheduledTimes(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"replicas": "10",
+							},
+						},
+					},
+				},
+			},
+			Schedule: &smith_v1.BundleSchedule{
+				DailyAt: []string{"08:00"},
+			},
+		},
+		Status: smith_v1.BundleStatus{
+			LastScheduledReconcileTime: meta_v1.Now(),
+		},
+	}
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+		Data: map[string]string{
+			"replicas": "1",
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	// Scheduled reconcile has completed, object is only monitored
+	result, err := s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Updated)
+	_, readyCond := result.Bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+	assert.True(t, bundle.Status.LastScheduledReconcileTime.Equal(&result.Bundle.Status.LastScheduledReconcileTime))
+
+	// Scheduled time has come, object is updated
+	bundle.Status.LastScheduledReconcileTime = meta_v1.Time{}
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Updated, 1)
+	assert.Equal(t, "cm1", result.Updated[0].GetName())
+	assert.False(t, result.Bundle.Status.LastScheduledReconcileTime.IsZero())
+}
+
+func TestObjectInOtherNamespaceIsRejected(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name:      "cm1",
+								Namespace: "other-ns",
+							},
+						},
+					},
+				},
+				{
+					Name: "config2",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name:      "cm2",
+								Namespace: "ns",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		ValidateObjectNamespace: true,
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.EqualError(t, result.Error, `error processing resource(s): ["config"]`)
+	assert.False(t, result.Retriable)
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm2", result.Created[0].GetName())
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonTerminalError, errorCond.Reason)
+	assert.Equal(t, `object namespace "other-ns" does not match Bundle namespace "ns"`, errorCond.Message)
+}
+
+func TestClusterScopedObjectIsRejectedUnlessAllowed(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "uid123",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "namespace",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.Namespace{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Namespace",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "ns2",
+							},
+						},
+					},
+				},
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	mapper := meta.NewDefaultRESTMapper(nil, meta.InterfacesForUnstructured)
+	mapper.Add(core_v1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		RESTMapper:              mapper,
+		ValidateObjectNamespace: true,
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.EqualError(t, result.Error, `error processing resource(s): ["namespace"]`)
+	assert.False(t, result.Retriable)
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm1", result.Created[0].GetName())
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("namespace")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonTerminalError, errorCond.Reason)
+	assert.Equal(t, `object Namespace "ns2" is cluster-scoped, cluster-scoped objects are not allowed`, errorCond.Message)
+
+	// Cluster-scoped objects are allowed explicitly
+	s.AllowClusterScopedObjects = true
+	result, err = s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Len(t, result.Created, 2)
+}
+
+// fieldManagerSmartClient records writes of objects together with their field managers.
+// Patches are applied to objects.
+type fieldManagerSmartClient struct {
+	objects map[string]*unstructured.Unstructured
+	mx      sync.Mutex
+	writes  map[string]string // verb and name -> field manager
+}
+
+func (c *fieldManagerSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return c.ForGVKWithFieldManager(gvk, namespace, "")
+}
+
+func (c *fieldManagerSmartClient) ForGVKWithFieldManager(gvk schema.GroupVersionKind, namespace, fieldManager string) (dynamic.ResourceInterface, error) {
+	return &fieldManagerResourceClient{
+		client:       c,
+		fieldManager: fieldManager,
+	}, nil
+}
+
+func (c *fieldManagerSmartClient) record(verb, name, fieldManager string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.writes[verb+" "+name] = fieldManager
+}
+
+type fieldManagerResourceClient struct {
+	dynamic.ResourceInterface
+	client       *fieldManagerSmartClient
+	fieldManager string
+}
+
+func (c *fieldManagerResourceClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.client.record("create", obj.GetName(), c.fieldManager)
+	return obj.DeepCopy(), nil
+}
+
+func (c *fieldManagerResourceClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.client.record("update", obj.GetName(), c.fieldManager)
+	return obj.DeepCopy(), nil
+}
+
+func (c *fieldManagerResourceClient) Patch(name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error) {
+	c.client.record("patch", name, c.fieldManager)
+	var patch map[string]interface{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	return applyObjectPatch(c.client.objects[name], pt, patch)
+}
+
+func (c *fieldManagerResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.client.record("delete", name, c.fieldManager)
+	return nil
+}
+
+func TestWritesUseFieldManager(t *testing.T) {
+	t.Parallel()
+	tr := true
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+			Data: map[string]string{
+				"a": "b",
+			},
+		}
+	}
+	newExisting := func(name string) *core_v1.ConfigMap {
+		existing := newConfigMap(name)
+		existing.Namespace = "ns"
+		existing.UID = types.UID(name + "-uid")
+		existing.Data = nil
+		existing.OwnerReferences = []meta_v1.OwnerReference{
+			{
+				APIVersion: smith_v1.BundleResourceGroupVersion,
+				Kind:       smith_v1.BundleResourceKind,
+				Name:       "bundle1",
+				UID:        "bundle1-uid",
+				Controller: &tr,
+			},
+		}
+		return existing
+	}
+
+	for _, patchUpdates := range []bool{false, true} {
+		patchUpdates := patchUpdates
+		t.Run(fmt.Sprintf("patchUpdates=%t", patchUpdates), func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+			updated := newExisting("updated")
+			store, err := newSimulationStore([]runtime.Object{updated, newExisting("removed")})
+			require.NoError(t, err)
+			updatedUnstr, err := util.RuntimeToUnstructured(updated)
+			require.NoError(t, err)
+			smartClient := &fieldManagerSmartClient{
+				objects: map[string]*unstructured.Unstructured{
+					"updated": updatedUnstr,
+				},
+				writes: make(map[string]string),
+			}
+			st := bundleSyncTask{
+				logger:      logger,
+				smartClient: smartClient,
+				rc:          configMapsReadyChecker{},
+				store:       store,
+				specCheck: &speccheck.SpecCheck{
+					Logger:  logger,
+					Cleaner: cleanup.New(),
+				},
+				bundle: &smith_v1.Bundle{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:       "bundle1",
+						Namespace:  "ns",
+						UID:        "bundle1-uid",
+						Finalizers: []string{FinalizerDeleteResources},
+					},
+					Spec: smith_v1.BundleSpec{
+						Resources: []smith_v1.Resource{
+							{
+								Name: "created",
+								Spec: smith_v1.ResourceSpec{
+									Object: newConfigMap("created"),
+								},
+							},
+							{
+								Name: "updated",
+								Spec: smith_v1.ResourceSpec{
+									Object: newConfigMap("updated"),
+								},
+							},
+						},
+					},
+				},
+				recorder:     record.NewFakeRecorder(10),
+				fieldManager: "smith-test",
+				patchUpdates: patchUpdates,
+			}
+
+			_, err = st.processNormal()
+			require.NoError(t, err)
+			if !patchUpdates {
+				assert.Equal(t, map[string]string{
+					"create created": "smith-test",
+					"update updated": "smith-test",
+					"delete removed": "smith-test",
+				}, smartClient.writes)
+				return
+			}
+			// Objects of the Bundle are written with the field manager of the Bundle
+			assert.Equal(t, map[string]string{
+				"create created": "smith-test/ns/bundle1",
+				"patch updated":  "smith-test/ns/bundle1",
+				"delete removed": "smith-test",
+			}, smartClient.writes)
+			assert.Equal(t, map[string]interface{}{"a": "b"}, st.processedResources["updated"].actual.Object["data"])
+		})
+	}
+}
+
+// conflictingSmartClient fails the first update or patch of an object with a conflict and serves the latest object.
+type conflictingSmartClient struct {
+	dynamic.ResourceInterface
+	latest  *unstructured.Unstructured
+	updates int
+	gets    int
+}
+
+func (c *conflictingSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return c, nil
+}
+
+func (c *conflictingSmartClient) Get(name string, opts meta_v1.GetOptions) (*unstructured.Unstructured, error) {
+	c.gets++
+	return c.latest.DeepCopy(), nil
+}
+
+func (c *conflictingSmartClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.updates++
+	if c.updates == 1 {
+		return nil, api_errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), errors.New("stale"))
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (c *conflictingSmartClient) Patch(name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error) {
+	c.updates++
+	if c.updates == 1 {
+		return nil, api_errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, name, errors.New("stale"))
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	return applyObjectPatch(c.latest, pt, patch)
+}
+
+func (c *conflictingSmartClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return obj.DeepCopy(), nil
+}
+
+// immutableSmartClient rejects updates of objects as invalid and records deletions.
+type immutableSmartClient struct {
+	dynamic.ResourceInterface
+	deleted []meta_v1.DeleteOptions
+}
+
+func (c *immutableSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return c, nil
+}
+
+func (c *immutableSmartClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return nil, api_errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, obj.GetName(), nil)
+}
+
+func (c *immutableSmartClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.deleted = append(c.deleted, *options)
+	return nil
+}
+
+func TestRecreateUpdateStrategy(t *testing.T) {
+	t.Parallel()
+	tr := true
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+
+	for _, strategy := range []smith_v1.UpdateStrategy{smith_v1.UpdateStrategyUpdate, smith_v1.UpdateStrategyRecreate} {
+		strategy := strategy
+		t.Run(string(strategy), func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+			store, err := newSimulationStore([]runtime.Object{existing})
+			require.NoError(t, err)
+			smartClient := &immutableSmartClient{}
+			st := bundleSyncTask{
+				logger:      logger,
+				smartClient: smartClient,
+				rc:          configMapsReadyChecker{},
+				store:       store,
+				specCheck: &speccheck.SpecCheck{
+					Logger:  logger,
+					Cleaner: cleanup.New(),
+				},
+				bundle: &smith_v1.Bundle{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:       "bundle1",
+						Namespace:  "ns",
+						UID:        "bundle1-uid",
+						Finalizers: []string{FinalizerDeleteResources},
+					},
+					Spec: smith_v1.BundleSpec{
+						Resources: []smith_v1.Resource{
+							{
+								Name:           "config",
+								UpdateStrategy: strategy,
+								DeletePolicy:   meta_v1.DeletePropagationBackground,
+								Spec: smith_v1.ResourceSpec{
+									Object: &core_v1.ConfigMap{
+										TypeMeta: meta_v1.TypeMeta{
+											Kind:       "ConfigMap",
+											APIVersion: core_v1.SchemeGroupVersion.String(),
+										},
+										ObjectMeta: meta_v1.ObjectMeta{
+											Name: "cm1",
+										},
+										Data: map[string]string{
+											"a": "b",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			_, err = st.processNormal()
+			require.NoError(t, err)
+			resInfo := st.processedResources["config"]
+			if strategy == smith_v1.UpdateStrategyUpdate {
+				_, err = resInfo.fetchError()
+				require.Error(t, err)
+				assert.True(t, api_errors.IsInvalid(errors.Cause(err)))
+				assert.Empty(t, smartClient.deleted)
+				return
+			}
+			assert.Equal(t, resourceStatusInProgress{waitingForOldObjectDeletion: true}, resInfo.status)
+			assert.Equal(t, smith_v1.ResourceActionDeleted, resInfo.action)
+			require.Len(t, smartClient.deleted, 1)
+			deleted := smartClient.deleted[0]
+			require.NotNil(t, deleted.Preconditions)
+			require.NotNil(t, deleted.Preconditions.UID)
+			assert.EqualValues(t, "cm1-uid", *deleted.Preconditions.UID)
+			require.NotNil(t, deleted.PropagationPolicy)
+			assert.Equal(t, meta_v1.DeletePropagationBackground, *deleted.PropagationPolicy)
+		})
+	}
+}
+
+func TestConflictIsRetried(t *testing.T) {
+	t.Parallel()
+	tr := true
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm1",
+			Namespace:       "ns",
+			UID:             "cm1-uid",
+			ResourceVersion: "1",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	latest, err := util.RuntimeToUnstructured(existing)
+	require.NoError(t, err)
+	latest.SetResourceVersion("2")
+
+	for _, tc := range []struct {
+		conflictRetries int
+		patchUpdates    bool
+	}{
+		{conflictRetries: 0},
+		{conflictRetries: 1},
+		// Patches are retried and short-circuit processing the same way as updates
+		{conflictRetries: 0, patchUpdates: true},
+		{conflictRetries: 1, patchUpdates: true},
+	} {
+		conflictRetries := tc.conflictRetries
+		patchUpdates := tc.patchUpdates
+		t.Run(fmt.Sprintf("retries=%d,patchUpdates=%t", conflictRetries, patchUpdates), func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+			store, err := newSimulationStore([]runtime.Object{existing})
+			require.NoError(t, err)
+			smartClient := &conflictingSmartClient{latest: latest}
+			st := bundleSyncTask{
+				logger:      logger,
+				smartClient: smartClient,
+				rc:          configMapsReadyChecker{},
+				store:       store,
+				specCheck: &speccheck.SpecCheck{
+					Logger:  logger,
+					Cleaner: cleanup.New(),
+				},
+				bundle: &smith_v1.Bundle{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:       "bundle1",
+						Namespace:  "ns",
+						UID:        "bundle1-uid",
+						Finalizers: []string{FinalizerDeleteResources},
+					},
+					Spec: smith_v1.BundleSpec{
+						Resources: []smith_v1.Resource{
+							{
+								Name: "config",
+								Spec: smith_v1.ResourceSpec{
+									Object: &core_v1.ConfigMap{
+										TypeMeta: meta_v1.TypeMeta{
+											Kind:       "ConfigMap",
+											APIVersion: core_v1.SchemeGroupVersion.String(),
+										},
+										ObjectMeta: meta_v1.ObjectMeta{
+											Name: "cm1",
+										},
+										Data: map[string]string{
+											"a": "b",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				recorder:        record.NewFakeRecorder(10),
+				conflictRetries: conflictRetries,
+				patchUpdates:    patchUpdates,
+			}
+
+			_, err = st.processNormal()
+			if conflictRetries == 0 {
+				require.Error(t, err)
+				assert.True(t, api_errors.IsConflict(errors.Cause(err)))
+				assert.Equal(t, 1, smartClient.updates)
+				assert.Zero(t, smartClient.gets)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 2, smartClient.updates)
+			assert.Equal(t, 1, smartClient.gets)
+			assert.True(t, st.processedResources["config"].isReady())
+		})
+	}
+}
+
+func TestContinueOnConflict(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm1",
+			Namespace:       "ns",
+			UID:             "cm1-uid",
+			ResourceVersion: "1",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	configMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+			Data: map[string]string{
+				"a": "b",
+			},
+		}
+	}
+	store, err := newSimulationStore([]runtime.Object{existing})
+	require.NoError(t, err)
+	smartClient := &conflictingSmartClient{}
+	st := bundleSyncTask{
+		logger:      logger,
+		smartClient: smartClient,
+		rc:          configMapsReadyChecker{},
+		store:       store,
+		specCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "conflicting",
+						Spec: smith_v1.ResourceSpec{
+							Object: configMap("cm1"),
+						},
+					},
+					{
+						Name: "independent",
+						Spec: smith_v1.ResourceSpec{
+							Object: configMap("cm2"),
+						},
+					},
+					{
+						Name: "dependent",
+						References: []smith_v1.Reference{
+							{Resource: "independent"},
+						},
+						Spec: smith_v1.ResourceSpec{
+							Object: configMap("cm3"),
+						},
+					},
+				},
+			},
+		},
+		recorder:           record.NewFakeRecorder(10),
+		continueOnConflict: true,
+	}
+
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, 1, smartClient.updates)
+	retriable, resErr := st.processedResources["conflicting"].fetchError()
+	require.Error(t, resErr)
+	assert.True(t, api_errors.IsConflict(errors.Cause(resErr)))
+	assert.True(t, retriable)
+	// Other resources progress despite the conflict
+	assert.True(t, st.processedResources["independent"].isReady())
+	assert.True(t, st.processedResources["dependent"].isReady())
+}
+
+func TestWaitForOldObjectDeletion(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	deletionTimestamp := meta_v1.NewTime(time.Now().Add(-time.Minute))
+	old := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              "cm1",
+			Namespace:         "ns",
+			UID:               "cm1-uid",
+			DeletionTimestamp: &deletionTimestamp,
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{old})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Updated)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, inProgressCond := resStatus.GetCondition(smith_v1.ResourceInProgress)
+	require.NotNil(t, inProgressCond)
+	assert.Equal(t, smith_v1.ConditionTrue, inProgressCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonWaitingForOldObjectDeletion, inProgressCond.Reason)
+
+	// Timeout
+	store, err := newSimulationStore([]runtime.Object{old})
+	require.NoError(t, err)
+	for timeout, timedOut := range map[time.Duration]bool{time.Second: true, time.Hour: false} {
+		rst := resourceSyncTask{
+			logger:                   logger,
+			store:                    store,
+			bundle:                   bundle,
+			oldObjectDeletionTimeout: timeout,
+		}
+		_, status := rst.getActualObject(&bundle.Spec.Resources[0])
+		if timedOut {
+			require.IsType(t, resourceStatusError{}, status)
+			assert.EqualError(t, status.(resourceStatusError).err, "timed out waiting for old object to be deleted (waited for 1s)")
+			assert.Zero(t, rst.requeueAfter)
+		} else {
+			assert.Equal(t, resourceStatusInProgress{waitingForOldObjectDeletion: true}, status)
+			assert.True(t, rst.requeueAfter > 58*time.Minute && rst.requeueAfter <= 59*time.Minute, "%s", rst.requeueAfter)
+		}
+	}
+}
+
+func TestWaitForDeletionNotObservedByStore(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	old := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	deleted := newDeletedObjects()
+
+	// Deletion is recorded
+	st := bundleSyncTask{
+		logger:         logger,
+		smartClient:    &deletePolicySmartClient{policies: make(map[string]meta_v1.DeletionPropagation)},
+		bundle:         bundle,
+		deletedObjects: deleted,
+	}
+	st.deleteObject(&objectDeletion{
+		ref: objectRef{
+			GroupVersionKind: core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+			Name:             "cm1",
+		},
+		obj:    old,
+		logger: logger,
+	})
+
+	// Store still has the deleted object without DeletionTimestamp
+	store, err := newSimulationStore([]runtime.Object{old})
+	require.NoError(t, err)
+	rst := resourceSyncTask{
+		logger:         logger,
+		store:          store,
+		bundle:         bundle,
+		deletedObjects: deleted,
+	}
+	actual, status := rst.getActualObject(&bundle.Spec.Resources[0])
+	assert.Nil(t, actual)
+	assert.Equal(t, resourceStatusInProgress{waitingForOldObjectDeletion: true}, status)
+
+	// Store observed the deletion, object can be created
+	rst.store, err = newSimulationStore(nil)
+	require.NoError(t, err)
+	actual, status = rst.getActualObject(&bundle.Spec.Resources[0])
+	assert.Nil(t, actual)
+	assert.Nil(t, status)
+	assert.Empty(t, deleted.objects)
+
+	// A new object with the same name is not affected
+	deleted.add("ns", schema.GroupKind{Kind: "ConfigMap"}, "cm1", "cm1-uid")
+	recreated := old.DeepCopy()
+	recreated.UID = "cm1-uid-2"
+	rst.store, err = newSimulationStore([]runtime.Object{recreated})
+	require.NoError(t, err)
+	actual, status = rst.getActualObject(&bundle.Spec.Resources[0])
+	assert.NotNil(t, actual)
+	assert.Nil(t, status)
+	assert.Empty(t, deleted.objects)
+}
+
+func TestIndependentResourcesAreProcessedConcurrently(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	var resources []smith_v1.Resource
+	var dependencies []smith_v1.ResourceName
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("cm%d", i)
+		resources = append(resources, smith_v1.Resource{
+			Name: smith_v1.ResourceName(name),
+			Spec: smith_v1.ResourceSpec{
+				Object: newConfigMap(name),
+			},
+		})
+		dependencies = append(dependencies, smith_v1.ResourceName(name))
+	}
+	resources = append(resources, smith_v1.Resource{
+		Name:     "last",
+		RunAfter: dependencies,
+		Spec: smith_v1.ResourceSpec{
+			Object: newConfigMap("last"),
+		},
+	})
+	store, err := newSimulationStore(nil)
+	require.NoError(t, err)
+	result := &ReconcileResult{}
+	st := bundleSyncTask{
+		logger:      logger,
+		smartClient: &simulationSmartClient{result: result},
+		rc:          configMapsReadyChecker{},
+		store:       store,
+		specCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: resources,
+			},
+		},
+		recorder:               &record.FakeRecorder{},
+		maxConcurrentResources: 3,
+	}
+	_, err = st.processNormal()
+	require.NoError(t, err)
+
+	require.Len(t, result.Created, len(resources))
+	// Dependent resource is processed after all of its dependencies
+	assert.Equal(t, "last", result.Created[len(resources)-1].GetName())
+	for _, res := range resources {
+		assert.True(t, st.processedResources[res.Name].isReady(), res.Name)
+	}
+}
+
+// deletePolicySmartClient records propagation policies and grace periods objects are deleted with.
+type deletePolicySmartClient struct {
+	policies     map[string]meta_v1.DeletionPropagation
+	gracePeriods map[string]*int64
+}
+
+func (c *deletePolicySmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return &deletePolicyResourceClient{policies: c.policies, gracePeriods: c.gracePeriods}, nil
+}
+
+type deletePolicyResourceClient struct {
+	dynamic.ResourceInterface
+	policies     map[string]meta_v1.DeletionPropagation
+	gracePeriods map[string]*int64
+}
+
+func (c *deletePolicyResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.policies[name] = *options.PropagationPolicy
+	if c.gracePeriods != nil {
+		c.gracePeriods[name] = options.GracePeriodSeconds
+	}
+	return nil
+}
+
+func TestDeletePolicy(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	// Object of a resource with a policy
+	cm1 := newConfigMap("cm1")
+	// Object of a removed resource with a recorded policy
+	cm2 := newConfigMap("cm2")
+	cm2.Annotations = map[string]string{
+		smith.DeletePolicyAnnotation: string(meta_v1.DeletePropagationBackground),
+	}
+	// Object without a policy
+	cm3 := newConfigMap("cm3")
+
+	now := meta_v1.Now()
+	client := &deletePolicySmartClient{policies: make(map[string]meta_v1.DeletionPropagation)}
+	st := bundleSyncTask{
+		logger:      logger,
+		store:       controlledObjectsStore{objs: []runtime.Object{cm1, cm2, cm3}},
+		smartClient: client,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              "bundle1",
+				Namespace:         "ns",
+				DeletionTimestamp: &now,
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name:         "cm1",
+						DeletePolicy: meta_v1.DeletePropagationOrphan,
+						Spec: smith_v1.ResourceSpec{
+							Object: newConfigMap("cm1"),
+						},
+					},
+				},
+			},
+		},
+	}
+	_, _, err := st.deleteAllResources()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]meta_v1.DeletionPropagation{
+		"cm1": meta_v1.DeletePropagationOrphan,
+		"cm2": meta_v1.DeletePropagationBackground,
+		"cm3": meta_v1.DeletePropagationForeground,
+	}, client.policies)
+}
+
+func TestDeletePolicyIsRecordedOnObject(t *testing.T) {
+	t.Parallel()
+	st := resourceSyncTask{
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "bundle1",
+			},
+		},
+	}
+	res := &smith_v1.Resource{
+		Name:         "cm1",
+		DeletePolicy: meta_v1.DeletePropagationOrphan,
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "cm1",
+				},
+			},
+		},
+	}
+	obj, err := st.evalSpec(res, nil)
+	require.NoError(t, err)
+	assert.Equal(t, string(meta_v1.DeletePropagationOrphan), obj.GetAnnotations()[smith.DeletePolicyAnnotation])
+
+	res.DeletePolicy = "Sometimes"
+	_, err = st.evalSpec(res, nil)
+	assert.EqualError(t, err, `invalid delete policy "Sometimes", must be one of "Foreground", "Background" or "Orphan"`)
+}
+
+func TestInvalidUpdateStrategy(t *testing.T) {
+	t.Parallel()
+	st := resourceSyncTask{
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "bundle1",
+			},
+		},
+	}
+	res := &smith_v1.Resource{
+		Name:           "cm1",
+		UpdateStrategy: "Sometimes",
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "cm1",
+				},
+			},
+		},
+	}
+	_, err := st.evalSpec(res, nil)
+	assert.EqualError(t, err, `invalid update strategy "Sometimes", must be one of "Update", "CreateOnly", "Recreate" or "Patch"`)
+
+	// Create-only resources must not have an update strategy
+	res.CreateOnly = true
+	res.UpdateStrategy = smith_v1.UpdateStrategyCreateOnly
+	_, err = st.evalSpec(res, nil)
+	assert.EqualError(t, err, `createOnly cannot be combined with update strategy "CreateOnly", use update strategy "CreateOnly" to check readiness of existing objects`)
+}
+
+func TestResourceStatusSpecHash(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"a": "b",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	expectedHash, err := bundle.Spec.Resources[0].SpecHash()
+	require.NoError(t, err)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	assert.Equal(t, expectedHash, resStatus.SpecHash)
+
+	// Status predates an edit of the resource
+	bundle.Spec.Resources[0].Spec.Object.(*core_v1.ConfigMap).Data["a"] = "c"
+	editedHash, err := bundle.Spec.Resources[0].SpecHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, editedHash, resStatus.SpecHash)
+}
+
+func TestOptionalReferenceDoesNotBlock(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					References: []smith_v1.Reference{
+						{
+							Name:     "replicas",
+							Resource: "deployment",
+							Path:     "status.replicas",
+							Optional: true,
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+				{
+					Name: "deployment",
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "d1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	// Deployment is never ready but ConfigMap is created anyway
+	assert.Len(t, result.Created, 2)
+	assert.Empty(t, result.Blocked)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+}
+
+func TestDefaultedReferenceToBlockedDependency(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "deployment",
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "d1",
+							},
+						},
+					},
+				},
+				{
+					Name: "blocked",
+					References: []smith_v1.Reference{
+						{
+							Resource: "deployment",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm2",
+							},
+						},
+					},
+				},
+				{
+					Name: "config",
+					References: []smith_v1.Reference{
+						{
+							Name:     "value",
+							Resource: "blocked",
+							Path:     "data.key",
+							Default:  "fallback",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"key": "!{value}",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	// Object of the blocked dependency does not exist, default is used and it is not an owner
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{
+		"blocked": {"deployment"},
+	}, result.Blocked)
+	var cm1 *unstructured.Unstructured
+	for _, obj := range result.Created {
+		if obj.GetName() == "cm1" {
+			cm1 = obj
+		}
+	}
+	require.NotNil(t, cm1)
+	value, _, err := unstructured.NestedString(cm1.Object, "data", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", value)
+	require.Len(t, cm1.GetOwnerReferences(), 1)
+	assert.Equal(t, smith_v1.BundleResourceKind, cm1.GetOwnerReferences()[0].Kind)
+}
+
+func TestDisabledResourceIsNotCreatedAndItsObjectIsDeleted(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:     "disabled",
+					Disabled: true,
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+				{
+					Name: "optional",
+					References: []smith_v1.Reference{
+						{
+							Name:     "data",
+							Resource: "disabled",
+							Path:     "data",
+							Optional: true,
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm2",
+							},
+						},
+					},
+				},
+				{
+					Name:     "blocked",
+					RunAfter: []smith_v1.ResourceName{"disabled"},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm3",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	// Only the resource with an optional reference to the disabled resource is created
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm2", result.Created[0].GetName())
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{"blocked": {"disabled"}}, result.Blocked)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("disabled")
+	assert.Nil(t, resStatus)
+	_, resStatus = result.Bundle.Status.GetResourceStatus("blocked")
+	require.NotNil(t, resStatus)
+	_, blockedCond := resStatus.GetCondition(smith_v1.ResourceBlocked)
+	require.NotNil(t, blockedCond)
+	assert.Equal(t, `Not ready: "disabled" (disabled)`, blockedCond.Message)
+
+	// Object of the disabled resource is going to be deleted once the Bundle is ready
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{
+			Version: "v1",
+			Kind:    "ConfigMap",
+			Name:    "cm1",
+		},
+	}, result.Bundle.Status.ObjectsToDelete)
+}
+
+func TestRetriableErrorBackoff(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	st := bundleSyncTask{
+		logger:         logger,
+		bundleClient:   simulationBundlesGetter{},
+		recorder:       &record.FakeRecorder{},
+		retryBaseDelay: time.Minute,
+		retryMaxDelay:  3 * time.Minute,
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+				},
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusError{
+					err:              errors.New("boom"),
+					isRetriableError: true,
+				},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{},
+	}
+
+	for i, expectedDelay := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		st.requeueAfter = 0
+		start := time.Now()
+		retriable, err := st.handleProcessResult(false, nil)
+		require.Error(t, err)
+		assert.False(t, retriable, "Bundle is requeued with backoff instead")
+		assert.InDelta(t, float64(expectedDelay), float64(st.requeueAfter), float64(time.Second))
+
+		_, resStatus := st.bundle.Status.GetResourceStatus("a")
+		require.NotNil(t, resStatus)
+		assert.EqualValues(t, i+1, resStatus.RetryCount)
+		require.NotNil(t, resStatus.NextRetryTime)
+		assert.InDelta(t, float64(expectedDelay), float64(resStatus.NextRetryTime.Sub(start)), float64(time.Second))
+		_, errorCond := st.bundle.GetCondition(smith_v1.BundleError)
+		require.NotNil(t, errorCond)
+		assert.Equal(t, smith_v1.BundleReasonRetriableError, errorCond.Reason)
+	}
+
+	// Counter is reset once the resource is ready
+	st.processedResources["a"].status = resourceStatusReady{}
+	st.requeueAfter = 0
+	_, err := st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.Zero(t, st.requeueAfter)
+	_, resStatus := st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	assert.Zero(t, resStatus.RetryCount)
+	assert.Nil(t, resStatus.NextRetryTime)
+}
+
+func TestResourceIsNotRetriedBeforeNextRetryTime(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	nextRetryTime := meta_v1.NewTime(time.Now().Add(time.Minute))
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       "bundle1",
+			Namespace:  "ns",
+			UID:        "bundle1-uid",
+			Generation: 1,
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+		Status: smith_v1.BundleStatus{
+			ResourceStatuses: []smith_v1.ResourceStatus{
+				{
+					Name: "config",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionFalse, ObservedGeneration: 1},
+						{Type: smith_v1.ResourceInProgress, Status: smith_v1.ConditionTrue, ObservedGeneration: 1},
+						{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse, ObservedGeneration: 1},
+						{
+							Type:               smith_v1.ResourceError,
+							Status:             smith_v1.ConditionTrue,
+							Reason:             smith_v1.ResourceReasonRetriableError,
+							Message:            "boom",
+							ObservedGeneration: 1,
+						},
+					},
+					RetryCount:    1,
+					NextRetryTime: &nextRetryTime,
+				},
+			},
+		},
+	}
+	bundle.Finalizers = addMissingFinalizers(bundle)
+	simulate := func(t *testing.T, bundle *smith_v1.Bundle) (*ReconcileResult, *bundleSyncTask) {
+		store, err := newSimulationStore(nil)
+		require.NoError(t, err)
+		result := &ReconcileResult{
+			Blocked: make(map[smith_v1.ResourceName][]smith_v1.ResourceName),
+		}
+		st := &bundleSyncTask{
+			logger:         logger,
+			bundleClient:   simulationBundlesGetter{},
+			smartClient:    &simulationSmartClient{result: result, store: store},
+			rc:             configMapsReadyChecker{},
+			store:          store,
+			specCheck:      &speccheck.SpecCheck{Logger: logger, Cleaner: cleanup.New()},
+			bundle:         bundle.DeepCopy(),
+			recorder:       &record.FakeRecorder{},
+			retryBaseDelay: time.Minute,
+		}
+		st.runRecorded(result)
+		return result, st
+	}
+
+	// Resource is not processed, previous error is kept and the Bundle is requeued at the retry time
+	result, st := simulate(t, bundle)
+	assert.Empty(t, result.Created)
+	assert.InDelta(t, float64(time.Minute), float64(st.requeueAfter), float64(time.Second))
+	assert.False(t, result.Retriable, "Bundle is requeued with backoff instead")
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	assert.EqualValues(t, 1, resStatus.RetryCount)
+	assert.True(t, nextRetryTime.Equal(resStatus.NextRetryTime))
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonRetriableError, errorCond.Reason)
+	assert.Equal(t, "boom", errorCond.Message)
+
+	// Resource is processed once the Bundle changes
+	changed := bundle.DeepCopy()
+	changed.Generation = 2
+	result, _ = simulate(t, changed)
+	require.NoError(t, result.Error)
+	assert.Len(t, result.Created, 1)
+
+	// Resource is processed once the retry time has passed
+	expired := bundle.DeepCopy()
+	expired.Status.ResourceStatuses[0].NextRetryTime = &meta_v1.Time{Time: time.Now().Add(-time.Second)}
+	result, _ = simulate(t, expired)
+	require.NoError(t, result.Error)
+	assert.Len(t, result.Created, 1)
+	_, resStatus = result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	assert.Zero(t, resStatus.RetryCount)
+	assert.Nil(t, resStatus.NextRetryTime)
+}
+
+func TestDuplicateObjectsAreRejected(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMap := func(apiVersion string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: apiVersion,
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "cm1",
+			},
+		}
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config1",
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap(core_v1.SchemeGroupVersion.String()),
+					},
+				},
+				{
+					Name: "config2",
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("v2"),
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	assert.EqualError(t, result.Error, `resources "config1" and "config2" produce the same object ConfigMap "cm1"`)
+	assert.False(t, result.Retriable)
+	assert.Empty(t, result.Created)
+}
+
+func TestMissingPluginFailsResource(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "p1",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "missing",
+							ObjectName: "obj1",
+						},
+					},
+				},
+				{
+					Name:     "config1",
+					RunAfter: []smith_v1.ResourceName{"p1"},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	assert.False(t, result.Retriable)
+	assert.Empty(t, result.Created)
+	assert.Equal(t, []smith_v1.ResourceName{"p1"}, result.Blocked["config1"])
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("p1")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonTerminalError, errorCond.Reason)
+	assert.Equal(t, `no such plugin "missing"`, errorCond.Message)
+}
+
+func TestBlockedConditionMessage(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "deployment",
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "d1",
+							},
+						},
+					},
+				},
+				{
+					Name:     "blocked1",
+					RunAfter: []smith_v1.ResourceName{"deployment"},
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm1"),
+					},
+				},
+				{
+					Name:     "blocked2",
+					RunAfter: []smith_v1.ResourceName{"deployment", "blocked1"},
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm2"),
+					},
+				},
+				{
+					Name:     "blocked3",
+					RunAfter: []smith_v1.ResourceName{"blocked1"},
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm3"),
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("blocked2")
+	require.NotNil(t, resStatus)
+	_, blockedCond := resStatus.GetCondition(smith_v1.ResourceBlocked)
+	require.NotNil(t, blockedCond)
+	assert.Equal(t, `Not ready: "blocked1" (blocked), "deployment" (in progress)`, blockedCond.Message)
+	// Object of the Deployment exists but is not ready
+	assert.Equal(t, smith_v1.ResourceReasonDependenciesNotReady, blockedCond.Reason)
+
+	// Object of the blocked dependency has not been created
+	_, resStatus = result.Bundle.Status.GetResourceStatus("blocked3")
+	require.NotNil(t, resStatus)
+	_, blockedCond = resStatus.GetCondition(smith_v1.ResourceBlocked)
+	require.NotNil(t, blockedCond)
+	assert.Equal(t, smith_v1.ConditionTrue, blockedCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonDependenciesNotCreated, blockedCond.Reason)
+}
+
+// concurrentDeletionSmartClient tracks the maximum number of concurrent deletions and fails deletion
+// of objects listed in failures.
+type concurrentDeletionSmartClient struct {
+	failures map[string]error
+
+	mx          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	deleted     []string
+}
+
+func (c *concurrentDeletionSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return &concurrentDeletionResourceClient{smartClient: c}, nil
+}
+
+type concurrentDeletionResourceClient struct {
+	dynamic.ResourceInterface
+	smartClient *concurrentDeletionSmartClient
+}
+
+func (c *concurrentDeletionResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	sc := c.smartClient
+	sc.mx.Lock()
+	sc.inFlight++
+	if sc.inFlight > sc.maxInFlight {
+		sc.maxInFlight = sc.inFlight
+	}
+	sc.mx.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+	sc.inFlight--
+	sc.deleted = append(sc.deleted, name)
+	return sc.failures[name]
+}
+
+// deletionNamespaceSmartClient records namespaces objects are deleted in.
+type deletionNamespaceSmartClient struct {
+	namespaces map[string]string
+}
+
+func (c *deletionNamespaceSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return &deletionNamespaceResourceClient{smartClient: c, namespace: namespace}, nil
+}
+
+type deletionNamespaceResourceClient struct {
+	dynamic.ResourceInterface
+	smartClient *deletionNamespaceSmartClient
+	namespace   string
+}
+
+func (c *deletionNamespaceResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.smartClient.namespaces[name] = c.namespace
+	return nil
+}
+
+func TestClusterScopedObjectsAreDeleted(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	owner := []meta_v1.OwnerReference{
+		{
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+			Kind:       smith_v1.BundleResourceKind,
+			Name:       "bundle1",
+			UID:        "bundle1-uid",
+			Controller: &tr,
+		},
+	}
+	configMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm1",
+			Namespace:       "ns",
+			UID:             "cm1-uid",
+			OwnerReferences: owner,
+		},
+	}
+	clusterRoleBinding := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRoleBinding",
+		},
+	}
+	clusterRoleBinding.SetName("crb1")
+	clusterRoleBinding.SetUID("crb1-uid")
+	clusterRoleBinding.SetOwnerReferences(owner)
+	store, err := newSimulationStore([]runtime.Object{configMap, clusterRoleBinding})
+	require.NoError(t, err)
+
+	client := &deletionNamespaceSmartClient{namespaces: make(map[string]string)}
+	st := bundleSyncTask{
+		logger:      logger,
+		store:       store,
+		smartClient: client,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "bundle1",
+				Namespace: "ns",
+				UID:       "bundle1-uid",
+			},
+		},
+	}
+	require.NoError(t, st.findObjectsToDelete())
+	assert.Len(t, st.objectsToDelete, 2)
+	_, err = st.deleteRemovedResources()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cm1":  "ns",
+		"crb1": meta_v1.NamespaceNone,
+	}, client.namespaces)
+}
+
+func TestRetainReclaimPolicyOrphansRemovedObjects(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	removed := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+				{
+					APIVersion: "v1",
+					Kind:       "Secret",
+					Name:       "s1",
+					UID:        "s1-uid",
+				},
+			},
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			ReclaimPolicy: smith_v1.ReclaimPolicyRetain,
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{removed})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Deleted)
+	require.Len(t, result.Updated, 1)
+	assert.Equal(t, "cm1", result.Updated[0].GetName())
+	assert.Equal(t, []meta_v1.OwnerReference{
+		{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Name:       "s1",
+			UID:        "s1-uid",
+		},
+	}, result.Updated[0].GetOwnerReferences())
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{
+			Version: "v1",
+			Kind:    "ConfigMap",
+			Name:    "cm1",
+		},
+	}, result.Bundle.Status.ObjectsToDelete)
+}
+
+func TestConcurrentDeletion(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	var objs []runtime.Object
+	for i := 1; i <= 6; i++ {
+		objs = append(objs, &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: fmt.Sprintf("map%d", i),
+				UID:  types.UID(fmt.Sprintf("map%d-uid", i)),
+			},
+		})
+	}
+	client := &concurrentDeletionSmartClient{
+		failures: map[string]error{
+			"map2": errors.New("map2 failed"),
+			"map5": errors.New("map5 failed"),
+		},
+	}
+	now := meta_v1.Now()
+	st := bundleSyncTask{
+		logger:      logger,
+		store:       controlledObjectsStore{objs: objs},
+		smartClient: client,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              "bundle1",
+				Namespace:         "ns",
+				DeletionTimestamp: &now,
+			},
+		},
+		maxConcurrentDeletions: 2,
+	}
+	remaining, retriable, err := st.deleteAllResources()
+	require.EqualError(t, err, "map2 failed") // First error wins regardless of completion order
+	assert.True(t, retriable)
+	assert.Equal(t, len(objs), remaining)
+	assert.Len(t, client.deleted, len(objs))
+	assert.Equal(t, 2, client.maxInFlight)
+}
+
+func TestProgressDeadline(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	deadline := int32(60)
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		recorder:     &record.FakeRecorder{},
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+				},
+				ProgressDeadlineSeconds: &deadline,
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusInProgress{},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{},
+	}
+
+	// Resource is not ready for the first time, deadline starts
+	_, err := st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+
+	// Deadline has not been exceeded yet, Bundle is requeued to check it again
+	st.requeueAfter = 0
+	_, err = st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Minute), float64(st.requeueAfter), float64(time.Second))
+
+	// Deadline is exceeded
+	_, readyCond := st.bundle.Status.ResourceStatuses[0].GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	readyCond.LastTransitionTime = meta_v1.NewTime(time.Now().Add(-2 * time.Minute))
+	retriable, err := st.handleProcessResult(false, nil)
+	require.Error(t, err)
+	assert.False(t, retriable)
+	_, resStatus := st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonDeadlineExceeded, errorCond.Reason)
+	assert.Equal(t, "resource has not become ready within the progress deadline of 60s", errorCond.Message)
+	_, bundleErrorCond := st.bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, bundleErrorCond)
+	assert.Equal(t, smith_v1.BundleReasonTerminalError, bundleErrorCond.Reason)
+}
+
+func TestProgressDeadlineRestartsOnGenerationChange(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	deadline := int32(60)
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		recorder:     &record.FakeRecorder{},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Generation: 1,
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+				},
+				ProgressDeadlineSeconds: &deadline,
+			},
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusInProgress{},
+			},
+		},
+		objectsToDelete: map[objectRef]runtime.Object{},
+	}
+
+	// Resource has been not ready for longer than the deadline at the previous generation
+	_, err := st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	_, readyCond := st.bundle.Status.ResourceStatuses[0].GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	readyCond.LastTransitionTime = meta_v1.NewTime(time.Now().Add(-2 * time.Minute))
+
+	// Bundle has changed, the deadline starts again
+	st.bundle.Generation = 2
+	st.requeueAfter = 0
+	_, err = st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Minute), float64(st.requeueAfter), float64(time.Second))
+	_, resStatus := st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	require.NotNil(t, resStatus.ProgressStartTime)
+	assert.WithinDuration(t, time.Now(), resStatus.ProgressStartTime.Time, time.Second)
+	_, readyCond = resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	assert.True(t, readyCond.LastTransitionTime.Before(resStatus.ProgressStartTime))
+
+	// Deadline is tracked from the restart while the generation stays the same
+	st.requeueAfter = 0
+	_, err = st.handleProcessResult(false, nil)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Minute), float64(st.requeueAfter), float64(time.Second))
+
+	// Deadline is exceeded
+	st.bundle.Status.ResourceStatuses[0].ProgressStartTime = &meta_v1.Time{Time: time.Now().Add(-90 * time.Second)}
+	retriable, err := st.handleProcessResult(false, nil)
+	require.Error(t, err)
+	assert.False(t, retriable)
+	_, resStatus = st.bundle.Status.GetResourceStatus("a")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ResourceReasonDeadlineExceeded, errorCond.Reason)
+}
+
+func TestConditionHistory(t *testing.T) {
+	t.Parallel()
+	bundle := &smith_v1.Bundle{}
+	update := func(status smith_v1.ConditionStatus, reason string) {
+		cond := smith_v1.BundleCondition{Type: smith_v1.BundleReady, Status: status, Reason: reason}
+		updateBundleCondition(bundle, &cond, 2)
+		bundle.Status.Conditions = []smith_v1.BundleCondition{cond}
+	}
+	statuses := func() []smith_v1.ConditionStatus {
+		var result []smith_v1.ConditionStatus
+		for _, transition := range bundle.Status.Conditions[0].Transitions {
+			result = append(result, transition.Status)
+		}
+		return result
+	}
+
+	update(smith_v1.ConditionTrue, "")
+	assert.Equal(t, []smith_v1.ConditionStatus{smith_v1.ConditionTrue}, statuses())
+
+	// Update without a transition is not recorded
+	update(smith_v1.ConditionTrue, "")
+	assert.Equal(t, []smith_v1.ConditionStatus{smith_v1.ConditionTrue}, statuses())
+
+	update(smith_v1.ConditionFalse, "Broken")
+	assert.Equal(t, []smith_v1.ConditionStatus{smith_v1.ConditionTrue, smith_v1.ConditionFalse}, statuses())
+	assert.Equal(t, "Broken", bundle.Status.Conditions[0].Transitions[1].Reason)
+
+	// Oldest transitions are dropped
+	update(smith_v1.ConditionTrue, "")
+	assert.Equal(t, []smith_v1.ConditionStatus{smith_v1.ConditionFalse, smith_v1.ConditionTrue}, statuses())
+
+	// History is dropped when disabled
+	cond := smith_v1.BundleCondition{Type: smith_v1.BundleReady, Status: smith_v1.ConditionTrue}
+	assert.True(t, updateBundleCondition(bundle, &cond, 0))
+	assert.Nil(t, cond.Transitions)
+}
+
+func TestDeleteGracePeriod(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	// Object of a resource with a grace period
+	cm1 := newConfigMap("cm1")
+	// Object of a removed resource with a recorded grace period
+	cm2 := newConfigMap("cm2")
+	cm2.Annotations = map[string]string{
+		smith.DeleteGracePeriodAnnotation: "30",
+	}
+	// Object without a grace period
+	cm3 := newConfigMap("cm3")
+
+	gracePeriod := int64(60)
+	now := meta_v1.Now()
+	client := &deletePolicySmartClient{
+		policies:     make(map[string]meta_v1.DeletionPropagation),
+		gracePeriods: make(map[string]*int64),
+	}
+	st := bundleSyncTask{
+		logger:      logger,
+		store:       controlledObjectsStore{objs: []runtime.Object{cm1, cm2, cm3}},
+		smartClient: client,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              "bundle1",
+				Namespace:         "ns",
+				DeletionTimestamp: &now,
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name:                     "cm1",
+						DeleteGracePeriodSeconds: &gracePeriod,
+						Spec: smith_v1.ResourceSpec{
+							Object: newConfigMap("cm1"),
+						},
+					},
+				},
+			},
+		},
+	}
+	_, _, err := st.deleteAllResources()
+	require.NoError(t, err)
+	require.NotNil(t, client.gracePeriods["cm1"])
+	assert.EqualValues(t, 60, *client.gracePeriods["cm1"])
+	require.NotNil(t, client.gracePeriods["cm2"])
+	assert.EqualValues(t, 30, *client.gracePeriods["cm2"])
+	assert.Nil(t, client.gracePeriods["cm3"])
+}
+
+func TestDeleteGracePeriodIsRecordedOnObject(t *testing.T) {
+	t.Parallel()
+	st := resourceSyncTask{
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "bundle1",
+			},
+		},
+	}
+	gracePeriod := int64(60)
+	res := &smith_v1.Resource{
+		Name:                     "cm1",
+		DeleteGracePeriodSeconds: &gracePeriod,
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "cm1",
+				},
+			},
+		},
+	}
+	obj, err := st.evalSpec(res, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "60", obj.GetAnnotations()[smith.DeleteGracePeriodAnnotation])
+
+	gracePeriod = -1
+	_, err = st.evalSpec(res, nil)
+	assert.EqualError(t, err, "invalid delete grace period -1, must not be negative")
+}
+
+func TestPausedBundle(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	resourceStatuses := []smith_v1.ResourceStatus{
+		{
+			Name: "a",
+			Conditions: []smith_v1.ResourceCondition{
+				{Type: smith_v1.ResourceInProgress, Status: smith_v1.ConditionTrue},
+			},
+		},
+	}
+	result := &ReconcileResult{}
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		smartClient:  &simulationSmartClient{result: result},
+		recorder:     &record.FakeRecorder{},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "a",
+								},
+							},
+						},
+					},
+				},
+				Paused: true,
+			},
+			Status: smith_v1.BundleStatus{
+				Conditions: []smith_v1.BundleCondition{
+					{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionTrue},
+					{Type: smith_v1.BundleReady, Status: smith_v1.ConditionFalse},
+					{Type: smith_v1.BundleError, Status: smith_v1.ConditionFalse},
+				},
+				ResourceStatuses: resourceStatuses,
+			},
+		},
+	}
+	retriable, err := st.processNormal()
+	retriable, err = st.handleProcessResult(retriable, err)
+	require.NoError(t, err)
+	assert.False(t, retriable)
+
+	assert.Empty(t, result.Created)
+	assert.Equal(t, resourceStatuses, st.bundle.Status.ResourceStatuses)
+	_, inProgressCond := st.bundle.GetCondition(smith_v1.BundleInProgress)
+	require.NotNil(t, inProgressCond)
+	assert.Equal(t, smith_v1.ConditionFalse, inProgressCond.Status)
+	assert.Equal(t, smith_v1.BundleReasonPaused, inProgressCond.Reason)
+	assert.Len(t, st.bundle.Status.Conditions, 3)
+}
+
+func TestBlockedResourceIsRequeued(t *testing.T) {
+	t.Parallel()
+	st := bundleSyncTask{
+		blockedRequeueDelay: time.Minute,
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {
+				status: resourceStatusReady{},
+			},
+		},
+	}
+
+	st.requeueIfBlocked()
+	assert.Zero(t, st.requeueAfter)
+
+	st.processedResources["b"] = &resourceInfo{
+		status: resourceStatusDependenciesNotReady{
+			dependencies: []smith_v1.ResourceName{"a"},
+		},
+	}
+	st.requeueIfBlocked()
+	assert.Equal(t, time.Minute, st.requeueAfter)
+
+	// Sooner requeue is kept
+	st.requeueAfter = time.Second
+	st.requeueIfBlocked()
+	assert.Equal(t, time.Second, st.requeueAfter)
+
+	// Disabled
+	st.blockedRequeueDelay = 0
+	st.requeueAfter = 0
+	st.requeueIfBlocked()
+	assert.Zero(t, st.requeueAfter)
+}
+
+func TestBlockedRequeueIsJittered(t *testing.T) {
+	t.Parallel()
+	st := bundleSyncTask{
+		blockedRequeueDelay: time.Minute,
+		requeueJitter:       0.5,
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"b": {
+				status: resourceStatusDependenciesNotReady{
+					dependencies: []smith_v1.ResourceName{"a"},
+				},
+			},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		st.requeueAfter = 0
+		st.requeueIfBlocked()
+		assert.True(t, st.requeueAfter >= time.Minute, st.requeueAfter)
+		assert.True(t, st.requeueAfter <= 90*time.Second, st.requeueAfter)
+	}
+}
+
+func TestTooManyResourcesIsTerminalError(t *testing.T) {
+	t.Parallel()
+	st := bundleSyncTask{
+		maxResources: 1,
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "a",
+					},
+					{
+						Name: "b",
+					},
+				},
+			},
+		},
+	}
+
+	retriable, err := st.processNormal()
+	require.EqualError(t, err, "bundle has 2 resources, maximum allowed number of resources is 1")
+	assert.False(t, retriable)
+	assert.Nil(t, st.processedResources)
+}
+
+// recreatedObjectsSmartClient simulates objects that were deleted and re-created out-of-band.
+type recreatedObjectsSmartClient struct {
+	mx      sync.Mutex
+	current map[string]*unstructured.Unstructured
+	deleted []types.UID
+}
+
+func (c *recreatedObjectsSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return &recreatedObjectsResourceClient{smartClient: c}, nil
+}
+
+type recreatedObjectsResourceClient struct {
+	dynamic.ResourceInterface
+	smartClient *recreatedObjectsSmartClient
+}
+
+func (c *recreatedObjectsResourceClient) Get(name string, options meta_v1.GetOptions) (*unstructured.Unstructured, error) {
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	obj, ok := c.smartClient.current[name]
+	if !ok {
+		return nil, api_errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (c *recreatedObjectsResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	obj, ok := c.smartClient.current[name]
+	if !ok {
+		return api_errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	if *options.Preconditions.UID != obj.GetUID() {
+		return api_errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, name, errors.New("UID mismatch"))
+	}
+	c.smartClient.deleted = append(c.smartClient.deleted, obj.GetUID())
+	delete(c.smartClient.current, name)
+	return nil
+}
+
+func TestForceDeletionDeletesRecreatedObjects(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundleRef := meta_v1.OwnerReference{
+		APIVersion: smith_v1.BundleResourceGroupVersion,
+		Kind:       smith_v1.BundleResourceKind,
+		Name:       "bundle1",
+		UID:        "bundle1-uid",
+		Controller: &tr,
+	}
+	newConfigMap := func(name string, uid types.UID) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:            name,
+				UID:             uid,
+				OwnerReferences: []meta_v1.OwnerReference{bundleRef},
+			},
+		}
+	}
+	toUnstructured := func(obj runtime.Object) *unstructured.Unstructured {
+		u, err := util.RuntimeToUnstructured(obj)
+		require.NoError(t, err)
+		return u
+	}
+	// Object re-created by someone else, not controlled by the Bundle
+	cm2 := newConfigMap("cm2", "cm2-new-uid")
+	cm2.OwnerReferences = nil
+
+	now := meta_v1.Now()
+	for _, force := range []bool{false, true} {
+		client := &recreatedObjectsSmartClient{
+			current: map[string]*unstructured.Unstructured{
+				"cm1": toUnstructured(newConfigMap("cm1", "cm1-new-uid")),
+				"cm2": toUnstructured(cm2),
+			},
+		}
+		st := bundleSyncTask{
+			logger: logger,
+			store: controlledObjectsStore{objs: []runtime.Object{
+				newConfigMap("cm1", "cm1-old-uid"),
+				newConfigMap("cm2", "cm2-old-uid"),
+			}},
+			smartClient:   client,
+			forceDeletion: force,
+			bundle: &smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:              "bundle1",
+					Namespace:         "ns",
+					UID:               "bundle1-uid",
+					DeletionTimestamp: &now,
+				},
+			},
+		}
+		_, _, err := st.deleteAllResources()
+		require.NoError(t, err)
+		if force {
+			assert.Equal(t, []types.UID{"cm1-new-uid"}, client.deleted)
+		} else {
+			assert.Empty(t, client.deleted)
+		}
+		assert.Contains(t, client.current, "cm2")
+	}
+}
@@ -0,0 +1,162 @@
+package bundlec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	completionQueueSize      = 1000
+	completionWebhookTimeout = 10 * time.Second
+	// completionWebhookMaxRetryDelay caps the delay between retries which doubles with each attempt.
+	completionWebhookMaxRetryDelay = time.Minute
+)
+
+// CompletionOutcome is the terminal state a Bundle has reached.
+type CompletionOutcome string
+
+const (
+	// CompletionOutcomeReady means all resources of the Bundle are ready.
+	CompletionOutcomeReady CompletionOutcome = "Ready"
+	// CompletionOutcomeError means processing of the Bundle failed with a non-retriable error.
+	CompletionOutcomeError CompletionOutcome = "Error"
+)
+
+// Completion describes a Bundle that has reached a terminal state for its current generation.
+type Completion struct {
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	UID        types.UID         `json:"uid"`
+	Generation int64             `json:"generation"`
+	Outcome    CompletionOutcome `json:"outcome"`
+	Message    string            `json:"message,omitempty"`
+	// TransitionTime is when the Bundle transitioned into the terminal state.
+	TransitionTime meta_v1.Time `json:"transitionTime"`
+	// TraceParent is the W3C trace context traceparent of the processing iteration that observed the
+	// transition. It is sent as the traceparent header. Empty if the iteration is not traced.
+	TraceParent string `json:"-"`
+}
+
+// completionOutcome returns the terminal state of a Bundle according to its Ready and Error conditions
+// observed at the generation. Empty outcome is returned if the Bundle is not in a terminal state.
+// A Bundle with a held Error condition is not considered Ready until the error is released.
+func completionOutcome(generation int64, readyCond, errorCond *smith_v1.BundleCondition) CompletionOutcome {
+	switch {
+	case errorCond != nil && errorCond.ObservedGeneration == generation &&
+		errorCond.Status == smith_v1.ConditionTrue && errorCond.Reason == smith_v1.BundleReasonTerminalError:
+		return CompletionOutcomeError
+	case errorCond != nil && errorCond.Status == smith_v1.ConditionTrue:
+		return ""
+	case readyCond != nil && readyCond.ObservedGeneration == generation && readyCond.Status == smith_v1.ConditionTrue:
+		return CompletionOutcomeReady
+	default:
+		return ""
+	}
+}
+
+// WebhookCompletionNotifier POSTs completions as JSON to a webhook. Delivery is retried with exponential backoff
+// on network errors and on 5xx and 429 responses. Notifications are dropped if the queue is full.
+type WebhookCompletionNotifier struct {
+	logger     *zap.Logger
+	url        string
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	queue      chan Completion
+}
+
+func NewWebhookCompletionNotifier(logger *zap.Logger, url string, retries int, retryDelay time.Duration) *WebhookCompletionNotifier {
+	return &WebhookCompletionNotifier{
+		logger: logger,
+		url:    url,
+		client: &http.Client{
+			Timeout: completionWebhookTimeout,
+		},
+		retries:    retries,
+		retryDelay: retryDelay,
+		queue:      make(chan Completion, completionQueueSize),
+	}
+}
+
+func (n *WebhookCompletionNotifier) NotifyCompletion(completion Completion) {
+	select {
+	case n.queue <- completion:
+	default:
+		n.logger.Error("Completion notification queue is full, dropping notification",
+			zap.String("namespace", completion.Namespace), zap.String("name", completion.Name),
+			zap.Int64("generation", completion.Generation))
+	}
+}
+
+func (n *WebhookCompletionNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case completion := <-n.queue:
+			n.deliver(ctx, completion)
+		}
+	}
+}
+
+// deliver sends the completion, retrying on retriable failures until retries are exhausted.
+func (n *WebhookCompletionNotifier) deliver(ctx context.Context, completion Completion) {
+	logger := n.logger.With(zap.String("namespace", completion.Namespace), zap.String("name", completion.Name),
+		zap.Int64("generation", completion.Generation))
+	body, err := json.Marshal(completion)
+	if err != nil {
+		logger.Error("Failed to marshal completion notification", zap.Error(err))
+		return
+	}
+	retryDelay := n.retryDelay
+	for attempt := 0; ; attempt++ {
+		retriable, err := n.send(ctx, body, completion.TraceParent)
+		if err == nil {
+			logger.Debug("Delivered completion notification", zap.String("outcome", string(completion.Outcome)))
+			return
+		}
+		if !retriable || attempt >= n.retries {
+			logger.Error("Failed to deliver completion notification", zap.Int("attempts", attempt+1), zap.Error(err))
+			return
+		}
+		logger.Debug("Failed to deliver completion notification, retrying", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+		retryDelay *= 2
+		if retryDelay > completionWebhookMaxRetryDelay {
+			retryDelay = completionWebhookMaxRetryDelay
+		}
+	}
+}
+
+func (n *WebhookCompletionNotifier) send(ctx context.Context, body []byte, traceParent string) (retriableError bool, e error) {
+	var header map[string]string
+	if traceParent != "" {
+		header = map[string]string{
+			"traceparent": traceParent,
+		}
+	}
+	statusCode, err := postJSON(ctx, n.client, n.url, body, header)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case isSuccessStatus(statusCode):
+		return false, nil
+	case statusCode >= 500 || statusCode == http.StatusTooManyRequests:
+		return true, errors.Errorf("unexpected response status %d", statusCode)
+	default:
+		return false, errors.Errorf("unexpected response status %d", statusCode)
+	}
+}
@@ -0,0 +1,187 @@
+package bundlec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type recordingCompletionNotifier struct {
+	completions []Completion
+}
+
+func (n *recordingCompletionNotifier) Run(ctx context.Context) {
+}
+
+func (n *recordingCompletionNotifier) NotifyCompletion(completion Completion) {
+	n.completions = append(n.completions, completion)
+}
+
+func TestCompletionOutcome(t *testing.T) {
+	t.Parallel()
+	cond := func(status smith_v1.ConditionStatus, reason string, generation int64) *smith_v1.BundleCondition {
+		return &smith_v1.BundleCondition{
+			Status:             status,
+			Reason:             reason,
+			ObservedGeneration: generation,
+		}
+	}
+	testcases := []struct {
+		name      string
+		readyCond *smith_v1.BundleCondition
+		errorCond *smith_v1.BundleCondition
+		outcome   CompletionOutcome
+	}{
+		{
+			name:    "no conditions",
+			outcome: "",
+		},
+		{
+			name:      "ready",
+			readyCond: cond(smith_v1.ConditionTrue, "", 2),
+			errorCond: cond(smith_v1.ConditionFalse, "", 2),
+			outcome:   CompletionOutcomeReady,
+		},
+		{
+			name:      "ready at old generation",
+			readyCond: cond(smith_v1.ConditionTrue, "", 1),
+			errorCond: cond(smith_v1.ConditionFalse, "", 1),
+			outcome:   "",
+		},
+		{
+			name:      "terminal error",
+			readyCond: cond(smith_v1.ConditionFalse, "", 2),
+			errorCond: cond(smith_v1.ConditionTrue, smith_v1.BundleReasonTerminalError, 2),
+			outcome:   CompletionOutcomeError,
+		},
+		{
+			name:      "retriable error",
+			readyCond: cond(smith_v1.ConditionFalse, "", 2),
+			errorCond: cond(smith_v1.ConditionTrue, smith_v1.BundleReasonRetriableError, 2),
+			outcome:   "",
+		},
+		{
+			name:      "ready with held error",
+			readyCond: cond(smith_v1.ConditionTrue, "", 2),
+			errorCond: cond(smith_v1.ConditionTrue, smith_v1.BundleReasonRetriableError, 2),
+			outcome:   "",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.outcome, completionOutcome(2, tc.readyCond, tc.errorCond))
+		})
+	}
+}
+
+func TestCompletionIsNotifiedOncePerTransition(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	store, err := newSimulationStore(nil)
+	require.NoError(t, err)
+	notifier := &recordingCompletionNotifier{}
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		smartClient:  &simulationSmartClient{result: &ReconcileResult{}},
+		store:        store,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Generation: 3,
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+		},
+		recorder:           &record.FakeRecorder{},
+		completionNotifier: notifier,
+	}
+	for i := 0; i < 2; i++ {
+		retriable, err := st.processNormal()
+		_, err = st.handleProcessResult(retriable, err)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, notifier.completions, 1)
+	_, readyCond := st.bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, []Completion{
+		{
+			Namespace:      "ns",
+			Name:           "bundle1",
+			UID:            "bundle1-uid",
+			Generation:     3,
+			Outcome:        CompletionOutcomeReady,
+			TransitionTime: readyCond.LastTransitionTime,
+		},
+	}, notifier.completions)
+}
+
+func TestWebhookCompletionNotifierRetries(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	var mx sync.Mutex
+	attempts := 0
+	delivered := make(chan Completion, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		attempts++
+		attempt := attempts
+		mx.Unlock()
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var completion Completion
+		if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Trace context is propagated in the header rather than in the body
+		completion.TraceParent = r.Header.Get("traceparent")
+		delivered <- completion
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifier := NewWebhookCompletionNotifier(logger, srv.URL, 1, time.Millisecond)
+	go notifier.Run(ctx)
+
+	completion := Completion{
+		Namespace:   "ns",
+		Name:        "bundle1",
+		UID:         "bundle1-uid",
+		Generation:  2,
+		Outcome:     CompletionOutcomeError,
+		Message:     "error processing resource(s)",
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	notifier.NotifyCompletion(completion)
+
+	select {
+	case actual := <-delivered:
+		assert.Equal(t, completion, actual)
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion was not delivered")
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	assert.Equal(t, 2, attempts)
+}
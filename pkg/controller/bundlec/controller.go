@@ -14,7 +14,9 @@ import (
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	core_v1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 type Controller struct {
@@ -28,6 +30,24 @@ type Controller struct {
 
 	crdContext       context.Context
 	crdContextCancel context.CancelFunc
+	// processContext is cancelled when the controller is shutting down to abort processing of Bundles.
+	processContext       context.Context
+	processContextCancel context.CancelFunc
+
+	// largeBundleSlots limits the number of large Bundles processed concurrently.
+	largeBundleSlots chan struct{}
+	// panicBudget tracks panics during processing of Bundles. Nil if termination is disabled.
+	panicBudget *panicBudget
+	// changes tracks changed objects of Bundles. Nil if selective reconcile is disabled.
+	changes *changeTracker
+	// resourceCache remembers inputs of ready resources. Nil if skipping of unchanged resources is disabled.
+	resourceCache *resourceCache
+	// deletedObjects remembers objects deleted by the controller until the Store observes the deletion.
+	deletedObjects *deletedObjects
+	// queueDepth tracks Bundles waiting in the work queue.
+	queueDepth *queueDepthTracker
+	// delayedRequeue adds Bundles to the work queue after a delay.
+	delayedRequeue *delayedRequeue
 
 	Logger *zap.Logger
 
@@ -42,34 +62,188 @@ type Controller struct {
 
 	// CRD
 	CrdResyncPeriod time.Duration
-	resourceHandler cache.ResourceEventHandler
 	Namespace       string
 
 	PluginContainers map[smith_v1.PluginName]plugin.PluginContainer
 	Scheme           *runtime.Scheme
 
 	Catalog *store.Catalog
+
+	Recorder record.EventRecorder
+	// Metrics records metrics of processing of Bundles. Optional.
+	Metrics *Metrics
+	// SchemaValidator is used to validate objects before they are created/updated. Optional.
+	SchemaValidator SchemaValidator
+
+	// OldObjectDeletionTimeout is how long to wait for an object that is being deleted to be gone before
+	// it is created again. Zero means no timeout.
+	OldObjectDeletionTimeout time.Duration
+	// DeletionBatchSize is the maximum number of objects deleted per iteration when a Bundle is deleted.
+	// If set, the finalizer is only removed once all objects are gone. Zero means all objects are deleted
+	// at once and the finalizer is removed without waiting.
+	DeletionBatchSize int
+	// ForceDeletion makes deletion of objects of a deleted Bundle retry against the current UID of an object
+	// that was deleted and re-created out-of-band, as long as the Bundle still controls it. Otherwise such
+	// objects are skipped.
+	ForceDeletion bool
+	// ConflictRetries is the number of times processing of a resource is retried on conflict, reading the
+	// object from the API server, before processing of the Bundle is short-circuited and the Bundle is requeued.
+	ConflictRetries int
+	// ContinueOnConflict makes a conflict that remains after ConflictRetries fail only the conflicting resource,
+	// with a retriable error, so that other resources of the Bundle are still processed.
+	ContinueOnConflict bool
+	// FieldManager identifies Smith with every write of objects of resources if SmartClient
+	// implements FieldManagerSmartClient. Optional.
+	FieldManager string
+	// PatchUpdates makes existing objects of resources with the Update strategy be patched with fields set in
+	// the Bundle instead of being replaced, as with the Patch update strategy. Objects of each Bundle are written
+	// with their own field manager derived from FieldManager and the Bundle.
+	PatchUpdates bool
+	// MaxConcurrentResources is the maximum number of resources of a Bundle that do not depend on each other
+	// processed concurrently. Values less than 2 mean resources are processed one by one.
+	MaxConcurrentResources int
+	// MaxConcurrentDeletions is the maximum number of objects of a Bundle deleted concurrently.
+	// Values less than 2 mean objects are deleted one by one.
+	MaxConcurrentDeletions int
+	// ValidateObjectNamespace enables validation that objects of resources are in the Bundle's namespace.
+	// Cluster-scoped objects fail the validation unless AllowClusterScopedObjects is set.
+	ValidateObjectNamespace bool
+	// AllowClusterScopedObjects allows resources to have cluster-scoped objects if ValidateObjectNamespace is set.
+	AllowClusterScopedObjects bool
+	// BlockInUseDeletion prevents deletion of objects removed from a Bundle while objects of
+	// resources which are still in the Bundle reference them, or while such resources reference or
+	// run after the resources of the objects (e.g. disabled resources).
+	BlockInUseDeletion bool
+	// ErrorHoldTime is how long Error conditions are held after the error has cleared
+	// to avoid flapping on transient errors. Zero disables holding.
+	ErrorHoldTime time.Duration
+	// RetryBaseDelay is the delay before a resource is retried after its first retriable error.
+	// The delay doubles with each consecutive retriable error, up to RetryMaxDelay.
+	// Zero disables backoff, the Bundle is requeued with the work queue's rate limiting instead.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// BlockedRequeueDelay is the delay after which a Bundle with resources blocked by dependencies is processed
+	// again even if no watch event is received for the dependencies. Zero disables the requeue.
+	BlockedRequeueDelay time.Duration
+	// RequeueJitter is the maximum fraction of BlockedRequeueDelay and of WatchThrottle windows that is randomly
+	// added to them. Spreads processing of Bundles woken up by the same change over time instead of processing
+	// all of them at once. Zero disables jitter.
+	RequeueJitter float64
+	// UnresolvableGvkPolicy defines what to do with objects that cannot be deleted because a client
+	// for their GVK cannot be obtained.
+	UnresolvableGvkPolicy UnresolvableGvkPolicy
+	// ConditionHistorySize is the number of most recent transitions recorded in each condition of a Bundle.
+	// Zero disables the history.
+	ConditionHistorySize int
+
+	// MaxResources is the maximum number of resources in a Bundle. Bundles with more resources fail with
+	// a terminal error without being processed. Zero means no limit.
+	MaxResources int
+
+	// LargeBundleResources is the number of resources starting from which a Bundle is considered large.
+	// At most MaxConcurrentLargeBundles large Bundles are processed concurrently so that they do not
+	// starve small Bundles of workers, e.g. after a restart when all Bundles are enqueued at once.
+	// Zero disables the limit.
+	LargeBundleResources      int
+	MaxConcurrentLargeBundles int
+
+	// ErrorMessageTransformer is used to transform errors into user-friendly messages for conditions. Optional.
+	ErrorMessageTransformer ErrorMessageTransformer
+	// Tracer is used to trace processing of Bundles. Optional.
+	Tracer Tracer
+	// CompletionNotifier is notified when a Bundle reaches Ready or a non-retriable Error for its
+	// current generation. Optional.
+	CompletionNotifier CompletionNotifier
+	// ResourceQuotaLister is used to check that Bundles that request it fit into ResourceQuotas of their
+	// namespaces. Optional, the check is skipped if it is nil.
+	ResourceQuotaLister core_v1listers.ResourceQuotaLister
+
+	// PanicBudget is the number of panics during processing of Bundles that are tolerated within
+	// PanicBudgetWindow. A panic is converted into an Error condition of the Bundle. If the budget is exceeded,
+	// the process is terminated. Zero disables termination.
+	PanicBudget       int
+	PanicBudgetWindow time.Duration
+
+	// FullReconcilePeriod enables selective reconcile. If set, only resources which objects have changed, and
+	// their transitive dependents, are processed when a Bundle is processed because of a change of an object.
+	// Resources that are not affected are skipped if they are ready. All resources are processed if
+	// the Bundle has changed and at least once per the period. Zero disables selective reconcile.
+	FullReconcilePeriod time.Duration
+	// SkipUnchangedResources enables skipping of resources that are ready and have the same definition,
+	// object and objects of dependencies as the last time they were processed.
+	SkipUnchangedResources bool
+
+	// WatchThrottle is a window per object kind within which events for objects of that kind are coalesced
+	// before the owning Bundle is enqueued. Used to avoid reconcile storms caused by objects that
+	// have their status updated constantly. Kinds without a window enqueue Bundles immediately.
+	WatchThrottle map[schema.GroupKind]time.Duration
+
+	// PluginTimeout is how long a plugin may take to process a resource before it fails with a retriable error.
+	// PluginTimeouts overrides it for particular plugins. Zero means no timeout.
+	PluginTimeout  time.Duration
+	PluginTimeouts map[smith_v1.PluginName]time.Duration
 }
 
 // Prepare prepares the controller to be run.
 func (c *Controller) Prepare(crdInf cache.SharedIndexInformer, resourceInfs map[schema.GroupVersionKind]cache.SharedIndexInformer) {
 	c.crdContext, c.crdContextCancel = context.WithCancel(context.Background())
-	c.resourceHandler = &ctrl.ControlledResourceHandler{
-		Logger:          c.Logger,
-		WorkQueue:       c.WorkQueue,
-		ControllerIndex: &controllerIndexAdapter{bundleStore: c.BundleStore},
-		ControllerGvk:   smith_v1.BundleGVK,
+	c.processContext, c.processContextCancel = context.WithCancel(context.Background())
+	if c.LargeBundleResources > 0 {
+		c.largeBundleSlots = make(chan struct{}, c.MaxConcurrentLargeBundles)
+	}
+	if c.PanicBudget > 0 {
+		c.panicBudget = newPanicBudget(c.PanicBudget, c.PanicBudgetWindow)
+	}
+	if c.FullReconcilePeriod > 0 {
+		c.changes = newChangeTracker(c.FullReconcilePeriod)
+	}
+	if c.SkipUnchangedResources {
+		c.resourceCache = newResourceCache()
+	}
+	c.deletedObjects = newDeletedObjects()
+	c.queueDepth = newQueueDepthTracker(c.WorkQueue)
+	c.WorkQueue = c.queueDepth
+	c.delayedRequeue = newDelayedRequeue(c.WorkQueue)
+	if bundleInf, ok := resourceInfs[smith_v1.BundleGVK]; ok {
+		bundleInf.AddEventHandler(&queueDepthHandler{
+			tracker: c.queueDepth,
+		})
 	}
 	crdInf.AddEventHandler(&crdEventHandler{
 		Controller: c,
 		watchers:   make(map[string]watchState),
 	})
 
-	for _, resourceInf := range resourceInfs {
-		resourceInf.AddEventHandler(c.resourceHandler)
+	for gvk, resourceInf := range resourceInfs {
+		resourceInf.AddEventHandler(c.resourceHandler(gvk.GroupKind()))
+		resourceInf.AddEventHandler(c.externalObjectHandler(gvk.GroupKind()))
 	}
 }
 
+// resourceHandler returns an event handler for objects of a particular kind that enqueues their Bundles.
+func (c *Controller) resourceHandler(gk schema.GroupKind) cache.ResourceEventHandler {
+	var workQueue ctrl.WorkQueueProducer = c.WorkQueue
+	if window := c.WatchThrottle[gk]; window > 0 {
+		workQueue = newThrottledWorkQueue(workQueue, window, c.RequeueJitter)
+	}
+	var handler cache.ResourceEventHandler = &ctrl.ControlledResourceHandler{
+		Logger:          c.Logger,
+		WorkQueue:       workQueue,
+		ControllerIndex: &controllerIndexAdapter{bundleStore: c.BundleStore},
+		ControllerGvk:   smith_v1.BundleGVK,
+	}
+	if c.changes != nil {
+		handler = &changeRecordingHandler{
+			ResourceEventHandler: handler,
+			logger:               c.Logger,
+			gk:                   gk,
+			changes:              c.changes,
+			bundleStore:          c.BundleStore,
+		}
+	}
+	return handler
+}
+
 // Run begins watching and syncing.
 // All informers must be synced before this method is invoked.
 func (c *Controller) Run(ctx context.Context) {
@@ -80,15 +254,29 @@ func (c *Controller) Run(ctx context.Context) {
 		defer c.wgLock.Unlock()
 		c.stopping = true
 	}()
+	defer c.processContextCancel() // abort processing of Bundles first
 
 	c.Logger.Info("Starting Bundle controller")
 	defer c.Logger.Info("Shutting down Bundle controller")
 
+	if c.CompletionNotifier != nil {
+		c.wg.StartWithContext(ctx, c.CompletionNotifier.Run)
+	}
+	if c.Tracer != nil {
+		c.wg.StartWithContext(ctx, c.Tracer.Run)
+	}
+	c.wg.StartWithContext(ctx, c.delayedRequeue.run)
+
 	c.ReadyForWork()
 
 	<-ctx.Done()
 }
 
+// QueueDepth returns the number of Bundles waiting in the work queue to be processed.
+func (c *Controller) QueueDepth() int {
+	return c.queueDepth.depth()
+}
+
 type controllerIndexAdapter struct {
 	bundleStore BundleStore
 }
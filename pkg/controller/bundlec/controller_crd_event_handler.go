@@ -126,7 +126,8 @@ func (h *crdEventHandler) ensureWatch(logger *zap.Logger, crd *apiext_v1b1.Custo
 	if h.stopping {
 		return false
 	}
-	crdInf.AddEventHandler(h.resourceHandler)
+	crdInf.AddEventHandler(h.resourceHandler(gvk.GroupKind()))
+	crdInf.AddEventHandler(h.externalObjectHandler(gvk.GroupKind()))
 	err = h.Store.AddInformer(gvk, crdInf)
 	if err != nil {
 		logger.Error("Failed to add informer for CRD to multisore", zap.Error(err))
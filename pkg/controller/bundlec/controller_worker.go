@@ -1,36 +1,183 @@
 package bundlec
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/atlassian/ctrl"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// largeBundleRequeueDelay is the delay after which a large Bundle is processed again if
+	// it was not processed because too many large Bundles were being processed.
+	largeBundleRequeueDelay = 5 * time.Second
+	// deletionBatchRequeueDelay is the delay after which a deleted Bundle is processed again to delete
+	// the next batch of objects or to check that objects are gone.
+	deletionBatchRequeueDelay = 5 * time.Second
 )
 
 func (c *Controller) Process(pctx *ctrl.ProcessContext) (retriableRet bool, errRet error) {
-	return c.ProcessBundle(pctx.Logger, pctx.Object.(*smith_v1.Bundle))
+	bundle := pctx.Object.(*smith_v1.Bundle)
+	key := bundleQueueKey(bundle)
+	c.queueDepth.removed(key)
+	defer func() {
+		if errRet != nil && retriableRet {
+			// Bundle is added back to the work queue with rate limiting
+			c.queueDepth.added(key)
+		}
+	}()
+	release, ok := c.acquireLargeBundleSlot(bundle)
+	if !ok {
+		pctx.Logger.Debug("Postponing processing of a large Bundle because too many large Bundles are being processed")
+		c.requeueAfter(bundle, largeBundleRequeueDelay)
+		return false, nil
+	}
+	defer release()
+	return c.ProcessBundle(pctx.Logger, bundle)
+}
+
+// acquireLargeBundleSlot acquires a slot for processing of a large Bundle without blocking.
+// Returns false if all slots are taken. Release function must be called once processing is done.
+func (c *Controller) acquireLargeBundleSlot(bundle *smith_v1.Bundle) (func(), bool /*acquired*/) {
+	if c.largeBundleSlots == nil || len(bundle.Spec.Resources) < c.LargeBundleResources {
+		return func() {}, true
+	}
+	select {
+	case c.largeBundleSlots <- struct{}{}:
+		return func() { <-c.largeBundleSlots }, true
+	default:
+		return nil, false
+	}
 }
 
 // ProcessBundle is only visible for testing purposes. Should not be called directly.
 func (c *Controller) ProcessBundle(logger *zap.Logger, bundle *smith_v1.Bundle) (retriableRet bool, errRet error) {
-	st := bundleSyncTask{
-		logger:           logger,
-		bundleClient:     c.BundleClient,
-		smartClient:      c.SmartClient,
-		rc:               c.Rc,
-		store:            c.Store,
-		specCheck:        c.SpecCheck,
-		bundle:           bundle,
-		pluginContainers: c.PluginContainers,
-		scheme:           c.Scheme,
-		catalog:          c.Catalog,
-	}
-
-	var retriable bool
+	span := startSpan(c.Tracer, bundleParentSpan(logger, bundle), spanReconcile, map[string]string{
+		"namespace":  bundle.Namespace,
+		"name":       bundle.Name,
+		"uid":        string(bundle.UID),
+		"generation": strconv.FormatInt(bundle.Generation, 10),
+	})
+	defer func() {
+		span.Finish(errRet)
+	}()
+	if id := traceID(span); id != "" {
+		// Logs of the processing iteration can be found by the trace ID and vice versa
+		logger = logger.With(zap.String("trace_id", id))
+	}
+	st := c.newBundleSyncTask(logger, bundle, span)
+	if c.resourceCache != nil && bundle.DeletionTimestamp != nil {
+		c.resourceCache.forget(bundle.UID)
+	}
+	if c.changes != nil {
+		if bundle.DeletionTimestamp != nil {
+			c.changes.forget(types.NamespacedName{Namespace: bundle.Namespace, Name: bundle.Name})
+		} else {
+			changedObjects, err := c.changes.take(bundle, time.Now())
+			if err != nil {
+				logger.Warn("Failed to determine changed objects, processing all resources", zap.Error(err))
+			}
+			st.changedObjects = changedObjects
+		}
+	}
+
+	var retriable, panicked bool
 	var err error
-	if st.bundle.DeletionTimestamp != nil {
-		retriable, err = st.processDeleted()
-	} else {
-		retriable, err = st.processNormal()
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+				retriable, err = false, c.recoverProcessingPanic(logger, r)
+			}
+		}()
+		if st.bundle.DeletionTimestamp != nil {
+			retriable, err = st.processDeleted()
+		} else {
+			retriable, err = st.processNormal()
+		}
+	}()
+	retriable, err = st.handleProcessResult(retriable, err)
+	if c.Metrics != nil {
+		c.Metrics.observeBundle(st.bundle)
+	}
+	if st.requeueAfter > 0 {
+		c.requeueAfter(st.bundle, st.requeueAfter)
+	}
+	if panicked && err != nil {
+		// Error condition is terminal but the Bundle is retried with backoff in case the panic was transient
+		retriable = true
+	}
+	return retriable, err
+}
+
+// newBundleSyncTask returns a task to process the Bundle with the controller's configuration.
+func (c *Controller) newBundleSyncTask(logger *zap.Logger, bundle *smith_v1.Bundle, span Span) *bundleSyncTask {
+	return &bundleSyncTask{
+		ctx:                      c.processContext,
+		logger:                   logger,
+		bundleClient:             c.BundleClient,
+		smartClient:              c.SmartClient,
+		rc:                       c.Rc,
+		store:                    c.Store,
+		specCheck:                c.SpecCheck,
+		bundle:                   bundle,
+		pluginContainers:         c.PluginContainers,
+		scheme:                   c.Scheme,
+		catalog:                  c.Catalog,
+		recorder:                 c.Recorder,
+		blockInUseDeletion:       c.BlockInUseDeletion,
+		errorHoldTime:            c.ErrorHoldTime,
+		retryBaseDelay:           c.RetryBaseDelay,
+		retryMaxDelay:            c.RetryMaxDelay,
+		blockedRequeueDelay:      c.BlockedRequeueDelay,
+		requeueJitter:            c.RequeueJitter,
+		pluginTimeout:            c.PluginTimeout,
+		pluginTimeouts:           c.PluginTimeouts,
+		maxResources:             c.MaxResources,
+		validateObjectNamespace:  c.ValidateObjectNamespace,
+		allowClusterScoped:       c.AllowClusterScopedObjects,
+		conflictRetries:          c.ConflictRetries,
+		continueOnConflict:       c.ContinueOnConflict,
+		fieldManager:             c.FieldManager,
+		patchUpdates:             c.PatchUpdates,
+		oldObjectDeletionTimeout: c.OldObjectDeletionTimeout,
+		deletionBatchSize:        c.DeletionBatchSize,
+		forceDeletion:            c.ForceDeletion,
+		schemaValidator:          c.SchemaValidator,
+		unresolvableGvkPolicy:    c.UnresolvableGvkPolicy,
+		errorMessageTransformer:  c.ErrorMessageTransformer,
+		tracer:                   c.Tracer,
+		span:                     span,
+		completionNotifier:       c.CompletionNotifier,
+		quotaLister:              c.ResourceQuotaLister,
+		maxConcurrentResources:   c.MaxConcurrentResources,
+		maxConcurrentDeletions:   c.MaxConcurrentDeletions,
+		resourceCache:            c.resourceCache,
+		deletedObjects:           c.deletedObjects,
+		conditionHistorySize:     c.ConditionHistorySize,
+		metrics:                  c.Metrics,
+	}
+}
+
+// requeueAfter adds the Bundle to the work queue after the delay. Only the earliest requeue of a Bundle
+// is kept. Requeues are cancelled when the controller stops.
+func (c *Controller) requeueAfter(bundle *smith_v1.Bundle, delay time.Duration) {
+	if c.delayedRequeue == nil {
+		// Controller has not been prepared to run, e.g. a Bundle is processed directly
+		return
+	}
+	c.delayedRequeue.addAfter(bundleQueueKey(bundle), delay)
+}
+
+// jitteredDelay returns the delay increased by a random duration of up to maxFactor times the delay.
+// The delay is returned as is if maxFactor is not positive.
+func jitteredDelay(delay time.Duration, maxFactor float64) time.Duration {
+	if maxFactor <= 0 {
+		return delay
 	}
-	return st.handleProcessResult(retriable, err)
+	return wait.Jitter(delay, maxFactor)
 }
@@ -8,6 +8,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestBundleSort(t *testing.T) {
@@ -48,12 +50,84 @@ func TestBundleSort(t *testing.T) {
 			},
 		},
 	}
-	_, sorted, err := sortBundle(&bundle)
+	_, sorted, err := sortBundle(&bundle, nil)
 	require.NoError(t, err)
 
 	assert.EqualValues(t, []graph.V{smith_v1.ResourceName("b"), smith_v1.ResourceName("c"), smith_v1.ResourceName("a"), smith_v1.ResourceName("e"), smith_v1.ResourceName("d")}, sorted)
 }
 
+func TestBundleSortSelector(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					References: []smith_v1.Reference{
+						{
+							Selector: &meta_v1.LabelSelector{
+								MatchLabels: map[string]string{"kind": "secret"},
+							},
+						},
+					},
+				},
+				{
+					Name: "b",
+					References: []smith_v1.Reference{
+						{
+							Selector: &meta_v1.LabelSelector{
+								MatchLabels: map[string]string{"kind": "none"},
+							},
+						},
+					},
+				},
+				{
+					Name:   "c",
+					Labels: map[string]string{"kind": "secret"},
+				},
+				{
+					Name:   "d",
+					Labels: map[string]string{"kind": "secret"},
+				},
+			},
+		},
+	}
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, []graph.V{smith_v1.ResourceName("c"), smith_v1.ResourceName("d"), smith_v1.ResourceName("a"), smith_v1.ResourceName("b")}, sorted)
+
+	resources, err := expandReferenceSelectors(bundle.Spec.Resources)
+	require.NoError(t, err)
+	assert.Equal(t, []smith_v1.Reference{{Resource: "c"}, {Resource: "d"}}, resources[0].References)
+	// Selector that matches no resources is satisfied
+	assert.Empty(t, resources[1].References)
+	// Bundle itself is not modified
+	assert.NotNil(t, bundle.Spec.Resources[0].References[0].Selector)
+}
+
+func TestBundleSortSelectorWithName(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					References: []smith_v1.Reference{
+						{
+							Name: "ref",
+							Selector: &meta_v1.LabelSelector{
+								MatchLabels: map[string]string{"kind": "secret"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, _, err := sortBundle(&bundle, nil)
+	require.EqualError(t, err, `resource "a" has a reference "ref" with a selector, such references cannot have a name or a path`)
+}
+
 func TestBundleSortMissingDependency(t *testing.T) {
 	t.Parallel()
 	bundle := smith_v1.Bundle{
@@ -92,8 +166,24 @@ func TestBundleSortMissingDependency(t *testing.T) {
 			},
 		},
 	}
-	_, sorted, err := sortBundle(&bundle)
-	require.EqualError(t, err, "vertex \"x\" not found", "%v", sorted)
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.EqualError(t, err, `resource "a" references unknown resource "x"`, "%v", sorted)
+}
+
+func TestBundleSortMissingRunAfterDependency(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:     "a",
+					RunAfter: []smith_v1.ResourceName{"x"},
+				},
+			},
+		},
+	}
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.EqualError(t, err, `resource "a" runs after unknown resource "x"`, "%v", sorted)
 }
 
 func TestBundleSortSelfReference(t *testing.T) {
@@ -134,6 +224,218 @@ func TestBundleSortSelfReference(t *testing.T) {
 			},
 		},
 	}
-	_, sorted, err := sortBundle(&bundle)
-	require.EqualError(t, err, "cycle error: [a a]", "%v", sorted)
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.EqualError(t, err, `cycle detected: a -> a: "a" references "a"`, "%v", sorted)
+}
+
+func TestBundleSortRunAfter(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:     "a",
+					RunAfter: []smith_v1.ResourceName{"b"},
+				},
+				{
+					Name: "b",
+				},
+				{
+					Name: "c",
+					References: []smith_v1.Reference{
+						{
+							Resource: "a",
+						},
+					},
+				},
+			},
+		},
+	}
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, []graph.V{smith_v1.ResourceName("b"), smith_v1.ResourceName("a"), smith_v1.ResourceName("c")}, sorted)
+}
+
+func TestBundleSortDisabled(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:     "a",
+					RunAfter: []smith_v1.ResourceName{"b"},
+				},
+				{
+					Name:     "b",
+					Disabled: true,
+					References: []smith_v1.Reference{
+						{
+							Resource: "c",
+						},
+					},
+				},
+				{
+					Name: "c",
+					References: []smith_v1.Reference{
+						{
+							Resource: "b",
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, []graph.V{smith_v1.ResourceName("a"), smith_v1.ResourceName("c")}, sorted)
+}
+
+func TestBundleSortPriority(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "deployment",
+				},
+				{
+					Name:     "networkpolicy",
+					Priority: 10,
+				},
+				{
+					Name:     "service",
+					Priority: 10,
+				},
+				{
+					Name:     "config",
+					Priority: -1,
+				},
+				{
+					Name:     "secret",
+					Priority: 20,
+					// Dependencies take precedence over priority
+					RunAfter: []smith_v1.ResourceName{"config"},
+				},
+			},
+		},
+	}
+	g, sorted, err := sortBundle(&bundle, nil)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, []graph.V{
+		smith_v1.ResourceName("config"),
+		smith_v1.ResourceName("secret"),
+		smith_v1.ResourceName("networkpolicy"),
+		smith_v1.ResourceName("service"),
+		smith_v1.ResourceName("deployment"),
+	}, sorted)
+	assert.EqualValues(t, [][]graph.V{
+		{smith_v1.ResourceName("config"), smith_v1.ResourceName("networkpolicy"), smith_v1.ResourceName("service"), smith_v1.ResourceName("deployment")},
+		{smith_v1.ResourceName("secret")},
+	}, g.Layers(sorted))
+}
+
+func TestBundleSortConflictingConstraints(t *testing.T) {
+	t.Parallel()
+	bundle := smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					References: []smith_v1.Reference{
+						{
+							Resource: "b",
+						},
+					},
+				},
+				{
+					Name:     "b",
+					RunAfter: []smith_v1.ResourceName{"c"},
+				},
+				{
+					Name:     "c",
+					RunAfter: []smith_v1.ResourceName{"a"},
+					References: []smith_v1.Reference{
+						{
+							Resource: "a",
+						},
+					},
+				},
+			},
+		},
+	}
+	_, sorted, err := sortBundle(&bundle, nil)
+	require.EqualError(t, err,
+		`cycle detected: a -> b -> c -> a: "a" references "b", "b" runs after "c", "c" references and runs after "a"`, "%v", sorted)
+}
+
+func TestCycleIsTerminalError(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	st := bundleSyncTask{
+		logger: logger,
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name:     "a",
+						RunAfter: []smith_v1.ResourceName{"b"},
+					},
+					{
+						Name:     "b",
+						RunAfter: []smith_v1.ResourceName{"a"},
+					},
+				},
+			},
+		},
+	}
+	retriable, err := st.processNormal()
+	require.EqualError(t, err, `topological sort of resources failed: cycle detected: a -> b -> a: "a" runs after "b", "b" runs after "a"`)
+	assert.False(t, retriable)
+}
+
+func TestLargeBundleSlots(t *testing.T) {
+	t.Parallel()
+	c := Controller{
+		LargeBundleResources:      2,
+		MaxConcurrentLargeBundles: 1,
+		largeBundleSlots:          make(chan struct{}, 1),
+	}
+	small := &smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{{Name: "a"}},
+		},
+	}
+	large := &smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{{Name: "a"}, {Name: "b"}},
+		},
+	}
+
+	release, ok := c.acquireLargeBundleSlot(large)
+	require.True(t, ok)
+
+	// All slots are taken
+	_, ok = c.acquireLargeBundleSlot(large)
+	assert.False(t, ok)
+
+	// Small Bundles are not limited
+	releaseSmall, ok := c.acquireLargeBundleSlot(small)
+	require.True(t, ok)
+	releaseSmall()
+
+	release()
+	release, ok = c.acquireLargeBundleSlot(large)
+	require.True(t, ok)
+	release()
 }
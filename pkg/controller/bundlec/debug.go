@@ -0,0 +1,300 @@
+package bundlec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atlassian/ctrl"
+	ctrlLogz "github.com/atlassian/ctrl/logz"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	ConditionsPath = "/debug/bundles/conditions"
+	DryRunPath     = "/debug/bundles/dryrun"
+	HealthPath     = "/debug/bundles/health"
+
+	debugReadTimeout     = 10 * time.Second
+	debugWriteTimeout    = 10 * time.Second
+	debugShutdownTimeout = 5 * time.Second
+)
+
+// DryRunner runs a reconcile iteration of a Bundle without mutating the cluster.
+type DryRunner interface {
+	DryRun(logger *zap.Logger, bundle *smith_v1.Bundle) *ReconcileResult
+}
+
+// QueueDepthReporter reports the number of Bundles waiting in the work queue.
+type QueueDepthReporter interface {
+	QueueDepth() int
+}
+
+// DebugServer serves read-only debug endpoints of the Bundle controller.
+type DebugServer struct {
+	Logger      *zap.Logger
+	Addr        string
+	BundleStore BundleStore
+	// DryRunner is optional. Dry-run endpoint is only served if it is set.
+	DryRunner DryRunner
+	// QueueDepthReporter is optional. Health endpoint is only served if it is set.
+	QueueDepthReporter QueueDepthReporter
+}
+
+func (s *DebugServer) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(ConditionsPath, &ConditionsHandler{
+		Logger:      s.Logger,
+		BundleStore: s.BundleStore,
+	})
+	if s.DryRunner != nil {
+		mux.Handle(DryRunPath, &DryRunHandler{
+			Logger:      s.Logger,
+			BundleStore: s.BundleStore,
+			DryRunner:   s.DryRunner,
+		})
+	}
+	if s.QueueDepthReporter != nil {
+		mux.Handle(HealthPath, &HealthHandler{
+			Logger:             s.Logger,
+			BundleStore:        s.BundleStore,
+			QueueDepthReporter: s.QueueDepthReporter,
+		})
+	}
+	srv := &http.Server{
+		Addr:         s.Addr,
+		Handler:      mux,
+		ReadTimeout:  debugReadTimeout,
+		WriteTimeout: debugWriteTimeout,
+	}
+	return ctrl.StartStopServer(ctx, srv, debugShutdownTimeout)
+}
+
+// ConditionsHandler returns conditions of all resources of a Bundle as a table.
+// Bundle is specified by "namespace" and "name" query parameters. Table is returned as
+// text unless "format=json" query parameter is specified.
+type ConditionsHandler struct {
+	Logger      *zap.Logger
+	BundleStore BundleStore
+}
+
+func (h *ConditionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bundle, ok := getRequestedBundle(h.Logger, h.BundleStore, w, r)
+	if !ok {
+		return
+	}
+	table := resourceConditionsTable(bundle)
+	var err error
+	switch r.URL.Query().Get("format") {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = writeResourceConditionsTable(w, table)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(table)
+	default:
+		http.Error(w, "unsupported format", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
+
+// DryRunHandler runs a reconcile iteration of a Bundle without mutating the cluster and returns
+// the operations that would be performed as JSON. Bundle is specified by "namespace" and "name" query parameters.
+type DryRunHandler struct {
+	Logger      *zap.Logger
+	BundleStore BundleStore
+	DryRunner   DryRunner
+}
+
+// dryRunResponse describes what a reconcile iteration of a Bundle would do.
+type dryRunResponse struct {
+	Created   []*unstructured.Unstructured                      `json:"created,omitempty"`
+	Updated   []*unstructured.Unstructured                      `json:"updated,omitempty"`
+	Deleted   []smith_v1.ObjectToDelete                         `json:"deleted,omitempty"`
+	Blocked   map[smith_v1.ResourceName][]smith_v1.ResourceName `json:"blocked,omitempty"`
+	Status    smith_v1.BundleStatus                             `json:"status"`
+	Retriable bool                                              `json:"retriable,omitempty"`
+	Error     string                                            `json:"error,omitempty"`
+}
+
+func (h *DryRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bundle, ok := getRequestedBundle(h.Logger, h.BundleStore, w, r)
+	if !ok {
+		return
+	}
+	logger := h.Logger.With(ctrlLogz.Namespace(bundle), ctrlLogz.Object(bundle))
+	result := h.DryRunner.DryRun(logger, bundle)
+	response := dryRunResponse{
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Deleted:   result.Deleted,
+		Blocked:   result.Blocked,
+		Status:    result.Bundle.Status,
+		Retriable: result.Retriable,
+	}
+	if result.Error != nil {
+		response.Error = result.Error.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
+
+// HealthHandler reports whether the controller is keeping up as JSON: the number of Bundles waiting in
+// the work queue and the number of Bundles in each state according to the informer cache.
+type HealthHandler struct {
+	Logger             *zap.Logger
+	BundleStore        BundleStore
+	QueueDepthReporter QueueDepthReporter
+}
+
+// healthResponse describes the state of the controller.
+type healthResponse struct {
+	QueueDepth int          `json:"queueDepth"`
+	Bundles    bundleCounts `json:"bundles"`
+}
+
+// bundleCounts is the number of Bundles in each state.
+// A Bundle in Error is not counted as InProgress or Ready. Bundles that have not been processed yet
+// are only counted in Total.
+type bundleCounts struct {
+	Total      int `json:"total"`
+	Error      int `json:"error"`
+	InProgress int `json:"inProgress"`
+	Ready      int `json:"ready"`
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response := healthResponse{
+		QueueDepth: h.QueueDepthReporter.QueueDepth(),
+		Bundles:    countBundles(h.BundleStore.List()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
+
+func countBundles(bundles []*smith_v1.Bundle) bundleCounts {
+	counts := bundleCounts{
+		Total: len(bundles),
+	}
+	isTrue := func(bundle *smith_v1.Bundle, condType smith_v1.BundleConditionType) bool {
+		_, cond := bundle.GetCondition(condType)
+		return cond != nil && cond.Status == smith_v1.ConditionTrue
+	}
+	for _, bundle := range bundles {
+		switch {
+		case isTrue(bundle, smith_v1.BundleError):
+			counts.Error++
+		case isTrue(bundle, smith_v1.BundleInProgress):
+			counts.InProgress++
+		case isTrue(bundle, smith_v1.BundleReady):
+			counts.Ready++
+		}
+	}
+	return counts
+}
+
+// getRequestedBundle gets the Bundle specified by "namespace" and "name" query parameters of a GET request.
+// An error response is written and false is returned if the Bundle cannot be returned.
+func getRequestedBundle(logger *zap.Logger, bundleStore BundleStore, w http.ResponseWriter, r *http.Request) (*smith_v1.Bundle, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	name := query.Get("name")
+	if namespace == "" || name == "" {
+		http.Error(w, `"namespace" and "name" query parameters are required`, http.StatusBadRequest)
+		return nil, false
+	}
+	bundle, err := bundleStore.Get(namespace, name)
+	if err != nil {
+		logger.Error("Failed to get Bundle", zap.Error(err))
+		http.Error(w, "failed to get Bundle", http.StatusInternalServerError)
+		return nil, false
+	}
+	if bundle == nil {
+		http.Error(w, "Bundle not found", http.StatusNotFound)
+		return nil, false
+	}
+	return bundle, true
+}
+
+// conditionSummary is a condition of a resource without timestamps.
+type conditionSummary struct {
+	Status smith_v1.ConditionStatus `json:"status"`
+	Reason string                   `json:"reason,omitempty"`
+}
+
+// resourceConditions is a row of the resource conditions table.
+type resourceConditions struct {
+	Resource   smith_v1.ResourceName `json:"resource"`
+	Blocked    conditionSummary      `json:"blocked"`
+	InProgress conditionSummary      `json:"inProgress"`
+	Ready      conditionSummary      `json:"ready"`
+	Error      conditionSummary      `json:"error"`
+}
+
+// resourceConditionsTable computes conditions of all resources of a Bundle from its status, in the order of
+// resources in the spec. Conditions that are not present in the status are reported as Unknown.
+func resourceConditionsTable(bundle *smith_v1.Bundle) []resourceConditions {
+	table := make([]resourceConditions, 0, len(bundle.Spec.Resources))
+	for _, res := range bundle.Spec.Resources {
+		_, resStatus := bundle.Status.GetResourceStatus(res.Name)
+		summary := func(condType smith_v1.ResourceConditionType) conditionSummary {
+			if resStatus != nil {
+				_, cond := resStatus.GetCondition(condType)
+				if cond != nil {
+					return conditionSummary{
+						Status: cond.Status,
+						Reason: cond.Reason,
+					}
+				}
+			}
+			return conditionSummary{
+				Status: smith_v1.ConditionUnknown,
+			}
+		}
+		table = append(table, resourceConditions{
+			Resource:   res.Name,
+			Blocked:    summary(smith_v1.ResourceBlocked),
+			InProgress: summary(smith_v1.ResourceInProgress),
+			Ready:      summary(smith_v1.ResourceReady),
+			Error:      summary(smith_v1.ResourceError),
+		})
+	}
+	return table
+}
+
+func writeResourceConditionsTable(w io.Writer, table []resourceConditions) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tBLOCKED\tINPROGRESS\tREADY\tERROR")
+	for _, row := range table {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", row.Resource, row.Blocked, row.InProgress, row.Ready, row.Error)
+	}
+	return tw.Flush()
+}
+
+func (c conditionSummary) String() string {
+	if c.Reason == "" {
+		return string(c.Status)
+	}
+	return fmt.Sprintf("%s(%s)", c.Status, c.Reason)
+}
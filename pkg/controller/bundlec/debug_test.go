@@ -0,0 +1,237 @@
+package bundlec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// singleBundleStore returns a single Bundle.
+type singleBundleStore struct {
+	BundleStore
+	bundle *smith_v1.Bundle
+}
+
+func (s singleBundleStore) Get(namespace, bundleName string) (*smith_v1.Bundle, error) {
+	if s.bundle.Namespace != namespace || s.bundle.Name != bundleName {
+		return nil, nil
+	}
+	return s.bundle, nil
+}
+
+func TestConditionsHandler(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{Name: "a"},
+				{Name: "b"},
+			},
+		},
+		Status: smith_v1.BundleStatus{
+			ResourceStatuses: []smith_v1.ResourceStatus{
+				{
+					Name: "a",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionFalse},
+						{Type: smith_v1.ResourceInProgress, Status: smith_v1.ConditionFalse},
+						{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionTrue},
+						{Type: smith_v1.ResourceError, Status: smith_v1.ConditionFalse},
+					},
+				},
+				{
+					Name: "b",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionFalse},
+						{Type: smith_v1.ResourceInProgress, Status: smith_v1.ConditionFalse},
+						{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse},
+						{Type: smith_v1.ResourceError, Status: smith_v1.ConditionTrue, Reason: smith_v1.ResourceReasonTerminalError},
+					},
+				},
+			},
+		},
+	}
+	h := &ConditionsHandler{
+		Logger:      logger,
+		BundleStore: singleBundleStore{bundle: bundle},
+	}
+
+	// Text
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConditionsPath+"?namespace=ns&name=bundle1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ""+
+		"RESOURCE  BLOCKED  INPROGRESS  READY  ERROR\n"+
+		"a         False    False       True   False\n"+
+		"b         False    False       False  True(TerminalError)\n", w.Body.String())
+
+	// JSON
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConditionsPath+"?namespace=ns&name=bundle1&format=json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var table []resourceConditions
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &table))
+	assert.Equal(t, resourceConditionsTable(bundle), table)
+
+	// Not found
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConditionsPath+"?namespace=ns&name=bundle2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResourceConditionsTableMissingStatus(t *testing.T) {
+	t.Parallel()
+	bundle := &smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{Name: "a"},
+			},
+		},
+	}
+	unknown := conditionSummary{Status: smith_v1.ConditionUnknown}
+	assert.Equal(t, []resourceConditions{
+		{Resource: "a", Blocked: unknown, InProgress: unknown, Ready: unknown, Error: unknown},
+	}, resourceConditionsTable(bundle))
+}
+
+// fixedDryRunner returns a fixed result.
+type fixedDryRunner struct {
+	result *ReconcileResult
+}
+
+func (r fixedDryRunner) DryRun(logger *zap.Logger, bundle *smith_v1.Bundle) *ReconcileResult {
+	return r.result
+}
+
+func TestDryRunHandler(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+		},
+	}
+	created := &unstructured.Unstructured{}
+	created.SetAPIVersion("v1")
+	created.SetKind("ConfigMap")
+	created.SetName("cm1")
+	h := &DryRunHandler{
+		Logger:      logger,
+		BundleStore: singleBundleStore{bundle: bundle},
+		DryRunner: fixedDryRunner{
+			result: &ReconcileResult{
+				Bundle:  bundle,
+				Created: []*unstructured.Unstructured{created},
+				Blocked: map[smith_v1.ResourceName][]smith_v1.ResourceName{
+					"b": {"a"},
+				},
+				Error: errors.New("boom"),
+			},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DryRunPath+"?namespace=ns&name=bundle1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response dryRunResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Created, 1)
+	assert.Equal(t, "cm1", response.Created[0].GetName())
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{"b": {"a"}}, response.Blocked)
+	assert.Equal(t, "boom", response.Error)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DryRunPath+"?namespace=ns&name=bundle2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// listBundleStore lists a fixed set of Bundles.
+type listBundleStore struct {
+	BundleStore
+	bundles []*smith_v1.Bundle
+}
+
+func (s listBundleStore) List() []*smith_v1.Bundle {
+	return s.bundles
+}
+
+type fixedQueueDepth int
+
+func (d fixedQueueDepth) QueueDepth() int {
+	return int(d)
+}
+
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundleWithConditions := func(conditions ...smith_v1.BundleCondition) *smith_v1.Bundle {
+		return &smith_v1.Bundle{
+			Status: smith_v1.BundleStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+	h := &HealthHandler{
+		Logger: logger,
+		BundleStore: listBundleStore{
+			bundles: []*smith_v1.Bundle{
+				bundleWithConditions(
+					smith_v1.BundleCondition{Type: smith_v1.BundleReady, Status: smith_v1.ConditionTrue},
+				),
+				bundleWithConditions(
+					smith_v1.BundleCondition{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionTrue},
+				),
+				bundleWithConditions(
+					smith_v1.BundleCondition{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionTrue},
+					smith_v1.BundleCondition{Type: smith_v1.BundleError, Status: smith_v1.ConditionTrue},
+				),
+				bundleWithConditions(
+					smith_v1.BundleCondition{Type: smith_v1.BundleReady, Status: smith_v1.ConditionFalse},
+				),
+				bundleWithConditions(),
+			},
+		},
+		QueueDepthReporter: fixedQueueDepth(3),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response healthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, healthResponse{
+		QueueDepth: 3,
+		Bundles: bundleCounts{
+			Total:      5,
+			Error:      1,
+			InProgress: 1,
+			Ready:      1,
+		},
+	}, response)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, HealthPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,73 @@
+package bundlec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/atlassian/ctrl"
+)
+
+// delayedRequeue adds keys to the work queue after a delay. Only the earliest pending add of a key is kept,
+// later requests for the same key are dropped. Pending adds are cancelled when run returns.
+type delayedRequeue struct {
+	queue ctrl.WorkQueueProducer
+
+	mx      sync.Mutex
+	stopped bool
+	pending map[ctrl.QueueKey]*pendingRequeue
+}
+
+type pendingRequeue struct {
+	timer *time.Timer
+	at    time.Time
+}
+
+func newDelayedRequeue(queue ctrl.WorkQueueProducer) *delayedRequeue {
+	return &delayedRequeue{
+		queue:   queue,
+		pending: make(map[ctrl.QueueKey]*pendingRequeue),
+	}
+}
+
+func (r *delayedRequeue) addAfter(key ctrl.QueueKey, delay time.Duration) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.stopped {
+		return
+	}
+	at := time.Now().Add(delay)
+	if p, ok := r.pending[key]; ok {
+		if !at.Before(p.at) {
+			return
+		}
+		p.timer.Stop()
+	}
+	p := &pendingRequeue{
+		at: at,
+	}
+	p.timer = time.AfterFunc(delay, func() {
+		r.mx.Lock()
+		if r.pending[key] != p {
+			// Replaced by an earlier add or cancelled
+			r.mx.Unlock()
+			return
+		}
+		delete(r.pending, key)
+		r.mx.Unlock()
+		r.queue.Add(key)
+	})
+	r.pending[key] = p
+}
+
+// run waits until the context is done and cancels pending adds.
+func (r *delayedRequeue) run(ctx context.Context) {
+	<-ctx.Done()
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.stopped = true
+	for key, p := range r.pending {
+		p.timer.Stop()
+		delete(r.pending, key)
+	}
+}
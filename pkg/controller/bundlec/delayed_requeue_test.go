@@ -0,0 +1,52 @@
+package bundlec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/ctrl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayedRequeueKeepsEarliestAdd(t *testing.T) {
+	t.Parallel()
+	queue := make(chanWorkQueue, 10)
+	r := newDelayedRequeue(queue)
+	key := ctrl.QueueKey{Namespace: "ns", Name: "bundle1"}
+
+	r.addAfter(key, time.Hour)
+	r.addAfter(key, 10*time.Millisecond)
+	r.addAfter(key, time.Hour)
+
+	select {
+	case added := <-queue:
+		assert.Equal(t, key, added)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delayed add")
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	assert.Empty(t, r.pending)
+}
+
+func TestDelayedRequeueIsCancelledOnStop(t *testing.T) {
+	t.Parallel()
+	queue := make(chanWorkQueue, 10)
+	r := newDelayedRequeue(queue)
+	key := ctrl.QueueKey{Namespace: "ns", Name: "bundle1"}
+
+	r.addAfter(key, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.run(ctx)
+	// Adds after stop are ignored
+	r.addAfter(key, 10*time.Millisecond)
+
+	select {
+	case <-queue:
+		t.Fatal("key was added after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Empty(t, r.pending)
+}
@@ -0,0 +1,79 @@
+package bundlec
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// deletedObjectTTL is how long a deleted object is remembered. The Store is expected to observe the deletion
+// well within this time. Entries of objects that are not produced by any resource are dropped after it.
+const deletedObjectTTL = time.Minute
+
+type deletedObjectKey struct {
+	namespace string
+	gk        schema.GroupKind
+	name      string
+}
+
+type deletedObject struct {
+	uid       types.UID
+	deletedAt time.Time
+}
+
+// deletedObjects remembers UIDs of objects that were deleted by the controller. The Store may still have such
+// an object without DeletionTimestamp for a while after it was deleted. Re-creating or updating it before the Store
+// catches up results in conflicts, so a resource that produces such an object waits until the Store has it
+// marked for deletion or gone.
+type deletedObjects struct {
+	mx      sync.Mutex
+	objects map[deletedObjectKey]deletedObject
+}
+
+func newDeletedObjects() *deletedObjects {
+	return &deletedObjects{
+		objects: make(map[deletedObjectKey]deletedObject),
+	}
+}
+
+func (d *deletedObjects) add(namespace string, gk schema.GroupKind, name string, uid types.UID) {
+	now := time.Now()
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for key, obj := range d.objects {
+		if now.Sub(obj.deletedAt) >= deletedObjectTTL {
+			delete(d.objects, key)
+		}
+	}
+	d.objects[deletedObjectKey{namespace: namespace, gk: gk, name: name}] = deletedObject{
+		uid:       uid,
+		deletedAt: now,
+	}
+}
+
+// isBeingDeleted returns true if the object with the UID was deleted by the controller but the Store does not
+// reflect that yet.
+func (d *deletedObjects) isBeingDeleted(namespace string, gk schema.GroupKind, name string, uid types.UID) bool {
+	key := deletedObjectKey{namespace: namespace, gk: gk, name: name}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	obj, ok := d.objects[key]
+	if !ok {
+		return false
+	}
+	if uid != obj.uid || time.Since(obj.deletedAt) >= deletedObjectTTL {
+		// A different object or the Store has not observed the deletion for too long
+		delete(d.objects, key)
+		return false
+	}
+	return true
+}
+
+// forget removes the object once the Store has it marked for deletion or gone.
+func (d *deletedObjects) forget(namespace string, gk schema.GroupKind, name string) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	delete(d.objects, deletedObjectKey{namespace: namespace, gk: gk, name: name})
+}
@@ -0,0 +1,87 @@
+package bundlec
+
+import (
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"go.uber.org/zap"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+)
+
+// DryRun runs a reconcile iteration of the Bundle against the live cluster without mutating it.
+// Objects are read from the Store and from the API server as usual, but create/update/delete operations
+// and the Bundle update are recorded in the result instead of being sent to the API server.
+// The Bundle is processed as if it had the finalizers set already.
+func (c *Controller) DryRun(logger *zap.Logger, bundle *smith_v1.Bundle) *ReconcileResult {
+	bundle = bundle.DeepCopy()
+	if bundle.DeletionTimestamp == nil {
+		bundle.Finalizers = addMissingFinalizers(bundle)
+	}
+	result := &ReconcileResult{
+		Blocked: make(map[smith_v1.ResourceName][]smith_v1.ResourceName),
+	}
+	st := c.newBundleSyncTask(logger, bundle, noopSpan{})
+	st.bundleClient = simulationBundlesGetter{}
+	st.smartClient = &dryRunSmartClient{
+		SmartClient:      c.SmartClient,
+		simulationClient: &simulationSmartClient{result: result, store: c.Store},
+	}
+	st.recorder = &record.FakeRecorder{}
+	st.tracer = nil
+	st.completionNotifier = nil
+	// Simulated results must not be recorded as outcomes of real processing
+	st.resourceCache = nil
+	st.deletedObjects = nil
+	st.metrics = nil
+	st.runRecorded(result)
+	return result
+}
+
+// dryRunSmartClient reads objects through the wrapped client but records create/update/delete operations
+// instead of sending them to the API server.
+type dryRunSmartClient struct {
+	SmartClient
+	// simulationClient records create/update/delete operations in the result of the dry run.
+	simulationClient *simulationSmartClient
+}
+
+func (c *dryRunSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	client, err := c.SmartClient.ForGVK(gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	simulationClient, err := c.simulationClient.ForGVK(gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunResourceClient{
+		ResourceInterface: client,
+		simulationClient:  simulationClient,
+	}, nil
+}
+
+// dryRunResourceClient overrides mutating methods used by the controller.
+// Other methods are served by the wrapped client.
+type dryRunResourceClient struct {
+	dynamic.ResourceInterface
+	simulationClient dynamic.ResourceInterface
+}
+
+func (c *dryRunResourceClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return c.simulationClient.Create(obj)
+}
+
+func (c *dryRunResourceClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return c.simulationClient.Update(obj)
+}
+
+func (c *dryRunResourceClient) Patch(name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error) {
+	return c.simulationClient.Patch(name, pt, data)
+}
+
+func (c *dryRunResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	return c.simulationClient.Delete(name, options)
+}
@@ -0,0 +1,99 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// readOnlySmartClient fails the test if an object is mutated through it.
+type readOnlySmartClient struct {
+	t *testing.T
+}
+
+func (c readOnlySmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return readOnlyResourceClient{t: c.t}, nil
+}
+
+type readOnlyResourceClient struct {
+	dynamic.ResourceInterface
+	t *testing.T
+}
+
+func (c readOnlyResourceClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.t.Errorf("unexpected create of %s", obj.GetName())
+	return obj, nil
+}
+
+func (c readOnlyResourceClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.t.Errorf("unexpected update of %s", obj.GetName())
+	return obj, nil
+}
+
+func (c readOnlyResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.t.Errorf("unexpected delete of %s", name)
+	return nil
+}
+
+func TestDryRunDoesNotMutate(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	store, err := newSimulationStore(nil)
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	c := &Controller{
+		SmartClient: readOnlySmartClient{t: t},
+		Rc:          configMapsReadyChecker{},
+		Store:       store,
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		MaxConcurrentResources: 1,
+	}
+
+	result := c.DryRun(logger, bundle)
+	require.NoError(t, result.Error)
+
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm1", result.Created[0].GetName())
+	assert.Empty(t, result.Updated)
+	assert.Empty(t, result.Deleted)
+	assert.Empty(t, bundle.Status.Conditions, "input Bundle must not be mutated")
+}
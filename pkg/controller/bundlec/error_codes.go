@@ -0,0 +1,59 @@
+package bundlec
+
+import (
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// codedError is an error classified with a code that is reported in the Error condition.
+type codedError struct {
+	code smith_v1.ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the classified error so that errors.Cause() sees through the classification.
+func (e *codedError) Cause() error {
+	return e.err
+}
+
+// withErrorCode classifies the error with the code.
+func withErrorCode(code smith_v1.ErrorCode, err error) error {
+	return &codedError{
+		code: code,
+		err:  err,
+	}
+}
+
+// errorCode returns the code of the error. The outermost classification wins. Errors that were not classified
+// explicitly are classified by their cause. Empty code is returned if the error cannot be classified.
+func errorCode(err error) smith_v1.ErrorCode {
+	type causer interface {
+		Cause() error
+	}
+	for e := err; e != nil; {
+		if ce, ok := e.(*codedError); ok {
+			return ce.code
+		}
+		c, ok := e.(causer)
+		if !ok {
+			break
+		}
+		e = c.Cause()
+	}
+	cause := errors.Cause(err)
+	switch {
+	case isPluginTimeoutError(cause):
+		return smith_v1.ErrorCodePluginTimeout
+	case api_errors.IsConflict(cause):
+		return smith_v1.ErrorCodeConflict
+	case api_errors.IsInvalid(cause):
+		return smith_v1.ErrorCodeSpecInvalid
+	default:
+		return ""
+	}
+}
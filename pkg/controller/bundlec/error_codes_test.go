@@ -0,0 +1,125 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestErrorCode(t *testing.T) {
+	t.Parallel()
+	conflict := api_errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "cm1", errors.New("stale"))
+	tests := []struct {
+		name string
+		err  error
+		code smith_v1.ErrorCode
+	}{
+		{
+			name: "unclassified",
+			err:  errors.New("boom"),
+			code: "",
+		},
+		{
+			name: "classified",
+			err:  withErrorCode(smith_v1.ErrorCodeDependencyCycle, errors.New("cycle")),
+			code: smith_v1.ErrorCodeDependencyCycle,
+		},
+		{
+			name: "wrapped classified",
+			err:  errors.Wrap(withErrorCode(smith_v1.ErrorCodePluginNotFound, errors.New("no such plugin")), "context"),
+			code: smith_v1.ErrorCodePluginNotFound,
+		},
+		{
+			name: "outermost classification",
+			err:  withErrorCode(smith_v1.ErrorCodeReadinessCheckFailed, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.New("boom"))),
+			code: smith_v1.ErrorCodeReadinessCheckFailed,
+		},
+		{
+			name: "conflict",
+			err:  errors.Wrap(conflict, "update failed"),
+			code: smith_v1.ErrorCodeConflict,
+		},
+		{
+			name: "invalid",
+			err:  api_errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "cm1", nil),
+			code: smith_v1.ErrorCodeSpecInvalid,
+		},
+		{
+			name: "plugin timeout",
+			err:  errors.WithStack(&pluginTimeoutError{plugin: "p"}),
+			code: smith_v1.ErrorCodePluginTimeout,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, errorCode(tc.err))
+		})
+	}
+}
+
+func TestErrorCodeDoesNotHideCause(t *testing.T) {
+	t.Parallel()
+	conflict := api_errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "cm1", errors.New("stale"))
+	err := withErrorCode(smith_v1.ErrorCodeConflict, conflict)
+	assert.True(t, api_errors.IsConflict(errors.Cause(err)))
+	assert.Equal(t, conflict.Error(), err.Error())
+}
+
+func TestErrorCodeIsReportedInConditions(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "p",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "unknown",
+							ObjectName: "obj1",
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("p")
+	require.NotNil(t, resStatus)
+	_, resErrorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, resErrorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, resErrorCond.Status)
+	assert.Equal(t, smith_v1.ErrorCodePluginNotFound, resErrorCond.Code)
+
+	_, bundleErrorCond := result.Bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, bundleErrorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, bundleErrorCond.Status)
+	assert.Equal(t, smith_v1.ErrorCodeResourcesFailed, bundleErrorCond.Code)
+}
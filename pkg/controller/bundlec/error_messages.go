@@ -0,0 +1,30 @@
+package bundlec
+
+import (
+	"regexp"
+)
+
+// ErrorMessagePattern maps errors with messages matching Pattern to Message.
+// Message may contain references to submatches of Pattern as supported by regexp.Regexp.Expand.
+type ErrorMessagePattern struct {
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// PatternErrorMessageTransformer is an ErrorMessageTransformer that uses the first pattern matching
+// the error message. Error message is used as is if no pattern matches.
+type PatternErrorMessageTransformer struct {
+	Patterns []ErrorMessagePattern
+}
+
+func (t *PatternErrorMessageTransformer) ErrorMessage(err error) string {
+	msg := err.Error()
+	for _, p := range t.Patterns {
+		submatches := p.Pattern.FindStringSubmatchIndex(msg)
+		if submatches == nil {
+			continue
+		}
+		return string(p.Pattern.ExpandString(nil, p.Message, msg, submatches))
+	}
+	return msg
+}
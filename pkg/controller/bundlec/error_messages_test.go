@@ -0,0 +1,31 @@
+package bundlec
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternErrorMessageTransformer(t *testing.T) {
+	t.Parallel()
+	tr := &PatternErrorMessageTransformer{
+		Patterns: []ErrorMessagePattern{
+			{
+				Pattern: regexp.MustCompile(`exceeded quota: (\S+)`),
+				Message: "Namespace quota ${1} is exhausted, ask the namespace owner to increase it",
+			},
+			{
+				Pattern: regexp.MustCompile(`admission webhook "([^"]+)" denied the request`),
+				Message: "Rejected by admission webhook ${1}",
+			},
+		},
+	}
+
+	assert.Equal(t, "Namespace quota compute-resources is exhausted, ask the namespace owner to increase it",
+		tr.ErrorMessage(errors.New(`pods "p1" is forbidden: exceeded quota: compute-resources, requested: pods=1`)))
+	assert.Equal(t, "Rejected by admission webhook policy.example.com",
+		tr.ErrorMessage(errors.New(`admission webhook "policy.example.com" denied the request: nope`)))
+	assert.Equal(t, "something else", tr.ErrorMessage(errors.New("something else")))
+}
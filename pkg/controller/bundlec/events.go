@@ -0,0 +1,94 @@
+package bundlec
+
+import (
+	"fmt"
+	"strings"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventReasonObjectUpdated is the reason for Events emitted when an object is updated to match the spec.
+	EventReasonObjectUpdated = "ObjectUpdated"
+	// EventReasonObjectRecreated is the reason for Events emitted when an object is deleted to be created again
+	// because its update was rejected as invalid.
+	EventReasonObjectRecreated = "ObjectRecreated"
+	// EventReasonDeletionProtected is the reason for Events emitted when resources of a deleted Bundle
+	// are not deleted because the Bundle is protected from deletion.
+	EventReasonDeletionProtected = "DeletionProtected"
+	// EventReasonObjectOrphaned is the reason for Events emitted when an object is left orphaned because
+	// it cannot be deleted or because the Bundle's reclaim policy is Retain.
+	EventReasonObjectOrphaned = "ObjectOrphaned"
+	// EventReasonResourceReady is the reason for Events emitted when a resource becomes ready and its
+	// Ready condition has no reason of its own. Events for other resource transitions use reasons of
+	// the corresponding conditions.
+	EventReasonResourceReady = "ResourceReady"
+
+	// maxChangedPathsInEvent is the maximum number of changed paths listed in an Event message.
+	maxChangedPathsInEvent = 10
+)
+
+// changedPathsMessage formats a list of changed paths for an Event message, truncating it if it is too long.
+func changedPathsMessage(paths []string) string {
+	if len(paths) == 0 {
+		return "no top-level changes"
+	}
+	if len(paths) <= maxChangedPathsInEvent {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:maxChangedPathsInEvent], ", "), len(paths)-maxChangedPathsInEvent)
+}
+
+// resourceEvent is an Event about a resource of a Bundle.
+type resourceEvent struct {
+	eventType string
+	reason    string
+	message   string
+}
+
+// resourceTransitionEvents returns Events for Blocked, Ready and Error conditions of a resource that
+// have transitioned to True. oldStatus is the status of the resource before the transition, may be nil.
+func resourceTransitionEvents(resName smith_v1.ResourceName, oldStatus *smith_v1.ResourceStatus, blockedCond, readyCond, errorCond *smith_v1.ResourceCondition) []resourceEvent {
+	transitioned := func(cond *smith_v1.ResourceCondition) bool {
+		if cond.Status != smith_v1.ConditionTrue {
+			return false
+		}
+		if oldStatus == nil {
+			return true
+		}
+		_, oldCond := oldStatus.GetCondition(cond.Type)
+		return oldCond == nil || oldCond.Status != smith_v1.ConditionTrue
+	}
+	var events []resourceEvent
+	if transitioned(blockedCond) {
+		events = append(events, resourceEvent{
+			eventType: core_v1.EventTypeNormal,
+			reason:    blockedCond.Reason,
+			message:   fmt.Sprintf("Resource %q is blocked: %s", resName, blockedCond.Message),
+		})
+	}
+	if transitioned(readyCond) {
+		reason := readyCond.Reason
+		if reason == "" {
+			reason = EventReasonResourceReady
+		}
+		message := fmt.Sprintf("Resource %q is ready", resName)
+		if readyCond.Message != "" {
+			message += ": " + readyCond.Message
+		}
+		events = append(events, resourceEvent{
+			eventType: core_v1.EventTypeNormal,
+			reason:    reason,
+			message:   message,
+		})
+	}
+	if transitioned(errorCond) {
+		events = append(events, resourceEvent{
+			eventType: core_v1.EventTypeWarning,
+			reason:    errorCond.Reason,
+			message:   fmt.Sprintf("Resource %q failed: %s", resName, errorCond.Message),
+		})
+	}
+	return events
+}
@@ -0,0 +1,134 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestResourceTransitionEvents(t *testing.T) {
+	t.Parallel()
+	oldStatus := &smith_v1.ResourceStatus{
+		Name: "a",
+		Conditions: []smith_v1.ResourceCondition{
+			{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionTrue, Reason: smith_v1.ResourceReasonDependenciesNotReady},
+			{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse},
+			{Type: smith_v1.ResourceError, Status: smith_v1.ConditionFalse},
+		},
+	}
+	blockedCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionTrue, Reason: smith_v1.ResourceReasonDependenciesNotReady, Message: `Not ready: ["b"]`}
+	readyCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionFalse}
+	errorCond := smith_v1.ResourceCondition{Type: smith_v1.ResourceError, Status: smith_v1.ConditionTrue, Reason: smith_v1.ResourceReasonTerminalError, Message: "boom"}
+
+	// Blocked condition has not changed
+	assert.Equal(t, []resourceEvent{
+		{
+			eventType: core_v1.EventTypeWarning,
+			reason:    smith_v1.ResourceReasonTerminalError,
+			message:   `Resource "a" failed: boom`,
+		},
+	}, resourceTransitionEvents("a", oldStatus, &blockedCond, &readyCond, &errorCond))
+
+	// No previous status
+	assert.Len(t, resourceTransitionEvents("a", nil, &blockedCond, &readyCond, &errorCond), 2)
+}
+
+func TestResourceTransitionEventsAreEmittedOnce(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	store, err := newSimulationStore(nil)
+	require.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		smartClient:  &simulationSmartClient{result: &ReconcileResult{}},
+		rc:           configMapsReadyChecker{},
+		store:        store,
+		specCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "config",
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "cm1",
+								},
+							},
+						},
+					},
+					{
+						Name: "deployment",
+						Spec: smith_v1.ResourceSpec{
+							Object: &apps_v1.Deployment{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "Deployment",
+									APIVersion: apps_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "d1",
+								},
+							},
+						},
+					},
+					{
+						Name: "blocked",
+						References: []smith_v1.Reference{
+							{
+								Resource: "deployment",
+							},
+						},
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "cm2",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		recorder:               recorder,
+		maxConcurrentResources: 1,
+	}
+	for i := 0; i < 2; i++ {
+		retriable, err := st.processNormal()
+		_, err = st.handleProcessResult(retriable, err)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, recorder.Events, 2)
+	assert.Equal(t, `Normal ResourceReady Resource "config" is ready`, <-recorder.Events)
+	assert.Equal(t, `Normal DependenciesNotReady Resource "blocked" is blocked: Not ready: "deployment" (in progress)`, <-recorder.Events)
+}
@@ -0,0 +1,160 @@
+package bundlec
+
+import (
+	"github.com/atlassian/ctrl"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/util"
+	"go.uber.org/zap"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resourceStatusObjectNotFound means the object outside of the Bundle that is referenced by a resource does not exist.
+type resourceStatusObjectNotFound struct {
+}
+
+// externalObjectResourceName returns the name under which the object outside of the Bundle is resolved as a dependency.
+// It cannot clash with names of resources because those cannot contain slashes.
+func externalObjectResourceName(object *smith_v1.ExternalObjectReference) smith_v1.ResourceName {
+	return smith_v1.ResourceName(object.GroupVersionKind().GroupKind().String() + "/" + object.Name)
+}
+
+// resolveExternalObjects makes objects outside of the Bundle that are referenced by the resource available as
+// processed dependencies. Returns a copy of the resource where such references refer to those dependencies,
+// or the resource as is if it has no such references.
+func (st *resourceSyncTask) resolveExternalObjects(res *smith_v1.Resource) *smith_v1.Resource {
+	var processedResources map[smith_v1.ResourceName]*resourceInfo
+	for i, reference := range res.References {
+		if reference.Object == nil {
+			continue
+		}
+		if processedResources == nil {
+			// Processed resources are shared with the Bundle, do not mutate them
+			processedResources = make(map[smith_v1.ResourceName]*resourceInfo, len(st.processedResources)+1)
+			for resName, resInfo := range st.processedResources {
+				processedResources[resName] = resInfo
+			}
+			res = res.DeepCopy()
+		}
+		resName := externalObjectResourceName(reference.Object)
+		res.References[i].Resource = resName
+		if _, ok := processedResources[resName]; !ok {
+			processedResources[resName] = st.externalObjectInfo(reference.Object)
+		}
+	}
+	if processedResources != nil {
+		st.processedResources = processedResources
+	}
+	return res
+}
+
+// externalObjectInfo returns information about an object outside of the Bundle.
+// Object is ready if it passes the same readiness checks as objects of resources.
+func (st *resourceSyncTask) externalObjectInfo(object *smith_v1.ExternalObjectReference) *resourceInfo {
+	gvk := object.GroupVersionKind()
+	actual, exists, err := st.getObject(gvk, object.Name)
+	if err != nil {
+		return &resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}
+	}
+	if !exists {
+		return &resourceInfo{
+			status: resourceStatusObjectNotFound{},
+		}
+	}
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return &resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}
+	}
+	// Typed objects from informers do not have kind/apiVersion set
+	actualUnstr.SetGroupVersionKind(gvk)
+	isReady, retriableError, err := st.defaultIsReady(actualUnstr)
+	if err != nil {
+		return &resourceInfo{
+			actual: actualUnstr,
+			status: resourceStatusError{
+				err:              err,
+				isRetriableError: retriableError,
+			},
+		}
+	}
+	if !isReady {
+		return &resourceInfo{
+			actual: actualUnstr,
+			status: resourceStatusInProgress{},
+		}
+	}
+	bindingSecret, err := st.maybeExtractBindingSecret(actualUnstr)
+	if err != nil {
+		return &resourceInfo{
+			actual: actualUnstr,
+			status: resourceStatusError{
+				err: err,
+			},
+		}
+	}
+	return &resourceInfo{
+		actual:               actualUnstr,
+		status:               resourceStatusReady{},
+		serviceBindingSecret: bindingSecret,
+	}
+}
+
+// externalObjectHandler returns an event handler for objects of a particular kind that enqueues Bundles
+// referencing them as objects outside of the Bundle.
+func (c *Controller) externalObjectHandler(gk schema.GroupKind) cache.ResourceEventHandler {
+	return &externalObjectEventHandler{
+		logger:      c.Logger,
+		workQueue:   c.WorkQueue,
+		gk:          gk,
+		bundleStore: c.BundleStore,
+	}
+}
+
+// externalObjectEventHandler enqueues Bundles that reference objects of a particular kind outside of them
+// when those objects change.
+type externalObjectEventHandler struct {
+	logger      *zap.Logger
+	workQueue   ctrl.WorkQueueProducer
+	gk          schema.GroupKind
+	bundleStore BundleStore
+}
+
+func (h *externalObjectEventHandler) OnAdd(obj interface{}) {
+	h.enqueueReferencing(obj)
+}
+
+func (h *externalObjectEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.enqueueReferencing(newObj)
+}
+
+func (h *externalObjectEventHandler) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	h.enqueueReferencing(obj)
+}
+
+func (h *externalObjectEventHandler) enqueueReferencing(obj interface{}) {
+	metaObj, ok := obj.(meta_v1.Object)
+	if !ok {
+		h.logger.Sugar().Errorf("Unexpected object type %T", obj)
+		return
+	}
+	bundles, err := h.bundleStore.GetBundlesByReferencedObject(h.gk, metaObj.GetNamespace(), metaObj.GetName())
+	if err != nil {
+		h.logger.Error("Failed to get Bundles referencing object", zap.Error(err))
+		return
+	}
+	for _, bundle := range bundles {
+		h.workQueue.Add(bundleQueueKey(bundle))
+	}
+}
@@ -0,0 +1,239 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestExternalObjectReference(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					References: []smith_v1.Reference{
+						{
+							Name: "shared",
+							Object: &smith_v1.ExternalObjectReference{
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+								Kind:       "ConfigMap",
+								Name:       "shared",
+							},
+							Path: "data.key",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"key": "!{shared}",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	shared := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "shared",
+			Namespace: "ns",
+			UID:       "shared-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle2",
+					UID:        "bundle2-uid",
+					Controller: &tr,
+				},
+			},
+		},
+		Data: map[string]string{
+			"key": "value",
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	// Resource is blocked while the object does not exist
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Created)
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{
+		"config": {"ConfigMap/shared"},
+	}, result.Blocked)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, blockedCond := resStatus.GetCondition(smith_v1.ResourceBlocked)
+	require.NotNil(t, blockedCond)
+	assert.Equal(t, smith_v1.ConditionTrue, blockedCond.Status)
+	assert.Contains(t, blockedCond.Message, `"ConfigMap/shared" (not found)`)
+
+	// Values are taken from the object once it is ready
+	result, err = s.Simulate(bundle, []runtime.Object{shared})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Blocked)
+	require.Len(t, result.Created, 1)
+	created := result.Created[0]
+	assert.Equal(t, "cm1", created.GetName())
+	value, _, err := unstructured.NestedString(created.Object, "data", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	for _, ref := range created.GetOwnerReferences() {
+		assert.NotEqual(t, shared.UID, ref.UID, "object outside of the Bundle must not be an owner")
+	}
+}
+
+func TestExternalObjectReferenceCannotNameResource(t *testing.T) {
+	t.Parallel()
+	resources := []smith_v1.Resource{
+		{
+			Name: "res1",
+		},
+		{
+			Name: "res2",
+			References: []smith_v1.Reference{
+				{
+					Resource: "res1",
+					Object: &smith_v1.ExternalObjectReference{
+						APIVersion: "v1",
+						Kind:       "ConfigMap",
+						Name:       "shared",
+					},
+				},
+			},
+		},
+	}
+	err := checkDependenciesExist(resources)
+	require.Error(t, err)
+	assert.Equal(t, smith_v1.ErrorCodeSpecInvalid, errorCode(err))
+}
+
+func TestExternalObjectReferenceDefault(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					References: []smith_v1.Reference{
+						{
+							Name: "input",
+							Object: &smith_v1.ExternalObjectReference{
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+								Kind:       "ConfigMap",
+								Name:       "input",
+							},
+							Path:    "data.key",
+							Default: "fallback",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"key": "!{input}",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	// Object is not managed by Smith
+	input := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "input",
+			Namespace: "ns",
+			UID:       "input-uid",
+		},
+		Data: map[string]string{
+			"key": "value",
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	dataKey := func(t *testing.T, result *ReconcileResult) string {
+		require.Len(t, result.Created, 1)
+		value, _, err := unstructured.NestedString(result.Created[0].Object, "data", "key")
+		require.NoError(t, err)
+		return value
+	}
+
+	// Default is used while the object does not exist
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Blocked)
+	assert.Equal(t, "fallback", dataKey(t, result))
+
+	// Value is taken from the object once it exists
+	result, err = s.Simulate(bundle, []runtime.Object{input})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Blocked)
+	assert.Equal(t, "value", dataKey(t, result))
+}
@@ -2,6 +2,7 @@ package bundlec
 
 import (
 	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/resources"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,8 +15,12 @@ func hasDeleteResourcesFinalizer(accessor meta_v1.Object) bool {
 	return resources.HasFinalizer(accessor, FinalizerDeleteResources)
 }
 
-func addDeleteResourcesFinalizer(finalizers []string) []string {
-	return append(finalizers, FinalizerDeleteResources)
+// addMissingFinalizers returns finalizers of the Bundle with missing finalizers added.
+func addMissingFinalizers(bundle *smith_v1.Bundle) []string {
+	finalizers := bundle.GetFinalizers()
+	newFinalizers := make([]string, 0, len(finalizers)+1)
+	newFinalizers = append(newFinalizers, finalizers...)
+	return append(newFinalizers, missingFinalizers(bundle)...)
 }
 
 func removeDeleteResourcesFinalizer(finalizers []string) []string {
@@ -28,3 +33,53 @@ func removeDeleteResourcesFinalizer(finalizers []string) []string {
 	}
 	return newFinalizers
 }
+
+// externalFinalizers returns finalizers requested in the Bundle spec. Such finalizers are set on the Bundle
+// by Smith and removed by external systems once they have finished their cleanup.
+func externalFinalizers(bundle *smith_v1.Bundle) []string {
+	finalizers := make([]string, 0, len(bundle.Spec.Finalizers))
+	for _, finalizer := range bundle.Spec.Finalizers {
+		if finalizer == FinalizerDeleteResources || finalizer == meta_v1.FinalizerDeleteDependents ||
+			finalizer == meta_v1.FinalizerOrphanDependents {
+			// Finalizers managed by Smith or by the garbage collector cannot be requested
+			continue
+		}
+		finalizers = append(finalizers, finalizer)
+	}
+	return finalizers
+}
+
+// missingFinalizers returns finalizers Smith sets on the Bundle which are not set yet, in the order
+// they should be added in. The "deleteResources" finalizer comes first.
+func missingFinalizers(bundle *smith_v1.Bundle) []string {
+	var missing []string
+	if !hasDeleteResourcesFinalizer(bundle) {
+		missing = append(missing, FinalizerDeleteResources)
+	}
+	for _, finalizer := range externalFinalizers(bundle) {
+		if !resources.HasFinalizer(bundle, finalizer) && !containsString(missing, finalizer) {
+			missing = append(missing, finalizer)
+		}
+	}
+	return missing
+}
+
+// pendingExternalFinalizers returns finalizers requested in the Bundle spec that have not been removed yet.
+func pendingExternalFinalizers(bundle *smith_v1.Bundle) []string {
+	var pending []string
+	for _, finalizer := range externalFinalizers(bundle) {
+		if resources.HasFinalizer(bundle, finalizer) && !containsString(pending, finalizer) {
+			pending = append(pending, finalizer)
+		}
+	}
+	return pending
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
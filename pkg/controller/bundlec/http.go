@@ -0,0 +1,35 @@
+package bundlec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// postJSON POSTs the JSON body to the url with the additional headers and returns the response status code.
+// Returned error means that the request could not be sent. Response body is discarded.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header map[string]string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body) // Drain body so that the connection can be reused
+	return resp.StatusCode, nil
+}
+
+func isSuccessStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
@@ -0,0 +1,228 @@
+package bundlec
+
+import (
+	"sync"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Resource processing outcomes.
+const (
+	resourceOutcomeReady      = "ready"
+	resourceOutcomeBlocked    = "blocked"
+	resourceOutcomeInProgress = "inprogress"
+	resourceOutcomeError      = "error"
+)
+
+// Metrics holds Prometheus metrics of the Bundle controller.
+type Metrics struct {
+	transitions         *transitionsCollector
+	panics              prometheus.Counter
+	resourceOutcomes    *prometheus.CounterVec
+	resourceProcessTime *prometheus.HistogramVec
+	blockedResources    *prometheus.GaugeVec
+}
+
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		transitions: &transitionsCollector{
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "bundle", "seconds_since_last_transition"),
+				"Number of seconds since the last condition transition of a Bundle or any of its resources.",
+				[]string{"namespace", "name", "blocked"},
+				nil,
+			),
+			blockedDesc: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "bundle", "blocked_seconds"),
+				"Number of seconds a Bundle has been blocked by dependencies of its resources, "+
+					"only reported while the Bundle is neither Ready nor in Error state.",
+				[]string{"namespace", "name"},
+				nil,
+			),
+			bundles: make(map[types.NamespacedName]bundleTransition),
+		},
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "bundle",
+			Name:      "process_panics_total",
+			Help:      "Number of panics recovered from while processing Bundles.",
+		}),
+		resourceOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "bundle",
+			Name:      "resource_outcomes_total",
+			Help:      "Number of processed resources by outcome and kind of object.",
+		}, []string{"group", "version", "kind", "outcome"}),
+		resourceProcessTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "bundle",
+			Name:      "resource_process_seconds",
+			Help:      "Time it takes to process a resource, by kind of object.",
+		}, []string{"group", "version", "kind"}),
+		blockedResources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "bundle",
+			Name:      "blocked_resources",
+			Help:      "Number of resources of a Bundle blocked by dependencies that are not ready.",
+		}, []string{"namespace", "name"}),
+	}
+}
+
+func (m *Metrics) Register(registry prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.transitions, m.panics, m.resourceOutcomes, m.resourceProcessTime, m.blockedResources} {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observePanic records a panic recovered from while processing a Bundle.
+func (m *Metrics) observePanic() {
+	m.panics.Inc()
+}
+
+// observeResource records the outcome and the duration of processing of a resource which object has the GVK.
+func (m *Metrics) observeResource(gvk schema.GroupVersionKind, outcome string, duration time.Duration) {
+	m.resourceOutcomes.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind, outcome).Inc()
+	m.resourceProcessTime.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Observe(duration.Seconds())
+}
+
+// observeBundle records the time of the last condition transition, the time the Bundle became blocked and
+// the number of blocked resources of a processed Bundle. Times are not reported for a Bundle without
+// condition transitions.
+func (m *Metrics) observeBundle(bundle *smith_v1.Bundle) {
+	key := types.NamespacedName{Namespace: bundle.Namespace, Name: bundle.Name}
+	if bundle.DeletionTimestamp != nil {
+		m.transitions.forget(key)
+		m.blockedResources.DeleteLabelValues(bundle.Namespace, bundle.Name)
+		return
+	}
+	if lastTransition := lastTransitionTime(bundle); lastTransition.IsZero() {
+		m.transitions.forget(key)
+	} else {
+		t := bundleTransition{
+			lastTransition: lastTransition,
+		}
+		if isBundleBlocked(bundle) {
+			t.blocked = true
+			t.blockedSince = blockedSince(bundle)
+		}
+		m.transitions.observe(key, t)
+	}
+	m.blockedResources.WithLabelValues(bundle.Namespace, bundle.Name).Set(float64(blockedResources(bundle)))
+}
+
+type bundleTransition struct {
+	lastTransition time.Time
+	blocked        bool
+	// blockedSince is when the Bundle became blocked. Zero if it is not known.
+	blockedSince time.Time
+}
+
+// transitionsCollector computes time since the last transition and time since the Bundle became blocked
+// at collection time.
+type transitionsCollector struct {
+	desc        *prometheus.Desc
+	blockedDesc *prometheus.Desc
+
+	mx      sync.Mutex
+	bundles map[types.NamespacedName]bundleTransition
+}
+
+func (c *transitionsCollector) observe(key types.NamespacedName, t bundleTransition) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.bundles[key] = t
+}
+
+func (c *transitionsCollector) forget(key types.NamespacedName) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.bundles, key)
+}
+
+func (c *transitionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	ch <- c.blockedDesc
+}
+
+func (c *transitionsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	now := time.Now()
+	for key, t := range c.bundles {
+		blocked := "false"
+		if t.blocked {
+			blocked = "true"
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(t.lastTransition).Seconds(),
+			key.Namespace, key.Name, blocked)
+		if !t.blockedSince.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.blockedDesc, prometheus.GaugeValue, now.Sub(t.blockedSince).Seconds(),
+				key.Namespace, key.Name)
+		}
+	}
+}
+
+// lastTransitionTime returns the most recent transition time of Bundle and resource conditions.
+func lastTransitionTime(bundle *smith_v1.Bundle) time.Time {
+	var last time.Time
+	for _, cond := range bundle.Status.Conditions {
+		if cond.LastTransitionTime.Time.After(last) {
+			last = cond.LastTransitionTime.Time
+		}
+	}
+	for _, resStatus := range bundle.Status.ResourceStatuses {
+		for _, cond := range resStatus.Conditions {
+			if cond.LastTransitionTime.Time.After(last) {
+				last = cond.LastTransitionTime.Time
+			}
+		}
+	}
+	return last
+}
+
+// blockedSince returns the time the Bundle became blocked, which is the earliest transition of a resource
+// to blocked among resources that are still blocked. Zero time is returned if no resource is blocked.
+func blockedSince(bundle *smith_v1.Bundle) time.Time {
+	var since time.Time
+	for _, resStatus := range bundle.Status.ResourceStatuses {
+		_, cond := resStatus.GetCondition(smith_v1.ResourceBlocked)
+		if cond == nil || cond.Status != smith_v1.ConditionTrue || cond.LastTransitionTime.IsZero() {
+			continue
+		}
+		if since.IsZero() || cond.LastTransitionTime.Time.Before(since) {
+			since = cond.LastTransitionTime.Time
+		}
+	}
+	return since
+}
+
+// blockedResources returns the number of resources of the Bundle that are blocked by dependencies.
+func blockedResources(bundle *smith_v1.Bundle) int {
+	blocked := 0
+	for _, resStatus := range bundle.Status.ResourceStatuses {
+		_, cond := resStatus.GetCondition(smith_v1.ResourceBlocked)
+		if cond != nil && cond.Status == smith_v1.ConditionTrue {
+			blocked++
+		}
+	}
+	return blocked
+}
+
+// isBundleBlocked returns true if the Bundle is neither Ready nor in Error state and
+// at least one of its resources is blocked by dependencies.
+func isBundleBlocked(bundle *smith_v1.Bundle) bool {
+	for _, condType := range []smith_v1.BundleConditionType{smith_v1.BundleReady, smith_v1.BundleError} {
+		_, cond := bundle.GetCondition(condType)
+		if cond != nil && cond.Status == smith_v1.ConditionTrue {
+			return false
+		}
+	}
+	return blockedResources(bundle) > 0
+}
@@ -0,0 +1,109 @@
+package bundlec
+
+import (
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBundleBlockedSince(t *testing.T) {
+	t.Parallel()
+	bundleTransition := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	resourceTransition := bundleTransition.Add(time.Minute)
+	bundle := &smith_v1.Bundle{
+		Status: smith_v1.BundleStatus{
+			Conditions: []smith_v1.BundleCondition{
+				{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionTrue, LastTransitionTime: meta_v1.NewTime(bundleTransition)},
+				{Type: smith_v1.BundleReady, Status: smith_v1.ConditionFalse, LastTransitionTime: meta_v1.NewTime(bundleTransition)},
+				{Type: smith_v1.BundleError, Status: smith_v1.ConditionFalse, LastTransitionTime: meta_v1.NewTime(bundleTransition)},
+			},
+			ResourceStatuses: []smith_v1.ResourceStatus{
+				{
+					Name: "a",
+					Conditions: []smith_v1.ResourceCondition{
+						{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionTrue, LastTransitionTime: meta_v1.NewTime(resourceTransition)},
+					},
+				},
+			},
+		},
+	}
+	assert.True(t, isBundleBlocked(bundle))
+	assert.Equal(t, 1, blockedResources(bundle))
+	assert.Equal(t, resourceTransition, lastTransitionTime(bundle))
+	assert.Equal(t, resourceTransition, blockedSince(bundle))
+
+	bundle.Status.Conditions[2].Status = smith_v1.ConditionTrue
+	assert.False(t, isBundleBlocked(bundle))
+}
+
+func TestBundleTransitionMetrics(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics("smith")
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, m.Register(registry))
+
+	// Bundle without conditions has not transitioned yet
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "bundle1",
+		},
+	}
+	m.observeBundle(bundle)
+	assert.Empty(t, gatheredMetrics(t, registry, "smith_bundle_seconds_since_last_transition"))
+	assert.Empty(t, gatheredMetrics(t, registry, "smith_bundle_blocked_seconds"))
+
+	blockedAt := time.Now().Add(-time.Hour)
+	bundle.Status = smith_v1.BundleStatus{
+		Conditions: []smith_v1.BundleCondition{
+			{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionTrue, LastTransitionTime: meta_v1.NewTime(blockedAt)},
+		},
+		ResourceStatuses: []smith_v1.ResourceStatus{
+			{
+				Name: "a",
+				Conditions: []smith_v1.ResourceCondition{
+					{Type: smith_v1.ResourceBlocked, Status: smith_v1.ConditionTrue, LastTransitionTime: meta_v1.NewTime(blockedAt)},
+				},
+			},
+		},
+	}
+	m.observeBundle(bundle)
+	require.Len(t, gatheredMetrics(t, registry, "smith_bundle_seconds_since_last_transition"), 1)
+	blocked := gatheredMetrics(t, registry, "smith_bundle_blocked_seconds")
+	require.Len(t, blocked, 1)
+	assert.True(t, blocked[0].GetGauge().GetValue() >= time.Hour.Seconds())
+
+	// Bundle is not blocked anymore
+	bundle.Status.ResourceStatuses[0].Conditions[0].Status = smith_v1.ConditionFalse
+	bundle.Status.ResourceStatuses[0].Conditions[0].LastTransitionTime = meta_v1.Now()
+	m.observeBundle(bundle)
+	require.Len(t, gatheredMetrics(t, registry, "smith_bundle_seconds_since_last_transition"), 1)
+	assert.Empty(t, gatheredMetrics(t, registry, "smith_bundle_blocked_seconds"))
+}
+
+// gatheredMetrics returns metrics of the family with the name.
+func gatheredMetrics(t *testing.T, registry prometheus.Gatherer, name string) []*dto.Metric {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.Metric
+		}
+	}
+	return nil
+}
+
+func TestResourceOutcome(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, resourceOutcomeReady, resourceOutcome(resourceStatusReady{}))
+	assert.Equal(t, resourceOutcomeBlocked, resourceOutcome(resourceStatusDependenciesNotReady{}))
+	assert.Equal(t, resourceOutcomeInProgress, resourceOutcome(resourceStatusInProgress{}))
+	assert.Equal(t, resourceOutcomeError, resourceOutcome(resourceStatusError{err: errors.New("boom")}))
+}
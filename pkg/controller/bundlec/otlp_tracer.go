@@ -0,0 +1,313 @@
+package bundlec
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	mathrand "math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	otlpQueueSize      = 10000
+	otlpBatchSize      = 512
+	otlpExportInterval = 5 * time.Second
+	otlpExportTimeout  = 10 * time.Second
+	// otlpServiceName is the service.name attribute of the resource that produces the spans.
+	otlpServiceName = "smith"
+	otlpScopeName   = "github.com/atlassian/smith/pkg/controller/bundlec"
+
+	otlpSpanKindInternal = 1
+	otlpStatusCodeOk     = 1
+	otlpStatusCodeError  = 2
+)
+
+// OTLPTracer exports spans in batches to an OpenTelemetry collector using OTLP over HTTP with JSON encoding.
+// Traces are sampled at their root span, spans of unsampled traces are not recorded. Spans with a parent
+// of another system (see smith.TraceParentAnnotation) follow the sampling decision of the parent.
+// Spans are dropped if the queue is full or if the export fails.
+type OTLPTracer struct {
+	logger         *zap.Logger
+	url            string
+	client         *http.Client
+	samplingRatio  float64
+	exportInterval time.Duration
+	queue          chan *otlpSpan
+}
+
+// NewOTLPTracer creates a tracer that exports spans to the url, e.g. http://otel-collector:4318/v1/traces.
+// samplingRatio is the fraction of traces that are sampled, between 0 and 1.
+func NewOTLPTracer(logger *zap.Logger, url string, samplingRatio float64) *OTLPTracer {
+	return &OTLPTracer{
+		logger: logger,
+		url:    url,
+		client: &http.Client{
+			Timeout: otlpExportTimeout,
+		},
+		samplingRatio:  samplingRatio,
+		exportInterval: otlpExportInterval,
+		queue:          make(chan *otlpSpan, otlpQueueSize),
+	}
+}
+
+func (t *OTLPTracer) StartSpan(parent Span, operation string, attributes map[string]string) Span {
+	span := &otlpSpan{
+		tracer:     t,
+		name:       operation,
+		attributes: attributes,
+		start:      time.Now(),
+	}
+	if parent == nil {
+		if t.samplingRatio <= 0 || (t.samplingRatio < 1 && mathrand.Float64() >= t.samplingRatio) {
+			return noopSpan{}
+		}
+		span.traceID = randomID(16)
+	} else {
+		tc, err := parseTraceParent(parent.TraceParent())
+		if err != nil || !tc.sampled {
+			// Parent is not sampled
+			return noopSpan{}
+		}
+		span.traceID = tc.traceID
+		span.parentSpanID = tc.spanID
+	}
+	span.spanID = randomID(8)
+	return span
+}
+
+// Run exports finished spans until the context is done. Spans that are queued when the context is done
+// are exported before Run returns.
+func (t *OTLPTracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.exportInterval)
+	defer ticker.Stop()
+	batch := make([]*otlpSpan, 0, otlpBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush(batch)
+			return
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		t.export(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// flush exports the batch and spans that are left in the queue.
+func (t *OTLPTracer) flush(batch []*otlpSpan) {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+	defer cancel()
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		default:
+			if len(batch) > 0 {
+				t.export(ctx, batch)
+			}
+			return
+		}
+		t.export(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+func (t *OTLPTracer) enqueue(span *otlpSpan) {
+	select {
+	case t.queue <- span:
+	default:
+		t.logger.Debug("Span queue is full, dropping span", zap.String("span", span.name))
+	}
+}
+
+// export sends the spans to the collector. Failures are logged, tracing must not affect processing of Bundles.
+func (t *OTLPTracer) export(ctx context.Context, spans []*otlpSpan) {
+	body, err := json.Marshal(newOTLPExportRequest(spans))
+	if err != nil {
+		t.logger.Error("Failed to marshal spans", zap.Error(err))
+		return
+	}
+	if err = t.send(ctx, body); err != nil {
+		t.logger.Warn("Failed to export spans", zap.Int("spans", len(spans)), zap.Error(err))
+	}
+}
+
+func (t *OTLPTracer) send(ctx context.Context, body []byte) error {
+	statusCode, err := postJSON(ctx, t.client, t.url, body, nil)
+	if err != nil {
+		return err
+	}
+	if !isSuccessStatus(statusCode) {
+		return errors.Errorf("unexpected response status %d", statusCode)
+	}
+	return nil
+}
+
+type otlpSpan struct {
+	tracer       *OTLPTracer
+	traceID      string
+	spanID       string
+	parentSpanID string
+	name         string
+	attributes   map[string]string
+	start        time.Time
+	end          time.Time
+	err          error
+}
+
+func (s *otlpSpan) Finish(err error) {
+	s.end = time.Now()
+	s.err = err
+	s.tracer.enqueue(s)
+}
+
+func (s *otlpSpan) TraceParent() string {
+	return traceContext{
+		traceID: s.traceID,
+		spanID:  s.spanID,
+		sampled: true,
+	}.traceParent()
+}
+
+// randomID returns a random hex-encoded ID of n bytes.
+func randomID(n int) string {
+	id := make([]byte, n)
+	if _, err := rand.Read(id); err != nil {
+		// Only happens if the system's source of randomness is broken
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Types below are the JSON encoding of the OTLP ExportTraceServiceRequest.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope      `json:"scope"`
+	Spans []otlpSpanData `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpanData struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func newOTLPExportRequest(spans []*otlpSpan) *otlpExportRequest {
+	data := make([]otlpSpanData, 0, len(spans))
+	for _, span := range spans {
+		status := otlpStatus{
+			Code: otlpStatusCodeOk,
+		}
+		if span.err != nil {
+			status = otlpStatus{
+				Code:    otlpStatusCodeError,
+				Message: span.err.Error(),
+			}
+		}
+		data = append(data, otlpSpanData{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentSpanID,
+			Name:              span.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+			Status:            status,
+		})
+	}
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]string{
+						"service.name": otlpServiceName,
+					}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{
+							Name: otlpScopeName,
+						},
+						Spans: data,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpAttributes returns attributes sorted by their keys.
+func otlpAttributes(attributes map[string]string) []otlpKeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+	result := make([]otlpKeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		result = append(result, otlpKeyValue{
+			Key: key,
+			Value: otlpAnyValue{
+				StringValue: value,
+			},
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
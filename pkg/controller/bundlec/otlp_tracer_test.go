@@ -0,0 +1,161 @@
+package bundlec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOTLPTracerExportsSpans(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	var mx sync.Mutex
+	var requests []otlpExportRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req otlpExportRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mx.Lock()
+		defer mx.Unlock()
+		requests = append(requests, req)
+	}))
+	defer srv.Close()
+
+	tracer := NewOTLPTracer(logger, srv.URL, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracer.Run(ctx)
+	}()
+
+	root := tracer.StartSpan(nil, spanReconcile, map[string]string{"bundle": "ns/bundle1"})
+	child := tracer.StartSpan(root, spanProcessResource, map[string]string{"resource": "config"})
+	child.Finish(errors.New("boom"))
+	root.Finish(nil)
+
+	// Queued spans are exported on shutdown
+	cancel()
+	<-done
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].ResourceSpans, 1)
+	resourceSpans := requests[0].ResourceSpans[0]
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "service.name", Value: otlpAnyValue{StringValue: otlpServiceName}},
+	}, resourceSpans.Resource.Attributes)
+	require.Len(t, resourceSpans.ScopeSpans, 1)
+	spans := resourceSpans.ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+
+	childData, rootData := spans[0], spans[1]
+	assert.Equal(t, spanReconcile, rootData.Name)
+	assert.Len(t, rootData.TraceID, 32)
+	assert.Len(t, rootData.SpanID, 16)
+	assert.Empty(t, rootData.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusCodeOk}, rootData.Status)
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "bundle", Value: otlpAnyValue{StringValue: "ns/bundle1"}},
+	}, rootData.Attributes)
+
+	assert.Equal(t, spanProcessResource, childData.Name)
+	assert.Equal(t, rootData.TraceID, childData.TraceID)
+	assert.Equal(t, rootData.SpanID, childData.ParentSpanID)
+	assert.NotEqual(t, rootData.SpanID, childData.SpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusCodeError, Message: "boom"}, childData.Status)
+	assert.NotEmpty(t, childData.StartTimeUnixNano)
+	assert.NotEmpty(t, childData.EndTimeUnixNano)
+}
+
+func TestOTLPTracerSampling(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tracer := NewOTLPTracer(logger, "http://127.0.0.1:0", 0)
+	root := tracer.StartSpan(nil, spanReconcile, nil)
+	assert.Equal(t, noopSpan{}, root)
+	// Children of unsampled spans are not recorded either
+	child := tracer.StartSpan(root, spanSortBundle, nil)
+	assert.Equal(t, noopSpan{}, child)
+	root.Finish(nil)
+	child.Finish(nil)
+	assert.Empty(t, tracer.queue)
+}
+
+func TestOTLPTracerRemoteParent(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	// Sampling decision of the parent is followed regardless of the sampling ratio
+	tracer := NewOTLPTracer(logger, "http://127.0.0.1:0", 0)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Annotations: map[string]string{
+				smith.TraceParentAnnotation: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+		},
+	}
+	root := tracer.StartSpan(bundleParentSpan(logger, bundle), spanReconcile, nil)
+	require.IsType(t, &otlpSpan{}, root)
+	rootSpan := root.(*otlpSpan)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rootSpan.traceID)
+	assert.Equal(t, "00f067aa0ba902b7", rootSpan.parentSpanID)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+rootSpan.spanID+"-01", root.TraceParent())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID(root))
+
+	// Parent is not sampled
+	bundle.Annotations[smith.TraceParentAnnotation] = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	root = tracer.StartSpan(bundleParentSpan(logger, bundle), spanReconcile, nil)
+	assert.Equal(t, noopSpan{}, root)
+	assert.Empty(t, traceID(root))
+}
+
+func TestParseTraceParent(t *testing.T) {
+	t.Parallel()
+	tc, err := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, traceContext{
+		traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		spanID:  "00f067aa0ba902b7",
+		sampled: true,
+	}, tc)
+
+	// Future versions may have more fields
+	_, err = parseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, err = parseTraceParent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,57 @@
+package bundlec
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// panicBudget tracks panics that happened during processing of Bundles within a sliding window.
+type panicBudget struct {
+	budget int
+	window time.Duration
+
+	mx     sync.Mutex
+	panics []time.Time
+}
+
+func newPanicBudget(budget int, window time.Duration) *panicBudget {
+	return &panicBudget{
+		budget: budget,
+		window: window,
+	}
+}
+
+// record records a panic that happened at the given time.
+// Returns true if the number of panics within the window exceeds the budget.
+func (b *panicBudget) record(now time.Time) bool /*exceeded*/ {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	cutoff := now.Add(-b.window)
+	// Drop panics that are out of the window
+	i := 0
+	for i < len(b.panics) && !b.panics[i].After(cutoff) {
+		i++
+	}
+	b.panics = append(b.panics[i:], now)
+	return len(b.panics) > b.budget
+}
+
+// recoverProcessingPanic recovers from a panic during processing of a Bundle and converts it into a
+// terminal error. If the panic budget is exceeded, the process is terminated so that a crash-looping
+// bug is noticed.
+func (c *Controller) recoverProcessingPanic(logger *zap.Logger, recovered interface{}) error {
+	logger.Error("Recovered from panic while processing Bundle",
+		zap.Any("panic", recovered), zap.ByteString("stack", debug.Stack()))
+	if c.Metrics != nil {
+		c.Metrics.observePanic()
+	}
+	if c.panicBudget != nil && c.panicBudget.record(time.Now()) {
+		logger.Fatal("Panic budget exceeded",
+			zap.Int("budget", c.panicBudget.budget), zap.Duration("window", c.panicBudget.window))
+	}
+	return errors.Errorf("internal error while processing Bundle: %v", recovered)
+}
@@ -0,0 +1,86 @@
+package bundlec
+
+import (
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPanicBudget(t *testing.T) {
+	t.Parallel()
+	b := newPanicBudget(2, time.Minute)
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.False(t, b.record(start))
+	assert.False(t, b.record(start.Add(30*time.Second)))
+	assert.True(t, b.record(start.Add(50*time.Second)))
+	// First two panics are out of the window
+	assert.False(t, b.record(start.Add(100*time.Second)))
+}
+
+func TestPanicIsConvertedIntoError(t *testing.T) {
+	t.Parallel()
+	// Metrics are optional
+	for name, metrics := range map[string]*Metrics{
+		"with metrics":    NewMetrics("test"),
+		"without metrics": nil,
+	} {
+		metrics := metrics
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testPanicIsConvertedIntoError(t, metrics)
+		})
+	}
+}
+
+func testPanicIsConvertedIntoError(t *testing.T, metrics *Metrics) {
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	c := &Controller{
+		BundleClient: simulationBundlesGetter{},
+		// Store panics because the embedded interface is nil
+		Store:       controlledObjectsStore{},
+		Recorder:    &record.FakeRecorder{},
+		Metrics:     metrics,
+		panicBudget: newPanicBudget(1, time.Minute),
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       "bundle1",
+			Namespace:  "ns",
+			Finalizers: []string{FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	retriable, err := c.ProcessBundle(logger, bundle)
+	require.Error(t, err)
+	assert.True(t, retriable)
+	_, errorCond := bundle.GetCondition(smith_v1.BundleError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.BundleReasonTerminalError, errorCond.Reason)
+}
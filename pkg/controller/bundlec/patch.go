@@ -0,0 +1,219 @@
+package bundlec
+
+import (
+	"encoding/json"
+
+	ctrlLogz "github.com/atlassian/ctrl/logz"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// patchResource patches the object with fields that are set in the spec. Unlike updateResource it does not
+// replace the object, fields that are only set on the object are left as they are.
+func (st *resourceSyncTask) patchResource(resClient dynamic.ResourceInterface, spec *unstructured.Unstructured, actual runtime.Object) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
+	patch, patchType, patched, err := objectPatch(spec, actual)
+	if err != nil {
+		return nil, false, err
+	}
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return nil, false, err
+	}
+	// Typed objects from informers do not have kind/apiVersion set
+	actualUnstr.SetGroupVersionKind(spec.GroupVersionKind())
+	if patch == nil {
+		st.logger.Info("Object has correct spec", ctrlLogz.Object(spec))
+		st.action = smith_v1.ResourceActionUnchanged
+		return actualUnstr, false, nil
+	}
+	changedPaths := speccheck.ChangedPaths(patched, actualUnstr)
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to marshal patch")
+	}
+	updated, err := resClient.Patch(spec.GetName(), patchType, data)
+	if err != nil {
+		if api_errors.IsConflict(err) {
+			// We let the next processKey() iteration, triggered by someone else updating the resource, finish the work.
+			return nil, false, errors.Wrap(err, "object patch resulted in conflict (will re-process)")
+		}
+		// Unexpected error, will retry
+		return nil, true, err
+	}
+	st.logger.Info("Object patched", ctrlLogz.Object(spec))
+	st.action = smith_v1.ResourceActionUpdated
+	// Only paths are reported, never values, so it is safe to do for Secrets too
+	st.recorder.Eventf(st.bundle, core_v1.EventTypeNormal, EventReasonObjectUpdated, "Patched %s %q: changed %s",
+		spec.GetKind(), spec.GetName(), changedPathsMessage(changedPaths))
+	return updated, false, nil
+}
+
+// objectPatch returns a patch that sets fields of the spec on the actual object, the type of the patch and
+// the object the patch produces. Nil patch is returned if the object has all fields of the spec already.
+// Missing, null and empty values are considered equal, same as in CompareActualVsSpec.
+func objectPatch(spec *unstructured.Unstructured, actual runtime.Object) (map[string]interface{}, types.PatchType, *unstructured.Unstructured, error) {
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	delete(actualUnstr.Object, "status")
+	// Typed objects from informers do not have kind/apiVersion set
+	actualUnstr.SetGroupVersionKind(spec.GroupVersionKind())
+
+	patch := prunePatchValue(patchFields(spec, actualUnstr)).(map[string]interface{})
+	patchType := objectPatchType(spec.GroupVersionKind())
+	patched, err := applyObjectPatch(actualUnstr, patchType, patch)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(speccheck.MergePatch(patched, actualUnstr)) == 0 {
+		return nil, "", nil, nil
+	}
+	return patch, patchType, patched, nil
+}
+
+// patchFields returns fields of the spec that are set by a patch. Of the metadata only labels, annotations,
+// owner references and finalizers are set. Finalizers of the object are kept.
+func patchFields(spec, actual *unstructured.Unstructured) map[string]interface{} {
+	patch := make(map[string]interface{}, len(spec.Object))
+	for field, value := range spec.Object {
+		switch field {
+		case "kind", "apiVersion", "metadata", "status":
+			continue
+		}
+		patch[field] = runtime.DeepCopyJSONValue(value)
+	}
+	metadata := make(map[string]interface{})
+	if labels := spec.GetLabels(); len(labels) > 0 {
+		metadata["labels"] = stringMapToJSON(labels)
+	}
+	if annotations := spec.GetAnnotations(); len(annotations) > 0 {
+		metadata["annotations"] = stringMapToJSON(annotations)
+	}
+	if specMeta, ok := spec.Object["metadata"].(map[string]interface{}); ok && specMeta["ownerReferences"] != nil {
+		metadata["ownerReferences"] = runtime.DeepCopyJSONValue(specMeta["ownerReferences"])
+	}
+	if specFinalizers := spec.GetFinalizers(); len(specFinalizers) > 0 {
+		// JSON merge patch replaces lists so all finalizers are sent
+		finalizers := sets.NewString(actual.GetFinalizers()...)
+		finalizers.Insert(specFinalizers...)
+		list := finalizers.List()
+		value := make([]interface{}, 0, len(list))
+		for _, finalizer := range list {
+			value = append(value, finalizer)
+		}
+		metadata["finalizers"] = value
+	}
+	patch["metadata"] = metadata
+	return patch
+}
+
+func stringMapToJSON(m map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// prunePatchValue removes null and empty values from maps, e.g. creationTimestamp of typed objects.
+// Patches treat null as a request to remove the field and fields that are not set in the spec must be left as they are.
+func prunePatchValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			fieldValue = prunePatchValue(fieldValue)
+			if isEmptyPatchValue(fieldValue) {
+				delete(v, field)
+				continue
+			}
+			v[field] = fieldValue
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = prunePatchValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isEmptyPatchValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// objectPatchType returns the type of patch to use for objects of the kind. Built-in kinds have strategic merge
+// metadata so that e.g. lists of containers are merged by their names. Other kinds, e.g. custom resources,
+// do not support strategic merge patches and are patched with a JSON merge patch.
+func objectPatchType(gvk schema.GroupVersionKind) types.PatchType {
+	if scheme.Scheme.Recognizes(gvk) {
+		return types.StrategicMergePatchType
+	}
+	return types.MergePatchType
+}
+
+// applyObjectPatch returns a copy of the object with the patch applied.
+func applyObjectPatch(obj *unstructured.Unstructured, patchType types.PatchType, patch map[string]interface{}) (*unstructured.Unstructured, error) {
+	original := obj.DeepCopy().Object
+	switch patchType {
+	case types.StrategicMergePatchType:
+		dataStruct, err := scheme.Scheme.New(obj.GroupVersionKind())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get type of %s for strategic merge patch", obj.GroupVersionKind())
+		}
+		patched, err := strategicpatch.StrategicMergeMapPatch(original, runtime.DeepCopyJSON(patch), dataStruct)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to apply strategic merge patch")
+		}
+		return &unstructured.Unstructured{Object: patched}, nil
+	case types.MergePatchType:
+		return &unstructured.Unstructured{Object: applyMergePatch(original, runtime.DeepCopyJSON(patch))}, nil
+	default:
+		return nil, errors.Errorf("unsupported patch type %q", patchType)
+	}
+}
+
+// applyMergePatch applies a JSON merge patch (RFC 7386) to the original object. Mutates the original.
+func applyMergePatch(original, patch map[string]interface{}) map[string]interface{} {
+	for field, patchValue := range patch {
+		if patchValue == nil {
+			delete(original, field)
+			continue
+		}
+		patchMap, ok := patchValue.(map[string]interface{})
+		if !ok {
+			original[field] = patchValue
+			continue
+		}
+		originalMap, ok := original[field].(map[string]interface{})
+		if !ok {
+			originalMap = make(map[string]interface{}, len(patchMap))
+		}
+		original[field] = applyMergePatch(originalMap, patchMap)
+	}
+	return original
+}
@@ -0,0 +1,242 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestObjectPatchStrategicMerge(t *testing.T) {
+	t.Parallel()
+	revisionHistoryLimit := int32(5)
+	actual := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              "deployment1",
+			Namespace:         "ns",
+			Labels:            map[string]string{"other": "label"},
+			CreationTimestamp: meta_v1.Now(),
+		},
+		Spec: apps_v1.DeploymentSpec{
+			RevisionHistoryLimit: &revisionHistoryLimit,
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: []core_v1.Container{
+						{
+							Name:            "app",
+							Image:           "app:1",
+							ImagePullPolicy: core_v1.PullIfNotPresent,
+						},
+						{
+							Name:  "sidecar",
+							Image: "sidecar",
+						},
+					},
+				},
+			},
+		},
+	}
+	spec, err := util.RuntimeToUnstructured(&apps_v1.Deployment{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: apps_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   "deployment1",
+			Labels: map[string]string{"app": "app"},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: []core_v1.Container{
+						{
+							Name:  "app",
+							Image: "app:2",
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	patch, patchType, patched, err := objectPatch(spec, actual)
+	require.NoError(t, err)
+	require.NotNil(t, patch)
+	assert.Equal(t, types.StrategicMergePatchType, patchType)
+
+	var deployment apps_v1.Deployment
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(patched.Object, &deployment))
+	assert.Equal(t, map[string]string{"app": "app", "other": "label"}, deployment.Labels)
+	assert.Equal(t, actual.CreationTimestamp.Unix(), deployment.CreationTimestamp.Unix())
+	assert.Equal(t, &revisionHistoryLimit, deployment.Spec.RevisionHistoryLimit)
+	// Containers are merged by their names
+	assert.Equal(t, []core_v1.Container{
+		{
+			Name:            "app",
+			Image:           "app:2",
+			ImagePullPolicy: core_v1.PullIfNotPresent,
+		},
+		{
+			Name:  "sidecar",
+			Image: "sidecar",
+		},
+	}, deployment.Spec.Template.Spec.Containers)
+
+	// Patched object does not need to be patched again
+	patch, _, _, err = objectPatch(spec, patched)
+	require.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+func TestObjectPatchJSONMerge(t *testing.T) {
+	t.Parallel()
+	actual := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"name":      "widget1",
+				"namespace": "ns",
+			},
+			"spec": map[string]interface{}{
+				"size": int64(1),
+				"generated": map[string]interface{}{
+					"by": "someone else",
+				},
+			},
+		},
+	}
+	spec := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"name": "widget1",
+			},
+			"spec": map[string]interface{}{
+				"size": int64(2),
+			},
+		},
+	}
+
+	patch, patchType, patched, err := objectPatch(spec, actual)
+	require.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, patchType)
+	assert.Equal(t, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"size": int64(2),
+		},
+	}, patch)
+	assert.Equal(t, map[string]interface{}{
+		"size": int64(2),
+		"generated": map[string]interface{}{
+			"by": "someone else",
+		},
+	}, patched.Object["spec"])
+
+	// Fields that are only set on the object do not make it different from the spec
+	patch, _, _, err = objectPatch(spec, patched)
+	require.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+func TestPatchUpdateStrategy(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:           "widget",
+					UpdateStrategy: smith_v1.UpdateStrategyPatch,
+					Spec: smith_v1.ResourceSpec{
+						Object: &unstructured.Unstructured{
+							Object: map[string]interface{}{
+								"apiVersion": "example.com/v1",
+								"kind":       "Widget",
+								"metadata": map[string]interface{}{
+									"name": "widget1",
+								},
+								"spec": map[string]interface{}{
+									"size": int64(2),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	existing := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"name":      "widget1",
+				"namespace": "ns",
+				"uid":       "widget1-uid",
+			},
+			"spec": map[string]interface{}{
+				"size": int64(1),
+				"generated": map[string]interface{}{
+					"by": "someone else",
+				},
+			},
+		},
+	}
+	existing.SetOwnerReferences([]meta_v1.OwnerReference{
+		{
+			APIVersion:         smith_v1.BundleResourceGroupVersion,
+			Kind:               smith_v1.BundleResourceKind,
+			Name:               "bundle1",
+			UID:                "bundle1-uid",
+			Controller:         &tr,
+			BlockOwnerDeletion: &tr,
+		},
+	})
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Updated, 1)
+	assert.Equal(t, map[string]interface{}{
+		"size": int64(2),
+		"generated": map[string]interface{}{
+			"by": "someone else",
+		},
+	}, result.Updated[0].Object["spec"])
+
+	// Patched object is not patched again
+	result, err = s.Simulate(bundle, []runtime.Object{result.Updated[0]})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Updated)
+}
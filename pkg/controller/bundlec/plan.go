@@ -0,0 +1,138 @@
+package bundlec
+
+import (
+	"encoding/json"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	PlanOperationCreate = "create"
+	PlanOperationUpdate = "update"
+	PlanOperationDelete = "delete"
+
+	redactedValue = "<redacted>"
+)
+
+// Plan is a machine-readable description of operations a reconcile iteration of a Bundle would perform.
+type Plan struct {
+	Operations []PlanOperation `json:"operations"`
+	// Error is the error the iteration would finish with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// PlanOperation is a create, update or delete operation on an object.
+type PlanOperation struct {
+	Operation string `json:"operation"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	// Patch is a JSON merge patch from the existing object to the updated object. Only set for updates.
+	// Values of Secrets are redacted.
+	Patch map[string]interface{} `json:"patch,omitempty"`
+}
+
+// Plan returns JSON representation of operations a reconcile iteration of the Bundle would perform.
+// Operations are determined by DryRun so nothing is applied to the cluster.
+func (c *Controller) Plan(logger *zap.Logger, bundle *smith_v1.Bundle) ([]byte, error) {
+	result := c.DryRun(logger, bundle)
+	plan, err := buildPlan(c.Store, bundle.Namespace, result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(plan)
+}
+
+// Plan returns operations a reconcile iteration of the Bundle would perform if the objects were the only
+// objects in the cluster. Unlike Controller.Plan it does not need a running controller, so it can be used to
+// preview what applying a Bundle would change, e.g. from a CLI or an admission webhook.
+// Returned error indicates invalid input; the error the iteration would finish with is reported in the plan.
+func (s *Simulator) Plan(bundle *smith_v1.Bundle, objects []runtime.Object) (*Plan, error) {
+	store, err := newSimulationStore(objects)
+	if err != nil {
+		return nil, err
+	}
+	result := s.simulate(store, bundle)
+	return buildPlan(store, bundle.Namespace, result)
+}
+
+// buildPlan converts the result of a reconcile iteration into a Plan. Updated objects are compared
+// with existing objects from the store.
+func buildPlan(store Store, namespace string, result *ReconcileResult) (*Plan, error) {
+	plan := &Plan{
+		Operations: make([]PlanOperation, 0, len(result.Created)+len(result.Updated)+len(result.Deleted)),
+	}
+	for _, obj := range result.Created {
+		plan.Operations = append(plan.Operations, planOperation(PlanOperationCreate, obj))
+	}
+	for _, obj := range result.Updated {
+		op := planOperation(PlanOperationUpdate, obj)
+		gvk := obj.GroupVersionKind()
+		actual, exists, err := store.Get(gvk, namespace, obj.GetName())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s %q", gvk.Kind, obj.GetName())
+		}
+		actualUnstr := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if exists {
+			actualUnstr, err = util.RuntimeToUnstructured(actual)
+			if err != nil {
+				return nil, err
+			}
+		}
+		op.Patch = speccheck.MergePatch(obj, actualUnstr)
+		if gvk.GroupKind() == core_v1.SchemeGroupVersion.WithKind("Secret").GroupKind() {
+			redactSecretPatch(op.Patch)
+		}
+		plan.Operations = append(plan.Operations, op)
+	}
+	for _, obj := range result.Deleted {
+		plan.Operations = append(plan.Operations, PlanOperation{
+			Operation: PlanOperationDelete,
+			Group:     obj.Group,
+			Version:   obj.Version,
+			Kind:      obj.Kind,
+			Name:      obj.Name,
+		})
+	}
+	if result.Error != nil {
+		plan.Error = result.Error.Error()
+	}
+	return plan, nil
+}
+
+func planOperation(operation string, obj *unstructured.Unstructured) PlanOperation {
+	gvk := obj.GroupVersionKind()
+	return PlanOperation{
+		Operation: operation,
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Name:      obj.GetName(),
+	}
+}
+
+// redactSecretPatch replaces values of Secret data in the patch. Removed keys are kept as null.
+func redactSecretPatch(patch map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := patch[field].(map[string]interface{})
+		if !ok {
+			if patch[field] != nil {
+				patch[field] = redactedValue
+			}
+			continue
+		}
+		for key, value := range data {
+			if value != nil {
+				data[key] = redactedValue
+			}
+		}
+	}
+}
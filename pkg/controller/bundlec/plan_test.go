@@ -0,0 +1,277 @@
+package bundlec
+
+import (
+	"encoding/json"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	owner := []meta_v1.OwnerReference{
+		{
+			APIVersion:         smith_v1.BundleResourceGroupVersion,
+			Kind:               smith_v1.BundleResourceKind,
+			Name:               "bundle1",
+			UID:                "bundle1-uid",
+			Controller:         &tr,
+			BlockOwnerDeletion: &tr,
+		},
+	}
+	existingConfigMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm1",
+			Namespace:       "ns",
+			UID:             "cm1-uid",
+			OwnerReferences: owner,
+		},
+		Data: map[string]string{
+			"a": "b",
+			"c": "d",
+		},
+	}
+	existingSecret := &core_v1.Secret{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "s1",
+			Namespace:       "ns",
+			UID:             "s1-uid",
+			OwnerReferences: owner,
+		},
+		StringData: map[string]string{
+			"password": "old",
+		},
+	}
+	store, err := newSimulationStore([]runtime.Object{existingConfigMap, existingSecret})
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config1",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"a": "e",
+							},
+						},
+					},
+				},
+				{
+					Name: "config2",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm2",
+							},
+						},
+					},
+				},
+				{
+					Name: "secret1",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.Secret{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Secret",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "s1",
+							},
+							StringData: map[string]string{
+								"password": "new",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	c := &Controller{
+		SmartClient: readOnlySmartClient{t: t},
+		Rc:          configMapsReadyChecker{},
+		Store:       store,
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		MaxConcurrentResources: 1,
+	}
+
+	data, err := c.Plan(logger, bundle)
+	require.NoError(t, err)
+	var plan Plan
+	require.NoError(t, json.Unmarshal(data, &plan))
+	assert.Empty(t, plan.Error)
+	assert.Equal(t, []PlanOperation{
+		{
+			Operation: PlanOperationCreate,
+			Version:   "v1",
+			Kind:      "ConfigMap",
+			Name:      "cm2",
+		},
+		{
+			Operation: PlanOperationUpdate,
+			Version:   "v1",
+			Kind:      "ConfigMap",
+			Name:      "cm1",
+			Patch: map[string]interface{}{
+				"data": map[string]interface{}{
+					"a": "e",
+					"c": nil,
+				},
+			},
+		},
+		{
+			Operation: PlanOperationUpdate,
+			Version:   "v1",
+			Kind:      "Secret",
+			Name:      "s1",
+			Patch: map[string]interface{}{
+				"stringData": map[string]interface{}{
+					"password": redactedValue,
+				},
+			},
+		},
+	}, plan.Operations)
+}
+
+func TestSimulatorPlan(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	owner := []meta_v1.OwnerReference{
+		{
+			APIVersion:         smith_v1.BundleResourceGroupVersion,
+			Kind:               smith_v1.BundleResourceKind,
+			Name:               "bundle1",
+			UID:                "bundle1-uid",
+			Controller:         &tr,
+			BlockOwnerDeletion: &tr,
+		},
+	}
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm1",
+			Namespace:       "ns",
+			UID:             "cm1-uid",
+			OwnerReferences: owner,
+		},
+		Data: map[string]string{
+			"a": "b",
+		},
+	}
+	removed := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cm2",
+			Namespace:       "ns",
+			UID:             "cm2-uid",
+			OwnerReferences: owner,
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config1",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"a": "c",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	plan, err := s.Plan(bundle, []runtime.Object{existing, removed})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Error)
+	assert.Equal(t, []PlanOperation{
+		{
+			Operation: PlanOperationUpdate,
+			Version:   "v1",
+			Kind:      "ConfigMap",
+			Name:      "cm1",
+			Patch: map[string]interface{}{
+				"data": map[string]interface{}{
+					"a": "c",
+				},
+			},
+		},
+		{
+			Operation: PlanOperationDelete,
+			Version:   "v1",
+			Kind:      "ConfigMap",
+			Name:      "cm2",
+		},
+	}, plan.Operations)
+}
@@ -0,0 +1,104 @@
+package bundlec
+
+import (
+	"strings"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// expandReferences returns resources with references expanded. References with selectors are replaced with references
+// to the selected resources and resources consumed by plugins are added as references.
+// Resources without such references are returned as is, other resources are copied.
+func expandReferences(resources []smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.PluginContainer) ([]smith_v1.Resource, error) {
+	resources, err := expandReferenceSelectors(resources)
+	if err != nil {
+		return nil, err
+	}
+	return addPluginInputReferences(resources, pluginContainers)
+}
+
+// addPluginInputReferences adds nameless references to resources consumed by plugins, as declared by
+// InputSpecFields of their descriptions. Resources that are referenced already are not added again.
+// Resources that refer to unknown plugins are returned as is, such resources fail when they are processed.
+func addPluginInputReferences(resources []smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.PluginContainer) ([]smith_v1.Resource, error) {
+	var declared map[smith_v1.ResourceName]struct{}
+	var expanded []smith_v1.Resource
+	for i, res := range resources {
+		var inputs []smith_v1.ResourceName
+		if res.Spec.Plugin != nil {
+			if pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]; ok {
+				var err error
+				inputs, err = pluginInputs(&res, pluginContainer.Plugin.Describe())
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(inputs) == 0 {
+			if expanded != nil {
+				expanded = append(expanded, res)
+			}
+			continue
+		}
+		if declared == nil {
+			declared = make(map[smith_v1.ResourceName]struct{}, len(resources))
+			for _, r := range resources {
+				declared[r.Name] = struct{}{}
+			}
+		}
+		if expanded == nil {
+			expanded = make([]smith_v1.Resource, 0, len(resources))
+			expanded = append(expanded, resources[:i]...)
+		}
+		res = *res.DeepCopy()
+	nextInput:
+		for _, input := range inputs {
+			if _, ok := declared[input]; !ok {
+				return nil, withErrorCode(smith_v1.ErrorCodeUnknownDependency, errors.Errorf("resource %q consumes unknown resource %q", res.Name, input))
+			}
+			for _, reference := range res.References {
+				if reference.Object == nil && reference.Resource == input {
+					continue nextInput
+				}
+			}
+			res.References = append(res.References, smith_v1.Reference{
+				Resource: input,
+			})
+		}
+		expanded = append(expanded, res)
+	}
+	if expanded == nil {
+		return resources, nil
+	}
+	return expanded, nil
+}
+
+// pluginInputs returns names of resources that the plugin spec of the resource names in input fields of the plugin.
+// Input fields that are not set are ignored.
+func pluginInputs(res *smith_v1.Resource, description *plugin.Description) ([]smith_v1.ResourceName, error) {
+	var inputs []smith_v1.ResourceName
+	for _, field := range description.InputSpecFields {
+		value, ok, err := unstructured.NestedFieldNoCopy(res.Spec.Plugin.Spec, strings.Split(field, ".")...)
+		if err != nil || !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			inputs = append(inputs, smith_v1.ResourceName(v))
+		case []interface{}:
+			for _, item := range v {
+				name, ok := item.(string)
+				if !ok {
+					return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Errorf("resource %q has plugin spec field %q that is expected to be a list of resource names but has an item of type %T", res.Name, field, item))
+				}
+				inputs = append(inputs, smith_v1.ResourceName(name))
+			}
+		default:
+			return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Errorf("resource %q has plugin spec field %q that is expected to be a resource name or a list of resource names but is of type %T", res.Name, field, value))
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,170 @@
+package bundlec
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// consumingPlugin consumes resources named in its spec and records names of dependencies it was given.
+type consumingPlugin struct{}
+
+func (consumingPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name:            "consuming",
+		GVK:             core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		InputSpecFields: []string{"input", "more.inputs"},
+	}
+}
+
+func (consumingPlugin) Process(spec map[string]interface{}, context *plugin.Context) (*plugin.ProcessResult, error) {
+	dependencies := make([]string, 0, len(context.Dependencies))
+	for resName := range context.Dependencies {
+		dependencies = append(dependencies, string(resName))
+	}
+	sort.Strings(dependencies)
+	return &plugin.ProcessResult{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			Data: map[string]string{
+				"dependencies": strings.Join(dependencies, ","),
+			},
+		},
+	}, nil
+}
+
+func consumingPluginContainers(t *testing.T) map[smith_v1.PluginName]plugin.PluginContainer {
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return consumingPlugin{}, nil
+	})
+	require.NoError(t, err)
+	return map[smith_v1.PluginName]plugin.PluginContainer{
+		"consuming": pluginContainer,
+	}
+}
+
+func consumedConfigMap(name string) smith_v1.Resource {
+	return smith_v1.Resource{
+		Name: smith_v1.ResourceName(name),
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: name,
+				},
+			},
+		},
+	}
+}
+
+func TestPluginInputs(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "consumer",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "consuming",
+							ObjectName: "consumer",
+							Spec: map[string]interface{}{
+								"input": "input1",
+								"more": map[string]interface{}{
+									"inputs": []interface{}{"input2", "input1"},
+								},
+							},
+						},
+					},
+				},
+				consumedConfigMap("input1"),
+				consumedConfigMap("input2"),
+				consumedConfigMap("unrelated"),
+			},
+		},
+	}
+	pluginContainers := consumingPluginContainers(t)
+
+	// Inputs are processed before the consumer although the consumer is listed first
+	_, sorted, err := sortBundle(bundle, pluginContainers)
+	require.NoError(t, err)
+	order := make(map[smith_v1.ResourceName]int, len(sorted))
+	for i, v := range sorted {
+		order[v.(smith_v1.ResourceName)] = i
+	}
+	assert.True(t, order["input1"] < order["consumer"])
+	assert.True(t, order["input2"] < order["consumer"])
+
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: pluginContainers,
+	}
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	var consumer *unstructured.Unstructured
+	for _, obj := range result.Created {
+		if obj.GetName() == "consumer" {
+			consumer = obj
+		}
+	}
+	require.NotNil(t, consumer)
+	dependencies, _, err := unstructured.NestedString(consumer.Object, "data", "dependencies")
+	require.NoError(t, err)
+	assert.Equal(t, "input1,input2", dependencies)
+}
+
+func TestPluginInputUnknownResource(t *testing.T) {
+	t.Parallel()
+	bundle := &smith_v1.Bundle{
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "consumer",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "consuming",
+							ObjectName: "consumer",
+							Spec: map[string]interface{}{
+								"input": "missing",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, _, err := sortBundle(bundle, consumingPluginContainers(t))
+	require.EqualError(t, err, `resource "consumer" consumes unknown resource "missing"`)
+	assert.Equal(t, smith_v1.ErrorCodeUnknownDependency, errorCode(err))
+}
@@ -0,0 +1,71 @@
+package bundlec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+)
+
+// pluginTimeoutError occurs when a plugin does not finish processing within its timeout.
+type pluginTimeoutError struct {
+	plugin  smith_v1.PluginName
+	timeout time.Duration
+}
+
+func (e *pluginTimeoutError) Error() string {
+	return fmt.Sprintf("plugin %q did not finish within %s", e.plugin, e.timeout)
+}
+
+func isPluginTimeoutError(err error) bool {
+	_, ok := errors.Cause(err).(*pluginTimeoutError)
+	return ok
+}
+
+// pluginOutcome is what a plugin returned or the value it panicked with.
+type pluginOutcome struct {
+	result   *plugin.ProcessResult
+	err      error
+	panicked interface{}
+}
+
+// processPlugin invokes the plugin with a context that is cancelled once the timeout expires or ctx is done.
+// The plugin is not waited for after that, it is expected to notice the cancellation and return.
+// Zero timeout means no timeout. A panic in the plugin is propagated to the caller.
+func processPlugin(ctx context.Context, timeout time.Duration, p plugin.Plugin, spec map[string]interface{}, pluginCtx *plugin.Context) (*plugin.ProcessResult, error) {
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	pluginCtx.Context = ctx
+	done := make(chan pluginOutcome, 1) // Buffered so that a plugin that finishes late does not leak
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- pluginOutcome{panicked: r}
+			}
+		}()
+		result, err := p.Process(spec, pluginCtx)
+		done <- pluginOutcome{result: result, err: err}
+	}()
+	select {
+	case outcome := <-done:
+		if outcome.panicked != nil {
+			panic(outcome.panicked)
+		}
+		return outcome.result, outcome.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.WithStack(&pluginTimeoutError{
+			plugin:  p.Describe().Name,
+			timeout: timeout,
+		})
+	}
+}
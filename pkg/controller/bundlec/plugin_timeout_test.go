@@ -0,0 +1,54 @@
+package bundlec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// blockingPlugin blocks until its context is done.
+type blockingPlugin struct {
+}
+
+func (blockingPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: "blocking",
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (blockingPlugin) Process(spec map[string]interface{}, pluginCtx *plugin.Context) (*plugin.ProcessResult, error) {
+	<-pluginCtx.Context.Done()
+	return nil, pluginCtx.Context.Err()
+}
+
+func TestPluginTimeoutIsRetriable(t *testing.T) {
+	t.Parallel()
+
+	_, err := processPlugin(context.Background(), 10*time.Millisecond, blockingPlugin{}, nil, &plugin.Context{})
+	require.Error(t, err)
+	assert.True(t, isPluginTimeoutError(err))
+	assert.EqualError(t, err, `plugin "blocking" did not finish within 10ms`)
+}
+
+func TestPluginIsCancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := processPlugin(ctx, time.Minute, blockingPlugin{}, nil, &plugin.Context{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestPluginWithinTimeout(t *testing.T) {
+	t.Parallel()
+
+	result, err := processPlugin(context.Background(), time.Minute, notReadyPlugin{}, nil, &plugin.Context{})
+	require.NoError(t, err)
+	assert.NotNil(t, result.Object)
+}
@@ -0,0 +1,85 @@
+package bundlec
+
+import (
+	"sync"
+
+	"github.com/atlassian/ctrl"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// queueDepthTracker tracks keys of Bundles that have been added to the work queue but have not been
+// processed yet. Work queue deduplicates keys that are waiting to be processed, so does the tracker.
+// A key added while its Bundle is being processed is counted again because the Bundle will be processed again.
+type queueDepthTracker struct {
+	queue ctrl.WorkQueueProducer
+
+	mx      sync.Mutex
+	pending map[ctrl.QueueKey]struct{}
+}
+
+func newQueueDepthTracker(queue ctrl.WorkQueueProducer) *queueDepthTracker {
+	return &queueDepthTracker{
+		queue:   queue,
+		pending: make(map[ctrl.QueueKey]struct{}),
+	}
+}
+
+func (t *queueDepthTracker) Add(key ctrl.QueueKey) {
+	t.added(key)
+	t.queue.Add(key)
+}
+
+// added records a key that has been added to the work queue.
+func (t *queueDepthTracker) added(key ctrl.QueueKey) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.pending[key] = struct{}{}
+}
+
+// removed records a key that has been taken from the work queue for processing or that is not going to be
+// processed because its Bundle is gone.
+func (t *queueDepthTracker) removed(key ctrl.QueueKey) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.pending, key)
+}
+
+func (t *queueDepthTracker) depth() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return len(t.pending)
+}
+
+// queueDepthHandler records Bundles enqueued by the generic controller on changes to Bundles.
+// Generic controller adds them to the work queue directly rather than through the tracker.
+type queueDepthHandler struct {
+	tracker *queueDepthTracker
+}
+
+func (h *queueDepthHandler) OnAdd(obj interface{}) {
+	h.tracker.added(bundleQueueKey(obj.(*smith_v1.Bundle)))
+}
+
+func (h *queueDepthHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.tracker.added(bundleQueueKey(newObj.(*smith_v1.Bundle)))
+}
+
+func (h *queueDepthHandler) OnDelete(obj interface{}) {
+	// Deleted Bundles are not processed
+	switch o := obj.(type) {
+	case *smith_v1.Bundle:
+		h.tracker.removed(bundleQueueKey(o))
+	case cache.DeletedFinalStateUnknown:
+		if bundle, ok := o.Obj.(*smith_v1.Bundle); ok {
+			h.tracker.removed(bundleQueueKey(bundle))
+		}
+	}
+}
+
+func bundleQueueKey(bundle *smith_v1.Bundle) ctrl.QueueKey {
+	return ctrl.QueueKey{
+		Namespace: bundle.Namespace,
+		Name:      bundle.Name,
+	}
+}
@@ -0,0 +1,42 @@
+package bundlec
+
+import (
+	"testing"
+
+	"github.com/atlassian/ctrl"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestQueueDepthTracker(t *testing.T) {
+	t.Parallel()
+	queue := make(chanWorkQueue, 10)
+	tracker := newQueueDepthTracker(queue)
+	handler := &queueDepthHandler{tracker: tracker}
+	key1 := ctrl.QueueKey{Namespace: "ns", Name: "bundle1"}
+	key2 := ctrl.QueueKey{Namespace: "ns", Name: "bundle2"}
+	bundle3 := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "bundle3",
+		},
+	}
+
+	tracker.Add(key1)
+	tracker.Add(key1)
+	tracker.Add(key2)
+	assert.Len(t, queue, 3) // all adds are passed through
+	assert.Equal(t, 2, tracker.depth())
+
+	handler.OnAdd(bundle3)
+	handler.OnUpdate(bundle3, bundle3)
+	assert.Equal(t, 3, tracker.depth())
+
+	tracker.removed(key1)
+	assert.Equal(t, 2, tracker.depth())
+
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Obj: bundle3})
+	assert.Equal(t, 1, tracker.depth())
+}
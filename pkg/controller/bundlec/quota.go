@@ -0,0 +1,192 @@
+package bundlec
+
+import (
+	"sort"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// quotaWorkload has the fields of workload objects that their pods are derived from.
+type quotaWorkload struct {
+	Spec struct {
+		Replicas    *int32                  `json:"replicas"`
+		Parallelism *int32                  `json:"parallelism"`
+		Template    core_v1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+// checkQuota checks that pods of workload objects which do not exist yet fit into ResourceQuotas of the namespace.
+// Usage of existing objects is already accounted for by the quotas. Quotas with scopes are not checked. Objects that
+// cannot be inspected, e.g. because their fields contain references, are not accounted for, so that only Bundles that
+// clearly do not fit fail.
+func (st *bundleSyncTask) checkQuota() error {
+	if !st.bundle.Spec.QuotaCheck {
+		return nil
+	}
+	if st.quotaLister == nil {
+		st.logger.Info("Quota check is requested but ResourceQuotas are not watched, skipping")
+		return nil
+	}
+	quotas, err := st.quotaLister.ResourceQuotas(st.bundle.Namespace).List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "failed to list ResourceQuotas")
+	}
+	if len(quotas) == 0 {
+		return nil
+	}
+	demand := st.quotaDemand()
+	// Report the same quota every time if several are exceeded
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].Name < quotas[j].Name
+	})
+	for _, quota := range quotas {
+		if len(quota.Spec.Scopes) > 0 {
+			continue
+		}
+		hard := quota.Status.Hard
+		if hard == nil {
+			// Status has not been populated by the quota controller yet
+			hard = quota.Spec.Hard
+		}
+		names := make([]string, 0, len(hard))
+		for name := range hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			required, ok := demand[core_v1.ResourceName(name)]
+			if !ok {
+				continue
+			}
+			limit := hard[core_v1.ResourceName(name)]
+			used := quota.Status.Used[core_v1.ResourceName(name)]
+			available := limit.MilliValue() - used.MilliValue()
+			if required > available {
+				return withErrorCode(smith_v1.ErrorCodeQuotaExceeded, errors.Errorf(
+					"objects to be created require %s of %s but only %s is available in ResourceQuota %q",
+					resource.NewMilliQuantity(required, limit.Format), name,
+					resource.NewMilliQuantity(available, limit.Format), quota.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// quotaDemand returns the amounts of quota resources, in thousandths, that pods of workload objects which
+// do not exist yet require.
+func (st *bundleSyncTask) quotaDemand() map[core_v1.ResourceName]int64 {
+	demand := make(map[core_v1.ResourceName]int64)
+	for _, res := range st.bundle.Spec.Resources {
+		if res.Disabled || res.Spec.Object == nil {
+			continue
+		}
+		obj, err := util.RuntimeToUnstructured(res.Spec.Object)
+		if err != nil {
+			// Reported when the resource is processed
+			continue
+		}
+		pods, podSpec := workloadPods(obj)
+		if podSpec == nil || pods <= 0 {
+			continue
+		}
+		_, exists, err := st.store.Get(obj.GroupVersionKind(), st.bundle.Namespace, obj.GetName())
+		if err != nil || exists {
+			continue
+		}
+		requests, limits := podResources(podSpec)
+		for name, value := range requests {
+			demand[core_v1.ResourceName("requests."+string(name))] += pods * value
+			switch name {
+			case core_v1.ResourceCPU, core_v1.ResourceMemory, core_v1.ResourceEphemeralStorage:
+				// Quotas limit requests of these resources under their plain names too
+				demand[name] += pods * value
+			}
+		}
+		for name, value := range limits {
+			demand[core_v1.ResourceName("limits."+string(name))] += pods * value
+		}
+		demand[core_v1.ResourcePods] += pods * 1000
+	}
+	return demand
+}
+
+// workloadPods returns the number of pods of a workload object and their spec.
+// Nil spec is returned if the object is not a workload or the number of its pods is not known upfront.
+func workloadPods(obj *unstructured.Unstructured) (int64, *core_v1.PodSpec) {
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Group: "", Kind: "Pod"}:
+		var pod core_v1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+			return 0, nil
+		}
+		return 1, &pod.Spec
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		schema.GroupKind{Group: "extensions", Kind: "Deployment"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"},
+		schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "extensions", Kind: "ReplicaSet"}:
+		var workload quotaWorkload
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &workload); err != nil {
+			return 0, nil
+		}
+		if workload.Spec.Replicas == nil {
+			return 1, &workload.Spec.Template.Spec
+		}
+		return int64(*workload.Spec.Replicas), &workload.Spec.Template.Spec
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		var workload quotaWorkload
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &workload); err != nil {
+			return 0, nil
+		}
+		if workload.Spec.Parallelism == nil {
+			return 1, &workload.Spec.Template.Spec
+		}
+		return int64(*workload.Spec.Parallelism), &workload.Spec.Template.Spec
+	default:
+		// DaemonSets have as many pods as there are matching nodes
+		return 0, nil
+	}
+}
+
+// podResources returns requests and limits of a pod, in thousandths. Init containers run one by one
+// before other containers, so the pod requires the maximum of what any of them and all other containers
+// together require. Limits are used as requests if the latter are not set, as the API server does.
+func podResources(spec *core_v1.PodSpec) (requests, limits map[core_v1.ResourceName]int64) {
+	requests = make(map[core_v1.ResourceName]int64)
+	limits = make(map[core_v1.ResourceName]int64)
+	for _, container := range spec.Containers {
+		for name, value := range container.Resources.Limits {
+			limits[name] += value.MilliValue()
+			if _, ok := container.Resources.Requests[name]; !ok {
+				requests[name] += value.MilliValue()
+			}
+		}
+		for name, value := range container.Resources.Requests {
+			requests[name] += value.MilliValue()
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for name, value := range container.Resources.Limits {
+			if value.MilliValue() > limits[name] {
+				limits[name] = value.MilliValue()
+			}
+			if _, ok := container.Resources.Requests[name]; !ok && value.MilliValue() > requests[name] {
+				requests[name] = value.MilliValue()
+			}
+		}
+		for name, value := range container.Resources.Requests {
+			if value.MilliValue() > requests[name] {
+				requests[name] = value.MilliValue()
+			}
+		}
+	}
+	return requests, limits
+}
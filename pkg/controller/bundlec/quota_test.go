@@ -0,0 +1,186 @@
+package bundlec
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	core_v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestQuotaCheck(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		quotaCheck bool
+		replicas   int32
+		exceeded   bool
+	}{
+		{
+			name:       "fits",
+			quotaCheck: true,
+			replicas:   3,
+		},
+		{
+			name:       "exceeded",
+			quotaCheck: true,
+			replicas:   4,
+			exceeded:   true,
+		},
+		{
+			name:     "not requested",
+			replicas: 4,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			logger := zaptest.NewLogger(t)
+			defer logger.Sync()
+
+			replicas := tc.replicas
+			bundle := &smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "bundle1",
+					Namespace: "ns",
+					UID:       "bundle1-uid",
+				},
+				Spec: smith_v1.BundleSpec{
+					Resources: []smith_v1.Resource{
+						{
+							Name: "deployment",
+							Spec: smith_v1.ResourceSpec{
+								Object: &apps_v1.Deployment{
+									TypeMeta: meta_v1.TypeMeta{
+										Kind:       "Deployment",
+										APIVersion: apps_v1.SchemeGroupVersion.String(),
+									},
+									ObjectMeta: meta_v1.ObjectMeta{
+										Name: "deployment1",
+									},
+									Spec: apps_v1.DeploymentSpec{
+										Replicas: &replicas,
+										Template: core_v1.PodTemplateSpec{
+											Spec: core_v1.PodSpec{
+												Containers: []core_v1.Container{
+													{
+														Name:  "app",
+														Image: "app",
+														Resources: core_v1.ResourceRequirements{
+															Requests: core_v1.ResourceList{
+																core_v1.ResourceCPU: resource.MustParse("500m"),
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					QuotaCheck: tc.quotaCheck,
+				},
+			}
+			quotas := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+				cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			})
+			require.NoError(t, quotas.Add(&core_v1.ResourceQuota{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "compute",
+					Namespace: "ns",
+				},
+				Spec: core_v1.ResourceQuotaSpec{
+					Hard: core_v1.ResourceList{
+						core_v1.ResourceRequestsCPU: resource.MustParse("2"),
+					},
+				},
+				Status: core_v1.ResourceQuotaStatus{
+					Hard: core_v1.ResourceList{
+						core_v1.ResourceRequestsCPU: resource.MustParse("2"),
+					},
+					Used: core_v1.ResourceList{
+						core_v1.ResourceRequestsCPU: resource.MustParse("500m"),
+					},
+				},
+			}))
+			s := Simulator{
+				Logger: logger,
+				Rc:     configMapsReadyChecker{},
+				SpecCheck: &speccheck.SpecCheck{
+					Logger:  logger,
+					Cleaner: cleanup.New(),
+				},
+				ResourceQuotaLister: core_v1listers.NewResourceQuotaLister(quotas),
+			}
+
+			result, err := s.Simulate(bundle, nil)
+			require.NoError(t, err)
+			if !tc.exceeded {
+				assert.Len(t, result.Created, 1)
+				return
+			}
+			require.Error(t, result.Error)
+			assert.Equal(t, smith_v1.ErrorCodeQuotaExceeded, errorCode(result.Error))
+			assert.EqualError(t, result.Error, `objects to be created require 2 of requests.cpu but only 1500m is available in ResourceQuota "compute"`)
+			assert.False(t, result.Retriable)
+			assert.Empty(t, result.Created)
+		})
+	}
+}
+
+func TestPodResources(t *testing.T) {
+	t.Parallel()
+	requests, limits := podResources(&core_v1.PodSpec{
+		InitContainers: []core_v1.Container{
+			{
+				Resources: core_v1.ResourceRequirements{
+					Requests: core_v1.ResourceList{
+						core_v1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			},
+		},
+		Containers: []core_v1.Container{
+			{
+				Resources: core_v1.ResourceRequirements{
+					Requests: core_v1.ResourceList{
+						core_v1.ResourceCPU:    resource.MustParse("100m"),
+						core_v1.ResourceMemory: resource.MustParse("256Mi"),
+					},
+					Limits: core_v1.ResourceList{
+						core_v1.ResourceCPU: resource.MustParse("200m"),
+					},
+				},
+			},
+			{
+				Resources: core_v1.ResourceRequirements{
+					// Requests default to limits
+					Limits: core_v1.ResourceList{
+						core_v1.ResourceCPU:    resource.MustParse("300m"),
+						core_v1.ResourceMemory: resource.MustParse("256Mi"),
+					},
+				},
+			},
+		},
+	})
+	assert.Equal(t, map[core_v1.ResourceName]int64{
+		core_v1.ResourceCPU:    400,
+		core_v1.ResourceMemory: 1024 * 1024 * 1024 * 1000,
+	}, requests)
+	assert.Equal(t, map[core_v1.ResourceName]int64{
+		core_v1.ResourceCPU:    500,
+		core_v1.ResourceMemory: 256 * 1024 * 1024 * 1000,
+	}, limits)
+}
@@ -0,0 +1,168 @@
+package bundlec
+
+import (
+	"sync"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// resourceInputs are everything processing of a resource depends on. A resource that was ready after it was
+// processed with the same inputs would be ready and unchanged if processed again, so it can be skipped.
+type resourceInputs struct {
+	// specHash is the hash of the resource definition.
+	specHash string
+	// objectVersion is the resource version of the object of the resource.
+	objectVersion string
+	// dependencyVersions are resource versions of objects of the resource's dependencies.
+	// Empty string means the dependency was not ready.
+	dependencyVersions map[smith_v1.ResourceName]string
+}
+
+func (i *resourceInputs) equal(other *resourceInputs) bool {
+	if i.specHash != other.specHash || i.objectVersion != other.objectVersion ||
+		len(i.dependencyVersions) != len(other.dependencyVersions) {
+		return false
+	}
+	for name, version := range i.dependencyVersions {
+		otherVersion, ok := other.dependencyVersions[name]
+		if !ok || version != otherVersion {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceCache remembers inputs of resources which were ready after they were processed. Bundles are
+// identified by UID so that a re-created Bundle does not reuse entries of the old one.
+type resourceCache struct {
+	mx      sync.Mutex
+	bundles map[types.UID]map[smith_v1.ResourceName]resourceInputs
+	// resyncs are the last seen values of the force resync annotation of Bundles.
+	resyncs map[types.UID]string
+}
+
+func newResourceCache() *resourceCache {
+	return &resourceCache{
+		bundles: make(map[types.UID]map[smith_v1.ResourceName]resourceInputs),
+		resyncs: make(map[types.UID]string),
+	}
+}
+
+func (c *resourceCache) get(bundle types.UID, resName smith_v1.ResourceName) (resourceInputs, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	inputs, ok := c.bundles[bundle][resName]
+	return inputs, ok
+}
+
+func (c *resourceCache) put(bundle types.UID, resName smith_v1.ResourceName, inputs resourceInputs) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	resources := c.bundles[bundle]
+	if resources == nil {
+		resources = make(map[smith_v1.ResourceName]resourceInputs)
+		c.bundles[bundle] = resources
+	}
+	resources[resName] = inputs
+}
+
+func (c *resourceCache) remove(bundle types.UID, resName smith_v1.ResourceName) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.bundles[bundle], resName)
+}
+
+func (c *resourceCache) forget(bundle types.UID) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.bundles, bundle)
+	delete(c.resyncs, bundle)
+}
+
+// resync forgets inputs of all resources of the Bundle if the value of its force resync annotation differs
+// from the last seen one. Returns true if any inputs were forgotten.
+func (c *resourceCache) resync(bundle types.UID, value string) bool /*forgotten*/ {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.resyncs[bundle] == value {
+		return false
+	}
+	c.resyncs[bundle] = value
+	forgotten := len(c.bundles[bundle]) > 0
+	delete(c.bundles, bundle)
+	return forgotten
+}
+
+// resourceInputs returns inputs of the resource given the object it has. Dependencies must have been processed
+// already. Returns false if inputs cannot be determined.
+func (st *bundleSyncTask) resourceInputs(res *smith_v1.Resource, resInfo *resourceInfo) (resourceInputs, bool) {
+	if resInfo.actual == nil || resInfo.specHash == "" {
+		return resourceInputs{}, false
+	}
+	dependencyVersions := make(map[smith_v1.ResourceName]string, len(res.References)+len(res.RunAfter))
+	for _, reference := range res.References {
+		if reference.Object != nil {
+			// Objects outside of the Bundle are not tracked
+			return resourceInputs{}, false
+		}
+		dependencyVersions[reference.Resource] = st.dependencyVersion(reference.Resource)
+	}
+	for _, dependency := range res.RunAfter {
+		dependencyVersions[dependency] = st.dependencyVersion(dependency)
+	}
+	return resourceInputs{
+		specHash:           resInfo.specHash,
+		objectVersion:      resInfo.actual.GetResourceVersion(),
+		dependencyVersions: dependencyVersions,
+	}, true
+}
+
+// dependencyVersion returns the resource version of the object of a processed dependency, or an empty string
+// if the dependency is not ready.
+func (st *bundleSyncTask) dependencyVersion(resName smith_v1.ResourceName) string {
+	resInfo := st.processedResources[resName]
+	if resInfo == nil || !resInfo.isReady() || resInfo.actual == nil {
+		return ""
+	}
+	return resInfo.actual.GetResourceVersion()
+}
+
+// cachedResourceInfo returns information about the existing object of a resource if the resource was ready
+// after it was processed with the same inputs. Returns false if the resource must be processed.
+func (st *bundleSyncTask) cachedResourceInfo(logger *zap.Logger, res *smith_v1.Resource) (*resourceInfo, bool) {
+	if isForceReady(st.bundle, res.Name) {
+		return nil, false
+	}
+	cached, ok := st.resourceCache.get(st.bundle.UID, res.Name)
+	if !ok {
+		return nil, false
+	}
+	specHash, err := res.SpecHash()
+	if err != nil {
+		return nil, false
+	}
+	resInfo, ok := st.unaffectedResourceInfo(logger, res)
+	if !ok {
+		return nil, false
+	}
+	resInfo.specHash = specHash
+	inputs, ok := st.resourceInputs(res, resInfo)
+	if !ok || !inputs.equal(&cached) {
+		return nil, false
+	}
+	return resInfo, true
+}
+
+// cacheResourceInputs remembers inputs of a processed resource if it is ready and forgets them otherwise.
+func (st *bundleSyncTask) cacheResourceInputs(res *smith_v1.Resource, resInfo *resourceInfo) {
+	// Resources with additional objects are not cached because versions of those objects are not tracked
+	if resInfo.isReady() && !isForceReady(st.bundle, res.Name) && len(resInfo.additional) == 0 {
+		if inputs, ok := st.resourceInputs(res, resInfo); ok {
+			st.resourceCache.put(st.bundle.UID, res.Name, inputs)
+			return
+		}
+	}
+	st.resourceCache.remove(st.bundle.UID, res.Name)
+}
@@ -0,0 +1,225 @@
+package bundlec
+
+import (
+	"testing"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestUnchangedResourcesAreSkipped(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	newObjects := func(aVersion string) []runtime.Object {
+		var objs []runtime.Object
+		for _, name := range []string{"a", "b"} {
+			obj := newConfigMap(name)
+			obj.Namespace = "ns"
+			obj.UID = types.UID(name + "-uid")
+			obj.ResourceVersion = "1"
+			obj.OwnerReferences = []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			}
+			objs = append(objs, obj)
+		}
+		objs[0].(*core_v1.ConfigMap).ResourceVersion = aVersion
+		return objs
+	}
+	resources := []smith_v1.Resource{
+		{Name: "a", Spec: smith_v1.ResourceSpec{Object: newConfigMap("a")}},
+		{Name: "b", RunAfter: []smith_v1.ResourceName{"a"}, Spec: smith_v1.ResourceSpec{Object: newConfigMap("b")}},
+	}
+	aHash, err := resources[0].SpecHash()
+	require.NoError(t, err)
+	bHash, err := resources[1].SpecHash()
+	require.NoError(t, err)
+	cache := newResourceCache()
+	cache.put("bundle1-uid", "a", resourceInputs{
+		specHash:           aHash,
+		objectVersion:      "1",
+		dependencyVersions: map[smith_v1.ResourceName]string{},
+	})
+	cache.put("bundle1-uid", "b", resourceInputs{
+		specHash:           bHash,
+		objectVersion:      "1",
+		dependencyVersions: map[smith_v1.ResourceName]string{"a": "1"},
+	})
+
+	newTask := func(objs []runtime.Object) *bundleSyncTask {
+		store, err := newSimulationStore(objs)
+		require.NoError(t, err)
+		return &bundleSyncTask{
+			logger:      logger,
+			smartClient: &simulationSmartClient{result: &ReconcileResult{}},
+			// Processed resources are never ready
+			rc:    neverReadyChecker{},
+			store: store,
+			specCheck: &speccheck.SpecCheck{
+				Logger:  logger,
+				Cleaner: cleanup.New(),
+			},
+			bundle: &smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:       "bundle1",
+					Namespace:  "ns",
+					UID:        "bundle1-uid",
+					Finalizers: []string{FinalizerDeleteResources},
+				},
+				Spec: smith_v1.BundleSpec{
+					Resources: resources,
+				},
+			},
+			recorder:      &record.FakeRecorder{},
+			resourceCache: cache,
+		}
+	}
+
+	// Nothing has changed, resources are skipped
+	st := newTask(newObjects("1"))
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, resourceStatusReady{}, st.processedResources["a"].status)
+	assert.Equal(t, resourceStatusReady{}, st.processedResources["b"].status)
+	assert.Equal(t, smith_v1.ResourceActionUnchanged, st.processedResources["a"].action)
+
+	// Object of a dependency has changed, both resources are processed
+	st = newTask(newObjects("2"))
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, resourceStatusInProgress{}, st.processedResources["a"].status)
+	assert.Equal(t, resourceStatusDependenciesNotReady{
+		dependencies: []smith_v1.ResourceName{"a"},
+		reasons:      map[smith_v1.ResourceName]string{"a": "in progress"},
+	}, st.processedResources["b"].status)
+
+	// Resources that are not ready are forgotten
+	_, ok := cache.get("bundle1-uid", "a")
+	assert.False(t, ok)
+	_, ok = cache.get("bundle1-uid", "b")
+	assert.False(t, ok)
+}
+
+func TestForceResyncBypassesResourceCache(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	configMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "a",
+		},
+	}
+	existing := configMap.DeepCopy()
+	existing.Namespace = "ns"
+	existing.UID = "a-uid"
+	existing.ResourceVersion = "1"
+	existing.OwnerReferences = []meta_v1.OwnerReference{
+		{
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+			Kind:       smith_v1.BundleResourceKind,
+			Name:       "bundle1",
+			UID:        "bundle1-uid",
+			Controller: &tr,
+		},
+	}
+	resources := []smith_v1.Resource{
+		{Name: "a", Spec: smith_v1.ResourceSpec{Object: configMap}},
+	}
+	aHash, err := resources[0].SpecHash()
+	require.NoError(t, err)
+	cache := newResourceCache()
+	cacheA := func() {
+		cache.put("bundle1-uid", "a", resourceInputs{
+			specHash:           aHash,
+			objectVersion:      "1",
+			dependencyVersions: map[smith_v1.ResourceName]string{},
+		})
+	}
+
+	newTask := func(resync string) *bundleSyncTask {
+		store, err := newSimulationStore([]runtime.Object{existing})
+		require.NoError(t, err)
+		return &bundleSyncTask{
+			logger:      logger,
+			smartClient: &simulationSmartClient{result: &ReconcileResult{}},
+			// Processed resources are never ready
+			rc:    neverReadyChecker{},
+			store: store,
+			specCheck: &speccheck.SpecCheck{
+				Logger:  logger,
+				Cleaner: cleanup.New(),
+			},
+			bundle: &smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "bundle1",
+					Namespace: "ns",
+					UID:       "bundle1-uid",
+					Annotations: map[string]string{
+						smith.ForceResyncAnnotation: resync,
+					},
+					Finalizers: []string{FinalizerDeleteResources},
+				},
+				Spec: smith_v1.BundleSpec{
+					Resources: resources,
+				},
+			},
+			recorder:      &record.FakeRecorder{},
+			resourceCache: cache,
+		}
+	}
+
+	// Annotation has changed, resource is processed
+	cacheA()
+	st := newTask("1")
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, resourceStatusInProgress{}, st.processedResources["a"].status)
+
+	// Annotation is the same, resource is skipped
+	cacheA()
+	st = newTask("1")
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, resourceStatusReady{}, st.processedResources["a"].status)
+
+	// Annotation has changed again, resource is processed
+	st = newTask("2")
+	_, err = st.processNormal()
+	require.NoError(t, err)
+	assert.Equal(t, resourceStatusInProgress{}, st.processedResources["a"].status)
+}
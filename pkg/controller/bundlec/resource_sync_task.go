@@ -1,9 +1,22 @@
 package bundlec
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	ctrlLogz "github.com/atlassian/ctrl/logz"
+	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/readychecker"
+	"github.com/atlassian/smith/pkg/speccheck"
 	"github.com/atlassian/smith/pkg/store"
 	"github.com/atlassian/smith/pkg/util"
 	sc_v1b1 "github.com/kubernetes-incubator/service-catalog/pkg/apis/servicecatalog/v1beta1"
@@ -18,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/diff"
 	k8s_json "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 )
 
 // resourceStatus is one of "resourceStatus*" structs.
@@ -27,20 +41,56 @@ type resourceStatus interface{}
 // resourceStatusDependenciesNotReady means resource processing is blocked by dependencies that are not ready.
 type resourceStatusDependenciesNotReady struct {
 	dependencies []smith_v1.ResourceName
+	// reasons describes why each of the dependencies is not ready.
+	reasons map[smith_v1.ResourceName]string
+	// notCreated is true if objects of none of the dependencies exist yet.
+	notCreated bool
+}
+
+// message returns a readable breakdown of dependencies and reasons they are not ready.
+func (s resourceStatusDependenciesNotReady) message() string {
+	dependencies := append([]smith_v1.ResourceName(nil), s.dependencies...)
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i] < dependencies[j]
+	})
+	parts := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		parts = append(parts, fmt.Sprintf("%q (%s)", dependency, s.reasons[dependency]))
+	}
+	return "Not ready: " + strings.Join(parts, ", ")
 }
 
 // resourceStatusInProgress means resource is being processed by its controller.
 type resourceStatusInProgress struct {
+	// waitingForOldObjectDeletion is true if the object cannot be created because the old object
+	// is still being deleted.
+	waitingForOldObjectDeletion bool
+	// waitingForMinReady is true if the object is ready but has not been ready for MinReadySeconds yet.
+	waitingForMinReady bool
+	// waitingForRollout is true if the object needs to be created or updated but changes are deferred
+	// because of MaxResourcesPerSync of the Bundle.
+	waitingForRollout bool
 }
 
 // resourceStatusReady means resource is ready.
 type resourceStatusReady struct {
+	// forceReady is true if the resource is considered ready because of smith.ForceReadyAnnotation.
+	forceReady bool
+	// createOnly is true if the resource is create-only and its object exists already.
+	createOnly bool
+}
+
+// resourceStatusDisabled means the resource is disabled and its object is not created.
+type resourceStatusDisabled struct {
 }
 
 // resourceStatusError means there was an error processing this resource.
 type resourceStatusError struct {
 	err              error
 	isRetriableError bool
+	// waitingForRetry is true if the resource was not processed because its retry delay has not expired yet.
+	// err is the error of the previous attempt.
+	waitingForRetry bool
 }
 
 type resourceInfo struct {
@@ -49,6 +99,22 @@ type resourceInfo struct {
 
 	// if actual is a ServiceBinding, we resolve the secret once it's been processed.
 	serviceBindingSecret *core_v1.Secret
+
+	// defaultedReferences contains names of references that were resolved to their default values.
+	defaultedReferences []smith_v1.ReferenceName
+
+	// action is the action that was taken on the object. Empty if no action was taken.
+	action smith_v1.ResourceAction
+
+	// specHash is the hash of the resource definition that was processed.
+	specHash string
+
+	// appliedSpecHash is the hash of the object that was sent to the API server. Empty if the object was
+	// not created or updated.
+	appliedSpecHash string
+
+	// additional are additional objects produced by a plugin resource.
+	additional []*unstructured.Unstructured
 }
 
 func (ri *resourceInfo) isReady() bool {
@@ -56,6 +122,11 @@ func (ri *resourceInfo) isReady() bool {
 	return ok
 }
 
+func (ri *resourceInfo) isWaitingForRetry() bool {
+	rse, ok := ri.status.(resourceStatusError)
+	return ok && rse.waitingForRetry
+}
+
 func (ri *resourceInfo) fetchError() (bool, error) {
 	if rse, ok := ri.status.(resourceStatusError); ok {
 		return rse.isRetriableError, rse.err
@@ -74,6 +145,50 @@ type resourceSyncTask struct {
 	pluginContainers   map[smith_v1.PluginName]plugin.PluginContainer
 	scheme             *runtime.Scheme
 	catalog            *store.Catalog
+	recorder           record.EventRecorder
+	schemaValidator    SchemaValidator
+	// monitorOnly disables creation/update of objects. Only readiness of existing objects is checked.
+	monitorOnly bool
+	// deferChanges disables creation/update of the object in this iteration because of MaxResourcesPerSync
+	// of the Bundle. Objects that do not need to change are processed as usual.
+	deferChanges bool
+	// validateObjectNamespace enables validation that objects are in the Bundle's namespace.
+	validateObjectNamespace bool
+	// allowClusterScoped allows cluster-scoped objects if validateObjectNamespace is set.
+	allowClusterScoped bool
+	// fetchFromServer makes the object to be read from the API server instead of the Store.
+	fetchFromServer bool
+	// tracer is optional. span is the span of the resource processing.
+	tracer Tracer
+	span   Span
+	// oldObjectDeletionTimeout is how long to wait for an object that is being deleted to be gone before
+	// giving up. Zero means no timeout.
+	oldObjectDeletionTimeout time.Duration
+	// deletedObjects is optional. It has objects that were deleted by the controller.
+	deletedObjects *deletedObjects
+	// fieldManager identifies writes of objects. Optional.
+	fieldManager string
+	// patchUpdates makes objects of resources with the Update strategy be patched instead of replaced.
+	patchUpdates bool
+	// ctx is cancelled when processing should be aborted. Optional.
+	ctx context.Context
+	// pluginTimeout is how long a plugin may take to process the resource. pluginTimeouts overrides it
+	// for particular plugins. Zero means no timeout.
+	pluginTimeout  time.Duration
+	pluginTimeouts map[smith_v1.PluginName]time.Duration
+
+	// defaultedReferences is set by evalSpec to names of references that were resolved to their default values.
+	defaultedReferences []smith_v1.ReferenceName
+	// additionalSpecs is set by evalSpec to additional objects produced by a plugin resource.
+	additionalSpecs []*unstructured.Unstructured
+	// action is set by createOrUpdate to the action that was taken on the object.
+	action smith_v1.ResourceAction
+	// adopting is set by getActualObject if the object does not have a controller and is taken over by the Bundle.
+	adopting bool
+	// appliedSpecHash is set to the hash of the object if it was created or updated.
+	appliedSpecHash string
+	// requeueAfter is set to the delay after which the Bundle should be processed again. Zero if not needed.
+	requeueAfter time.Duration
 }
 
 func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo {
@@ -93,13 +208,18 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	}
 
+	// Objects outside of the Bundle are dependencies too
+	res = st.resolveExternalObjects(res)
+
 	// Check if all resource dependencies are ready (so we can start processing this one)
-	notReadyDependencies := st.checkAllDependenciesAreReady(res)
+	notReadyDependencies, reasons := st.checkAllDependenciesAreReady(res)
 	if len(notReadyDependencies) > 0 {
 		st.logger.Sugar().Infof("Dependencies required by resource but not ready: %q", notReadyDependencies)
 		return resourceInfo{
 			status: resourceStatusDependenciesNotReady{
 				dependencies: notReadyDependencies,
+				reasons:      reasons,
+				notCreated:   !st.anyDependencyExists(notReadyDependencies),
 			},
 		}
 	}
@@ -118,6 +238,8 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		return resourceInfo{
 			status: resourceStatusError{
 				err: err,
+				// Plugin may finish in time on the next attempt
+				isRetriableError: isPluginTimeoutError(err),
 			},
 		}
 	}
@@ -132,44 +254,164 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	}
 
-	// Create or update resource
-	resUpdated, retriable, err := st.createOrUpdate(spec, actual)
-	if err != nil {
-		return resourceInfo{
-			actual: resUpdated,
-			status: resourceStatusError{
-				err:              err,
-				isRetriableError: retriable,
-			},
+	// Validate the object against its schema to fail early with a precise error
+	if st.schemaValidator != nil {
+		if retriable, err := st.schemaValidator.ValidateObject(spec); err != nil {
+			if !retriable {
+				err = withErrorCode(smith_v1.ErrorCodeSpecInvalid, err)
+			}
+			return resourceInfo{
+				status: resourceStatusError{
+					err:              err,
+					isRetriableError: retriable,
+				},
+			}
 		}
 	}
 
-	// Check if the resource actually matches the spec to detect infinite update cycles
-	updatedSpec, match, err := st.specCheck.CompareActualVsSpec(spec, resUpdated)
-	if err != nil {
+	// Objects are adopted by updating them
+	if st.adopting && (res.CreateOnly || res.UpdateStrategy == smith_v1.UpdateStrategyCreateOnly) {
 		return resourceInfo{
 			status: resourceStatusError{
-				err: errors.Wrap(err, "specification re-check failed"),
+				err: errors.New("object does not have a controller and cannot be adopted because the resource is create-only"),
 			},
 		}
 	}
-	if !match {
-		st.logger.Sugar().Warnf("Objects are different after specification re-check:\n%s",
-			diff.ObjectReflectDiff(updatedSpec.Object, resUpdated.Object))
+
+	// Objects of create-only resources are never updated and are considered ready once they exist
+	if res.CreateOnly && actual != nil {
+		st.logger.Debug("Object of create-only resource exists, not updating it")
+		resInfo := st.unchangedResourceInfo(spec.GroupVersionKind(), actual, resourceStatusReady{createOnly: true})
+		// Additional objects are not updated either, they are still produced by the resource
+		resInfo.additional = st.additionalSpecs
+		return resInfo
+	}
+
+	// Changes are deferred while other resources are being rolled out
+	if st.deferChanges && !st.monitorOnly && !(res.UpdateStrategy == smith_v1.UpdateStrategyCreateOnly && actual != nil) {
+		if needsChange, err := st.needsChange(res, spec, actual); err != nil {
+			return resourceInfo{
+				status: resourceStatusError{
+					err: err,
+				},
+			}
+		} else if needsChange {
+			st.logger.Info("Object needs to be created or updated, waiting for other resources to be rolled out")
+			return resourceInfo{
+				status: resourceStatusInProgress{
+					waitingForRollout: true,
+				},
+			}
+		}
+	}
+
+	var resUpdated *unstructured.Unstructured
+	var retriable bool
+	if st.monitorOnly || (res.UpdateStrategy == smith_v1.UpdateStrategyCreateOnly && actual != nil) {
+		// Outside of scheduled reconcile times objects are not created/updated.
+		// Existing objects of resources with CreateOnly update strategy are never updated.
+		if actual == nil {
+			st.logger.Info("Object not found, it will be created at the next scheduled reconcile")
+			return resourceInfo{
+				status: resourceStatusInProgress{},
+			}
+		}
+		if st.adopting {
+			st.logger.Info("Object does not have a controller, it will be adopted at the next scheduled reconcile")
+			return resourceInfo{
+				status: resourceStatusInProgress{},
+			}
+		}
+		resUpdated, err = util.RuntimeToUnstructured(actual)
+		if err != nil {
+			return resourceInfo{
+				status: resourceStatusError{
+					err: err,
+				},
+			}
+		}
+		// Typed objects from informers do not have kind/apiVersion set
+		resUpdated.SetGroupVersionKind(spec.GroupVersionKind())
+	} else {
+		// Spec is hashed before it is mutated by the update
+		specHash, hashErr := objectSpecHash(spec)
+		if hashErr != nil {
+			// Hash is informational, object can be created/updated without it
+			st.logger.Error("Failed to hash object", zap.Error(hashErr))
+		}
+		// Create or update resource
+		resUpdated, retriable, err = st.createOrUpdate(res, spec, actual)
+		if err == nil && st.adopting && st.action == smith_v1.ResourceActionUpdated {
+			st.logger.Info("Object adopted", ctrlLogz.Object(spec))
+			st.action = smith_v1.ResourceActionAdopted
+		}
+		if err == nil && (st.action == smith_v1.ResourceActionCreated || st.action == smith_v1.ResourceActionUpdated || st.action == smith_v1.ResourceActionAdopted) {
+			st.appliedSpecHash = specHash
+		}
+		if err != nil && actual != nil && res.UpdateStrategy == smith_v1.UpdateStrategyRecreate && api_errors.IsInvalid(errors.Cause(err)) {
+			return st.deleteForRecreation(res, spec, actual, err)
+		}
+		if err != nil {
+			return resourceInfo{
+				actual: resUpdated,
+				status: resourceStatusError{
+					err:              err,
+					isRetriableError: retriable,
+				},
+			}
+		}
+
+		// Check if the resource actually matches the spec to detect infinite update cycles
+		updatedSpec, match, err := st.matchesSpec(res, spec, resUpdated)
+		if err != nil {
+			return resourceInfo{
+				status: resourceStatusError{
+					err: errors.Wrap(err, "specification re-check failed"),
+				},
+			}
+		}
+		if !match {
+			st.logger.Sugar().Warnf("Objects are different after specification re-check:\n%s",
+				diff.ObjectReflectDiff(updatedSpec.Object, resUpdated.Object))
+			return resourceInfo{
+				status: resourceStatusError{
+					err: errors.New("specification of the created/updated object does not match the desired spec"),
+				},
+			}
+		}
+	}
+
+	// Create or update additional objects produced by the plugin
+	additional, status := st.processAdditionalObjects(res)
+	if status != nil {
 		return resourceInfo{
-			status: resourceStatusError{
-				err: errors.New("specification of the created/updated object does not match the desired spec"),
-			},
+			actual: resUpdated,
+			status: status,
 		}
 	}
 
 	// Check if resource is ready
 	var ready bool
-	if ready, retriable, err = st.rc.IsReady(resUpdated); err != nil {
+	readinessSpan := startSpan(st.tracer, st.span, spanReadinessCheck, nil)
+	ready, retriable, err = st.isReady(res, resUpdated)
+	for _, obj := range additional {
+		if err != nil || !ready {
+			break
+		}
+		ready, retriable, err = st.isReady(res, obj)
+		if err != nil {
+			err = errors.Wrapf(err, "%s %q", obj.GetKind(), obj.GetName())
+		}
+	}
+	readinessSpan.Finish(err)
+	forceReady := (err != nil || !ready) && isForceReady(st.bundle, res.Name)
+	if forceReady {
+		st.logger.Warn("Resource is forced to be ready by annotation", zap.Bool("ready", ready), zap.Error(err))
+	} else if err != nil {
 		return resourceInfo{
 			actual: resUpdated,
 			status: resourceStatusError{
-				err:              errors.Wrap(err, "readiness check failed"),
+				err:              withErrorCode(smith_v1.ErrorCodeReadinessCheckFailed, errors.Wrap(err, "readiness check failed")),
 				isRetriableError: retriable,
 			},
 		}
@@ -178,6 +420,15 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 			actual: resUpdated,
 			status: resourceStatusInProgress{},
 		}
+	} else if remaining := st.minReadyRemaining(res); remaining > 0 {
+		st.logger.Debug("Object is ready but has not been ready for long enough", zap.Duration("remaining", remaining))
+		st.requeueAfter = remaining
+		return resourceInfo{
+			actual: resUpdated,
+			status: resourceStatusInProgress{
+				waitingForMinReady: true,
+			},
+		}
 	}
 
 	// Augment with binding output (used for references)
@@ -193,11 +444,187 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 
 	return resourceInfo{
 		actual:               resUpdated,
-		status:               resourceStatusReady{},
+		status:               resourceStatusReady{forceReady: forceReady},
+		serviceBindingSecret: bindingSecret,
+		additional:           additional,
+	}
+}
+
+// minReadyRemaining returns how long the object of the resource, which is ready, must stay ready before the resource
+// is reported as ready. The time the object became ready is the LastTransitionTime of the Ready condition, which is
+// Unknown while the resource is waiting. The transition time is reset when the waiting starts, even if the
+// condition was Unknown for another reason before.
+func (st *resourceSyncTask) minReadyRemaining(res *smith_v1.Resource) time.Duration {
+	minReady := time.Duration(res.MinReadySeconds) * time.Second
+	if minReady <= 0 {
+		return 0
+	}
+	_, resStatus := st.bundle.Status.GetResourceStatus(res.Name)
+	if resStatus == nil {
+		return minReady
+	}
+	_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	switch {
+	case readyCond == nil:
+		return minReady
+	case readyCond.Status == smith_v1.ConditionTrue:
+		// Resource has been reported as ready already and the object is still ready
+		return 0
+	case readyCond.Status == smith_v1.ConditionUnknown && readyCond.Reason == smith_v1.ResourceReasonWaitingForMinReadySeconds:
+		return minReady - time.Since(readyCond.LastTransitionTime.Time)
+	default:
+		// Object has just become ready
+		return minReady
+	}
+}
+
+// processAdditionalObjects creates or updates additional objects produced by a plugin resource. In monitor-only
+// mode objects are only read. Returns a status if the objects cannot be used yet.
+func (st *resourceSyncTask) processAdditionalObjects(res *smith_v1.Resource) ([]*unstructured.Unstructured, resourceStatus) {
+	if len(st.additionalSpecs) == 0 {
+		return nil, nil
+	}
+	action := st.action
+	additional := make([]*unstructured.Unstructured, 0, len(st.additionalSpecs))
+	for _, spec := range st.additionalSpecs {
+		gvk := spec.GroupVersionKind()
+		actual, status := st.getControlledObject(gvk, spec.GetName(), false)
+		if status != nil {
+			if rse, ok := status.(resourceStatusError); ok {
+				rse.err = errors.Wrapf(rse.err, "%s %q", gvk.Kind, spec.GetName())
+				status = rse
+			}
+			return nil, status
+		}
+		var updated *unstructured.Unstructured
+		if st.monitorOnly {
+			if actual == nil {
+				st.logger.Info("Additional object not found, it will be created at the next scheduled reconcile",
+					ctrlLogz.ObjectGk(gvk.GroupKind()), ctrlLogz.Object(spec))
+				return nil, resourceStatusInProgress{}
+			}
+			var err error
+			updated, err = util.RuntimeToUnstructured(actual)
+			if err != nil {
+				return nil, resourceStatusError{
+					err: err,
+				}
+			}
+			// Typed objects from informers do not have kind/apiVersion set
+			updated.SetGroupVersionKind(gvk)
+		} else {
+			var retriable bool
+			var err error
+			updated, retriable, err = st.createOrUpdate(res, spec, actual)
+			if err != nil {
+				return nil, resourceStatusError{
+					err:              errors.Wrapf(err, "%s %q", gvk.Kind, spec.GetName()),
+					isRetriableError: retriable,
+				}
+			}
+			if st.action != smith_v1.ResourceActionUnchanged && action == smith_v1.ResourceActionUnchanged {
+				// The resource has changed if any of its objects has changed
+				action = smith_v1.ResourceActionUpdated
+			}
+		}
+		additional = append(additional, updated)
+	}
+	st.action = action
+	return additional, nil
+}
+
+// unchangedResourceInfo returns information about an existing object that is not updated.
+// The object is neither compared with the spec nor checked for readiness, the status is returned as is.
+func (st *resourceSyncTask) unchangedResourceInfo(gvk schema.GroupVersionKind, actual runtime.Object, status resourceStatusReady) resourceInfo {
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}
+	}
+	// Typed objects from informers do not have kind/apiVersion set
+	actualUnstr.SetGroupVersionKind(gvk)
+	st.action = smith_v1.ResourceActionUnchanged
+
+	// Augment with binding output (used for references)
+	bindingSecret, err := st.maybeExtractBindingSecret(actualUnstr)
+	if err != nil {
+		return resourceInfo{
+			actual: actualUnstr,
+			status: resourceStatusError{
+				err: err,
+			},
+		}
+	}
+	return resourceInfo{
+		actual:               actualUnstr,
+		status:               status,
 		serviceBindingSecret: bindingSecret,
 	}
 }
 
+// isForceReady checks if the resource is listed in smith.ForceReadyAnnotation on the Bundle.
+func isForceReady(bundle *smith_v1.Bundle, resName smith_v1.ResourceName) bool {
+	forceReady, ok := bundle.Annotations[smith.ForceReadyAnnotation]
+	if !ok {
+		return false
+	}
+	for _, name := range strings.Split(forceReady, ",") {
+		if smith_v1.ResourceName(strings.TrimSpace(name)) == resName {
+			return true
+		}
+	}
+	return false
+}
+
+// isReady checks if the object is ready. Plugins may provide their own readiness check for objects they produce.
+func (st *resourceSyncTask) isReady(res *smith_v1.Resource, obj *unstructured.Unstructured) (isReady, retriableError bool, e error) {
+	if res.Spec.Plugin != nil {
+		if pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]; ok {
+			if rc, ok := pluginContainer.Plugin.(plugin.ReadyChecker); ok {
+				return pluginIsReady(rc, obj)
+			}
+		}
+	}
+	return st.defaultIsReady(obj)
+}
+
+// defaultIsReady checks if the object is ready using the readiness checks for built-in kinds and Custom Resources.
+func (st *resourceSyncTask) defaultIsReady(obj *unstructured.Unstructured) (isReady, retriableError bool, e error) {
+	// Bundle may provide a default readiness field path/value for Custom Resources
+	defaultPathValue := readychecker.FieldPathValue{
+		Path:  st.bundle.Annotations[smith.CrFieldPathAnnotation],
+		Value: st.bundle.Annotations[smith.CrFieldValueAnnotation],
+	}
+	return st.rc.IsReady(obj, defaultPathValue)
+}
+
+// pluginReadinessError is an error or a panic of the readiness check of a plugin.
+type pluginReadinessError struct {
+	err error
+}
+
+func (e *pluginReadinessError) Error() string {
+	return fmt.Sprintf("plugin readiness check failed: %v", e.err)
+}
+
+// pluginIsReady runs the readiness check of a plugin, converting a panic into an error.
+func pluginIsReady(rc plugin.ReadyChecker, obj *unstructured.Unstructured) (isReady, retriableError bool, e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			isReady, retriableError = false, false
+			e = errors.WithStack(&pluginReadinessError{err: errors.Errorf("panic: %v", r)})
+		}
+	}()
+	isReady, retriableError, e = rc.IsReady(obj)
+	if e != nil {
+		return false, retriableError, errors.WithStack(&pluginReadinessError{err: e})
+	}
+	return isReady, retriableError, nil
+}
+
 func (st *resourceSyncTask) maybeExtractBindingSecret(obj *unstructured.Unstructured) (*core_v1.Secret, error) {
 	if obj.GroupVersionKind() != sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding") {
 		return nil, nil
@@ -217,19 +644,115 @@ func (st *resourceSyncTask) maybeExtractBindingSecret(obj *unstructured.Unstruct
 	return secret.(*core_v1.Secret), nil
 }
 
-func (st *resourceSyncTask) checkAllDependenciesAreReady(res *smith_v1.Resource) []smith_v1.ResourceName {
+// checkAllDependenciesAreReady returns dependencies of the resource that are not ready and reasons why.
+func (st *resourceSyncTask) checkAllDependenciesAreReady(res *smith_v1.Resource) ([]smith_v1.ResourceName, map[smith_v1.ResourceName]string) {
 	// No len here because dependencies can occur more than once in reference list
-	notReadyDependenciesSet := make(map[smith_v1.ResourceName]struct{})
+	reasons := make(map[smith_v1.ResourceName]string)
 	for _, reference := range res.References {
-		if !st.processedResources[reference.Resource].isReady() {
-			notReadyDependenciesSet[reference.Resource] = struct{}{}
+		if !isBlockingReference(reference) {
+			// Default value is used if the dependency is not ready
+			continue
+		}
+		resInfo := st.processedResources[reference.Resource]
+		if !resInfo.isReady() {
+			reasons[reference.Resource] = dependencyNotReadyReason(resInfo)
+			continue
+		}
+		switch reference.Modifier {
+		case smith_v1.ReferenceModifierLabel, smith_v1.ReferenceModifierAnnotation:
+			// Dependency is not ready until it has the referenced label/annotation
+			if reference.Path == "" {
+				break // Invalid reference, reported when references are resolved
+			}
+			if _, ok := metadataValue(resInfo.actual, reference.Modifier, reference.Path); !ok {
+				reasons[reference.Resource] = fmt.Sprintf("no %s %q", reference.Modifier, reference.Path)
+			}
 		}
 	}
-	notReadyDependencies := make([]smith_v1.ResourceName, 0, len(notReadyDependenciesSet))
-	for resourceName := range notReadyDependenciesSet {
+	for _, dependency := range res.RunAfter {
+		if resInfo := st.processedResources[dependency]; !resInfo.isReady() {
+			reasons[dependency] = dependencyNotReadyReason(resInfo)
+		}
+	}
+	notReadyDependencies := make([]smith_v1.ResourceName, 0, len(reasons))
+	for resourceName := range reasons {
 		notReadyDependencies = append(notReadyDependencies, resourceName)
 	}
-	return notReadyDependencies
+	return notReadyDependencies, reasons
+}
+
+// anyDependencyExists checks if an object of any of the dependencies exists.
+func (st *resourceSyncTask) anyDependencyExists(dependencies []smith_v1.ResourceName) bool {
+	for _, dependency := range dependencies {
+		if st.dependencyExists(dependency) {
+			return true
+		}
+	}
+	return false
+}
+
+// readyDependencyObject returns the object of a dependency if the dependency is ready, nil otherwise.
+// Dependencies of non-blocking references are not necessarily ready when the resource is processed.
+func (st *resourceSyncTask) readyDependencyObject(resName smith_v1.ResourceName) *unstructured.Unstructured {
+	resInfo := st.processedResources[resName]
+	if resInfo == nil || !resInfo.isReady() {
+		return nil
+	}
+	return resInfo.actual
+}
+
+// dependencyExists checks if the object of a dependency exists. Objects of dependencies that were not
+// processed or were blocked themselves are looked up in the Store.
+func (st *resourceSyncTask) dependencyExists(resName smith_v1.ResourceName) bool {
+	if resInfo := st.processedResources[resName]; resInfo != nil && resInfo.actual != nil {
+		return true
+	}
+	for i := range st.bundle.Spec.Resources {
+		res := &st.bundle.Spec.Resources[i]
+		if res.Name != resName {
+			continue
+		}
+		if res.Disabled {
+			// Object of a disabled resource is deleted, it does not count
+			return false
+		}
+		var gvk schema.GroupVersionKind
+		if res.Spec.Object != nil {
+			gvk = res.Spec.Object.GetObjectKind().GroupVersionKind()
+		} else if res.Spec.Plugin != nil {
+			pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
+			if !ok {
+				return false
+			}
+			gvk = pluginContainer.Plugin.Describe().GVK
+		} else {
+			return false
+		}
+		_, exists, err := st.getObject(gvk, resourceObjectName(res))
+		return err == nil && exists
+	}
+	return false
+}
+
+// dependencyNotReadyReason describes why a dependency that is not ready is in that state.
+func dependencyNotReadyReason(resInfo *resourceInfo) string {
+	if resInfo == nil {
+		return "not processed"
+	}
+	switch resInfo.status.(type) {
+	case resourceStatusDependenciesNotReady:
+		return "blocked"
+	case resourceStatusDisabled:
+		return "disabled"
+	case resourceStatusObjectNotFound:
+		return "not found"
+	case resourceStatusInProgress:
+		return "in progress"
+	case resourceStatusError:
+		return "error"
+	default:
+		return "not ready"
+	}
 }
 
 func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Object, resourceStatus) {
@@ -241,7 +764,9 @@ func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Obj
 	} else if res.Spec.Plugin != nil {
 		pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
 		if !ok {
-			return nil, errors.Errorf("no such plugin %q", res.Spec.Plugin.Name)
+			return nil, resourceStatusError{
+				err: withErrorCode(smith_v1.ErrorCodePluginNotFound, errors.Errorf("no such plugin %q", res.Spec.Plugin.Name)),
+			}
 		}
 		gvk = pluginContainer.Plugin.Describe().GVK
 		name = res.Spec.Plugin.ObjectName
@@ -251,27 +776,64 @@ func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Obj
 			err: errors.New(`neither "object" nor "plugin" field is specified`),
 		}
 	}
-	actual, exists, err := st.store.Get(gvk, st.bundle.Namespace, name)
+	return st.getControlledObject(gvk, name, res.Adopt)
+}
+
+// getControlledObject gets the object and checks that it is controlled by the Bundle. An object without a controller
+// is accepted if adopt is true, it is taken over when it is updated. Returns nil if the object does not exist or
+// a status if the object cannot be used yet.
+func (st *resourceSyncTask) getControlledObject(gvk schema.GroupVersionKind, name string, adopt bool) (runtime.Object, resourceStatus) {
+	actual, exists, err := st.getObject(gvk, name)
 	if err != nil {
 		return nil, resourceStatusError{
-			err: errors.Wrap(err, "failed to get object from the Store"),
+			err: err,
 		}
 	}
 	if !exists {
+		if st.deletedObjects != nil {
+			st.deletedObjects.forget(st.bundle.Namespace, gvk.GroupKind(), name)
+		}
 		return nil, nil
 	}
 	actualMeta := actual.(meta_v1.Object)
 
-	// Check that the object is not marked for deletion
-	if actualMeta.GetDeletionTimestamp() != nil {
-		return nil, resourceStatusError{
-			err: errors.New("object is marked for deletion"),
+	// Wait for the object that is being deleted to be gone before it is created again.
+	// Deletion of the object triggers processing of the Bundle.
+	if deletionTimestamp := actualMeta.GetDeletionTimestamp(); deletionTimestamp != nil {
+		if st.deletedObjects != nil {
+			st.deletedObjects.forget(st.bundle.Namespace, gvk.GroupKind(), name)
+		}
+		if st.oldObjectDeletionTimeout > 0 {
+			waiting := time.Since(deletionTimestamp.Time)
+			if waiting >= st.oldObjectDeletionTimeout {
+				return nil, resourceStatusError{
+					err: errors.Errorf("timed out waiting for old object to be deleted (waited for %s)", st.oldObjectDeletionTimeout),
+				}
+			}
+			st.requeueAfter = st.oldObjectDeletionTimeout - waiting
+		}
+		st.logger.Info("Waiting for old object to be deleted")
+		return nil, resourceStatusInProgress{
+			waitingForOldObjectDeletion: true,
+		}
+	}
+	// Object was deleted but the Store has not caught up yet. Update of the object in the Store
+	// triggers processing of the Bundle.
+	if st.deletedObjects != nil && st.deletedObjects.isBeingDeleted(st.bundle.Namespace, gvk.GroupKind(), name, actualMeta.GetUID()) {
+		st.logger.Info("Waiting for old object to be deleted, Store has not observed the deletion yet")
+		return nil, resourceStatusInProgress{
+			waitingForOldObjectDeletion: true,
 		}
 	}
 
 	// Check that this bundle controls the object
 	if !meta_v1.IsControlledBy(actualMeta, st.bundle) {
 		ref := meta_v1.GetControllerOf(actualMeta)
+		if ref == nil && adopt {
+			st.logger.Info("Object does not have a controller, adopting it")
+			st.adopting = true
+			return actual, nil
+		}
 		var err error
 		if ref == nil {
 			err = errors.New("object is not controlled by the Bundle and does not have a controller at all")
@@ -284,6 +846,29 @@ func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Obj
 	return actual, nil
 }
 
+// getObject gets the object from the Store or from the API server if fetchFromServer is set.
+func (st *resourceSyncTask) getObject(gvk schema.GroupVersionKind, name string) (runtime.Object, bool /*exists*/, error) {
+	if !st.fetchFromServer {
+		actual, exists, err := st.store.Get(gvk, st.bundle.Namespace, name)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to get object from the Store")
+		}
+		return actual, exists, nil
+	}
+	resClient, err := st.smartClient.ForGVK(gvk, st.bundle.Namespace)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to get the client for %q", gvk)
+	}
+	actual, err := resClient.Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		if api_errors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(err, "failed to get object from the API server")
+	}
+	return actual, true, nil
+}
+
 // prevalidate does as much validation as possible before doing any real work.
 func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) error {
 	sp, err := newExamplesSpec(res.References)
@@ -335,7 +920,7 @@ func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) error {
 
 			err = st.catalog.ValidateServiceInstanceSpec(&serviceInstance.Spec)
 			if err != nil {
-				return errors.WithStack(err)
+				return withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.WithStack(err))
 			}
 		}
 		// TODO validate service binding parameters
@@ -348,11 +933,11 @@ func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) error {
 		}
 		pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
 		if !ok {
-			return errors.Errorf("plugin %q does not exist", res.Spec.Plugin.Name)
+			return withErrorCode(smith_v1.ErrorCodePluginNotFound, errors.Errorf("plugin %q does not exist", res.Spec.Plugin.Name))
 		}
 		err := pluginContainer.ValidateSpec(res.Spec.Plugin.Spec)
 		if err != nil {
-			return errors.Wrap(err, "invalid spec")
+			return withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Wrap(err, "invalid spec"))
 		}
 	}
 
@@ -384,6 +969,7 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 	if err := sp.ProcessObject(objectOrPluginSpec); err != nil {
 		return nil, err
 	}
+	st.defaultedReferences = sp.defaulted
 
 	var obj *unstructured.Unstructured
 	if res.Spec.Object != nil {
@@ -392,7 +978,7 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 		}
 	} else if res.Spec.Plugin != nil {
 		var err error
-		obj, err = st.evalPluginSpec(res, actual)
+		obj, st.additionalSpecs, err = st.evalPluginSpec(res, actual)
 		if err != nil {
 			return nil, err
 		}
@@ -400,15 +986,99 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 		return nil, errors.New(`neither "object" nor "plugin" field is specified`)
 	}
 
+	if err := st.completeObject(res, obj); err != nil {
+		return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, err)
+	}
+	for _, additional := range st.additionalSpecs {
+		if err := st.completeObject(res, additional); err != nil {
+			return nil, withErrorCode(smith_v1.ErrorCodeSpecInvalid, errors.Wrapf(err, "%s %q", additional.GetKind(), additional.GetName()))
+		}
+	}
+	return obj, nil
+}
+
+// completeObject validates the object of the resource and sets labels, annotations and owner references on it.
+func (st *resourceSyncTask) completeObject(res *smith_v1.Resource, obj *unstructured.Unstructured) error {
+	// Objects outside of the Bundle's namespace cannot be found by the controller and garbage collected
+	if st.validateObjectNamespace {
+		if ns := obj.GetNamespace(); ns != "" && ns != st.bundle.Namespace {
+			return errors.Errorf("object namespace %q does not match Bundle namespace %q", ns, st.bundle.Namespace)
+		}
+		if !st.allowClusterScoped && st.isClusterScoped(obj.GroupVersionKind()) {
+			return errors.Errorf("object %s %q is cluster-scoped, cluster-scoped objects are not allowed", obj.GetKind(), obj.GetName())
+		}
+	}
+
+	// Inject hash of dependencies into the pod template to trigger rolling updates
+	if err := injectDependenciesHash(obj, st.processedResources, res.References); err != nil {
+		return err
+	}
+
 	// Update label to point at the parent bundle
 	obj.SetLabels(mergeLabels(st.bundle.Labels, obj.GetLabels()))
 
+	if !isValidUpdateStrategy(res.UpdateStrategy) {
+		return errors.Errorf("invalid update strategy %q, must be one of %q, %q, %q or %q", res.UpdateStrategy,
+			smith_v1.UpdateStrategyUpdate, smith_v1.UpdateStrategyCreateOnly, smith_v1.UpdateStrategyRecreate, smith_v1.UpdateStrategyPatch)
+	}
+	if res.CreateOnly && res.UpdateStrategy != "" {
+		// Both never update existing objects but differ in whether their readiness is checked
+		return errors.Errorf("createOnly cannot be combined with update strategy %q, use update strategy %q to check readiness of existing objects",
+			res.UpdateStrategy, smith_v1.UpdateStrategyCreateOnly)
+	}
+
+	// Record delete policy so that it is known once the resource is removed from the Bundle
+	if res.DeletePolicy != "" {
+		if !isValidDeletePolicy(res.DeletePolicy) {
+			return errors.Errorf("invalid delete policy %q, must be one of %q, %q or %q", res.DeletePolicy,
+				meta_v1.DeletePropagationForeground, meta_v1.DeletePropagationBackground, meta_v1.DeletePropagationOrphan)
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[smith.DeletePolicyAnnotation] = string(res.DeletePolicy)
+		obj.SetAnnotations(annotations)
+	}
+
+	// Record delete grace period for the same reason
+	if res.DeleteGracePeriodSeconds != nil {
+		if *res.DeleteGracePeriodSeconds < 0 {
+			return errors.Errorf("invalid delete grace period %d, must not be negative", *res.DeleteGracePeriodSeconds)
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[smith.DeleteGracePeriodAnnotation] = strconv.FormatInt(*res.DeleteGracePeriodSeconds, 10)
+		obj.SetAnnotations(annotations)
+	}
+
+	// Record ignored paths for the spec check
+	if len(res.IgnorePaths) > 0 {
+		for _, path := range res.IgnorePaths {
+			if _, err := speccheck.ParseIgnorePath(path); err != nil {
+				return err
+			}
+		}
+		paths, err := json.Marshal(res.IgnorePaths)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[smith.IgnorePathsAnnotation] = string(paths)
+		obj.SetAnnotations(annotations)
+	}
+
 	// Update OwnerReferences
 	trueRef := true
 	refs := obj.GetOwnerReferences()
 	for i, ref := range refs {
 		if ref.Controller != nil && *ref.Controller {
-			return nil, errors.Errorf("cannot create resource with controller owner reference %v", ref)
+			return errors.Errorf("cannot create resource with controller owner reference %v", ref)
 		}
 		refs[i].BlockOwnerDeletion = &trueRef
 	}
@@ -422,7 +1092,15 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 		BlockOwnerDeletion: &trueRef,
 	})
 	for _, dep := range res.References {
-		processedObj := st.processedResources[dep.Resource].actual // this is ok because we've checked earlier that resources contains all dependencies
+		if dep.Object != nil {
+			// Objects outside of the Bundle are not owners
+			continue
+		}
+		processedObj := st.readyDependencyObject(dep.Resource)
+		if processedObj == nil {
+			// Dependency of a non-blocking reference is not ready, its object may not exist
+			continue
+		}
 		refs = append(refs, meta_v1.OwnerReference{
 			APIVersion:         processedObj.GetAPIVersion(),
 			Kind:               processedObj.GetKind(),
@@ -432,49 +1110,116 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 		})
 	}
 	obj.SetOwnerReferences(refs)
+	return nil
+}
 
-	return obj, nil
+// isClusterScoped checks if objects of the kind are cluster-scoped. Scope is unknown if the SmartClient cannot
+// determine it, such objects are treated as namespaced.
+func (st *resourceSyncTask) isClusterScoped(gvk schema.GroupVersionKind) bool {
+	scopeClient, ok := st.smartClient.(ScopeSmartClient)
+	if !ok {
+		return false
+	}
+	namespaced, err := scopeClient.IsNamespaced(gvk)
+	if err != nil {
+		// Kind is not known, the object cannot be created either and fails with a more specific error
+		st.logger.Debug("Failed to determine scope of object kind", zap.Stringer("gvk", gvk), zap.Error(err))
+		return false
+	}
+	return !namespaced
 }
 
-// evalPluginSpec evaluates the plugin resource specification and returns the result.
-func (st *resourceSyncTask) evalPluginSpec(res *smith_v1.Resource, actual runtime.Object) (*unstructured.Unstructured, error) {
+// evalPluginSpec evaluates the plugin resource specification and returns the result and additional objects.
+func (st *resourceSyncTask) evalPluginSpec(res *smith_v1.Resource, actual runtime.Object) (*unstructured.Unstructured, []*unstructured.Unstructured, error) {
 	pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
 	if !ok {
-		return nil, errors.Errorf("no such plugin %q", res.Spec.Plugin.Name)
+		return nil, nil, withErrorCode(smith_v1.ErrorCodePluginNotFound, errors.Errorf("no such plugin %q", res.Spec.Plugin.Name))
 	}
 	err := pluginContainer.ValidateSpec(res.Spec.Plugin.Spec)
 	if err != nil {
-		return nil, errors.Wrapf(err, "plugin %q has invalid spec", res.Spec.Plugin.Name)
+		return nil, nil, errors.Wrapf(err, "plugin %q has invalid spec", res.Spec.Plugin.Name)
 	}
 
 	// validate above should guarantee that our plugin is there
 	dependencies, err := st.prepareDependencies(res.References)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	result, err := pluginContainer.Plugin.Process(res.Spec.Plugin.Spec, &plugin.Context{
+	timeout := st.pluginTimeout
+	if pluginTimeout, ok := st.pluginTimeouts[res.Spec.Plugin.Name]; ok {
+		timeout = pluginTimeout
+	}
+	result, err := processPlugin(st.context(), timeout, pluginContainer.Plugin, res.Spec.Plugin.Spec, &plugin.Context{
 		Namespace:    st.bundle.Namespace,
 		Actual:       actual,
 		Dependencies: dependencies,
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Make sure plugin is returning us something that obeys the PluginSpec.
 	object, err := util.RuntimeToUnstructured(result.Object)
 	if err != nil {
-		return nil, errors.Wrap(err, "plugin output cannot be converted from runtime.Object")
+		return nil, nil, errors.Wrap(err, "plugin output cannot be converted from runtime.Object")
 	}
 	expectedGVK := pluginContainer.Plugin.Describe().GVK
 	if object.GroupVersionKind() != expectedGVK {
-		return nil, errors.Errorf("unexpected GVK from plugin (wanted %s, got %s)", expectedGVK, object.GroupVersionKind())
+		return nil, nil, errors.Errorf("unexpected GVK from plugin (wanted %s, got %s)", expectedGVK, object.GroupVersionKind())
 	}
 	// We are in charge of naming.
 	object.SetName(res.Spec.Plugin.ObjectName)
 
-	return object, nil
+	additional := make([]*unstructured.Unstructured, 0, len(result.AdditionalObjects))
+	for i, additionalObj := range result.AdditionalObjects {
+		additionalUnstr, err := util.RuntimeToUnstructured(additionalObj)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "plugin additional object %d cannot be converted from runtime.Object", i)
+		}
+		if additionalUnstr.GetName() == "" {
+			return nil, nil, errors.Errorf("plugin additional object %d (%s) does not have a name", i, additionalUnstr.GroupVersionKind())
+		}
+		if additionalUnstr.GroupVersionKind().GroupKind() == expectedGVK.GroupKind() && additionalUnstr.GetName() == object.GetName() {
+			return nil, nil, errors.Errorf("plugin additional object %d has the same kind and name as the main object", i)
+		}
+		if owner, ok := st.otherResourceProducing(res.Name, additionalUnstr.GroupVersionKind().GroupKind(), additionalUnstr.GetName()); ok {
+			return nil, nil, withErrorCode(smith_v1.ErrorCodeDuplicateResource, errors.Errorf("plugin additional object %d is the same object %s %q as an object of resource %q",
+				i, additionalUnstr.GroupVersionKind().GroupKind(), additionalUnstr.GetName(), owner))
+		}
+		// Record which resource produced the object so that it is not deleted while the resource is in the Bundle
+		annotations := additionalUnstr.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[smith.PluginResourceAnnotation] = string(res.Name)
+		additionalUnstr.SetAnnotations(annotations)
+		additional = append(additional, additionalUnstr)
+	}
+
+	return object, additional, nil
+}
+
+// otherResourceProducing returns the name of another resource of the Bundle that produces the object, either as
+// its object or as an additional object that was produced already.
+func (st *resourceSyncTask) otherResourceProducing(resName smith_v1.ResourceName, gk schema.GroupKind, name string) (smith_v1.ResourceName, bool) {
+	for i := range st.bundle.Spec.Resources {
+		res := &st.bundle.Spec.Resources[i]
+		if res.Name == resName || res.Disabled {
+			continue
+		}
+		if resourceObjectName(res) == name && resourceGVK(res, st.pluginContainers).GroupKind() == gk {
+			return res.Name, true
+		}
+		if resInfo := st.processedResources[res.Name]; resInfo != nil {
+			for _, additional := range resInfo.additional {
+				if additional.GetName() == name && additional.GroupVersionKind().GroupKind() == gk {
+					return res.Name, true
+				}
+			}
+		}
+	}
+	return "", false
 }
 
 func (st *resourceSyncTask) prepareDependencies(references []smith_v1.Reference) (map[smith_v1.ResourceName]plugin.Dependency, error) {
@@ -484,7 +1229,12 @@ func (st *resourceSyncTask) prepareDependencies(references []smith_v1.Reference)
 			// References could refer to the same resource as a previous one.
 			continue
 		}
-		unstructuredActual := st.processedResources[reference.Resource].actual.DeepCopy() // Pass a copy to the plugin to insulate from it
+		readyActual := st.readyDependencyObject(reference.Resource)
+		if readyActual == nil {
+			// Dependency of a non-blocking reference is not ready, it is not passed to the plugin
+			continue
+		}
+		unstructuredActual := readyActual.DeepCopy() // Pass a copy to the plugin to insulate from it
 		gvk := unstructuredActual.GroupVersionKind()
 		var actual runtime.Object
 		if st.scheme.Recognizes(gvk) {
@@ -533,27 +1283,102 @@ func (st *resourceSyncTask) prepareServiceBindingDependency(dependency *plugin.D
 	return nil
 }
 
-// createOrUpdate creates or updates a resources.
-func (st *resourceSyncTask) createOrUpdate(spec *unstructured.Unstructured, actual runtime.Object) (actualRet *unstructured.Unstructured, retriableRet bool, e error) {
+// context returns the context of the processing iteration.
+func (st *resourceSyncTask) context() context.Context {
+	if st.ctx == nil {
+		return context.Background()
+	}
+	return st.ctx
+}
+
+// createOrUpdate creates or updates a resources. Existing objects are updated according to the update strategy
+// of the resource.
+func (st *resourceSyncTask) createOrUpdate(res *smith_v1.Resource, spec *unstructured.Unstructured, actual runtime.Object) (actualRet *unstructured.Unstructured, retriableRet bool, e error) {
+	// Clients do not support contexts, so at least do not start writing if processing was aborted
+	if err := st.context().Err(); err != nil {
+		return nil, false, err
+	}
 	// Prepare client
 	gvk := spec.GroupVersionKind()
-	resClient, err := st.smartClient.ForGVK(gvk, st.bundle.Namespace)
+	resClient, err := writeClient(st.smartClient, gvk, st.bundle.Namespace, st.objectFieldManager())
 	if err != nil {
 		return nil, false, errors.Wrapf(err, "failed to get the client for %q", gvk)
 	}
 	if actual != nil {
 		st.logger.Info("Object found, checking spec", ctrlLogz.ObjectGk(gvk.GroupKind()), ctrlLogz.Object(spec))
+		if st.patchesObject(res) {
+			return st.patchResource(resClient, spec, actual)
+		}
 		return st.updateResource(resClient, spec, actual)
 	}
 	st.logger.Info("Object not found, creating", ctrlLogz.ObjectGk(gvk.GroupKind()), ctrlLogz.Object(spec))
 	return st.createResource(resClient, spec)
 }
 
+// patchesObject checks if an existing object of the resource is patched rather than replaced.
+func (st *resourceSyncTask) patchesObject(res *smith_v1.Resource) bool {
+	switch res.UpdateStrategy {
+	case smith_v1.UpdateStrategyPatch:
+		return true
+	case "", smith_v1.UpdateStrategyUpdate:
+		return st.patchUpdates
+	default:
+		return false
+	}
+}
+
+// objectFieldManager returns the field manager that identifies writes of objects of resources. When objects
+// are patched each Bundle has its own field manager, so that fields set by different Bundles can be told apart.
+func (st *resourceSyncTask) objectFieldManager() string {
+	if !st.patchUpdates || st.fieldManager == "" {
+		return st.fieldManager
+	}
+	return fmt.Sprintf("%s/%s/%s", st.fieldManager, st.bundle.Namespace, st.bundle.Name)
+}
+
+// needsChange checks if the object does not exist or does not match the spec and would be created or updated.
+func (st *resourceSyncTask) needsChange(res *smith_v1.Resource, spec *unstructured.Unstructured, actual runtime.Object) (bool, error) {
+	if actual == nil {
+		return true, nil
+	}
+	// Comparison mutates its arguments
+	_, match, err := st.matchesSpec(res, spec.DeepCopy(), actual.DeepCopyObject())
+	if err != nil {
+		return false, errors.Wrap(err, "specification check failed")
+	}
+	return !match, nil
+}
+
+// matchesSpec checks if the object matches the spec, as it is compared when the object is updated according to
+// the update strategy of the resource. Returns the object with the spec applied if it does not match.
+// Mutates spec and actual.
+func (st *resourceSyncTask) matchesSpec(res *smith_v1.Resource, spec *unstructured.Unstructured, actual runtime.Object) (*unstructured.Unstructured, bool /*match*/, error) {
+	if !st.patchesObject(res) {
+		return st.specCheck.CompareActualVsSpec(spec, actual)
+	}
+	patch, _, patched, err := objectPatch(spec, actual)
+	if err != nil {
+		return nil, false, err
+	}
+	return patched, patch == nil, nil
+}
+
+// objectSpecHash returns a short hash of the object.
+func objectSpecHash(obj *unstructured.Unstructured) (string, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal object")
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8]), nil
+}
+
 func (st *resourceSyncTask) createResource(resClient dynamic.ResourceInterface, spec *unstructured.Unstructured) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
 	gvk := spec.GroupVersionKind()
 	response, err := resClient.Create(spec)
 	if err == nil {
 		st.logger.Info("Object created", ctrlLogz.ObjectGk(gvk.GroupKind()), ctrlLogz.Object(spec))
+		st.action = smith_v1.ResourceActionCreated
 		return response, false, nil
 	}
 	if api_errors.IsAlreadyExists(err) {
@@ -574,9 +1399,16 @@ func (st *resourceSyncTask) updateResource(resClient dynamic.ResourceInterface,
 	}
 	if match {
 		st.logger.Info("Object has correct spec", ctrlLogz.Object(spec))
+		st.action = smith_v1.ResourceActionUnchanged
 		return updated, false, nil
 	}
 
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return nil, false, err
+	}
+	changedPaths := speccheck.ChangedPaths(updated, actualUnstr)
+
 	// Update if different
 	updated, err = resClient.Update(updated)
 	if err != nil {
@@ -588,9 +1420,71 @@ func (st *resourceSyncTask) updateResource(resClient dynamic.ResourceInterface,
 		return nil, true, err
 	}
 	st.logger.Info("Object updated", ctrlLogz.Object(spec))
+	st.action = smith_v1.ResourceActionUpdated
+	// Only paths are reported, never values, so it is safe to do for Secrets too
+	st.recorder.Eventf(st.bundle, core_v1.EventTypeNormal, EventReasonObjectUpdated, "Updated %s %q: changed %s",
+		spec.GetKind(), spec.GetName(), changedPathsMessage(changedPaths))
 	return updated, false, nil
 }
 
+// deleteForRecreation deletes the object which update was rejected, e.g. because an immutable field was changed.
+// The object is created from the spec once it is gone. Only the object that was found to be controlled by
+// the Bundle is deleted, it is not deleted if it has been re-created in the meantime.
+func (st *resourceSyncTask) deleteForRecreation(res *smith_v1.Resource, spec *unstructured.Unstructured, actual runtime.Object, updateErr error) resourceInfo {
+	gvk := spec.GroupVersionKind()
+	st.logger.Info("Object update rejected, deleting object to re-create it", ctrlLogz.Object(spec), zap.Error(updateErr))
+	resClient, err := writeClient(st.smartClient, gvk, st.bundle.Namespace, st.fieldManager)
+	if err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: errors.Wrapf(err, "failed to get the client for %q", gvk),
+			},
+		}
+	}
+	uid := actual.(meta_v1.Object).GetUID()
+	policy := meta_v1.DeletePropagationForeground
+	if res.DeletePolicy != "" {
+		policy = res.DeletePolicy
+	}
+	err = resClient.Delete(spec.GetName(), &meta_v1.DeleteOptions{
+		Preconditions: &meta_v1.Preconditions{
+			UID: &uid,
+		},
+		PropagationPolicy:  &policy,
+		GracePeriodSeconds: res.DeleteGracePeriodSeconds,
+	})
+	if err != nil && !api_errors.IsNotFound(err) && !api_errors.IsConflict(err) {
+		// not found means object has been deleted already
+		// conflict means it has been deleted and re-created (UID does not match)
+		return resourceInfo{
+			status: resourceStatusError{
+				err:              errors.Wrap(err, "failed to delete object to re-create it"),
+				isRetriableError: true,
+			},
+		}
+	}
+	if err == nil && st.deletedObjects != nil {
+		st.deletedObjects.add(st.bundle.Namespace, gvk.GroupKind(), spec.GetName(), uid)
+	}
+	st.action = smith_v1.ResourceActionDeleted
+	st.recorder.Eventf(st.bundle, core_v1.EventTypeNormal, EventReasonObjectRecreated, "Deleted %s %q to re-create it because its update was rejected: %v",
+		spec.GetKind(), spec.GetName(), updateErr)
+	return resourceInfo{
+		status: resourceStatusInProgress{
+			waitingForOldObjectDeletion: true,
+		},
+	}
+}
+
+// writeClient returns a client for writing objects of the kind. Writes are identified with the field manager
+// if it is set and the SmartClient supports it.
+func writeClient(smartClient SmartClient, gvk schema.GroupVersionKind, namespace, fieldManager string) (dynamic.ResourceInterface, error) {
+	if fmClient, ok := smartClient.(FieldManagerSmartClient); ok && fieldManager != "" {
+		return fmClient.ForGVKWithFieldManager(gvk, namespace, fieldManager)
+	}
+	return smartClient.ForGVK(gvk, namespace)
+}
+
 func mergeLabels(labels ...map[string]string) map[string]string {
 	result := make(map[string]string)
 	for _, m := range labels {
@@ -0,0 +1,41 @@
+package bundlec
+
+import (
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+)
+
+const scheduleTimeLayout = "15:04"
+
+// scheduleInstants returns the latest scheduled instant that is not after now and
+// the earliest scheduled instant that is after now.
+func scheduleInstants(schedule *smith_v1.BundleSchedule, now time.Time) (prev, next time.Time, e error) {
+	if len(schedule.DailyAt) == 0 {
+		return time.Time{}, time.Time{}, errors.New("schedule must have at least one time of day")
+	}
+	now = now.UTC()
+	for _, dailyAt := range schedule.DailyAt {
+		t, err := time.Parse(scheduleTimeLayout, dailyAt)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.Errorf("invalid scheduled time of day %q, expected HH:MM", dailyAt)
+		}
+		instant := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+		var p, n time.Time
+		if instant.After(now) {
+			p = instant.AddDate(0, 0, -1)
+			n = instant
+		} else {
+			p = instant
+			n = instant.AddDate(0, 0, 1)
+		}
+		if prev.IsZero() || p.After(prev) {
+			prev = p
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return prev, next, nil
+}
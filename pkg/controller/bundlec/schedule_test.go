@@ -0,0 +1,45 @@
+package bundlec
+
+import (
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleInstants(t *testing.T) {
+	t.Parallel()
+	schedule := &smith_v1.BundleSchedule{
+		DailyAt: []string{"20:00", "08:00"},
+	}
+	day := func(d, h, m int) time.Time {
+		return time.Date(2018, 6, d, h, m, 0, 0, time.UTC)
+	}
+	testcases := []struct {
+		now  time.Time
+		prev time.Time
+		next time.Time
+	}{
+		{now: day(10, 7, 59), prev: day(9, 20, 0), next: day(10, 8, 0)},
+		{now: day(10, 8, 0), prev: day(10, 8, 0), next: day(10, 20, 0)},
+		{now: day(10, 12, 30), prev: day(10, 8, 0), next: day(10, 20, 0)},
+		{now: day(10, 23, 0), prev: day(10, 20, 0), next: day(11, 8, 0)},
+	}
+	for _, tc := range testcases {
+		prev, next, err := scheduleInstants(schedule, tc.now)
+		require.NoError(t, err)
+		assert.Equal(t, tc.prev, prev, "now %s", tc.now)
+		assert.Equal(t, tc.next, next, "now %s", tc.now)
+	}
+}
+
+func TestScheduleInstantsInvalid(t *testing.T) {
+	t.Parallel()
+	_, _, err := scheduleInstants(&smith_v1.BundleSchedule{DailyAt: []string{"8am"}}, time.Now())
+	assert.EqualError(t, err, `invalid scheduled time of day "8am", expected HH:MM`)
+
+	_, _, err = scheduleInstants(&smith_v1.BundleSchedule{}, time.Now())
+	assert.Error(t, err)
+}
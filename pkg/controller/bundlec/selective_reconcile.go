@@ -0,0 +1,169 @@
+package bundlec
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// changedObject identifies an object which event triggered processing of a Bundle.
+type changedObject struct {
+	schema.GroupKind
+	Name string
+}
+
+type bundleChanges struct {
+	objects map[changedObject]struct{}
+	// specHash is the hash of the Bundle spec and annotations at the time of the last processing.
+	specHash [sha256.Size]byte
+	// lastFullReconcile is the time of the last processing when all resources were processed.
+	lastFullReconcile time.Time
+}
+
+// changeTracker records objects which events triggered processing of Bundles, so that only resources that
+// are affected by the changes are processed.
+type changeTracker struct {
+	fullReconcilePeriod time.Duration
+
+	mx      sync.Mutex
+	bundles map[types.NamespacedName]*bundleChanges
+}
+
+func newChangeTracker(fullReconcilePeriod time.Duration) *changeTracker {
+	return &changeTracker{
+		fullReconcilePeriod: fullReconcilePeriod,
+		bundles:             make(map[types.NamespacedName]*bundleChanges),
+	}
+}
+
+func (t *changeTracker) record(bundle types.NamespacedName, obj changedObject) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	changes := t.bundles[bundle]
+	if changes == nil {
+		changes = &bundleChanges{}
+		t.bundles[bundle] = changes
+	}
+	if changes.objects == nil {
+		changes.objects = make(map[changedObject]struct{})
+	}
+	changes.objects[obj] = struct{}{}
+}
+
+// take returns objects that changed since the Bundle was processed last time and forgets them.
+// Nil is returned if all resources must be processed i.e. if the Bundle was processed for some other reason
+// than a change of an object, if the Bundle itself has changed or if it is time for a periodic full reconcile.
+func (t *changeTracker) take(bundle *smith_v1.Bundle, now time.Time) (map[changedObject]struct{}, error) {
+	hash, err := bundleSpecHash(bundle)
+	if err != nil {
+		return nil, err
+	}
+	key := types.NamespacedName{Namespace: bundle.Namespace, Name: bundle.Name}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	changes := t.bundles[key]
+	if changes == nil {
+		changes = &bundleChanges{}
+		t.bundles[key] = changes
+	}
+	objects := changes.objects
+	changes.objects = nil
+	if len(objects) == 0 || hash != changes.specHash || now.Sub(changes.lastFullReconcile) >= t.fullReconcilePeriod {
+		changes.specHash = hash
+		changes.lastFullReconcile = now
+		return nil, nil
+	}
+	return objects, nil
+}
+
+func (t *changeTracker) forget(bundle types.NamespacedName) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.bundles, bundle)
+}
+
+// bundleSpecHash returns a hash of the Bundle spec and annotations, which affect processing too.
+func bundleSpecHash(bundle *smith_v1.Bundle) ([sha256.Size]byte, error) {
+	data, err := json.Marshal(struct {
+		Annotations map[string]string   `json:"annotations"`
+		Spec        smith_v1.BundleSpec `json:"spec"`
+	}{
+		Annotations: bundle.Annotations,
+		Spec:        bundle.Spec,
+	})
+	if err != nil {
+		return [sha256.Size]byte{}, errors.Wrap(err, "failed to hash Bundle spec")
+	}
+	return sha256.Sum256(data), nil
+}
+
+// changeRecordingHandler records changed objects for Bundles that are enqueued by the wrapped handler.
+type changeRecordingHandler struct {
+	cache.ResourceEventHandler
+	logger      *zap.Logger
+	gk          schema.GroupKind
+	changes     *changeTracker
+	bundleStore BundleStore
+}
+
+func (h *changeRecordingHandler) OnAdd(obj interface{}) {
+	h.recordControlled(obj.(meta_v1.Object))
+	h.ResourceEventHandler.OnAdd(obj)
+}
+
+func (h *changeRecordingHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.recordControlled(oldObj.(meta_v1.Object))
+	h.recordControlled(newObj.(meta_v1.Object))
+	h.ResourceEventHandler.OnUpdate(oldObj, newObj)
+}
+
+func (h *changeRecordingHandler) OnDelete(obj interface{}) {
+	metaObj, ok := obj.(meta_v1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if ok {
+			metaObj, ok = tombstone.Obj.(meta_v1.Object)
+		}
+		if !ok {
+			// Wrapped handler logs the error
+			h.ResourceEventHandler.OnDelete(obj)
+			return
+		}
+	}
+	if !h.recordControlled(metaObj) {
+		// Bundles that want to own the object
+		bundles, err := h.bundleStore.GetBundlesByObject(h.gk, metaObj.GetNamespace(), metaObj.GetName())
+		if err != nil {
+			h.logger.Error("Failed to get Bundles for object", zap.Error(err))
+		}
+		for _, bundle := range bundles {
+			h.changes.record(types.NamespacedName{Namespace: bundle.Namespace, Name: bundle.Name}, changedObject{
+				GroupKind: h.gk,
+				Name:      metaObj.GetName(),
+			})
+		}
+	}
+	h.ResourceEventHandler.OnDelete(obj)
+}
+
+// recordControlled records the change for the Bundle that controls the object, if any.
+func (h *changeRecordingHandler) recordControlled(obj meta_v1.Object) bool /*recorded*/ {
+	ref := meta_v1.GetControllerOf(obj)
+	if ref == nil || ref.APIVersion != smith_v1.BundleGVK.GroupVersion().String() || ref.Kind != smith_v1.BundleGVK.Kind {
+		return false
+	}
+	h.changes.record(types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.Name}, changedObject{
+		GroupKind: h.gk,
+		Name:      obj.GetName(),
+	})
+	return true
+}
@@ -0,0 +1,161 @@
+package bundlec
+
+import (
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/readychecker"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// neverReadyChecker considers all objects not ready.
+type neverReadyChecker struct {
+}
+
+func (neverReadyChecker) IsReady(obj *unstructured.Unstructured, defaultPathValue readychecker.FieldPathValue) (bool, bool, error) {
+	return false, false, nil
+}
+
+func TestChangeTracker(t *testing.T) {
+	t.Parallel()
+	tracker := newChangeTracker(time.Hour)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+		},
+	}
+	key := types.NamespacedName{Namespace: "ns", Name: "bundle1"}
+	cm1 := changedObject{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Name: "cm1"}
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// First processing is a full reconcile
+	tracker.record(key, cm1)
+	changed, err := tracker.take(bundle, now)
+	require.NoError(t, err)
+	assert.Nil(t, changed)
+
+	// Only changed objects
+	tracker.record(key, cm1)
+	changed, err = tracker.take(bundle, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, map[changedObject]struct{}{cm1: {}}, changed)
+
+	// Processing without changes is a full reconcile
+	changed, err = tracker.take(bundle, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, changed)
+
+	// Changed Bundle
+	bundle.Annotations = map[string]string{"a": "b"}
+	tracker.record(key, cm1)
+	changed, err = tracker.take(bundle, now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, changed)
+
+	// Periodic full reconcile
+	tracker.record(key, cm1)
+	changed, err = tracker.take(bundle, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, changed)
+}
+
+func TestOnlyAffectedResourcesAreProcessed(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	newConfigMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+	var objs []runtime.Object
+	var statuses []smith_v1.ResourceStatus
+	for _, name := range []string{"a", "b", "c"} {
+		obj := newConfigMap(name)
+		obj.Namespace = "ns"
+		obj.UID = types.UID(name + "-uid")
+		obj.OwnerReferences = []meta_v1.OwnerReference{
+			{
+				APIVersion: smith_v1.BundleResourceGroupVersion,
+				Kind:       smith_v1.BundleResourceKind,
+				Name:       "bundle1",
+				UID:        "bundle1-uid",
+				Controller: &tr,
+			},
+		}
+		objs = append(objs, obj)
+		statuses = append(statuses, smith_v1.ResourceStatus{
+			Name: smith_v1.ResourceName(name),
+			Conditions: []smith_v1.ResourceCondition{
+				{Type: smith_v1.ResourceReady, Status: smith_v1.ConditionTrue},
+			},
+		})
+	}
+	store, err := newSimulationStore(objs)
+	require.NoError(t, err)
+	st := bundleSyncTask{
+		logger:      logger,
+		smartClient: &simulationSmartClient{result: &ReconcileResult{}},
+		rc:          neverReadyChecker{},
+		store:       store,
+		specCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{Name: "a", Spec: smith_v1.ResourceSpec{Object: newConfigMap("a")}},
+					{Name: "b", RunAfter: []smith_v1.ResourceName{"a"}, Spec: smith_v1.ResourceSpec{Object: newConfigMap("b")}},
+					{Name: "c", Spec: smith_v1.ResourceSpec{Object: newConfigMap("c")}},
+				},
+			},
+			Status: smith_v1.BundleStatus{
+				ResourceStatuses: statuses,
+			},
+		},
+		recorder: &record.FakeRecorder{},
+		changedObjects: map[changedObject]struct{}{
+			{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Name: "a"}: {},
+		},
+	}
+	_, err = st.processNormal()
+	require.NoError(t, err)
+
+	// Changed object and its dependent are processed
+	assert.Equal(t, resourceStatusInProgress{}, st.processedResources["a"].status)
+	assert.Equal(t, resourceStatusDependenciesNotReady{
+		dependencies: []smith_v1.ResourceName{"a"},
+		reasons:      map[smith_v1.ResourceName]string{"a": "in progress"},
+	}, st.processedResources["b"].status)
+	// Unaffected ready resource is skipped
+	assert.Equal(t, resourceStatusReady{}, st.processedResources["c"].status)
+	assert.Equal(t, "c", st.processedResources["c"].actual.GetName())
+}
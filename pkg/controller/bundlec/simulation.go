@@ -0,0 +1,309 @@
+package bundlec
+
+import (
+	"sort"
+	"sync"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	smithClient_v1 "github.com/atlassian/smith/pkg/client/clientset_generated/clientset/typed/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8s_json "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/client-go/dynamic"
+	core_v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// ReconcileResult describes what a reconcile iteration of a Bundle would do.
+type ReconcileResult struct {
+	// Created contains objects that would be created.
+	Created []*unstructured.Unstructured
+	// Updated contains objects that would be updated, as they would be sent to the API server.
+	// Objects that would be patched are recorded as they would be after the patch.
+	Updated []*unstructured.Unstructured
+	// Deleted contains objects that would be deleted.
+	Deleted []smith_v1.ObjectToDelete
+	// Blocked contains resources that would be blocked, mapped to dependencies they are blocked by.
+	Blocked map[smith_v1.ResourceName][]smith_v1.ResourceName
+	// Bundle is the Bundle with the status that would be set.
+	Bundle *smith_v1.Bundle
+	// Retriable and Error is the outcome of the iteration.
+	Retriable bool
+	Error     error
+}
+
+// Simulator runs a reconcile iteration of a Bundle in memory against a supplied set of objects.
+// All decisions are made by the same logic that is used by the controller, but no requests are sent to
+// the API server.
+type Simulator struct {
+	Logger           *zap.Logger
+	Rc               ReadyChecker
+	SpecCheck        SpecCheck
+	PluginContainers map[smith_v1.PluginName]plugin.PluginContainer
+	Scheme           *runtime.Scheme
+	SchemaValidator  SchemaValidator
+	// ResourceQuotaLister is used for Bundles with quotaCheck. Optional.
+	ResourceQuotaLister core_v1listers.ResourceQuotaLister
+	// RESTMapper is used to determine scope of objects. Optional, objects are not checked for being
+	// cluster-scoped if it is not set.
+	RESTMapper meta.RESTMapper
+
+	ValidateObjectNamespace   bool
+	AllowClusterScopedObjects bool
+}
+
+// Simulate runs a reconcile iteration of the Bundle as if the objects were the only objects in the cluster.
+// The Bundle is processed as if it had the finalizers set already. Objects must have kind and apiVersion set.
+// Returned error indicates invalid input; outcome of the iteration is reported in the result.
+func (s *Simulator) Simulate(bundle *smith_v1.Bundle, objects []runtime.Object) (*ReconcileResult, error) {
+	store, err := newSimulationStore(objects)
+	if err != nil {
+		return nil, err
+	}
+	return s.simulate(store, bundle), nil
+}
+
+// simulate runs a reconcile iteration of the Bundle against objects in the store.
+func (s *Simulator) simulate(store Store, bundle *smith_v1.Bundle) *ReconcileResult {
+	bundle = bundle.DeepCopy()
+	if bundle.DeletionTimestamp == nil {
+		bundle.Finalizers = addMissingFinalizers(bundle)
+	}
+	result := &ReconcileResult{
+		Blocked: make(map[smith_v1.ResourceName][]smith_v1.ResourceName),
+	}
+	st := bundleSyncTask{
+		logger:                  s.Logger,
+		bundleClient:            simulationBundlesGetter{},
+		smartClient:             &simulationSmartClient{result: result, store: store, mapper: s.RESTMapper},
+		rc:                      s.Rc,
+		store:                   store,
+		specCheck:               s.SpecCheck,
+		bundle:                  bundle,
+		pluginContainers:        s.PluginContainers,
+		scheme:                  s.Scheme,
+		recorder:                &record.FakeRecorder{},
+		schemaValidator:         s.SchemaValidator,
+		validateObjectNamespace: s.ValidateObjectNamespace,
+		allowClusterScoped:      s.AllowClusterScopedObjects,
+		quotaLister:             s.ResourceQuotaLister,
+	}
+	st.runRecorded(result)
+	return result
+}
+
+// runRecorded runs a reconcile iteration of the Bundle and fills the result. The task must be set up to
+// record create/update/delete operations into the result instead of sending them to the API server.
+func (st *bundleSyncTask) runRecorded(result *ReconcileResult) {
+	var retriable bool
+	var err error
+	if st.bundle.DeletionTimestamp != nil {
+		retriable, err = st.processDeleted()
+	} else {
+		retriable, err = st.processNormal()
+	}
+	result.Retriable, result.Error = st.handleProcessResult(retriable, err)
+	result.Bundle = st.bundle
+
+	// Objects to delete are iterated in random order
+	sort.Slice(result.Deleted, func(i, j int) bool {
+		return result.Deleted[i].Kind < result.Deleted[j].Kind ||
+			result.Deleted[i].Kind == result.Deleted[j].Kind && result.Deleted[i].Name < result.Deleted[j].Name
+	})
+	for resName, resInfo := range st.processedResources {
+		if notReady, ok := resInfo.status.(resourceStatusDependenciesNotReady); ok {
+			dependencies := append([]smith_v1.ResourceName(nil), notReady.dependencies...)
+			sort.Slice(dependencies, func(i, j int) bool {
+				return dependencies[i] < dependencies[j]
+			})
+			result.Blocked[resName] = dependencies
+		}
+	}
+}
+
+// simulationStore is a Store backed by a fixed set of objects.
+type simulationStore struct {
+	objects map[objectRef]*unstructured.Unstructured
+}
+
+func newSimulationStore(objects []runtime.Object) (*simulationStore, error) {
+	s := &simulationStore{
+		objects: make(map[objectRef]*unstructured.Unstructured, len(objects)),
+	}
+	for _, obj := range objects {
+		u, err := util.RuntimeToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		gvk := u.GroupVersionKind()
+		if gvk.Kind == "" || gvk.Version == "" {
+			return nil, errors.Errorf("object %q has empty kind/version", u.GetName())
+		}
+		s.objects[simulationObjectRef(gvk, u.GetNamespace(), u.GetName())] = u
+	}
+	return s, nil
+}
+
+func simulationObjectRef(gvk schema.GroupVersionKind, namespace, name string) objectRef {
+	// Namespace is encoded into the name because objectRef does not have a namespace
+	return objectRef{
+		GroupVersionKind: gvk,
+		Name:             namespace + "/" + name,
+	}
+}
+
+func (s *simulationStore) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists */, error) {
+	obj, ok := s.objects[simulationObjectRef(gvk, namespace, name)]
+	if !ok {
+		return nil, false, nil
+	}
+	return obj.DeepCopy(), true, nil
+}
+
+func (s *simulationStore) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error) {
+	var result []runtime.Object
+	for _, obj := range s.objects {
+		if obj.GetNamespace() != namespace && obj.GetNamespace() != meta_v1.NamespaceNone {
+			continue
+		}
+		ref := meta_v1.GetControllerOf(obj)
+		if ref != nil && ref.UID == uid {
+			result = append(result, obj.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+func (s *simulationStore) AddInformer(schema.GroupVersionKind, cache.SharedIndexInformer) error {
+	return errors.New("informers are not supported in simulation")
+}
+
+func (s *simulationStore) RemoveInformer(schema.GroupVersionKind) bool {
+	return false
+}
+
+// simulationSmartClient records create/update/delete operations instead of sending them to the API server.
+type simulationSmartClient struct {
+	// mx guards result because independent resources may be processed concurrently.
+	mx     sync.Mutex
+	result *ReconcileResult
+	// store has objects that patches are applied to. Optional, patches fail if it is not set.
+	store Store
+	// mapper is used to determine scope of objects. Optional.
+	mapper meta.RESTMapper
+}
+
+func (c *simulationSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return &simulationResourceClient{
+		gvk:         gvk,
+		namespace:   namespace,
+		smartClient: c,
+	}, nil
+}
+
+func (c *simulationSmartClient) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	if c.mapper == nil {
+		return false, errors.New("scope of objects is unknown without a REST mapper")
+	}
+	rm, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
+	}
+	return rm.Scope.Name() != meta.RESTScopeNameRoot, nil
+}
+
+// simulationResourceClient only implements methods used by the controller.
+// Other methods panic because the embedded interface is nil.
+type simulationResourceClient struct {
+	dynamic.ResourceInterface
+	gvk         schema.GroupVersionKind
+	namespace   string
+	smartClient *simulationSmartClient
+}
+
+func (c *simulationResourceClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	c.smartClient.result.Created = append(c.smartClient.result.Created, obj.DeepCopy())
+	return obj.DeepCopy(), nil
+}
+
+func (c *simulationResourceClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	c.smartClient.result.Updated = append(c.smartClient.result.Updated, obj.DeepCopy())
+	return obj.DeepCopy(), nil
+}
+
+// Patch applies the patch to the object from the store and records the result as an update.
+func (c *simulationResourceClient) Patch(name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error) {
+	if c.smartClient.store == nil {
+		return nil, errors.New("patches are not supported without a store")
+	}
+	actual, exists, err := c.smartClient.store.Get(c.gvk, c.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, api_errors.NewNotFound(schema.GroupResource{Group: c.gvk.Group, Resource: c.gvk.Kind}, name)
+	}
+	actualUnstr, err := util.RuntimeToUnstructured(actual)
+	if err != nil {
+		return nil, err
+	}
+	// Typed objects from informers do not have kind/apiVersion set
+	actualUnstr.SetGroupVersionKind(c.gvk)
+	var patch map[string]interface{}
+	// Unlike encoding/json this keeps integers as int64, as they are in unstructured objects
+	if err = k8s_json.Unmarshal(data, &patch); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal patch")
+	}
+	patched, err := applyObjectPatch(actualUnstr, pt, patch)
+	if err != nil {
+		return nil, err
+	}
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	c.smartClient.result.Updated = append(c.smartClient.result.Updated, patched.DeepCopy())
+	return patched, nil
+}
+
+func (c *simulationResourceClient) Delete(name string, options *meta_v1.DeleteOptions) error {
+	c.smartClient.mx.Lock()
+	defer c.smartClient.mx.Unlock()
+	c.smartClient.result.Deleted = append(c.smartClient.result.Deleted, smith_v1.ObjectToDelete{
+		Group:   c.gvk.Group,
+		Version: c.gvk.Version,
+		Kind:    c.gvk.Kind,
+		Name:    name,
+	})
+	return nil
+}
+
+// simulationBundlesGetter does not persist Bundle updates.
+type simulationBundlesGetter struct {
+}
+
+func (simulationBundlesGetter) Bundles(namespace string) smithClient_v1.BundleInterface {
+	return simulationBundleClient{}
+}
+
+// simulationBundleClient only implements methods used by the controller.
+// Other methods panic because the embedded interface is nil.
+type simulationBundleClient struct {
+	smithClient_v1.BundleInterface
+}
+
+func (simulationBundleClient) Update(bundle *smith_v1.Bundle) (*smith_v1.Bundle, error) {
+	return bundle.DeepCopy(), nil
+}
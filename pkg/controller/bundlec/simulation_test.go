@@ -0,0 +1,1307 @@
+package bundlec
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/readychecker"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// configMapsReadyChecker considers only ConfigMaps ready.
+type configMapsReadyChecker struct {
+}
+
+func (configMapsReadyChecker) IsReady(obj *unstructured.Unstructured, defaultPathValue readychecker.FieldPathValue) (bool, bool, error) {
+	return obj.GetKind() == "ConfigMap", false, nil
+}
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "deployment",
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "d1",
+							},
+						},
+					},
+				},
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+				{
+					Name: "blocked",
+					References: []smith_v1.Reference{
+						{
+							Resource: "deployment",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm2",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	removed := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "removed",
+			Namespace: "ns",
+			UID:       "removed-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	result, err := s.Simulate(bundle, []runtime.Object{removed})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	var created []string
+	for _, obj := range result.Created {
+		created = append(created, obj.GetName())
+	}
+	sort.Strings(created)
+	assert.Equal(t, []string{"cm1", "d1"}, created)
+	assert.Empty(t, result.Updated)
+	assert.Empty(t, result.Deleted, "nothing should be deleted while Bundle is not ready")
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{
+		"blocked": {"deployment"},
+	}, result.Blocked)
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{Version: "v1", Kind: "ConfigMap", Name: "removed"},
+	}, result.Bundle.Status.ObjectsToDelete)
+	assert.EqualValues(t, 1, result.Bundle.Status.ReadyResources)
+	assert.EqualValues(t, 3, result.Bundle.Status.TotalResources)
+	assert.Equal(t, []smith_v1.ResourceName{"deployment", "config", "blocked"}, result.Bundle.Status.ResourceOrder)
+	assert.Empty(t, bundle.Status.Conditions, "input Bundle must not be mutated")
+}
+
+// notReadyPlugin produces ConfigMaps and considers them not ready.
+type notReadyPlugin struct {
+}
+
+func (notReadyPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: "notReady",
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (notReadyPlugin) Process(spec map[string]interface{}, context *plugin.Context) (*plugin.ProcessResult, error) {
+	return &plugin.ProcessResult{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+		},
+	}, nil
+}
+
+func (notReadyPlugin) IsReady(obj *unstructured.Unstructured) (bool, bool, error) {
+	return false, false, nil
+}
+
+func TestPluginReadyChecker(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return notReadyPlugin{}, nil
+	})
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "notReady",
+							ObjectName: "cm1",
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"notReady": pluginContainer,
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Created, 1)
+
+	// Generic check considers ConfigMaps ready but the plugin does not
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, inProgressCond := resStatus.GetCondition(smith_v1.ResourceInProgress)
+	require.NotNil(t, inProgressCond)
+	assert.Equal(t, smith_v1.ConditionTrue, inProgressCond.Status)
+}
+
+// panickingReadyCheckPlugin produces ConfigMaps and panics when checking their readiness.
+type panickingReadyCheckPlugin struct {
+	notReadyPlugin
+}
+
+func (panickingReadyCheckPlugin) IsReady(obj *unstructured.Unstructured) (bool, bool, error) {
+	panic("boom")
+}
+
+func TestPluginReadyCheckerPanic(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return panickingReadyCheckPlugin{}, nil
+	})
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "notReady",
+							ObjectName: "cm1",
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"notReady": pluginContainer,
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.Error(t, result.Error)
+
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, "readiness check failed: plugin readiness check failed: panic: boom", errorCond.Message)
+	require.Len(t, result.Bundle.Status.PluginStatuses, 1)
+	assert.Equal(t, smith_v1.PluginStatusReadinessCheckError, result.Bundle.Status.PluginStatuses[0].Status)
+	assert.EqualValues(t, 1, result.Bundle.Status.PluginStatuses[0].FailedResources)
+}
+
+func TestPluginStatusesCountResources(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return notReadyPlugin{}, nil
+	})
+	require.NoError(t, err)
+	st := bundleSyncTask{
+		bundle: &smith_v1.Bundle{
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{Name: "a", Spec: smith_v1.ResourceSpec{Plugin: &smith_v1.PluginSpec{Name: "notReady"}}},
+					{Name: "b", Spec: smith_v1.ResourceSpec{Plugin: &smith_v1.PluginSpec{Name: "missing"}}},
+					{Name: "c", Spec: smith_v1.ResourceSpec{Plugin: &smith_v1.PluginSpec{Name: "notReady"}}},
+					{Name: "d", Spec: smith_v1.ResourceSpec{Plugin: &smith_v1.PluginSpec{Name: "notReady"}}},
+				},
+			},
+		},
+		pluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"notReady": pluginContainer,
+		},
+		processedResources: map[smith_v1.ResourceName]*resourceInfo{
+			"a": {status: resourceStatusReady{}},
+			"b": {status: resourceStatusError{err: errors.New("no such plugin")}},
+			"c": {status: resourceStatusError{err: errors.New("boom")}},
+		},
+	}
+	statuses := st.pluginStatuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, smith_v1.PluginStatus{
+		Name:            "notReady",
+		Version:         "v1",
+		Kind:            "ConfigMap",
+		Status:          smith_v1.PluginStatusOk,
+		Resources:       3,
+		FailedResources: 1,
+	}, statuses[0])
+	assert.Equal(t, "Ok 2/3", statuses[0].String())
+	assert.Equal(t, smith_v1.PluginStatus{
+		Name:            "missing",
+		Status:          smith_v1.PluginStatusNoSuchPlugin,
+		Resources:       1,
+		FailedResources: 1,
+	}, statuses[1])
+}
+
+func TestCreateOnlyResourceIsNotUpdated(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	newConfigMap := func(data string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cm1",
+				Namespace: "ns",
+			},
+			Data: map[string]string{
+				"key": data,
+			},
+		}
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:       "config",
+					CreateOnly: true,
+					Spec: smith_v1.ResourceSpec{
+						Object: newConfigMap("seed"),
+					},
+				},
+			},
+		},
+	}
+	edited := newConfigMap("edited")
+	edited.UID = "cm1-uid"
+	edited.OwnerReferences = []meta_v1.OwnerReference{
+		{
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+			Kind:       smith_v1.BundleResourceKind,
+			Name:       "bundle1",
+			UID:        "bundle1-uid",
+			Controller: &tr,
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+
+	// Object is created if it does not exist
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Len(t, result.Created, 1)
+
+	// Existing object is not updated
+	result, err = s.Simulate(bundle, []runtime.Object{edited})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Updated)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	assert.Equal(t, smith_v1.ResourceActionUnchanged, resStatus.LastAction)
+	_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonCreateOnly, readyCond.Reason)
+
+	// Existing object is not updated with CreateOnly update strategy either, but its readiness is checked
+	bundle.Spec.Resources[0].CreateOnly = false
+	bundle.Spec.Resources[0].UpdateStrategy = smith_v1.UpdateStrategyCreateOnly
+	result, err = s.Simulate(bundle, []runtime.Object{edited})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Updated)
+	_, resStatus = result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, readyCond = resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+	assert.Empty(t, readyCond.Reason)
+}
+
+func TestAdoptResource(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+		},
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"key": "value",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	resourceError := func(bundle *smith_v1.Bundle) *smith_v1.ResourceCondition {
+		_, resStatus := bundle.Status.GetResourceStatus("config")
+		require.NotNil(t, resStatus)
+		_, errCond := resStatus.GetCondition(smith_v1.ResourceError)
+		require.NotNil(t, errCond)
+		return errCond
+	}
+
+	// Object without a controller is not adopted by default
+	result, err := s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	assert.Empty(t, result.Updated)
+	assert.Equal(t, smith_v1.ConditionTrue, resourceError(result.Bundle).Status)
+
+	// Create-only resources cannot adopt objects
+	bundle.Spec.Resources[0].Adopt = true
+	bundle.Spec.Resources[0].CreateOnly = true
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	assert.Empty(t, result.Updated)
+	assert.Equal(t, smith_v1.ConditionTrue, resourceError(result.Bundle).Status)
+
+	// Object is adopted
+	bundle.Spec.Resources[0].CreateOnly = false
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Updated, 1)
+	assert.Equal(t, smith_v1.ConditionFalse, resourceError(result.Bundle).Status)
+	ref := meta_v1.GetControllerOf(result.Updated[0])
+	require.NotNil(t, ref)
+	assert.EqualValues(t, "bundle1-uid", ref.UID)
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	assert.Equal(t, smith_v1.ResourceActionAdopted, resStatus.LastAction)
+
+	// Adopted object is controlled by the Bundle
+	result, err = s.Simulate(result.Bundle, []runtime.Object{result.Updated[0]})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Updated)
+	_, resStatus = result.Bundle.Status.GetResourceStatus("config")
+	assert.Equal(t, smith_v1.ResourceActionUnchanged, resStatus.LastAction)
+}
+
+func TestMinReadySeconds(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	tr := true
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:            "config",
+					MinReadySeconds: 60,
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	existing := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cm1",
+			Namespace: "ns",
+			UID:       "cm1-uid",
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: smith_v1.BundleResourceGroupVersion,
+					Kind:       smith_v1.BundleResourceKind,
+					Name:       "bundle1",
+					UID:        "bundle1-uid",
+					Controller: &tr,
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	readyCondition := func(result *ReconcileResult) *smith_v1.ResourceCondition {
+		_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+		require.NotNil(t, resStatus)
+		_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+		require.NotNil(t, readyCond)
+		return readyCond
+	}
+
+	// Object has just become ready
+	result, err := s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	readyCond := readyCondition(result)
+	assert.Equal(t, smith_v1.ConditionUnknown, readyCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonWaitingForMinReadySeconds, readyCond.Reason)
+
+	// Object has not been ready for long enough
+	result, err = s.Simulate(result.Bundle.DeepCopy(), []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	readyCond = readyCondition(result)
+	assert.Equal(t, smith_v1.ConditionUnknown, readyCond.Status)
+
+	// Object has been ready for long enough
+	readyCond.LastTransitionTime = meta_v1.NewTime(readyCond.LastTransitionTime.Add(-time.Minute))
+	result, err = s.Simulate(result.Bundle.DeepCopy(), []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	readyCond = readyCondition(result)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+	assert.Empty(t, readyCond.Reason)
+
+	// Resource was Unknown for another reason for long enough but the object has just become ready
+	unknownSince := meta_v1.NewTime(time.Now().Add(-time.Hour))
+	bundle.Status.ResourceStatuses = []smith_v1.ResourceStatus{
+		{
+			Name: "config",
+			Conditions: []smith_v1.ResourceCondition{
+				{
+					Type:               smith_v1.ResourceReady,
+					Status:             smith_v1.ConditionUnknown,
+					LastTransitionTime: unknownSince,
+					LastUpdateTime:     unknownSince,
+				},
+			},
+		},
+	}
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	readyCond = readyCondition(result)
+	assert.Equal(t, smith_v1.ConditionUnknown, readyCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonWaitingForMinReadySeconds, readyCond.Reason)
+	assert.True(t, readyCond.LastTransitionTime.After(unknownSince.Time))
+
+	// Waiting continues
+	result, err = s.Simulate(result.Bundle.DeepCopy(), []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	readyCond = readyCondition(result)
+	assert.Equal(t, smith_v1.ConditionUnknown, readyCond.Status)
+	assert.Equal(t, smith_v1.ResourceReasonWaitingForMinReadySeconds, readyCond.Reason)
+}
+
+type additionalObjectsPlugin struct {
+}
+
+func (additionalObjectsPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: "additional",
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (additionalObjectsPlugin) Process(spec map[string]interface{}, context *plugin.Context) (*plugin.ProcessResult, error) {
+	return &plugin.ProcessResult{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+		},
+		AdditionalObjects: []runtime.Object{
+			&core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "cm1-extra",
+				},
+			},
+		},
+	}, nil
+}
+
+func TestAppliedSpecHash(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       "bundle1",
+			Namespace:  "ns",
+			UID:        "bundle1-uid",
+			Generation: 1,
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1",
+							},
+							Data: map[string]string{
+								"key": "value1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	appliedSpec := func(bundle *smith_v1.Bundle) (string, int64) {
+		_, resStatus := bundle.Status.GetResourceStatus("config")
+		require.NotNil(t, resStatus)
+		return resStatus.AppliedSpecHash, resStatus.AppliedGeneration
+	}
+
+	// Hash of the created object is recorded
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Created, 1)
+	createdHash, generation := appliedSpec(result.Bundle)
+	assert.NotEmpty(t, createdHash)
+	assert.EqualValues(t, 1, generation)
+
+	// Hash is kept while the object is unchanged
+	existing := result.Created[0]
+	existing.SetUID("cm1-uid")
+	bundle = result.Bundle
+	bundle.Generation = 2
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Updated)
+	hash, generation := appliedSpec(result.Bundle)
+	assert.Equal(t, createdHash, hash)
+	assert.EqualValues(t, 1, generation)
+
+	// Hash of the updated object is recorded
+	bundle = result.Bundle
+	bundle.Generation = 3
+	bundle.Spec.Resources[0].Spec.Object.(*core_v1.ConfigMap).Data["key"] = "value2"
+	result, err = s.Simulate(bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Updated, 1)
+	hash, generation = appliedSpec(result.Bundle)
+	assert.NotEmpty(t, hash)
+	assert.NotEqual(t, createdHash, hash)
+	assert.EqualValues(t, 3, generation)
+}
+
+func TestMaxResourcesPerSync(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	configMap := func(name string) smith_v1.Resource {
+		return smith_v1.Resource{
+			Name: smith_v1.ResourceName(name),
+			Spec: smith_v1.ResourceSpec{
+				Object: &core_v1.ConfigMap{
+					TypeMeta: meta_v1.TypeMeta{
+						Kind:       "ConfigMap",
+						APIVersion: core_v1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name: name,
+					},
+				},
+			},
+		}
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				configMap("cm1"),
+				configMap("cm2"),
+				configMap("cm3"),
+			},
+			MaxResourcesPerSync: 1,
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+	}
+	assertWaiting := func(t *testing.T, bundle *smith_v1.Bundle, resName smith_v1.ResourceName) {
+		_, resStatus := bundle.Status.GetResourceStatus(resName)
+		require.NotNil(t, resStatus)
+		_, inProgressCond := resStatus.GetCondition(smith_v1.ResourceInProgress)
+		require.NotNil(t, inProgressCond)
+		assert.Equal(t, smith_v1.ConditionTrue, inProgressCond.Status)
+		assert.Equal(t, smith_v1.ResourceReasonWaitingForRollout, inProgressCond.Reason)
+	}
+
+	// Only the first resource is rolled out
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm1", result.Created[0].GetName())
+	assertWaiting(t, result.Bundle, "cm2")
+	assertWaiting(t, result.Bundle, "cm3")
+	_, readyCond := result.Bundle.GetCondition(smith_v1.BundleReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionFalse, readyCond.Status)
+
+	// Once it is ready, the next one is rolled out
+	existing := result.Created[0]
+	existing.SetUID("cm1-uid")
+	result, err = s.Simulate(result.Bundle, []runtime.Object{existing})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm2", result.Created[0].GetName())
+	assertWaiting(t, result.Bundle, "cm3")
+}
+
+func TestPluginAdditionalObjects(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return additionalObjectsPlugin{}, nil
+	})
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "additional",
+							ObjectName: "cm1",
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"additional": pluginContainer,
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	require.Len(t, result.Created, 2)
+	names := []string{result.Created[0].GetName(), result.Created[1].GetName()}
+	sort.Strings(names)
+	assert.Equal(t, []string{"cm1", "cm1-extra"}, names)
+	for _, obj := range result.Created {
+		if obj.GetName() == "cm1-extra" {
+			assert.Equal(t, "config", obj.GetAnnotations()[smith.PluginResourceAnnotation])
+			assert.True(t, meta_v1.IsControlledBy(obj, bundle))
+		}
+	}
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+	require.NotNil(t, readyCond)
+	assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+	require.Len(t, result.Bundle.Status.PluginStatuses, 1)
+	assert.EqualValues(t, 2, result.Bundle.Status.PluginStatuses[0].Objects)
+
+	// Existing additional objects of the resource are not deleted, objects the plugin does not produce anymore are
+	stale := result.Created[1].DeepCopy()
+	stale.SetName("cm1-stale")
+	stale.SetAnnotations(map[string]string{
+		smith.PluginResourceAnnotation: "config",
+	})
+	result, err = s.Simulate(bundle, []runtime.Object{result.Created[0], result.Created[1], stale})
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Empty(t, result.Created)
+	assert.Equal(t, []smith_v1.ObjectToDelete{
+		{Version: "v1", Kind: "ConfigMap", Name: "cm1-stale"},
+	}, result.Deleted)
+}
+
+func TestPluginAdditionalObjectCollision(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return additionalObjectsPlugin{}, nil
+	})
+	require.NoError(t, err)
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "extra",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "cm1-extra",
+							},
+						},
+					},
+				},
+				{
+					Name: "config",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "additional",
+							ObjectName: "cm1",
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"additional": pluginContainer,
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.Error(t, result.Error)
+
+	// Object of the other resource is not overwritten by the plugin resource
+	require.Len(t, result.Created, 1)
+	assert.Equal(t, "cm1-extra", result.Created[0].GetName())
+	_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+	require.NotNil(t, resStatus)
+	_, errorCond := resStatus.GetCondition(smith_v1.ResourceError)
+	require.NotNil(t, errorCond)
+	assert.Equal(t, smith_v1.ConditionTrue, errorCond.Status)
+	assert.Equal(t, smith_v1.ErrorCodeDuplicateResource, errorCond.Code)
+	assert.Equal(t, `plugin additional object 0 is the same object ConfigMap "cm1-extra" as an object of resource "extra"`, errorCond.Message)
+}
+
+// dependencyNamesPlugin produces ConfigMaps with names of dependencies that were passed to the plugin.
+type dependencyNamesPlugin struct {
+}
+
+func (dependencyNamesPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: "dependencyNames",
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (dependencyNamesPlugin) Process(spec map[string]interface{}, context *plugin.Context) (*plugin.ProcessResult, error) {
+	names := make([]string, 0, len(context.Dependencies))
+	for name := range context.Dependencies {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return &plugin.ProcessResult{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			Data: map[string]string{
+				"dependencies": strings.Join(names, ","),
+			},
+		},
+	}, nil
+}
+
+func TestOptionalReferenceToNotReadyDependency(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return dependencyNamesPlugin{}, nil
+	})
+	require.NoError(t, err)
+
+	dependencies := map[string]smith_v1.Resource{
+		// Blocked by the Deployment that is never ready
+		"blocked": {
+			Name: "dependency",
+			References: []smith_v1.Reference{
+				{
+					Resource: "deployment",
+				},
+			},
+			Spec: smith_v1.ResourceSpec{
+				Object: &core_v1.ConfigMap{
+					TypeMeta: meta_v1.TypeMeta{
+						Kind:       "ConfigMap",
+						APIVersion: core_v1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name: "cm2",
+					},
+				},
+			},
+		},
+		"error": {
+			Name:           "dependency",
+			UpdateStrategy: "Sometimes",
+			Spec: smith_v1.ResourceSpec{
+				Object: &core_v1.ConfigMap{
+					TypeMeta: meta_v1.TypeMeta{
+						Kind:       "ConfigMap",
+						APIVersion: core_v1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name: "cm2",
+					},
+				},
+			},
+		},
+	}
+	reference := smith_v1.Reference{
+		Name:     "value",
+		Resource: "dependency",
+		Path:     "data.key",
+		Optional: true,
+	}
+	dependents := map[string]smith_v1.Resource{
+		"object": {
+			Name:       "config",
+			References: []smith_v1.Reference{reference},
+			Spec: smith_v1.ResourceSpec{
+				Object: &core_v1.ConfigMap{
+					TypeMeta: meta_v1.TypeMeta{
+						Kind:       "ConfigMap",
+						APIVersion: core_v1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name: "cm1",
+						Annotations: map[string]string{
+							"value": "!{value}",
+						},
+					},
+				},
+			},
+		},
+		"plugin": {
+			Name:       "config",
+			References: []smith_v1.Reference{reference},
+			Spec: smith_v1.ResourceSpec{
+				Plugin: &smith_v1.PluginSpec{
+					Name:       "dependencyNames",
+					ObjectName: "cm1",
+					Spec: map[string]interface{}{
+						"value": "!{value}",
+					},
+				},
+			},
+		},
+	}
+	for dependencyName, dependency := range dependencies {
+		for dependentName, dependent := range dependents {
+			dependency, dependent := dependency, dependent
+			t.Run(dependencyName+"-"+dependentName, func(t *testing.T) {
+				t.Parallel()
+				logger := zaptest.NewLogger(t)
+				defer logger.Sync()
+
+				bundle := &smith_v1.Bundle{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      "bundle1",
+						Namespace: "ns",
+						UID:       "bundle1-uid",
+					},
+					Spec: smith_v1.BundleSpec{
+						Resources: []smith_v1.Resource{
+							{
+								Name: "deployment",
+								Spec: smith_v1.ResourceSpec{
+									Object: &apps_v1.Deployment{
+										TypeMeta: meta_v1.TypeMeta{
+											Kind:       "Deployment",
+											APIVersion: apps_v1.SchemeGroupVersion.String(),
+										},
+										ObjectMeta: meta_v1.ObjectMeta{
+											Name: "d1",
+										},
+									},
+								},
+							},
+							*dependency.DeepCopy(),
+							*dependent.DeepCopy(),
+						},
+					},
+				}
+				s := Simulator{
+					Logger: logger,
+					Rc:     configMapsReadyChecker{},
+					SpecCheck: &speccheck.SpecCheck{
+						Logger:  logger,
+						Cleaner: cleanup.New(),
+					},
+					PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+						"dependencyNames": pluginContainer,
+					},
+				}
+
+				result, err := s.Simulate(bundle, nil)
+				require.NoError(t, err)
+
+				// Dependent is processed without the object of the dependency
+				var cm1 *unstructured.Unstructured
+				for _, obj := range result.Created {
+					if obj.GetName() == "cm1" {
+						cm1 = obj
+					}
+				}
+				require.NotNil(t, cm1)
+				require.Len(t, cm1.GetOwnerReferences(), 1)
+				assert.Equal(t, smith_v1.BundleResourceKind, cm1.GetOwnerReferences()[0].Kind)
+				if dependent.Spec.Plugin != nil {
+					deps, _, err := unstructured.NestedString(cm1.Object, "data", "dependencies")
+					require.NoError(t, err)
+					assert.Empty(t, deps)
+				}
+				_, resStatus := result.Bundle.Status.GetResourceStatus("config")
+				require.NotNil(t, resStatus)
+				_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+				require.NotNil(t, readyCond)
+				assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status)
+			})
+		}
+	}
+}
+
+func TestReferencesToDisabledResource(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	pluginContainer, err := plugin.NewPluginContainer(func() (plugin.Plugin, error) {
+		return dependencyNamesPlugin{}, nil
+	})
+	require.NoError(t, err)
+	configMap := func(name string) *core_v1.ConfigMap {
+		return &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					"value": "!{value}",
+				},
+			},
+		}
+	}
+	bundle := &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle1",
+			Namespace: "ns",
+			UID:       "bundle1-uid",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name:     "a",
+					RunAfter: []smith_v1.ResourceName{"b"},
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm-a"),
+					},
+				},
+				{
+					Name:     "b",
+					Disabled: true,
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm-b"),
+					},
+				},
+				{
+					Name: "c",
+					References: []smith_v1.Reference{
+						{
+							Name:     "value",
+							Resource: "b",
+							Path:     "metadata.name",
+							Optional: true,
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Object: configMap("cm-c"),
+					},
+				},
+				{
+					Name: "d",
+					References: []smith_v1.Reference{
+						{
+							Name:     "value",
+							Resource: "b",
+							Path:     "metadata.name",
+							Default:  "fallback",
+						},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "dependencyNames",
+							ObjectName: "cm-d",
+							Spec: map[string]interface{}{
+								"value": "!{value}",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := Simulator{
+		Logger: logger,
+		Rc:     configMapsReadyChecker{},
+		SpecCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		PluginContainers: map[smith_v1.PluginName]plugin.PluginContainer{
+			"dependencyNames": pluginContainer,
+		},
+	}
+
+	result, err := s.Simulate(bundle, nil)
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+
+	// Optional and defaulted references to the disabled resource do not block, other dependencies do
+	assert.Equal(t, map[smith_v1.ResourceName][]smith_v1.ResourceName{
+		"a": {"b"},
+	}, result.Blocked)
+	created := make(map[string]*unstructured.Unstructured, len(result.Created))
+	for _, obj := range result.Created {
+		created[obj.GetName()] = obj
+	}
+	require.Len(t, created, 2)
+	for _, name := range []string{"cm-c", "cm-d"} {
+		obj := created[name]
+		require.NotNil(t, obj, name)
+		require.Len(t, obj.GetOwnerReferences(), 1, name)
+		assert.Equal(t, smith_v1.BundleResourceKind, obj.GetOwnerReferences()[0].Kind, name)
+	}
+	deps, _, err := unstructured.NestedString(created["cm-d"].Object, "data", "dependencies")
+	require.NoError(t, err)
+	assert.Empty(t, deps)
+	for _, resName := range []smith_v1.ResourceName{"c", "d"} {
+		_, resStatus := result.Bundle.Status.GetResourceStatus(resName)
+		require.NotNil(t, resStatus, resName)
+		_, readyCond := resStatus.GetCondition(smith_v1.ResourceReady)
+		require.NotNil(t, readyCond, resName)
+		assert.Equal(t, smith_v1.ConditionTrue, readyCond.Status, resName)
+	}
+}
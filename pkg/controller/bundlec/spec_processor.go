@@ -1,14 +1,20 @@
 package bundlec
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"unicode/utf8"
 
+	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/resources"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
@@ -19,6 +25,23 @@ var (
 
 type specProcessor struct {
 	variables map[smith_v1.ReferenceName]interface{}
+	// defaulted contains names of references that were resolved to their default values.
+	defaulted []smith_v1.ReferenceName
+}
+
+// referenceNotResolvedError occurs when the referenced resource is not ready or
+// the referenced field does not exist. Default value of the reference is used in that case, if set.
+type referenceNotResolvedError struct {
+	message string
+}
+
+func (e *referenceNotResolvedError) Error() string {
+	return e.message
+}
+
+// isBlockingReference checks if the referenced resource must be ready before the dependent resource can be processed.
+func isBlockingReference(reference smith_v1.Reference) bool {
+	return reference.Default == nil && !reference.Optional
 }
 
 // noExampleError occurs when we try to process the spec with examples rather
@@ -48,8 +71,16 @@ func isNoExampleError(err error) bool {
 }
 
 func newSpec(resources map[smith_v1.ResourceName]*resourceInfo, references []smith_v1.Reference) (*specProcessor, error) {
-	variables, err := resolveAllReferences(references, func(reference smith_v1.Reference) (interface{}, error) {
-		return resolveReference(resources, reference)
+	var defaulted []smith_v1.ReferenceName
+	variables, err := resolveAllReferences(references, func(_ *referenceResolver, reference smith_v1.Reference) (interface{}, error) {
+		value, err := resolveReference(resources, reference)
+		if err != nil && !isBlockingReference(reference) {
+			if _, ok := errors.Cause(err).(*referenceNotResolvedError); ok {
+				defaulted = append(defaulted, reference.Name)
+				return reference.Default, nil
+			}
+		}
+		return value, err
 	})
 
 	if err != nil {
@@ -58,11 +89,12 @@ func newSpec(resources map[smith_v1.ResourceName]*resourceInfo, references []smi
 
 	return &specProcessor{
 		variables: variables,
+		defaulted: defaulted,
 	}, nil
 }
 
 func newExamplesSpec(references []smith_v1.Reference) (*specProcessor, error) {
-	variables, err := resolveAllReferences(references, func(reference smith_v1.Reference) (interface{}, error) {
+	variables, err := resolveAllReferences(references, func(_ *referenceResolver, reference smith_v1.Reference) (interface{}, error) {
 		if reference.Example == nil {
 			return nil, errors.WithStack(&noExampleError{referenceName: reference.Name})
 		}
@@ -78,11 +110,66 @@ func newExamplesSpec(references []smith_v1.Reference) (*specProcessor, error) {
 	}, nil
 }
 
+// referenceCycleError occurs when value of a reference depends on itself via values of other references.
+type referenceCycleError struct {
+	cycle []smith_v1.ReferenceName
+}
+
+func (e *referenceCycleError) Error() string {
+	names := make([]string, 0, len(e.cycle))
+	for _, name := range e.cycle {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	return fmt.Sprintf("reference cycle detected: %s", strings.Join(names, " -> "))
+}
+
+// referenceResolver resolves each reference at most once and detects cycles between references
+// whose values depend on values of other references.
+type referenceResolver struct {
+	references map[smith_v1.ReferenceName]smith_v1.Reference
+	resolve    func(r *referenceResolver, reference smith_v1.Reference) (interface{}, error)
+	resolved   map[smith_v1.ReferenceName]interface{}
+	// resolving is the stack of references that are being resolved.
+	resolving []smith_v1.ReferenceName
+}
+
+func (r *referenceResolver) resolveReference(reference smith_v1.Reference) (interface{}, error) {
+	if value, ok := r.resolved[reference.Name]; ok {
+		return value, nil
+	}
+	for i, name := range r.resolving {
+		if name == reference.Name {
+			cycle := make([]smith_v1.ReferenceName, 0, len(r.resolving)-i+1)
+			cycle = append(cycle, r.resolving[i:]...)
+			cycle = append(cycle, reference.Name)
+			return nil, errors.WithStack(&referenceCycleError{cycle: cycle})
+		}
+	}
+	r.resolving = append(r.resolving, reference.Name)
+	value, err := r.resolve(r, reference)
+	r.resolving = r.resolving[:len(r.resolving)-1]
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[reference.Name] = value
+	return value, nil
+}
+
 func resolveAllReferences(
 	references []smith_v1.Reference,
-	resolveReference func(reference smith_v1.Reference) (interface{}, error),
+	resolveReference func(r *referenceResolver, reference smith_v1.Reference) (interface{}, error),
 ) (map[smith_v1.ReferenceName]interface{}, error) {
 
+	r := &referenceResolver{
+		references: make(map[smith_v1.ReferenceName]smith_v1.Reference, len(references)),
+		resolve:    resolveReference,
+		resolved:   make(map[smith_v1.ReferenceName]interface{}, len(references)),
+	}
+	for _, reference := range references {
+		if reference.Name != "" {
+			r.references[reference.Name] = reference
+		}
+	}
 	refs := make(map[smith_v1.ReferenceName]interface{}, len(references))
 	var errs []error
 	for _, reference := range references {
@@ -92,8 +179,12 @@ func resolveAllReferences(
 			continue
 		}
 
-		resolvedRef, err := resolveReference(reference)
+		resolvedRef, err := r.resolveReference(reference)
 		if err != nil {
+			if _, ok := errors.Cause(err).(*referenceCycleError); ok {
+				// Cycle is reported once, other references in the cycle would report the same cycle
+				return nil, err
+			}
 			errs = append(errs, err)
 			continue
 		}
@@ -169,6 +260,11 @@ func resolveReference(resInfos map[smith_v1.ResourceName]*resourceInfo, referenc
 	if resInfo == nil {
 		return nil, errors.Errorf("internal dependency resolution error - resource referenced by %q not found in Bundle: %s", reference.Name, reference.Resource)
 	}
+	if !resInfo.isReady() {
+		return nil, errors.WithStack(&referenceNotResolvedError{
+			message: fmt.Sprintf("resource %q referenced by %q is not ready", reference.Resource, reference.Name),
+		})
+	}
 
 	var objToTraverse interface{}
 	switch reference.Modifier {
@@ -179,6 +275,10 @@ func resolveReference(resInfos map[smith_v1.ResourceName]*resourceInfo, referenc
 			return nil, errors.Errorf("%q requested, but %q is not a ServiceBinding", smith_v1.ReferenceModifierBindSecret, reference.Resource)
 		}
 		objToTraverse = resInfo.serviceBindingSecret
+	case smith_v1.ReferenceModifierHash:
+		return hashReference(resInfo, reference)
+	case smith_v1.ReferenceModifierLabel, smith_v1.ReferenceModifierAnnotation:
+		return metadataReference(resInfo, reference)
 	default:
 		return nil, errors.Errorf("reference modifier %q not understood for %q", reference.Modifier, reference.Resource)
 	}
@@ -191,7 +291,9 @@ func resolveReference(resInfos map[smith_v1.ResourceName]*resourceInfo, referenc
 		return nil, errors.Wrapf(err, "failed to process reference %q", reference.Name)
 	}
 	if fieldValue == nil {
-		return nil, errors.Errorf("field not found: %q", reference.Path)
+		return nil, errors.WithStack(&referenceNotResolvedError{
+			message: fmt.Sprintf("field not found: %q", reference.Path),
+		})
 	}
 
 	if byteFieldValue, ok := fieldValue.([]byte); ok {
@@ -205,3 +307,133 @@ func resolveReference(resInfos map[smith_v1.ResourceName]*resourceInfo, referenc
 
 	return fieldValue, nil
 }
+
+// hashReference returns a hash of the content of the referenced resource.
+// If the reference has a path then only the value at that path is hashed, otherwise the whole object
+// except metadata and status is hashed.
+func hashReference(resInfo *resourceInfo, reference smith_v1.Reference) (string, error) {
+	if !resInfo.isReady() || resInfo.actual == nil {
+		return "", errors.WithStack(&referenceNotResolvedError{
+			message: fmt.Sprintf("resource %q referenced by %q is not ready", reference.Resource, reference.Name),
+		})
+	}
+	var value interface{}
+	if reference.Path == "" {
+		content := make(map[string]interface{}, len(resInfo.actual.Object))
+		for field, fieldValue := range resInfo.actual.Object {
+			switch field {
+			case "metadata", "status":
+				continue
+			}
+			content[field] = fieldValue
+		}
+		value = content
+	} else {
+		jsonPath := fmt.Sprintf("{$.%s}", reference.Path)
+		fieldValue, err := resources.GetJsonPathValue(resInfo.actual.Object, jsonPath, false)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to process reference %q", reference.Name)
+		}
+		if fieldValue == nil {
+			return "", errors.WithStack(&referenceNotResolvedError{
+				message: fmt.Sprintf("field not found: %q", reference.Path),
+			})
+		}
+		value = fieldValue
+	}
+	return hashValue(value, reference)
+}
+
+// hashValue returns a hash of the value of the reference.
+func hashValue(value interface{}, reference smith_v1.Reference) (string, error) {
+	// Map keys are sorted by the encoder so the result is deterministic
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to hash reference %q", reference.Name)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// metadataReference returns the value of the label or annotation of the referenced object with the key
+// specified by the path of the reference.
+func metadataReference(resInfo *resourceInfo, reference smith_v1.Reference) (string, error) {
+	if reference.Path == "" {
+		return "", errors.Errorf("reference %q with %q modifier must specify the key in path", reference.Name, reference.Modifier)
+	}
+	value, ok := metadataValue(resInfo.actual, reference.Modifier, reference.Path)
+	if !ok {
+		return "", errors.WithStack(&referenceNotResolvedError{
+			message: fmt.Sprintf("%s not found: %q", reference.Modifier, reference.Path),
+		})
+	}
+	return value, nil
+}
+
+// metadataValue returns the value of the label or annotation, depending on the modifier, with the key.
+func metadataValue(obj *unstructured.Unstructured, modifier, key string) (string, bool /*found*/) {
+	var values map[string]string
+	if modifier == smith_v1.ReferenceModifierLabel {
+		values = obj.GetLabels()
+	} else {
+		values = obj.GetAnnotations()
+	}
+	value, ok := values[key]
+	return value, ok
+}
+
+// injectDependenciesHash sets an annotation on the pod template of the object to a combined hash of all
+// references with the "hash" modifier. When a dependency changes, the annotation changes too and
+// triggers a rolling update of the pods. Non-blocking references that cannot be resolved contribute
+// their default value, or nothing if they do not have one.
+func injectDependenciesHash(obj *unstructured.Unstructured, resInfos map[smith_v1.ResourceName]*resourceInfo, references []smith_v1.Reference) error {
+	hash := sha256.New()
+	found := false
+	for _, reference := range references {
+		if reference.Modifier != smith_v1.ReferenceModifierHash {
+			continue
+		}
+		resInfo := resInfos[reference.Resource]
+		if resInfo == nil {
+			return errors.Errorf("internal dependency resolution error - resource referenced by %q not found in Bundle: %s", reference.Name, reference.Resource)
+		}
+		refHash, err := hashReference(resInfo, reference)
+		if err != nil {
+			if _, ok := errors.Cause(err).(*referenceNotResolvedError); !ok || isBlockingReference(reference) {
+				return err
+			}
+			if reference.Default == nil {
+				continue
+			}
+			refHash, err = hashValue(reference.Default, reference)
+			if err != nil {
+				return err
+			}
+		}
+		_, _ = hash.Write([]byte(refHash))
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return errors.Errorf("cannot inject dependencies hash: %s %q does not have a spec", obj.GetKind(), obj.GetName())
+	}
+	template, ok := spec["template"].(map[string]interface{})
+	if !ok {
+		return errors.Errorf("cannot inject dependencies hash: %s %q does not have a pod template", obj.GetKind(), obj.GetName())
+	}
+	metadata, ok := template["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = make(map[string]interface{})
+		template["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = make(map[string]interface{})
+		metadata["annotations"] = annotations
+	}
+	annotations[smith.DependenciesHashAnnotation] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
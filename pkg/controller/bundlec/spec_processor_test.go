@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 
 	"github.com/stretchr/testify/assert"
@@ -330,6 +331,284 @@ func TestSpecProcessorErrors(t *testing.T) {
 	}
 }
 
+func TestReferenceDefaultValue(t *testing.T) {
+	t.Parallel()
+	resInfos := processedResources()
+	resInfos["resnotready"] = &resourceInfo{
+		status: resourceStatusDependenciesNotReady{
+			dependencies: []smith_v1.ResourceName{"res1"},
+		},
+	}
+	sp, err := newSpec(resInfos, []smith_v1.Reference{
+		{
+			Name:     "found",
+			Resource: "res1",
+			Path:     "a.string",
+			Default:  "default1",
+		},
+		{
+			Name:     "missing",
+			Resource: "res1",
+			Path:     "a.missing",
+			Default:  "default2",
+		},
+		{
+			Name:     "notready",
+			Resource: "resnotready",
+			Path:     "a.string",
+			Default:  int64(3),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[smith_v1.ReferenceName]interface{}{
+		"found":    "string1",
+		"missing":  "default2",
+		"notready": int64(3),
+	}, sp.variables)
+	assert.Equal(t, []smith_v1.ReferenceName{"missing", "notready"}, sp.defaulted)
+
+	// Default value does not hide other errors
+	_, err = newSpec(resInfos, []smith_v1.Reference{
+		{
+			Name:     "badmodifier",
+			Resource: "res1",
+			Path:     "a.string",
+			Modifier: "unknown",
+			Default:  "default1",
+		},
+	})
+	assert.EqualError(t, err, `reference modifier "unknown" not understood for "res1"`)
+}
+
+func TestOptionalReference(t *testing.T) {
+	t.Parallel()
+	resInfos := processedResources()
+	resInfos["resnotready"] = &resourceInfo{
+		status: resourceStatusDependenciesNotReady{
+			dependencies: []smith_v1.ResourceName{"res1"},
+		},
+	}
+	sp, err := newSpec(resInfos, []smith_v1.Reference{
+		{
+			Name:     "found",
+			Resource: "res1",
+			Path:     "a.string",
+			Optional: true,
+		},
+		{
+			Name:     "missing",
+			Resource: "res1",
+			Path:     "a.missing",
+			Optional: true,
+		},
+		{
+			Name:     "notready",
+			Resource: "resnotready",
+			Path:     "a.string",
+			Optional: true,
+			Default:  "default1",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[smith_v1.ReferenceName]interface{}{
+		"found":    "string1",
+		"missing":  nil,
+		"notready": "default1",
+	}, sp.variables)
+	assert.Equal(t, []smith_v1.ReferenceName{"missing", "notready"}, sp.defaulted)
+}
+
+func TestMetadataReferences(t *testing.T) {
+	t.Parallel()
+	resInfos := processedResources()
+	resInfos["res1"].actual.SetLabels(map[string]string{
+		"app": "app1",
+	})
+	resInfos["res1"].actual.SetAnnotations(map[string]string{
+		"example.com/endpoint": "https://example.com",
+	})
+	references := []smith_v1.Reference{
+		{
+			Name:     "label",
+			Resource: "res1",
+			Path:     "app",
+			Modifier: smith_v1.ReferenceModifierLabel,
+		},
+		{
+			Name:     "annotation",
+			Resource: "res1",
+			Path:     "example.com/endpoint",
+			Modifier: smith_v1.ReferenceModifierAnnotation,
+		},
+		{
+			Name:     "missing",
+			Resource: "res1",
+			Path:     "example.com/missing",
+			Modifier: smith_v1.ReferenceModifierAnnotation,
+			Default:  "default1",
+		},
+	}
+	sp, err := newSpec(resInfos, references)
+	require.NoError(t, err)
+	assert.Equal(t, map[smith_v1.ReferenceName]interface{}{
+		"label":      "app1",
+		"annotation": "https://example.com",
+		"missing":    "default1",
+	}, sp.variables)
+	assert.Equal(t, []smith_v1.ReferenceName{"missing"}, sp.defaulted)
+
+	// Dependent is blocked until the key is present
+	st := resourceSyncTask{
+		processedResources: resInfos,
+	}
+	notReady, _ := st.checkAllDependenciesAreReady(&smith_v1.Resource{References: references})
+	assert.Empty(t, notReady)
+	references[1].Path = "example.com/other"
+	notReady, reasons := st.checkAllDependenciesAreReady(&smith_v1.Resource{References: references})
+	assert.Equal(t, []smith_v1.ResourceName{"res1"}, notReady)
+	assert.Equal(t, map[smith_v1.ResourceName]string{"res1": `no annotation "example.com/other"`}, reasons)
+}
+
+func TestReferenceCycleDetected(t *testing.T) {
+	t.Parallel()
+	// Value of each reference is the value of the reference named by its path
+	resolveFromOtherReference := func(r *referenceResolver, reference smith_v1.Reference) (interface{}, error) {
+		if reference.Path == "" {
+			return reference.Example, nil
+		}
+		return r.resolveReference(r.references[smith_v1.ReferenceName(reference.Path)])
+	}
+
+	refs, err := resolveAllReferences([]smith_v1.Reference{
+		{Name: "a", Path: "b"},
+		{Name: "b", Path: "c"},
+		{Name: "c", Example: "value"},
+	}, resolveFromOtherReference)
+	require.NoError(t, err)
+	assert.Equal(t, map[smith_v1.ReferenceName]interface{}{
+		"a": "value",
+		"b": "value",
+		"c": "value",
+	}, refs)
+
+	_, err = resolveAllReferences([]smith_v1.Reference{
+		{Name: "a", Path: "b"},
+		{Name: "b", Path: "c"},
+		{Name: "c", Path: "a"},
+	}, resolveFromOtherReference)
+	assert.EqualError(t, err, `reference cycle detected: "a" -> "b" -> "c" -> "a"`)
+}
+
+func TestInjectDependenciesHash(t *testing.T) {
+	t.Parallel()
+	references := []smith_v1.Reference{
+		{
+			Resource: "res1",
+			Path:     "a.object",
+			Modifier: smith_v1.ReferenceModifierHash,
+		},
+	}
+	newDeployment := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Deployment",
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{},
+				},
+			},
+		}
+	}
+	templateAnnotation := func(obj *unstructured.Unstructured) interface{} {
+		return obj.Object["spec"].(map[string]interface{})["template"].(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})[smith.DependenciesHashAnnotation]
+	}
+
+	resInfos := processedResources()
+	obj1 := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj1, resInfos, references))
+	hash1 := templateAnnotation(obj1)
+	assert.NotEmpty(t, hash1)
+
+	// Same content - same hash
+	obj2 := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj2, resInfos, references))
+	assert.Equal(t, hash1, templateAnnotation(obj2))
+
+	// Changed content - different hash
+	resInfos["res1"].actual.Object["a"].(map[string]interface{})["object"].(map[string]interface{})["b"] = "changed"
+	obj3 := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj3, resInfos, references))
+	assert.NotEqual(t, hash1, templateAnnotation(obj3))
+
+	// Not an object with a pod template
+	configMap := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind": "ConfigMap",
+		},
+	}
+	configMap.SetName("cm1")
+	assert.EqualError(t, injectDependenciesHash(configMap, resInfos, references), `cannot inject dependencies hash: ConfigMap "cm1" does not have a spec`)
+}
+
+func TestInjectDependenciesHashUnresolvedReference(t *testing.T) {
+	t.Parallel()
+	newDeployment := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Deployment",
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{},
+				},
+			},
+		}
+	}
+	resInfos := map[smith_v1.ResourceName]*resourceInfo{
+		"notready": {
+			status: resourceStatusInProgress{},
+		},
+	}
+
+	// Optional reference does not contribute to the hash
+	obj := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj, resInfos, []smith_v1.Reference{
+		{
+			Resource: "notready",
+			Modifier: smith_v1.ReferenceModifierHash,
+			Optional: true,
+		},
+	}))
+	assert.Equal(t, map[string]interface{}{}, obj.Object["spec"].(map[string]interface{})["template"])
+
+	// Default is hashed instead
+	withDefault := func(value interface{}) []smith_v1.Reference {
+		return []smith_v1.Reference{
+			{
+				Resource: "notready",
+				Path:     "data.config",
+				Modifier: smith_v1.ReferenceModifierHash,
+				Default:  value,
+			},
+		}
+	}
+	obj1 := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj1, resInfos, withDefault("a")))
+	obj2 := newDeployment()
+	require.NoError(t, injectDependenciesHash(obj2, resInfos, withDefault("b")))
+	annotations1, _, _ := unstructured.NestedStringMap(obj1.Object, "spec", "template", "metadata", "annotations")
+	annotations2, _, _ := unstructured.NestedStringMap(obj2.Object, "spec", "template", "metadata", "annotations")
+	assert.NotEmpty(t, annotations1[smith.DependenciesHashAnnotation])
+	assert.NotEqual(t, annotations1[smith.DependenciesHashAnnotation], annotations2[smith.DependenciesHashAnnotation])
+
+	// Blocking reference fails
+	err := injectDependenciesHash(newDeployment(), resInfos, []smith_v1.Reference{
+		{
+			Name:     "ref",
+			Resource: "notready",
+			Modifier: smith_v1.ReferenceModifierHash,
+		},
+	})
+	assert.EqualError(t, err, `resource "notready" referenced by "ref" is not ready`)
+}
+
 func processedResources() map[smith_v1.ResourceName]*resourceInfo {
 	return map[smith_v1.ResourceName]*resourceInfo{
 		"res1": {
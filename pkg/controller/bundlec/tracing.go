@@ -0,0 +1,137 @@
+package bundlec
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Names of spans.
+const (
+	spanReconcile              = "reconcile"
+	spanSortBundle             = "sortBundle"
+	spanProcessResource        = "processResource"
+	spanReadinessCheck         = "readinessCheck"
+	spanDeleteRemovedResources = "deleteRemovedResources"
+	spanDeleteAllResources     = "deleteAllResources"
+)
+
+type noopSpan struct {
+}
+
+func (noopSpan) Finish(error) {
+}
+
+func (noopSpan) TraceParent() string {
+	return ""
+}
+
+const (
+	// traceParentVersion is the version of the W3C trace context traceparent format that is produced.
+	traceParentVersion = "00"
+	traceFlagsSampled  = "01"
+)
+
+// traceContext identifies a span across process boundaries.
+type traceContext struct {
+	traceID string
+	spanID  string
+	sampled bool
+}
+
+// parseTraceParent parses a W3C trace context traceparent, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceParent(traceParent string) (traceContext, error) {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 || !isHexID(parts[0], 1) || parts[0] == "ff" || (parts[0] == traceParentVersion && len(parts) != 4) {
+		return traceContext{}, errors.Errorf("invalid traceparent %q", traceParent)
+	}
+	if !isHexID(parts[1], 16) || !isHexID(parts[2], 8) || !isHexID(parts[3], 1) {
+		return traceContext{}, errors.Errorf("invalid traceparent %q", traceParent)
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return traceContext{
+		traceID: parts[1],
+		spanID:  parts[2],
+		sampled: flags[0]&1 == 1,
+	}, nil
+}
+
+// isHexID checks if s is a lowercase hex-encoded ID of n bytes that is not all zeros.
+func isHexID(s string, n int) bool {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return false
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	// A single byte is a version or flags where zero is valid
+	if n == 1 {
+		return true
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (tc traceContext) traceParent() string {
+	flags := "00"
+	if tc.sampled {
+		flags = traceFlagsSampled
+	}
+	return traceParentVersion + "-" + tc.traceID + "-" + tc.spanID + "-" + flags
+}
+
+// remoteSpan is a span of another system, the parent of the root span of a processing iteration.
+// It is finished by that system.
+type remoteSpan struct {
+	tc traceContext
+}
+
+func (remoteSpan) Finish(error) {
+}
+
+func (s remoteSpan) TraceParent() string {
+	return s.tc.traceParent()
+}
+
+// bundleParentSpan returns the span that the Bundle is annotated with. Returns nil if the Bundle is not
+// annotated or the annotation is invalid.
+func bundleParentSpan(logger *zap.Logger, bundle *smith_v1.Bundle) Span {
+	traceParent, ok := bundle.Annotations[smith.TraceParentAnnotation]
+	if !ok {
+		return nil
+	}
+	tc, err := parseTraceParent(traceParent)
+	if err != nil {
+		logger.Debug("Ignoring trace parent annotation", zap.Error(err))
+		return nil
+	}
+	return remoteSpan{tc: tc}
+}
+
+// traceID returns the trace ID of the span to correlate logs and traces. Empty string is returned if the
+// span is not recorded.
+func traceID(span Span) string {
+	tc, err := parseTraceParent(span.TraceParent())
+	if err != nil {
+		return ""
+	}
+	return tc.traceID
+}
+
+// startSpan starts a span using the tracer. A no-op span is returned if tracer is nil.
+func startSpan(tracer Tracer, parent Span, operation string, attributes map[string]string) Span {
+	if tracer == nil {
+		return noopSpan{}
+	}
+	return tracer.StartSpan(parent, operation, attributes)
+}
@@ -0,0 +1,128 @@
+package bundlec
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/atlassian/smith/pkg/speccheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type recordedSpan struct {
+	parent     *recordedSpan
+	operation  string
+	attributes map[string]string
+	finished   bool
+	err        error
+}
+
+func (s *recordedSpan) Finish(err error) {
+	s.finished = true
+	s.err = err
+}
+
+func (s *recordedSpan) TraceParent() string {
+	return ""
+}
+
+type recordingTracer struct {
+	mx    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(parent Span, operation string, attributes map[string]string) Span {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	span := &recordedSpan{
+		operation:  operation,
+		attributes: attributes,
+	}
+	if parent != nil {
+		span.parent = parent.(*recordedSpan)
+	}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func (t *recordingTracer) Run(ctx context.Context) {
+}
+
+func TestProcessNormalIsTraced(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	store, err := newSimulationStore(nil)
+	require.NoError(t, err)
+	tracer := &recordingTracer{}
+	root := tracer.StartSpan(nil, spanReconcile, nil)
+	st := bundleSyncTask{
+		logger:       logger,
+		bundleClient: simulationBundlesGetter{},
+		smartClient:  &simulationSmartClient{result: &ReconcileResult{}},
+		rc:           configMapsReadyChecker{},
+		store:        store,
+		specCheck: &speccheck.SpecCheck{
+			Logger:  logger,
+			Cleaner: cleanup.New(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       "bundle1",
+				Namespace:  "ns",
+				UID:        "bundle1-uid",
+				Finalizers: []string{FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: "config",
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: "cm1",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		recorder: record.NewFakeRecorder(10),
+		tracer:   tracer,
+		span:     root,
+	}
+
+	_, err = st.processNormal()
+	require.NoError(t, err)
+
+	var operations []string
+	for _, span := range tracer.spans {
+		operations = append(operations, span.operation)
+		if span.operation != spanReconcile {
+			assert.True(t, span.finished, span.operation)
+			assert.NoError(t, span.err, span.operation)
+		}
+	}
+	assert.Equal(t, []string{spanReconcile, spanSortBundle, spanProcessResource, spanReadinessCheck, spanDeleteRemovedResources}, operations)
+
+	resSpan := tracer.spans[2]
+	assert.Equal(t, root, resSpan.parent)
+	assert.Equal(t, map[string]string{
+		"resource": "config",
+		"gvk":      "/v1, Kind=ConfigMap",
+	}, resSpan.attributes)
+	assert.Equal(t, resSpan, tracer.spans[3].parent)
+}
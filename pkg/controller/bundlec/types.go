@@ -1,7 +1,10 @@
 package bundlec
 
 import (
+	"context"
+
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/readychecker"
 
 	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -17,11 +20,62 @@ type SpecCheck interface {
 }
 
 type ReadyChecker interface {
-	IsReady(*unstructured.Unstructured) (isReady, retriableError bool, e error)
+	IsReady(obj *unstructured.Unstructured, defaultPathValue readychecker.FieldPathValue) (isReady, retriableError bool, e error)
+}
+
+// UnresolvableGvkPolicy defines what to do with objects that cannot be deleted because
+// a client for their GVK cannot be obtained.
+type UnresolvableGvkPolicy string
+
+const (
+	// UnresolvableGvkPolicyBlock blocks deletion until a client can be obtained.
+	UnresolvableGvkPolicyBlock UnresolvableGvkPolicy = "block"
+	// UnresolvableGvkPolicySkip leaves such objects orphaned and reports them with a warning Event.
+	UnresolvableGvkPolicySkip UnresolvableGvkPolicy = "skip"
+)
+
+// ErrorMessageTransformer transforms errors into user-friendly messages that are put into conditions.
+// Original errors are still logged.
+type ErrorMessageTransformer interface {
+	// ErrorMessage returns a message for the error.
+	ErrorMessage(err error) string
+}
+
+// Tracer creates spans that describe where time is spent while a Bundle is processed.
+// It can be backed by a distributed tracing system.
+type Tracer interface {
+	// StartSpan starts a span. parent is nil for the root span of a processing iteration.
+	StartSpan(parent Span, operation string, attributes map[string]string) Span
+	// Run exports finished spans until the context is done.
+	Run(ctx context.Context)
+}
+
+// Span is a unit of work in a trace.
+type Span interface {
+	// Finish finishes the span. err is the outcome of the unit of work and may be nil.
+	Finish(err error)
+	// TraceParent returns the W3C trace context traceparent of the span to propagate it to other systems.
+	// Empty string is returned if the span is not recorded.
+	TraceParent() string
+}
+
+// CompletionNotifier is notified when a Bundle reaches a terminal state for its current generation,
+// e.g. to signal an external orchestrator without it having to poll.
+type CompletionNotifier interface {
+	// Run delivers notifications until the context is done.
+	Run(ctx context.Context)
+	// NotifyCompletion queues a notification for delivery. Must not block.
+	NotifyCompletion(completion Completion)
+}
+
+// SchemaValidator validates objects against their schema before they are created/updated.
+type SchemaValidator interface {
+	ValidateObject(obj *unstructured.Unstructured) (retriableError bool, e error)
 }
 
 type Store interface {
 	Get(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
+	// ObjectsControlledBy returns objects in the namespace and cluster-scoped objects controlled by the object with the UID.
 	ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error)
 	AddInformer(schema.GroupVersionKind, cache.SharedIndexInformer) error
 	RemoveInformer(schema.GroupVersionKind) bool
@@ -30,12 +84,33 @@ type Store interface {
 type BundleStore interface {
 	// Get returns Bundle based on its namespace and name.
 	Get(namespace, bundleName string) (*smith_v1.Bundle, error)
+	// List returns all Bundles. Returned Bundles must not be mutated.
+	List() []*smith_v1.Bundle
 	// GetBundlesByCrd returns Bundles which have a resource defined by CRD.
 	GetBundlesByCrd(*apiext_v1b1.CustomResourceDefinition) ([]*smith_v1.Bundle, error)
 	// GetBundlesByObject returns Bundles which have a resource of a particular group/kind with a name in a namespace.
 	GetBundlesByObject(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error)
+	// GetBundlesByReferencedObject returns Bundles which have a reference to an object outside of the Bundle
+	// of a particular group/kind with a name in a namespace.
+	GetBundlesByReferencedObject(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error)
 }
 
 type SmartClient interface {
 	ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
 }
+
+// ScopeSmartClient is a SmartClient that can determine the scope of kinds of objects.
+// Objects are not checked for being cluster-scoped if the SmartClient does not implement it.
+type ScopeSmartClient interface {
+	SmartClient
+	// IsNamespaced checks if objects of the kind are namespaced.
+	IsNamespaced(gvk schema.GroupVersionKind) (bool, error)
+}
+
+// FieldManagerSmartClient is a SmartClient that can identify writes of objects with a field manager.
+// Objects are written using a client returned by ForGVK if the SmartClient does not implement it.
+type FieldManagerSmartClient interface {
+	SmartClient
+	// ForGVKWithFieldManager returns a client which writes are identified with the field manager.
+	ForGVKWithFieldManager(gvk schema.GroupVersionKind, namespace, fieldManager string) (dynamic.ResourceInterface, error)
+}
@@ -0,0 +1,45 @@
+package bundlec
+
+import (
+	"sync"
+	"time"
+
+	"github.com/atlassian/ctrl"
+)
+
+// throttledWorkQueue coalesces adds of the same key within a window.
+// The first add of a key schedules the key to be added to the underlying queue after the window,
+// subsequent adds of the same key before that are dropped. The window of each key is extended by a random
+// jitter so that keys added at the same time are not added to the underlying queue all at once.
+type throttledWorkQueue struct {
+	queue  ctrl.WorkQueueProducer
+	window time.Duration
+	jitter float64
+
+	mx      sync.Mutex
+	pending map[ctrl.QueueKey]struct{}
+}
+
+func newThrottledWorkQueue(queue ctrl.WorkQueueProducer, window time.Duration, jitter float64) *throttledWorkQueue {
+	return &throttledWorkQueue{
+		queue:   queue,
+		window:  window,
+		jitter:  jitter,
+		pending: make(map[ctrl.QueueKey]struct{}),
+	}
+}
+
+func (q *throttledWorkQueue) Add(key ctrl.QueueKey) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if _, ok := q.pending[key]; ok {
+		return
+	}
+	q.pending[key] = struct{}{}
+	time.AfterFunc(jitteredDelay(q.window, q.jitter), func() {
+		q.mx.Lock()
+		delete(q.pending, key)
+		q.mx.Unlock()
+		q.queue.Add(key)
+	})
+}
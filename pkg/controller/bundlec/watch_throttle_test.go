@@ -0,0 +1,49 @@
+package bundlec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/ctrl"
+	"github.com/stretchr/testify/assert"
+)
+
+type chanWorkQueue chan ctrl.QueueKey
+
+func (q chanWorkQueue) Add(key ctrl.QueueKey) {
+	q <- key
+}
+
+func TestThrottledWorkQueueCoalescesAdds(t *testing.T) {
+	t.Parallel()
+	queue := make(chanWorkQueue, 10)
+	tq := newThrottledWorkQueue(queue, 50*time.Millisecond, 0)
+	key1 := ctrl.QueueKey{Namespace: "ns", Name: "bundle1"}
+	key2 := ctrl.QueueKey{Namespace: "ns", Name: "bundle2"}
+
+	tq.Add(key1)
+	tq.Add(key1)
+	tq.Add(key2)
+	tq.Add(key1)
+	assert.Empty(t, queue)
+
+	added := map[ctrl.QueueKey]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case key := <-queue:
+			added[key]++
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for throttled add")
+		}
+	}
+	assert.Equal(t, map[ctrl.QueueKey]int{key1: 1, key2: 1}, added)
+
+	// Window is over, next add is scheduled again
+	tq.Add(key1)
+	select {
+	case key := <-queue:
+		assert.Equal(t, key1, key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for throttled add")
+	}
+}
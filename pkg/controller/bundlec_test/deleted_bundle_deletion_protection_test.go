@@ -0,0 +1,72 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+// Should not delete resources nor remove the "deleteResources" finalizer
+// if the Bundle is protected from deletion
+func TestDeleteResourcesBlockedByDeletionProtection(t *testing.T) {
+	t.Parallel()
+	now := meta_v1.Now()
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			configMapNeedsDelete(),
+			configMapNeedsUpdate(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              bundle1,
+				Namespace:         testNamespace,
+				UID:               bundle1uid,
+				DeletionTimestamp: &now,
+				// Finalizer to enforce manual deletion
+				Finalizers: []string{bundlec.FinalizerDeleteResources},
+				Annotations: map[string]string{
+					smith.DeletionProtectionAnnotation: "true",
+				},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: resMapNeedsAnUpdate,
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: mapNeedsAnUpdate,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, "resources are not deleted because Bundle is protected by smith.atlassian.com/deletionProtection annotation")
+
+			actions := tc.smithFake.Actions()
+			require.Len(t, actions, 2)
+			assert.Implements(t, (*kube_testing.ListAction)(nil), actions[0])
+			assert.Implements(t, (*kube_testing.WatchAction)(nil), actions[1])
+		},
+	}
+	tc.run(t)
+}
@@ -121,7 +121,7 @@ func TestNoActionsForBlockedResources(t *testing.T) {
 			resCond = smith_testing.AssertResourceCondition(t, updateBundle, resSb1, smith_v1.ResourceBlocked, smith_v1.ConditionTrue)
 			if resCond != nil {
 				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotReady, resCond.Reason)
-				assert.Equal(t, `Not ready: ["`+resSi1+`"]`, resCond.Message)
+				assert.Equal(t, `Not ready: "`+resSi1+`" (error)`, resCond.Message)
 			}
 			smith_testing.AssertResourceCondition(t, updateBundle, resSb1, smith_v1.ResourceInProgress, smith_v1.ConditionFalse)
 			smith_testing.AssertResourceCondition(t, updateBundle, resSb1, smith_v1.ResourceReady, smith_v1.ConditionFalse)
@@ -129,8 +129,8 @@ func TestNoActionsForBlockedResources(t *testing.T) {
 
 			resCond = smith_testing.AssertResourceCondition(t, updateBundle, resMapNeedsAnUpdate, smith_v1.ResourceBlocked, smith_v1.ConditionTrue)
 			if resCond != nil {
-				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotReady, resCond.Reason)
-				assert.Equal(t, `Not ready: ["`+resSb1+`"]`, resCond.Message)
+				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotCreated, resCond.Reason)
+				assert.Equal(t, `Not ready: "`+resSb1+`" (blocked)`, resCond.Message)
 			}
 			smith_testing.AssertResourceCondition(t, updateBundle, resMapNeedsAnUpdate, smith_v1.ResourceInProgress, smith_v1.ConditionFalse)
 			smith_testing.AssertResourceCondition(t, updateBundle, resMapNeedsAnUpdate, smith_v1.ResourceReady, smith_v1.ConditionFalse)
@@ -0,0 +1,107 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+// Should rebuild status from objects in the cluster when Bundle status is wiped
+func TestRebuildWipedStatusFromCluster(t *testing.T) {
+	t.Parallel()
+	testRebuildStatusFromCluster(t, smith_v1.BundleStatus{})
+}
+
+// Should rebuild status from objects in the cluster when Bundle status is stale
+func TestRebuildStaleStatusFromCluster(t *testing.T) {
+	t.Parallel()
+	testRebuildStatusFromCluster(t, smith_v1.BundleStatus{
+		Conditions: []smith_v1.BundleCondition{
+			{Type: smith_v1.BundleInProgress, Status: smith_v1.ConditionFalse},
+			{Type: smith_v1.BundleReady, Status: smith_v1.ConditionFalse},
+			{Type: smith_v1.BundleError, Status: smith_v1.ConditionTrue, Reason: smith_v1.BundleReasonTerminalError, Message: "stale"},
+		},
+		ResourceStatuses: []smith_v1.ResourceStatus{
+			{
+				Name: "removed-resource",
+				Conditions: []smith_v1.ResourceCondition{
+					{Type: smith_v1.ResourceError, Status: smith_v1.ConditionTrue, Reason: smith_v1.ResourceReasonTerminalError, Message: "stale"},
+				},
+			},
+		},
+		ObjectsToDelete: []smith_v1.ObjectToDelete{
+			{
+				Version: "v1",
+				Kind:    "ConfigMap",
+				Name:    "long-gone",
+			},
+		},
+	})
+}
+
+func testRebuildStatusFromCluster(t *testing.T, status smith_v1.BundleStatus) {
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			configMapNeedsDelete(), // Already matches the spec below
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       bundle1,
+				Namespace:  testNamespace,
+				UID:        bundle1uid,
+				Finalizers: []string{bundlec.FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: resMapNeedsAnUpdate,
+						Spec: smith_v1.ResourceSpec{
+							Object: &core_v1.ConfigMap{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       "ConfigMap",
+									APIVersion: core_v1.SchemeGroupVersion.String(),
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: mapNeedsDelete,
+								},
+							},
+						},
+					},
+				},
+			},
+			Status: status,
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+
+			var updateBundle *smith_v1.Bundle
+			for _, action := range tc.smithFake.Actions() {
+				if bundleUpdate, ok := action.(kube_testing.UpdateAction); ok {
+					updateBundle = bundleUpdate.GetObject().(*smith_v1.Bundle)
+				}
+			}
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertCondition(t, updateBundle, smith_v1.BundleReady, smith_v1.ConditionTrue)
+			smith_testing.AssertCondition(t, updateBundle, smith_v1.BundleInProgress, smith_v1.ConditionFalse)
+			smith_testing.AssertCondition(t, updateBundle, smith_v1.BundleError, smith_v1.ConditionFalse)
+
+			require.Len(t, updateBundle.Status.ResourceStatuses, 1)
+			smith_testing.AssertResourceCondition(t, updateBundle, resMapNeedsAnUpdate, smith_v1.ResourceReady, smith_v1.ConditionTrue)
+			smith_testing.AssertResourceCondition(t, updateBundle, resMapNeedsAnUpdate, smith_v1.ResourceError, smith_v1.ConditionFalse)
+			assert.Empty(t, updateBundle.Status.ObjectsToDelete)
+		},
+	}
+	tc.run(t)
+}
@@ -208,13 +208,13 @@ func TestSchemaEarlyValidation(t *testing.T) {
 			}
 			resCond = smith_testing.AssertResourceCondition(t, updateBundle, resPWithoutDefaults, smith_v1.ResourceBlocked, smith_v1.ConditionTrue)
 			if resCond != nil {
-				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotReady, resCond.Reason)
-				assert.Equal(t, `Not ready: ["`+resSb1+`"]`, resCond.Message)
+				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotCreated, resCond.Reason)
+				assert.Equal(t, `Not ready: "`+resSb1+`" (blocked)`, resCond.Message)
 			}
 			resCond = smith_testing.AssertResourceCondition(t, updateBundle, resPWithoutDefaults, smith_v1.ResourceBlocked, smith_v1.ConditionTrue)
 			if resCond != nil {
-				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotReady, resCond.Reason)
-				assert.Equal(t, `Not ready: ["`+resSb1+`"]`, resCond.Message)
+				assert.Equal(t, smith_v1.ResourceReasonDependenciesNotCreated, resCond.Reason)
+				assert.Equal(t, `Not ready: "`+resSb1+`" (blocked)`, resCond.Message)
 			}
 		},
 	}
@@ -5,8 +5,11 @@ package plugin
 // OR have plugin validate itself
 
 import (
+	"context"
+
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -19,25 +22,42 @@ type NewFunc func() (Plugin, error)
 type Plugin interface {
 	// Describe returns information about the plugin.
 	Describe() *Description
-	// Process processes a plugin specification and produces an object as the result.
+	// Process processes a plugin specification and produces an object, and optionally additional objects,
+	// as the result.
 	Process(map[string]interface{}, *Context) (*ProcessResult, error)
 }
 
+// ReadyChecker may be implemented by a Plugin to determine readiness of objects it produces.
+// Readiness of objects produced by plugins that do not implement it is determined by the generic readiness check.
+type ReadyChecker interface {
+	// IsReady checks if the object produced by the plugin is ready.
+	IsReady(obj *unstructured.Unstructured) (isReady, retriableError bool, e error)
+}
+
 type Description struct {
 	Name smith_v1.PluginName
 	GVK  schema.GroupVersionKind
 	// gojsonschema supported schema for the spec (first argument of Process)
 	SpecSchema []byte
+	// InputSpecFields are dot-separated paths of fields of the spec that hold names of resources the plugin
+	// consumes, either as a string or as a list of strings. Such resources become dependencies of the resource
+	// as if it referenced them, so that it is not processed before they are ready, and they are passed to Process
+	// in Context.Dependencies. Optional.
+	InputSpecFields []string
 }
 
 // Context contains contextual information for the Process() call.
 type Context struct {
+	// Context is cancelled when the plugin exceeds its timeout or the controller is shutting down.
+	// Long running plugins should abort processing and return when it is done.
+	Context context.Context
 	// Namespace is the namespace where the returned object will be created.
 	Namespace string
 	// Actual is the actual object that will be updated if it exists already.
 	// nil if the object does not exist.
 	Actual runtime.Object
 	// Dependencies is the map from dependency name to a description of that dependency.
+	// Dependencies of optional or defaulted references that are not ready are not in the map.
 	Dependencies map[smith_v1.ResourceName]Dependency
 }
 
@@ -57,4 +77,8 @@ type Dependency struct {
 type ProcessResult struct {
 	// Object is the object that should be created/updated.
 	Object runtime.Object
+	// AdditionalObjects are other objects that should be created/updated along with Object, e.g. a Service
+	// for a Deployment. They must have names set. The resource is ready once all objects are ready.
+	// Optional.
+	AdditionalObjects []runtime.Object
 }
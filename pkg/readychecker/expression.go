@@ -0,0 +1,194 @@
+package readychecker
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/atlassian/smith/pkg/resources"
+	"github.com/pkg/errors"
+)
+
+// Readiness expression grammar:
+//
+//   expression := condition ( "&&" condition )*
+//   condition  := operand [ ( "==" | "!=" ) operand ]
+//   operand    := "{" JsonPath "}" | quoted string | literal
+//
+// JsonPath operands are evaluated against the object and compared as strings. A condition without
+// a comparison is true if the operand is neither empty nor "false".
+// Example: {.status.phase} == "Running" && {.status.replicas} == {.status.readyReplicas}
+
+type tokenKind int
+
+const (
+	tokenOperand tokenKind = iota
+	tokenAnd
+	tokenEqual
+	tokenNotEqual
+)
+
+type token struct {
+	kind tokenKind
+	// path is set for JsonPath operands, value for other operands.
+	path  string
+	value string
+}
+
+// evalReadinessExpression evaluates a readiness expression against the object.
+func evalReadinessExpression(obj map[string]interface{}, expression string) (bool, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid readiness expression %q", expression)
+	}
+	ready := true
+	for len(tokens) > 0 {
+		var condTokens []token
+		condTokens, tokens = splitCondition(tokens)
+		condReady, err := evalCondition(obj, condTokens)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid readiness expression %q", expression)
+		}
+		// All conditions are evaluated so that an invalid expression is always reported
+		ready = ready && condReady
+		if len(tokens) > 0 {
+			tokens = tokens[1:] // Skip "&&"
+			if len(tokens) == 0 {
+				return false, errors.Errorf(`invalid readiness expression %q: condition expected after "&&"`, expression)
+			}
+		}
+	}
+	return ready, nil
+}
+
+// splitCondition returns tokens of the first condition and the rest of tokens, starting with "&&" if any.
+func splitCondition(tokens []token) ([]token, []token) {
+	for i, t := range tokens {
+		if t.kind == tokenAnd {
+			return tokens[:i], tokens[i:]
+		}
+	}
+	return tokens, nil
+}
+
+func evalCondition(obj map[string]interface{}, tokens []token) (bool, error) {
+	switch {
+	case len(tokens) == 1 && tokens[0].kind == tokenOperand:
+		value, err := operandValue(obj, tokens[0])
+		if err != nil {
+			return false, err
+		}
+		return value != "" && value != "false", nil
+	case len(tokens) == 3 && tokens[0].kind == tokenOperand && tokens[2].kind == tokenOperand &&
+		(tokens[1].kind == tokenEqual || tokens[1].kind == tokenNotEqual):
+		left, err := operandValue(obj, tokens[0])
+		if err != nil {
+			return false, err
+		}
+		right, err := operandValue(obj, tokens[2])
+		if err != nil {
+			return false, err
+		}
+		return (left == right) == (tokens[1].kind == tokenEqual), nil
+	default:
+		return false, errors.New(`condition must be an operand or a comparison of two operands with "==" or "!="`)
+	}
+}
+
+func operandValue(obj map[string]interface{}, t token) (string, error) {
+	if t.path == "" {
+		return t.value, nil
+	}
+	return resources.GetJsonPathString(obj, t.path)
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		switch c := expression[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(expression[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd})
+			i += 2
+		case strings.HasPrefix(expression[i:], "=="):
+			tokens = append(tokens, token{kind: tokenEqual})
+			i += 2
+		case strings.HasPrefix(expression[i:], "!="):
+			tokens = append(tokens, token{kind: tokenNotEqual})
+			i += 2
+		case c == '{':
+			end, err := pathEnd(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenOperand, path: expression[i:end]})
+			i = end
+		case c == '"':
+			end, err := quotedEnd(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.Unquote(expression[i:end])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid string at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenOperand, value: value})
+			i = end
+		default:
+			end := i
+			for end < len(expression) && !strings.ContainsRune(" \t\n&=!{\"", rune(expression[end])) {
+				end++
+			}
+			if end == i {
+				return nil, errors.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperand, value: expression[i:end]})
+			i = end
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("expression is empty")
+	}
+	return tokens, nil
+}
+
+// pathEnd returns the offset after the closing brace of a JsonPath that starts at offset start.
+// Braces within quoted strings of JsonPath filters are ignored.
+func pathEnd(expression string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(expression); i++ {
+		c := expression[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, errors.Errorf("unterminated JsonPath at offset %d", start)
+}
+
+// quotedEnd returns the offset after the closing quote of a string that starts at offset start.
+func quotedEnd(expression string, start int) (int, error) {
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errors.Errorf("unterminated string at offset %d", start)
+}
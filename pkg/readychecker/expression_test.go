@@ -0,0 +1,71 @@
+package readychecker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalReadinessExpression(t *testing.T) {
+	t.Parallel()
+	obj := map[string]interface{}{
+		"status": map[string]interface{}{
+			"phase":         "Running",
+			"replicas":      int64(3),
+			"readyReplicas": int64(3),
+			"updated":       true,
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "Ready",
+					"status": "True",
+				},
+			},
+		},
+	}
+	inputs := []struct {
+		name       string
+		expression string
+		ready      bool
+	}{
+		{name: "equal to string", expression: `{.status.phase} == "Running"`, ready: true},
+		{name: "not equal to string", expression: `{.status.phase} != "Running"`, ready: false},
+		{name: "equal fields", expression: `{.status.replicas} == {.status.readyReplicas}`, ready: true},
+		{name: "equal to literal", expression: `{.status.replicas} == 3`, ready: true},
+		{name: "conjunction", expression: `{.status.phase} == "Running" && {.status.replicas} == {.status.readyReplicas}`, ready: true},
+		{name: "conjunction with false condition", expression: `{.status.phase} == "Running" && {.status.replicas} == 2`, ready: false},
+		{name: "truthy field", expression: `{.status.updated}`, ready: true},
+		{name: "missing field", expression: `{.status.missing}`, ready: false},
+		{name: "filter", expression: `{.status.conditions[?(@.type=="Ready")].status} == "True"`, ready: true},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			ready, err := evalReadinessExpression(obj, input.expression)
+			require.NoError(t, err)
+			assert.Equal(t, input.ready, ready)
+		})
+	}
+}
+
+func TestEvalInvalidReadinessExpression(t *testing.T) {
+	t.Parallel()
+	inputs := []string{
+		``,
+		`{.status.phase`,
+		`{.status.phase} == "Running`,
+		`{.status.phase} ==`,
+		`{.status.phase} == "Running" &&`,
+		`&& {.status.phase}`,
+		`{.status.phase} "Running"`,
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+			_, err := evalReadinessExpression(map[string]interface{}{}, input)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -21,9 +21,22 @@ type CrdStore interface {
 	Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error)
 }
 
+// FieldPathValue is a path to a field and a value of that field which indicate that a Custom Resource is ready.
+type FieldPathValue struct {
+	Path  string
+	Value string
+}
+
+func (pv FieldPathValue) isSet() bool {
+	return len(pv.Path) > 0 && len(pv.Value) > 0
+}
+
 type ReadyChecker struct {
 	Store      CrdStore
 	KnownTypes map[schema.GroupKind]IsObjectReady
+	// Default field path/value used for Custom Resources that have no path/value annotations on their CRDs
+	// if no default was provided for the object being checked.
+	Default FieldPathValue
 }
 
 func New(store CrdStore, kts ...map[schema.GroupKind]IsObjectReady) *ReadyChecker {
@@ -42,7 +55,11 @@ func New(store CrdStore, kts ...map[schema.GroupKind]IsObjectReady) *ReadyChecke
 	}
 }
 
-func (rc *ReadyChecker) IsReady(obj *unstructured.Unstructured) (isReady, retriableError bool, e error) {
+// IsReady checks if the object is ready.
+// Readiness expression set on the object with smith.ReadinessPathAnnotation takes precedence over all other checks.
+// defaultPathValue is used for Custom Resources that have no path/value annotations on their CRDs. It takes
+// precedence over the controller-wide default.
+func (rc *ReadyChecker) IsReady(obj *unstructured.Unstructured, defaultPathValue FieldPathValue) (isReady, retriableError bool, e error) {
 	gvk := obj.GroupVersionKind()
 	gk := gvk.GroupKind()
 
@@ -50,18 +67,24 @@ func (rc *ReadyChecker) IsReady(obj *unstructured.Unstructured) (isReady, retria
 		return false, false, errors.Errorf("object has empty kind/version: %s", gvk)
 	}
 
-	// 1. Check if it is a known built-in resource
+	// 1. Check if the object has a readiness expression
+	if expression, ok := obj.GetAnnotations()[smith.ReadinessPathAnnotation]; ok {
+		ready, err := evalReadinessExpression(obj.Object, expression)
+		return ready, false, err
+	}
+
+	// 2. Check if it is a known built-in resource
 	if isObjectReady, ok := rc.KnownTypes[gk]; ok {
 		return isObjectReady(obj)
 	}
 
-	// 2. Check if it is a CRD with path/value annotation
-	ready, retriable, err := rc.checkPathValue(gk, obj)
+	// 3. Check if it is a CRD with path/value annotation
+	ready, retriable, err := rc.checkPathValue(gk, obj, defaultPathValue)
 	if err != nil || ready {
 		return ready, retriable, err
 	}
 
-	// 3. Check if it is a CRD with Kind/GroupVersion annotation
+	// 4. Check if it is a CRD with Kind/GroupVersion annotation
 	return rc.checkForInstance(gk, obj)
 }
 
@@ -70,7 +93,7 @@ func (rc *ReadyChecker) checkForInstance(gk schema.GroupKind, obj *unstructured.
 	return false, false, nil
 }
 
-func (rc *ReadyChecker) checkPathValue(gk schema.GroupKind, obj *unstructured.Unstructured) (isReady, retriableError bool, e error) {
+func (rc *ReadyChecker) checkPathValue(gk schema.GroupKind, obj *unstructured.Unstructured, defaultPathValue FieldPathValue) (isReady, retriableError bool, e error) {
 	crd, err := rc.Store.Get(gk)
 	if err != nil {
 		return false, true, err
@@ -78,16 +101,25 @@ func (rc *ReadyChecker) checkPathValue(gk schema.GroupKind, obj *unstructured.Un
 	if crd == nil {
 		return false, false, nil
 	}
-	path := crd.Annotations[smith.CrFieldPathAnnotation]
-	value := crd.Annotations[smith.CrFieldValueAnnotation]
-	if len(path) == 0 || len(value) == 0 {
+	// Precedence: CRD annotations, then provided default, then controller-wide default
+	pathValue := FieldPathValue{
+		Path:  crd.Annotations[smith.CrFieldPathAnnotation],
+		Value: crd.Annotations[smith.CrFieldValueAnnotation],
+	}
+	if !pathValue.isSet() {
+		pathValue = defaultPathValue
+	}
+	if !pathValue.isSet() {
+		pathValue = rc.Default
+	}
+	if !pathValue.isSet() {
 		return false, false, nil
 	}
-	actualValue, err := resources.GetJsonPathString(obj.Object, path)
+	actualValue, err := resources.GetJsonPathString(obj.Object, pathValue.Path)
 	if err != nil {
 		return false, false, err
 	}
-	if actualValue != value {
+	if actualValue != pathValue.Value {
 		return false, false, nil
 	}
 	return true, false, nil
@@ -0,0 +1,108 @@
+package readychecker
+
+import (
+	"testing"
+
+	"github.com/atlassian/smith"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeCrdStore map[schema.GroupKind]*apiext_v1b1.CustomResourceDefinition
+
+func (s fakeCrdStore) Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error) {
+	return s[resource], nil
+}
+
+func TestFieldPathValuePrecedence(t *testing.T) {
+	t.Parallel()
+	annotated := schema.GroupKind{Group: "example.com", Kind: "Annotated"}
+	notAnnotated := schema.GroupKind{Group: "example.com", Kind: "NotAnnotated"}
+	rc := New(fakeCrdStore{
+		annotated: &apiext_v1b1.CustomResourceDefinition{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Annotations: map[string]string{
+					smith.CrFieldPathAnnotation:  "{$.status.crd}",
+					smith.CrFieldValueAnnotation: "Ready",
+				},
+			},
+		},
+		notAnnotated: &apiext_v1b1.CustomResourceDefinition{},
+	})
+	rc.Default = FieldPathValue{
+		Path:  "{$.status.controller}",
+		Value: "Ready",
+	}
+	bundleDefault := FieldPathValue{
+		Path:  "{$.status.bundle}",
+		Value: "Ready",
+	}
+
+	inputs := []struct {
+		name          string
+		gk            schema.GroupKind
+		readyField    string
+		bundleDefault FieldPathValue
+		ready         bool
+	}{
+		{name: "CRD annotation wins over Bundle default", gk: annotated, readyField: "crd", bundleDefault: bundleDefault, ready: true},
+		{name: "Bundle default ignored if CRD is annotated", gk: annotated, readyField: "bundle", bundleDefault: bundleDefault, ready: false},
+		{name: "Bundle default wins over controller default", gk: notAnnotated, readyField: "bundle", bundleDefault: bundleDefault, ready: true},
+		{name: "controller default ignored if Bundle default is set", gk: notAnnotated, readyField: "controller", bundleDefault: bundleDefault, ready: false},
+		{name: "controller default", gk: notAnnotated, readyField: "controller", ready: true},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			obj := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"status": map[string]interface{}{
+						input.readyField: "Ready",
+					},
+				},
+			}
+			obj.SetGroupVersionKind(input.gk.WithVersion("v1"))
+			ready, retriable, err := rc.IsReady(obj, input.bundleDefault)
+			require.NoError(t, err)
+			assert.False(t, retriable)
+			assert.Equal(t, input.ready, ready)
+		})
+	}
+}
+
+func TestReadinessExpressionTakesPrecedence(t *testing.T) {
+	t.Parallel()
+	gk := schema.GroupKind{Kind: "ConfigMap"}
+	rc := New(fakeCrdStore{}, map[schema.GroupKind]IsObjectReady{
+		gk: func(runtime.Object) (bool, bool, error) {
+			return true, false, nil
+		},
+	})
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"data": map[string]interface{}{
+				"state": "pending",
+			},
+		},
+	}
+	obj.SetGroupVersionKind(gk.WithVersion("v1"))
+	obj.SetAnnotations(map[string]string{
+		smith.ReadinessPathAnnotation: `{.data.state} == "done"`,
+	})
+
+	ready, retriable, err := rc.IsReady(obj, FieldPathValue{})
+	require.NoError(t, err)
+	assert.False(t, retriable)
+	assert.False(t, ready)
+
+	require.NoError(t, unstructured.SetNestedField(obj.Object, "done", "data", "state"))
+	ready, _, err = rc.IsReady(obj, FieldPathValue{})
+	require.NoError(t, err)
+	assert.True(t, ready)
+}
@@ -7,6 +7,7 @@ import (
 	sc_v1b1 "github.com/kubernetes-incubator/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	"github.com/pkg/errors"
 	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
 	ext_v1b1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -20,14 +21,18 @@ var (
 		{Group: core_v1.GroupName, Kind: "Service"}:        alwaysReady,
 		{Group: core_v1.GroupName, Kind: "ServiceAccount"}: alwaysReady,
 		{Group: apps_v1.GroupName, Kind: "Deployment"}:     isDeploymentReady,
+		{Group: apps_v1.GroupName, Kind: "StatefulSet"}:    isStatefulSetReady,
+		{Group: apps_v1.GroupName, Kind: "DaemonSet"}:      isDaemonSetReady,
+		{Group: batch_v1.GroupName, Kind: "Job"}:           isJobReady,
 		{Group: ext_v1b1.GroupName, Kind: "Ingress"}:       alwaysReady,
 	}
 	ServiceCatalogKnownTypes = map[schema.GroupKind]readychecker.IsObjectReady{
 		{Group: sc_v1b1.GroupName, Kind: "ServiceBinding"}:  isScServiceBindingReady,
 		{Group: sc_v1b1.GroupName, Kind: "ServiceInstance"}: isScServiceInstanceReady,
 	}
-	apps_v1_scheme = runtime.NewScheme()
-	sc_v1b1_scheme = runtime.NewScheme()
+	apps_v1_scheme  = runtime.NewScheme()
+	batch_v1_scheme = runtime.NewScheme()
+	sc_v1b1_scheme  = runtime.NewScheme()
 )
 
 func init() {
@@ -35,6 +40,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = batch_v1.SchemeBuilder.AddToScheme(batch_v1_scheme)
+	if err != nil {
+		panic(err)
+	}
 	err = sc_v1b1.SchemeBuilder.AddToScheme(sc_v1b1_scheme)
 	if err != nil {
 		panic(err)
@@ -62,6 +71,73 @@ func isDeploymentReady(obj runtime.Object) (isReady, retriableError bool, e erro
 		deployment.Status.UpdatedReplicas == replicas, false, nil
 }
 
+// StatefulSet is ready once all replicas are updated (except for those excluded by the partition) and ready.
+// Replicas of a StatefulSet with OnDelete update strategy are only updated when pods are deleted,
+// so only their readiness is checked.
+func isStatefulSetReady(obj runtime.Object) (isReady, retriableError bool, e error) {
+	var statefulSet apps_v1.StatefulSet
+	if err := util.ConvertType(apps_v1_scheme, obj, &statefulSet); err != nil {
+		return false, false, err
+	}
+
+	replicas := int32(1) // Default value if not specified
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation || statefulSet.Status.ReadyReplicas < replicas {
+		return false, false, nil
+	}
+	if statefulSet.Spec.UpdateStrategy.Type == apps_v1.OnDeleteStatefulSetStrategyType {
+		return true, false, nil
+	}
+	partition := int32(0)
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		partition = *rollingUpdate.Partition
+	}
+	return statefulSet.Status.UpdatedReplicas >= replicas-partition, false, nil
+}
+
+// DaemonSet is ready once pods are updated and available on all nodes they should be scheduled to.
+// Pods of a DaemonSet with OnDelete update strategy are only updated when they are deleted,
+// so only their availability is checked.
+func isDaemonSetReady(obj runtime.Object) (isReady, retriableError bool, e error) {
+	var daemonSet apps_v1.DaemonSet
+	if err := util.ConvertType(apps_v1_scheme, obj, &daemonSet); err != nil {
+		return false, false, err
+	}
+
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation ||
+		daemonSet.Status.NumberAvailable < daemonSet.Status.DesiredNumberScheduled {
+		return false, false, nil
+	}
+	if daemonSet.Spec.UpdateStrategy.Type == apps_v1.OnDeleteDaemonSetStrategyType {
+		return true, false, nil
+	}
+	return daemonSet.Status.UpdatedNumberScheduled >= daemonSet.Status.DesiredNumberScheduled, false, nil
+}
+
+// Job is ready once it has completed. A failed Job is a terminal error.
+func isJobReady(obj runtime.Object) (isReady, retriableError bool, e error) {
+	var job batch_v1.Job
+	if err := util.ConvertType(batch_v1_scheme, obj, &job); err != nil {
+		return false, false, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != core_v1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batch_v1.JobComplete:
+			return true, false, nil
+		case batch_v1.JobFailed:
+			return false, false, errors.Errorf("job failed: %s: %s", cond.Reason, cond.Message)
+		}
+	}
+	return false, false, nil
+}
+
 func isScServiceBindingReady(obj runtime.Object) (isReady, retriableError bool, e error) {
 	var sic sc_v1b1.ServiceBinding
 	if err := util.ConvertType(sc_v1b1_scheme, obj, &sic); err != nil {
@@ -0,0 +1,148 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWorkloadReadiness(t *testing.T) {
+	t.Parallel()
+	three := int32(3)
+	two := int32(2)
+	inputs := []struct {
+		name  string
+		obj   runtime.Object
+		ready bool
+		err   string
+	}{
+		{
+			name: "StatefulSet ready",
+			obj: &apps_v1.StatefulSet{
+				ObjectMeta: meta_v1.ObjectMeta{Generation: 2},
+				Spec:       apps_v1.StatefulSetSpec{Replicas: &three},
+				Status:     apps_v1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 3, UpdatedReplicas: 3},
+			},
+			ready: true,
+		},
+		{
+			name: "StatefulSet not observed",
+			obj: &apps_v1.StatefulSet{
+				ObjectMeta: meta_v1.ObjectMeta{Generation: 2},
+				Spec:       apps_v1.StatefulSetSpec{Replicas: &three},
+				Status:     apps_v1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 3, UpdatedReplicas: 3},
+			},
+		},
+		{
+			name: "StatefulSet not updated",
+			obj: &apps_v1.StatefulSet{
+				Spec:   apps_v1.StatefulSetSpec{Replicas: &three},
+				Status: apps_v1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 1},
+			},
+		},
+		{
+			name: "StatefulSet partition",
+			obj: &apps_v1.StatefulSet{
+				Spec: apps_v1.StatefulSetSpec{
+					Replicas: &three,
+					UpdateStrategy: apps_v1.StatefulSetUpdateStrategy{
+						Type: apps_v1.RollingUpdateStatefulSetStrategyType,
+						RollingUpdate: &apps_v1.RollingUpdateStatefulSetStrategy{
+							Partition: &two,
+						},
+					},
+				},
+				Status: apps_v1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 1},
+			},
+			ready: true,
+		},
+		{
+			name: "StatefulSet OnDelete",
+			obj: &apps_v1.StatefulSet{
+				Spec: apps_v1.StatefulSetSpec{
+					Replicas: &three,
+					UpdateStrategy: apps_v1.StatefulSetUpdateStrategy{
+						Type: apps_v1.OnDeleteStatefulSetStrategyType,
+					},
+				},
+				Status: apps_v1.StatefulSetStatus{ReadyReplicas: 3},
+			},
+			ready: true,
+		},
+		{
+			name: "DaemonSet ready",
+			obj: &apps_v1.DaemonSet{
+				Status: apps_v1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 2, UpdatedNumberScheduled: 2},
+			},
+			ready: true,
+		},
+		{
+			name: "DaemonSet not available",
+			obj: &apps_v1.DaemonSet{
+				Status: apps_v1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 1, UpdatedNumberScheduled: 2},
+			},
+		},
+		{
+			name: "DaemonSet not updated",
+			obj: &apps_v1.DaemonSet{
+				Status: apps_v1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 2, UpdatedNumberScheduled: 1},
+			},
+		},
+		{
+			name: "Job complete",
+			obj: &batch_v1.Job{
+				Status: batch_v1.JobStatus{
+					Conditions: []batch_v1.JobCondition{
+						{Type: batch_v1.JobComplete, Status: core_v1.ConditionTrue},
+					},
+				},
+			},
+			ready: true,
+		},
+		{
+			name: "Job running",
+			obj: &batch_v1.Job{
+				Status: batch_v1.JobStatus{Active: 1},
+			},
+		},
+		{
+			name: "Job failed",
+			obj: &batch_v1.Job{
+				Status: batch_v1.JobStatus{
+					Conditions: []batch_v1.JobCondition{
+						{Type: batch_v1.JobFailed, Status: core_v1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"},
+					},
+				},
+			},
+			err: "job failed: BackoffLimitExceeded: Job has reached the specified backoff limit",
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			var isObjectReady func(runtime.Object) (bool, bool, error)
+			switch input.obj.(type) {
+			case *apps_v1.StatefulSet:
+				isObjectReady = isStatefulSetReady
+			case *apps_v1.DaemonSet:
+				isObjectReady = isDaemonSetReady
+			case *batch_v1.Job:
+				isObjectReady = isJobReady
+			}
+			ready, retriable, err := isObjectReady(input.obj)
+			if input.err != "" {
+				assert.EqualError(t, err, input.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.False(t, retriable)
+			assert.Equal(t, input.ready, ready)
+		})
+	}
+}
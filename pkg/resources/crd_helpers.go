@@ -152,14 +152,51 @@ func BundleCrd() *apiext_v1b1.CustomResourceDefinition {
 	reference := apiext_v1b1.JSONSchemaProps{
 		Description: "A reference to a path in another resource",
 		Type:        "object",
-		Required:    []string{"resource"},
+		OneOf: []apiext_v1b1.JSONSchemaProps{
+			{
+				Required: []string{"resource"},
+			},
+			{
+				Required: []string{"selector"},
+			},
+			{
+				Required: []string{"object"},
+			},
+		},
 		Properties: map[string]apiext_v1b1.JSONSchemaProps{
 			"name":     referenceName,
 			"resource": resourceName,
+			"object": {
+				Description: "Object outside of the Bundle to depend on instead of a resource",
+				Type:        "object",
+				Required:    []string{"apiVersion", "kind", "name"},
+				Properties: map[string]apiext_v1b1.JSONSchemaProps{
+					"apiVersion": {
+						Type:      "string",
+						MinLength: int64ptr(1),
+					},
+					"kind": {
+						Type:      "string",
+						MinLength: int64ptr(1),
+					},
+					"name": DNS_SUBDOMAIN,
+				},
+			},
+			"selector": {
+				Description: "Label selector of resources to depend on instead of a single resource",
+				Type:        "object",
+			},
 			"example": {
 				Description: "example of how we expect reference to resolve. Used for validation",
 			},
+			"default": {
+				Description: "value used if the referenced resource is not ready or the referenced field does not exist",
+			},
 			"modifier": DNS_SUBDOMAIN,
+			"optional": {
+				Description: "Do not block on the referenced resource if it is not ready or the referenced field does not exist",
+				Type:        "boolean",
+			},
 			"path": {
 				Description: "JSONPath expression used to extract data from resource",
 				Type:        "string",
@@ -172,12 +209,74 @@ func BundleCrd() *apiext_v1b1.CustomResourceDefinition {
 		Required:    []string{"name", "spec"},
 		Properties: map[string]apiext_v1b1.JSONSchemaProps{
 			"name": resourceName,
+			"labels": {
+				Description: "Labels used to select the resource in references. Not applied to the object",
+				Type:        "object",
+			},
+			"createOnly": {
+				Description: "Create the object if it does not exist but never update it",
+				Type:        "boolean",
+			},
+			"updateStrategy": {
+				Description: "What is done when the object exists already. Update is used if not set",
+				Type:        "string",
+				Pattern:     `^(Update|CreateOnly|Recreate|Patch)$`,
+			},
+			"adopt": {
+				Description: "Take over an existing object that does not have a controller",
+				Type:        "boolean",
+			},
+			"disabled": {
+				Description: "Do not create the object and delete it if it exists",
+				Type:        "boolean",
+			},
+			"deletePolicy": {
+				Description: "Propagation policy used when the object is deleted. Foreground is used if not set",
+				Type:        "string",
+				Pattern:     `^(Foreground|Background|Orphan)$`,
+			},
+			"deleteGracePeriodSeconds": {
+				Description: "Grace period used when the object is deleted. Default grace period of the object's kind is used if not set",
+				Type:        "integer",
+				Minimum:     float64ptr(0),
+			},
+			"ignorePaths": {
+				Description: "Paths to fields of the object that are not compared with the spec and are never updated",
+				Type:        "array",
+				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
+					Schema: &apiext_v1b1.JSONSchemaProps{
+						Type:      "string",
+						MinLength: int64ptr(1),
+					},
+				},
+			},
+			"minReadySeconds": {
+				Description: "Number of seconds the object must be continuously ready for before the resource is ready",
+				Type:        "integer",
+				Minimum:     float64ptr(0),
+			},
+			"maxRetries": {
+				Description: "Maximum number of consecutive failed attempts after which an error is considered terminal. Zero means unlimited",
+				Type:        "integer",
+				Minimum:     float64ptr(0),
+			},
+			"priority": {
+				Description: "Resources with higher priority are processed first among resources that do not depend on each other",
+				Type:        "integer",
+			},
 			"references": {
 				Type: "array",
 				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
 					Schema: &reference,
 				},
 			},
+			"runAfter": {
+				Description: "Resources that must be ready before this resource is processed",
+				Type:        "array",
+				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
+					Schema: &resourceName,
+				},
+			},
 			"spec": {
 				Type: "object",
 				OneOf: []apiext_v1b1.JSONSchemaProps{
@@ -227,6 +326,57 @@ func BundleCrd() *apiext_v1b1.CustomResourceDefinition {
 										Schema: &resource,
 									},
 								},
+								"finalizers": {
+									Description: "Finalizers added to the Bundle for external systems to do their cleanup before objects are deleted",
+									Type:        "array",
+									Items: &apiext_v1b1.JSONSchemaPropsOrArray{
+										Schema: &apiext_v1b1.JSONSchemaProps{
+											Type:      "string",
+											MinLength: int64ptr(1),
+										},
+									},
+								},
+								"maxResourcesPerSync": {
+									Description: "Maximum number of resources that are being created or updated at a time. Zero means no limit",
+									Type:        "integer",
+									Minimum:     float64ptr(0),
+								},
+								"paused": {
+									Description: "Stops processing of the Bundle",
+									Type:        "boolean",
+								},
+								"quotaCheck": {
+									Description: "Fail the Bundle before creating objects if pods of its workloads do not fit into ResourceQuotas of the namespace",
+									Type:        "boolean",
+								},
+								"reclaimPolicy": {
+									Description: "What happens to objects of resources removed from the Bundle. Delete is used if not set",
+									Type:        "string",
+									Pattern:     `^(Delete|Retain)$`,
+								},
+								"progressDeadlineSeconds": {
+									Description: "Maximum time in seconds a resource may be continuously not ready before it is considered failed",
+									Type:        "integer",
+									Minimum:     float64ptr(1),
+								},
+								"schedule": {
+									Description: "Schedule restricts creation/update of objects to scheduled times",
+									Type:        "object",
+									Required:    []string{"dailyAt"},
+									Properties: map[string]apiext_v1b1.JSONSchemaProps{
+										"dailyAt": {
+											Description: "Times of day in HH:MM format (UTC) when objects are reconciled",
+											Type:        "array",
+											MinItems:    int64ptr(1),
+											Items: &apiext_v1b1.JSONSchemaPropsOrArray{
+												Schema: &apiext_v1b1.JSONSchemaProps{
+													Type:    "string",
+													Pattern: `^([01][0-9]|2[0-3]):[0-5][0-9]$`,
+												},
+											},
+										},
+									},
+								},
 							},
 						},
 					},
@@ -236,6 +386,10 @@ func BundleCrd() *apiext_v1b1.CustomResourceDefinition {
 	}
 }
 
+func float64ptr(val float64) *float64 {
+	return &val
+}
+
 func int64ptr(val int64) *int64 {
 	return &val
 }
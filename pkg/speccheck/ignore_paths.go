@@ -0,0 +1,93 @@
+package speccheck
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/atlassian/smith"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ParseIgnorePath parses a path to a field that is ignored when objects are compared. Fields are separated
+// with dots, keys that contain dots are quoted in brackets, e.g. metadata.annotations['sidecar.istio.io/status'].
+// An optional "$." prefix is allowed. Lists cannot be indexed, a path can only point at a list as a whole.
+func ParseIgnorePath(path string) ([]string, error) {
+	p := strings.TrimPrefix(path, "$.")
+	var fields []string
+	for len(p) > 0 {
+		if p[0] == '[' {
+			if len(p) < 4 || (p[1] != '\'' && p[1] != '"') {
+				return nil, errors.Errorf("invalid path %q: only quoted keys are supported in brackets", path)
+			}
+			end := strings.Index(p[2:], string(p[1])+"]")
+			if end < 0 {
+				return nil, errors.Errorf("invalid path %q: unterminated bracket", path)
+			}
+			fields = append(fields, p[2:2+end])
+			p = p[2+end+2:]
+		} else {
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, errors.Errorf("invalid path %q: empty field name", path)
+			}
+			fields = append(fields, p[:end])
+			p = p[end:]
+		}
+		if strings.HasPrefix(p, ".") {
+			p = p[1:]
+			if len(p) == 0 {
+				return nil, errors.Errorf("invalid path %q: empty field name", path)
+			}
+		} else if len(p) > 0 && p[0] != '[' {
+			return nil, errors.Errorf("invalid path %q: unexpected %q", path, p)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.Errorf("invalid path %q: empty path", path)
+	}
+	return fields, nil
+}
+
+// ignoredPaths returns parsed paths recorded in smith.IgnorePathsAnnotation of the object.
+func ignoredPaths(obj *unstructured.Unstructured) ([][]string, error) {
+	value, ok := obj.GetAnnotations()[smith.IgnorePathsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var paths []string
+	if err := json.Unmarshal([]byte(value), &paths); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s annotation", smith.IgnorePathsAnnotation)
+	}
+	parsed := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		fields, err := ParseIgnorePath(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, fields)
+	}
+	return parsed, nil
+}
+
+// maskIgnoredPaths copies values of ignored fields from actual into updated so that they are neither
+// compared nor overwritten. Fields missing in actual are removed from updated.
+func maskIgnoredPaths(paths [][]string, updated, actual *unstructured.Unstructured) error {
+	for _, fields := range paths {
+		value, found, err := unstructured.NestedFieldNoCopy(actual.Object, fields...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get ignored field %q", strings.Join(fields, "."))
+		}
+		if !found {
+			unstructured.RemoveNestedField(updated.Object, fields...)
+			continue
+		}
+		if err = unstructured.SetNestedField(updated.Object, value, fields...); err != nil {
+			return errors.Wrapf(err, "failed to set ignored field %q", strings.Join(fields, "."))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package speccheck
+
+import (
+	"testing"
+
+	"github.com/atlassian/smith"
+	"github.com/atlassian/smith/pkg/cleanup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseIgnorePath(t *testing.T) {
+	t.Parallel()
+	valid := map[string][]string{
+		"spec.replicas":   {"spec", "replicas"},
+		"$.spec.replicas": {"spec", "replicas"},
+		"metadata.annotations['sidecar.istio.io/status']": {"metadata", "annotations", "sidecar.istio.io/status"},
+		`metadata.annotations["a.b"].c`:                   {"metadata", "annotations", "a.b", "c"},
+		"['data']":                                        {"data"},
+	}
+	for path, expected := range valid {
+		fields, err := ParseIgnorePath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, fields, path)
+	}
+	for _, path := range []string{"", "$.", "spec.", "spec..replicas", "spec[0]", "spec['a", "spec['a']b"} {
+		_, err := ParseIgnorePath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestIgnoredPathsAreNotCompared(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync()
+
+	spec := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "cm1",
+			Annotations: map[string]string{
+				smith.IgnorePathsAnnotation: `["data.injected","data.removed"]`,
+			},
+		},
+		Data: map[string]string{
+			"owned":   "a",
+			"removed": "b",
+		},
+	}
+	actual := spec.DeepCopy()
+	actual.Data = map[string]string{
+		"owned":    "a",
+		"injected": "by someone else",
+	}
+	sc := SpecCheck{
+		Logger:  logger,
+		Cleaner: cleanup.New(),
+	}
+
+	_, match, err := sc.CompareActualVsSpec(spec, actual)
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	// Owned fields are still updated, ignored fields are kept as is
+	spec.Data["owned"] = "changed"
+	updated, match, err := sc.CompareActualVsSpec(spec, actual)
+	require.NoError(t, err)
+	assert.False(t, match)
+	assert.Equal(t, map[string]interface{}{
+		"owned":    "changed",
+		"injected": "by someone else",
+	}, updated.Object["data"])
+}
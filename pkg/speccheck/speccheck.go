@@ -1,6 +1,8 @@
 package speccheck
 
 import (
+	"sort"
+
 	ctrlLogz "github.com/atlassian/ctrl/logz"
 	"github.com/atlassian/smith/pkg/util"
 	"github.com/pkg/errors"
@@ -88,6 +90,15 @@ func (sc *SpecCheck) compareActualVsSpec(spec, actual *unstructured.Unstructured
 	// observed the update yet. Like Generation/ObservedGeneration for built-in controllers.
 	delete(updated.Object, "status")
 
+	// 5. Fields that are mutated by someone else are taken from the actual object
+	paths, err := ignoredPaths(spec)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = maskIgnoredPaths(paths, updated, actualClone); err != nil {
+		return nil, false, err
+	}
+
 	if !equality.Semantic.DeepEqual(updated.Object, actualClone.Object) {
 		gvk := spec.GroupVersionKind()
 
@@ -102,6 +113,103 @@ func (sc *SpecCheck) compareActualVsSpec(spec, actual *unstructured.Unstructured
 	return actual, true, nil
 }
 
+// ChangedPaths returns a sorted list of top-level paths which differ between updated and actual objects.
+// Fields of metadata are reported individually (e.g. "metadata.labels"), other fields are reported
+// by their top-level name only. TypeMeta and status are ignored.
+// Values are never included so the result is safe to use for Secrets too.
+func ChangedPaths(updated, actual *unstructured.Unstructured) []string {
+	var paths []string
+	for field := range unionKeys(updated.Object, actual.Object) {
+		switch field {
+		case "kind", "apiVersion", "status":
+			continue
+		case "metadata":
+			updatedMeta, _ := updated.Object[field].(map[string]interface{})
+			actualMeta, _ := actual.Object[field].(map[string]interface{})
+			for metaField := range unionKeys(updatedMeta, actualMeta) {
+				if !equality.Semantic.DeepEqual(updatedMeta[metaField], actualMeta[metaField]) {
+					paths = append(paths, field+"."+metaField)
+				}
+			}
+			continue
+		}
+		if !equality.Semantic.DeepEqual(updated.Object[field], actual.Object[field]) {
+			paths = append(paths, field)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// MergePatch returns a JSON merge patch (RFC 7386) that turns actual object into updated object.
+// TypeMeta and status are ignored. Missing, null and empty values are considered equal, same as
+// in CompareActualVsSpec. Unlike ChangedPaths the patch contains values.
+func MergePatch(updated, actual *unstructured.Unstructured) map[string]interface{} {
+	patch := mergePatch(updated.Object, actual.Object)
+	delete(patch, "kind")
+	delete(patch, "apiVersion")
+	delete(patch, "status")
+	return patch
+}
+
+func mergePatch(updated, actual map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for field, actualValue := range actual {
+		updatedValue, ok := updated[field]
+		if isEmptyValue(updatedValue) && isEmptyValue(actualValue) {
+			continue
+		}
+		if !ok {
+			// Removed fields are set to null
+			patch[field] = nil
+			continue
+		}
+		if equality.Semantic.DeepEqual(updatedValue, actualValue) {
+			continue
+		}
+		updatedMap, updatedIsMap := updatedValue.(map[string]interface{})
+		actualMap, actualIsMap := actualValue.(map[string]interface{})
+		if updatedIsMap && actualIsMap {
+			if fieldPatch := mergePatch(updatedMap, actualMap); len(fieldPatch) > 0 {
+				patch[field] = fieldPatch
+			}
+		} else {
+			// Lists and scalar values are replaced as a whole
+			patch[field] = updatedValue
+		}
+	}
+	for field, updatedValue := range updated {
+		if _, ok := actual[field]; !ok && !isEmptyValue(updatedValue) {
+			patch[field] = updatedValue
+		}
+	}
+	return patch
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+func unionKeys(a, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
 func processAnnotations(spec, actual map[string]string) map[string]string {
 	if actual == nil {
 		actual = make(map[string]string, len(spec))
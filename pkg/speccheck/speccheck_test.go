@@ -199,6 +199,59 @@ func TestUpdateResourceEmptyMissingNilNoChanges(t *testing.T) {
 	}
 }
 
+func TestChangedPaths(t *testing.T) {
+	t.Parallel()
+
+	actual := missingMap()
+	actual.Object["status"] = map[string]interface{}{
+		"x": "y",
+	}
+	updated := missingMap()
+	updated.SetLabels(map[string]string{
+		"l": "v",
+	})
+	updated.Object["data"] = map[string]interface{}{
+		"a": "c",
+	}
+
+	assert.Equal(t, []string{"data", "metadata.labels"}, ChangedPaths(updated, actual))
+	assert.Empty(t, ChangedPaths(actual, actual.DeepCopy()))
+}
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	actual := missingMap()
+	actual.Object["status"] = map[string]interface{}{
+		"x": "y",
+	}
+	actual.Object["data"] = map[string]interface{}{
+		"a": "b",
+		"c": "d",
+	}
+	updated := missingMap()
+	updated.SetLabels(map[string]string{
+		"l": "v",
+	})
+	updated.Object["data"] = map[string]interface{}{
+		"a": "e",
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"l": "v",
+			},
+		},
+		"data": map[string]interface{}{
+			"a": "e",
+			"c": nil,
+		},
+	}, MergePatch(updated, actual))
+	assert.Empty(t, MergePatch(actual, actual.DeepCopy()))
+	assert.Empty(t, MergePatch(emptyMap(), missingMap()))
+}
+
 func emptyMap() *unstructured.Unstructured {
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -15,8 +15,9 @@ import (
 )
 
 const (
-	byCrdGroupKindIndexName = "ByCrdGroupKind"
-	byObjectIndexName       = "ByObject"
+	byCrdGroupKindIndexName     = "ByCrdGroupKind"
+	byObjectIndexName           = "ByObject"
+	byReferencedObjectIndexName = "ByReferencedObject"
 )
 
 type ByNameStore interface {
@@ -26,6 +27,7 @@ type ByNameStore interface {
 type BundleStore struct {
 	store            ByNameStore
 	bundleByIndex    func(indexName, indexKey string) ([]interface{}, error)
+	bundleList       func() []interface{}
 	pluginContainers map[smith_v1.PluginName]plugin.PluginContainer
 }
 
@@ -33,11 +35,13 @@ func NewBundle(bundleInf cache.SharedIndexInformer, store ByNameStore, pluginCon
 	bs := &BundleStore{
 		store:            store,
 		bundleByIndex:    bundleInf.GetIndexer().ByIndex,
+		bundleList:       bundleInf.GetIndexer().List,
 		pluginContainers: pluginContainers,
 	}
 	err := bundleInf.AddIndexers(cache.Indexers{
-		byCrdGroupKindIndexName: bs.byCrdGroupKindIndex,
-		byObjectIndexName:       bs.byObjectIndex,
+		byCrdGroupKindIndexName:     bs.byCrdGroupKindIndex,
+		byObjectIndexName:           bs.byObjectIndex,
+		byReferencedObjectIndexName: bs.byReferencedObjectIndex,
 	})
 	if err != nil {
 		return nil, err
@@ -55,6 +59,17 @@ func (s *BundleStore) Get(namespace, bundleName string) (*smith_v1.Bundle, error
 	return bundle.(*smith_v1.Bundle), nil
 }
 
+// List returns all bundles.
+// Returned bundles are shared with the informer and must not be mutated.
+func (s *BundleStore) List() []*smith_v1.Bundle {
+	bundles := s.bundleList()
+	result := make([]*smith_v1.Bundle, 0, len(bundles))
+	for _, bundle := range bundles {
+		result = append(result, bundle.(*smith_v1.Bundle))
+	}
+	return result
+}
+
 // GetBundlesByCrd returns Bundles which have a resource defined by CRD.
 func (s *BundleStore) GetBundlesByCrd(crd *apiext_v1b1.CustomResourceDefinition) ([]*smith_v1.Bundle, error) {
 	return s.getBundles(byCrdGroupKindIndexName, byCrdGroupKindIndexKey(crd.Spec.Group, crd.Spec.Names.Kind))
@@ -65,6 +80,12 @@ func (s *BundleStore) GetBundlesByObject(gk schema.GroupKind, namespace, name st
 	return s.getBundles(byObjectIndexName, byObjectIndexKey(gk, namespace, name))
 }
 
+// GetBundlesByReferencedObject returns bundles where a resource references an object outside of the bundle
+// with specified GVK, namespace and name.
+func (s *BundleStore) GetBundlesByReferencedObject(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error) {
+	return s.getBundles(byReferencedObjectIndexName, byObjectIndexKey(gk, namespace, name))
+}
+
 func (s *BundleStore) getBundles(indexName, indexKey string) ([]*smith_v1.Bundle, error) {
 	bundles, err := s.bundleByIndex(indexName, indexKey)
 	if err != nil {
@@ -138,3 +159,18 @@ func (s *BundleStore) byObjectIndex(obj interface{}) ([]string, error) {
 func byObjectIndexKey(gk schema.GroupKind, namespace, name string) string {
 	return fmt.Sprintf("%s/%s/%s/%s", gk.Group, gk.Kind, namespace, name)
 }
+
+func (s *BundleStore) byReferencedObjectIndex(obj interface{}) ([]string, error) {
+	bundle := obj.(*smith_v1.Bundle)
+	var result []string
+	for _, resource := range bundle.Spec.Resources {
+		for _, reference := range resource.References {
+			if reference.Object == nil {
+				continue
+			}
+			gk := reference.Object.GroupVersionKind().GroupKind()
+			result = append(result, byObjectIndexKey(gk, bundle.Namespace, reference.Object.Name))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CrdSchema validates objects against OpenAPI v3 schemas published by their CRDs.
+type CrdSchema struct {
+	crdStore *Crd
+
+	// schemas is a cache of parsed schemas by CRD name.
+	// ResourceVersion is checked on access so the cache only holds up-to-date schemas.
+	schemas        map[string]schemaWithResourceVersion
+	schemasRWMutex sync.RWMutex
+}
+
+func NewCrdSchema(crdStore *Crd) *CrdSchema {
+	return &CrdSchema{
+		crdStore: crdStore,
+		schemas:  make(map[string]schemaWithResourceVersion),
+	}
+}
+
+// ValidateObject validates the object against the schema of its CRD.
+// Objects of kinds that are not defined by a CRD or whose CRD does not publish a schema are not validated.
+func (s *CrdSchema) ValidateObject(obj *unstructured.Unstructured) (retriableError bool, e error) {
+	gk := obj.GroupVersionKind().GroupKind()
+	crd, err := s.crdStore.Get(gk)
+	if err != nil {
+		return true, err
+	}
+	if crd == nil {
+		return false, nil
+	}
+	schema, err := s.getParsedSchema(crd)
+	if err != nil {
+		return false, err
+	}
+	if schema == nil {
+		// no schema to validate against
+		return false, nil
+	}
+	return false, validateAgainstSchema(schema, obj)
+}
+
+func (s *CrdSchema) getParsedSchema(crd *apiext_v1b1.CustomResourceDefinition) (*gojsonschema.Schema, error) {
+	s.schemasRWMutex.RLock()
+	schemaWithRv, ok := s.schemas[crd.Name]
+	s.schemasRWMutex.RUnlock()
+	if ok && schemaWithRv.resourceVersion == crd.ResourceVersion {
+		return schemaWithRv.schema, nil
+	}
+
+	var schema *gojsonschema.Schema
+	if crd.Spec.Validation != nil && crd.Spec.Validation.OpenAPIV3Schema != nil {
+		rawSchema, err := json.Marshal(crd.Spec.Validation.OpenAPIV3Schema)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		schema, err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(rawSchema))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse OpenAPI schema of CRD %q", crd.Name)
+		}
+	}
+
+	s.schemasRWMutex.Lock()
+	defer s.schemasRWMutex.Unlock()
+	s.schemas[crd.Name] = schemaWithResourceVersion{crd.ResourceVersion, schema}
+	return schema, nil
+}
+
+func validateAgainstSchema(schema *gojsonschema.Schema, obj *unstructured.Unstructured) error {
+	validationResult, err := schema.Validate(gojsonschema.NewGoLoader(obj.Object))
+	if err != nil {
+		return errors.Wrapf(err, "error validating %s %q", obj.GetKind(), obj.GetName())
+	}
+
+	if !validationResult.Valid() {
+		validationErrors := validationResult.Errors()
+		msgs := make([]string, 0, len(validationErrors))
+
+		for _, validationErr := range validationErrors {
+			msgs = append(msgs, fmt.Sprintf("%s: %s (rule %q)", validationErr.Field(), validationErr.Description(), validationErr.Type()))
+		}
+
+		return errors.Errorf("%s %q failed validation against CRD schema: %s",
+			obj.GetKind(), obj.GetName(), strings.Join(msgs, ", "))
+	}
+
+	return nil
+}
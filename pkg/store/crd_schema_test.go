@@ -0,0 +1,62 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	t.Parallel()
+	minReplicas := float64(1)
+	props := apiext_v1b1.JSONSchemaProps{
+		Properties: map[string]apiext_v1b1.JSONSchemaProps{
+			"spec": {
+				Type:     "object",
+				Required: []string{"image"},
+				Properties: map[string]apiext_v1b1.JSONSchemaProps{
+					"image": {
+						Type: "string",
+					},
+					"replicas": {
+						Type:    "integer",
+						Minimum: &minReplicas,
+					},
+				},
+			},
+		},
+	}
+	rawSchema, err := json.Marshal(props)
+	require.NoError(t, err)
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(rawSchema))
+	require.NoError(t, err)
+
+	valid := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind": "Thing",
+			"metadata": map[string]interface{}{
+				"name": "thing1",
+			},
+			"spec": map[string]interface{}{
+				"image":    "nginx",
+				"replicas": int64(2),
+			},
+		},
+	}
+	assert.NoError(t, validateAgainstSchema(schema, valid))
+
+	invalid := valid.DeepCopy()
+	unstructured.RemoveNestedField(invalid.Object, "spec", "image")
+	err = unstructured.SetNestedField(invalid.Object, int64(0), "spec", "replicas")
+	require.NoError(t, err)
+	err = validateAgainstSchema(schema, invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Thing "thing1" failed validation against CRD schema: `)
+	assert.Contains(t, err.Error(), `image is required (rule "required")`)
+	assert.Contains(t, err.Error(), `spec.replicas: `)
+}
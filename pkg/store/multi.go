@@ -45,18 +45,25 @@ func (s *Multi) AddInformer(gvk schema.GroupVersionKind, informer cache.SharedIn
 	return nil
 }
 
+// ObjectsControlledBy returns objects in the namespace and cluster-scoped objects controlled by the object with the UID.
 func (s *Multi) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error) {
 	var result []runtime.Object
-	indexKey := ByNamespaceAndControllerUidIndexKey(namespace, uid)
+	indexKeys := []string{ByNamespaceAndControllerUidIndexKey(namespace, uid)}
+	if namespace != meta_v1.NamespaceNone {
+		// Cluster-scoped objects can be controlled by a namespaced object
+		indexKeys = append(indexKeys, ByNamespaceAndControllerUidIndexKey(meta_v1.NamespaceNone, uid))
+	}
 	for gvk, inf := range s.GetInformers() {
-		objs, err := inf.GetIndexer().ByIndex(ByNamespaceAndControllerUidIndex, indexKey)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get objects for bundle from %s informer", gvk)
-		}
-		for _, obj := range objs {
-			ro := obj.(runtime.Object).DeepCopyObject()
-			ro.GetObjectKind().SetGroupVersionKind(gvk) // Objects from type-specific informers don't have GVK set
-			result = append(result, ro)
+		for _, indexKey := range indexKeys {
+			objs, err := inf.GetIndexer().ByIndex(ByNamespaceAndControllerUidIndex, indexKey)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get objects for bundle from %s informer", gvk)
+			}
+			for _, obj := range objs {
+				ro := obj.(runtime.Object).DeepCopyObject()
+				ro.GetObjectKind().SetGroupVersionKind(gvk) // Objects from type-specific informers don't have GVK set
+				result = append(result, ro)
+			}
 		}
 	}
 	return result, nil
@@ -14,6 +14,28 @@ func (g *Graph) TopologicalSort() ([]V, error) {
 	return results.items, nil
 }
 
+// Layers splits topologically sorted vertices into layers. Vertices only have edges to vertices of
+// previous layers, so vertices of the same layer do not depend on each other. Order of vertices within
+// a layer is the same as in sorted.
+func (g *Graph) Layers(sorted []V) [][]V {
+	layerOf := make(map[V]int, len(sorted))
+	var layers [][]V
+	for _, name := range sorted {
+		layer := 0
+		for _, edge := range g.Vertices[name].OutgoingEdges {
+			if l := layerOf[edge] + 1; l > layer {
+				layer = l
+			}
+		}
+		layerOf[name] = layer
+		if layer == len(layers) {
+			layers = append(layers, nil)
+		}
+		layers[layer] = append(layers[layer], name)
+	}
+	return layers
+}
+
 func (g *Graph) visit(name V, results *orderedset, visited *orderedset) error {
 	if visited == nil {
 		visited = newOrderedSet()
@@ -23,7 +45,7 @@ func (g *Graph) visit(name V, results *orderedset, visited *orderedset) error {
 	if !added {
 		index := visited.index(name)
 		cycle := append(visited.items[index:], name)
-		return errors.Errorf("cycle error: %v", cycle)
+		return errors.WithStack(&CycleError{Cycle: cycle})
 	}
 
 	n := g.Vertices[name]
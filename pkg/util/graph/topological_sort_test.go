@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -49,6 +50,22 @@ func TestSort3(t *testing.T) {
 	assertSortResult(t, g, []V{"b", "c", "d", "a"})
 }
 
+func TestLayers(t *testing.T) {
+	t.Parallel()
+	g := initGraph()
+
+	// a -> b
+	// a -> d
+	// c -> b
+	require.NoError(t, g.AddEdge("a", "b"))
+	require.NoError(t, g.AddEdge("a", "d"))
+	require.NoError(t, g.AddEdge("c", "b"))
+
+	sorted, err := g.TopologicalSort()
+	require.NoError(t, err)
+	assert.Equal(t, [][]V{{"b", "d"}, {"a", "c"}}, g.Layers(sorted))
+}
+
 func TestSortCycleError1(t *testing.T) {
 	t.Parallel()
 	g := initGraph()
@@ -89,6 +106,25 @@ func TestSortCycleError3(t *testing.T) {
 	assertCycleDetection(t, g)
 }
 
+func TestSortCycleErrorPath(t *testing.T) {
+	t.Parallel()
+	g := initGraph()
+
+	// a -> b
+	// b -> c
+	// c -> b
+	require.NoError(t, g.AddEdge("a", "b"))
+	require.NoError(t, g.AddEdge("b", "c"))
+	require.NoError(t, g.AddEdge("c", "b"))
+
+	_, err := g.TopologicalSort()
+	require.Error(t, err)
+	cycleErr, ok := errors.Cause(err).(*CycleError)
+	require.True(t, ok, "%T", errors.Cause(err))
+	assert.Equal(t, []V{"b", "c", "b"}, cycleErr.Cycle)
+	assert.EqualError(t, err, "cycle detected: b -> c -> b")
+}
+
 func TestSortMissingVertexError(t *testing.T) {
 	t.Parallel()
 	g := initGraph()
@@ -1,6 +1,11 @@
 package graph
 
-import "github.com/pkg/errors"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 // V is name of the vertex.
 type V interface{}
@@ -62,3 +67,17 @@ func (g *Graph) ContainsVertex(name V) bool {
 func (v *Vertex) addEdge(name V) {
 	v.OutgoingEdges = append(v.OutgoingEdges, name)
 }
+
+// CycleError is returned by TopologicalSort if the graph has a cycle.
+type CycleError struct {
+	// Cycle is a path of vertices that starts and ends with the same vertex.
+	Cycle []V
+}
+
+func (e *CycleError) Error() string {
+	path := make([]string, 0, len(e.Cycle))
+	for _, v := range e.Cycle {
+		path = append(path, fmt.Sprint(v))
+	}
+	return "cycle detected: " + strings.Join(path, " -> ")
+}
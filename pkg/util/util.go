@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func Sleep(ctx context.Context, d time.Duration) error {
@@ -40,6 +41,23 @@ func ConvertType(scheme *runtime.Scheme, in, out runtime.Object) error {
 	return nil
 }
 
+// ObjectGVK returns GVK of the object. If the object does not have GVK set, which is the case for
+// typed objects from some informers, GVK is resolved using the scheme.
+func ObjectGVK(scheme *runtime.Scheme, obj runtime.Object) (schema.GroupVersionKind, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind != "" && gvk.Version != "" { // Group can be empty
+		return gvk, nil
+	}
+	if scheme == nil {
+		return schema.GroupVersionKind{}, errors.Errorf("cannot determine GVK of %T: object Kind and/or object Version is empty", obj)
+	}
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, errors.Wrapf(err, "cannot determine GVK of %T", obj)
+	}
+	return gvks[0], nil
+}
+
 // RuntimeToUnstructured can be used to convert any typed or unstructured object into
 // an unstructured object. The obj must have GVK set.
 func RuntimeToUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {